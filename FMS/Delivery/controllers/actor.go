@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"FMS/Domain"
+	"FMS/Infrastructure"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// actorFrom builds the caller from the keys set by AuthMiddleware. Like
+// FinanceOnly, finance visibility needs two-factor authentication.
+func actorFrom(c *gin.Context) Domain.Actor {
+	mfa := c.GetBool("mfa")
+	return Domain.Actor{
+		UserID:         c.GetString("user_id"),
+		Username:       c.GetString("username"),
+		Role:           c.GetString("role"),
+		Department:     c.GetString("department"),
+		DepartmentHead: c.GetBool("department_head"),
+		Finance:        Infrastructure.IsFinance(c) && mfa,
+		MFA:            mfa,
+		IP:             c.ClientIP(),
+		RequestID:      c.GetString("request_id"),
+		IfMatch:        ifMatch(c),
+	}
+}
+
+// ifMatch reads the record version named by the If-Match header. A missing
+// header or "*" names none; anything but a quoted version, weak ETags
+// included, can never match and so fails the precondition.
+func ifMatch(c *gin.Context) int64 {
+	v := strings.TrimSpace(c.GetHeader("If-Match"))
+	if v == "" || v == "*" {
+		return 0
+	}
+	n, err := strconv.ParseInt(strings.Trim(v, `"`), 10, 64)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// setETag labels a response with the version of the record it carries
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// errorStatus maps domain errors to HTTP codes, falling back to fallback
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, Domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, Domain.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, Domain.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, Domain.ErrInvalidTransition), errors.Is(err, Domain.ErrPeriodClosed), errors.Is(err, Domain.ErrPeriodInUse):
+		return http.StatusConflict
+	case errors.Is(err, Domain.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, Domain.ErrAuditFailed):
+		return http.StatusInternalServerError
+	}
+	return fallback
+}
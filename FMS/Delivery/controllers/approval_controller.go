@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"FMS/Domain"
+	"FMS/Usecases"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApprovalController struct {
+	ApprovalUC Usecases.ApprovalUsecase
+}
+
+func NewApprovalController(a Usecases.ApprovalUsecase) *ApprovalController {
+	return &ApprovalController{ApprovalUC: a}
+}
+
+// GetPendingApprovals is the caller's inbox of records waiting on their sign-off
+func (ac *ApprovalController) GetPendingApprovals(c *gin.Context) {
+	pending, err := ac.ApprovalUC.GetPending(actorFrom(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pending": pending})
+}
+
+func (ac *ApprovalController) GetPolicies(c *gin.Context) {
+	policies, err := ac.ApprovalUC.GetPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// SavePolicy creates or replaces the policy for an entity type and department
+func (ac *ApprovalController) SavePolicy(c *gin.Context) {
+	var payload Domain.ApprovalPolicy
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy, err := ac.ApprovalUC.SavePolicy(&payload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policy": policy})
+}
+
+func (ac *ApprovalController) DeletePolicy(c *gin.Context) {
+	if err := ac.ApprovalUC.DeletePolicy(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// decisionComment reads the optional {"comment": "..."} body of approve and reject calls
+func decisionComment(c *gin.Context) (string, error) {
+	var body struct {
+		Comment string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimSpace(body.Comment), nil
+}
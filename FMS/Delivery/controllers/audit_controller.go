@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"FMS/Domain"
+	"FMS/Usecases"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditController struct {
+	AuditUC Usecases.AuditUsecase
+}
+
+func NewAuditController(a Usecases.AuditUsecase) *AuditController {
+	return &AuditController{AuditUC: a}
+}
+
+// GetAuditLog pages through audit entries, newest first, filtered by actor_id,
+// action, entity_type, entity_id, from and to
+func (ac *AuditController) GetAuditLog(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter := Domain.AuditFilter{
+		ActorID:    c.Query("actor_id"),
+		Action:     c.Query("action"),
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		Page:       page,
+		Limit:      limit,
+	}
+
+	var err error
+	if filter.From, err = parseDate(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+		return
+	}
+	if filter.To, err = parseDate(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+		return
+	}
+
+	result, err := ac.AuditUC.GetEntries(filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// VerifyAuditLog recomputes the hash chain and reports the first tampered entry
+func (ac *AuditController) VerifyAuditLog(c *gin.Context) {
+	result, err := ac.AuditUC.Verify()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
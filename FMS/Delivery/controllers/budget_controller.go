@@ -4,9 +4,9 @@ import (
 	"FMS/Domain"
 	"FMS/Usecases"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type BudgetController struct {
@@ -18,27 +18,33 @@ func NewBudgetController(b Usecases.BudgetUsecase) *BudgetController {
 }
 
 func (bc *BudgetController) GetAllBudgets(c *gin.Context) {
-	budgets, err := bc.BudgetUC.GetAllBudgets()
+	q, err := listQuery(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	budgets, page, err := bc.BudgetUC.GetAllBudgets(q, actorFrom(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"budgets": budgets})
+	c.JSON(http.StatusOK, gin.H{"budgets": budgets, "pagination": page})
 }
 
 func (bc *BudgetController) GetBudgetByID(c *gin.Context) {
 	id := c.Param("id")
-	b, err := bc.BudgetUC.GetBudgetByID(id)
+	b, err := bc.BudgetUC.GetBudgetByID(id, actorFrom(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c, b.Version)
 	c.JSON(http.StatusOK, gin.H{"budget": b})
 }
 
 func (bc *BudgetController) GetBudgetSummary(c *gin.Context) {
 	id := c.Param("id")
-	summary, err := bc.BudgetUC.GetBudgetSummary(id)
+	summary, err := bc.BudgetUC.GetBudgetSummary(id, actorFrom(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -52,12 +58,14 @@ func (bc *BudgetController) CreateBudget(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	payload.CreatedAt = time.Now().UTC()
-	created, err := bc.BudgetUC.CreateBudget(&payload)
+	// only recurring templates link budgets to themselves
+	payload.TemplateID = primitive.NilObjectID
+	created, err := bc.BudgetUC.CreateBudget(&payload, actorFrom(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c, created.Version)
 	c.JSON(http.StatusCreated, gin.H{"budget": created})
 }
 
@@ -68,27 +76,46 @@ func (bc *BudgetController) UpdateBudget(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := bc.BudgetUC.UpdateBudget(id, &payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bc.BudgetUC.UpdateBudget(id, &payload, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c, payload.Version)
 	c.JSON(http.StatusOK, gin.H{"message": "updated"})
 }
 
 func (bc *BudgetController) ApproveBudget(c *gin.Context) {
 	id := c.Param("id")
-	if err := bc.BudgetUC.ApproveBudget(id); err != nil {
+	comment, err := decisionComment(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "approved"})
+	updated, err := bc.BudgetUC.ApproveBudget(id, actorFrom(c), comment)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	message := "approved"
+	if updated.Status == "pending" {
+		message = "approval recorded"
+	}
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": message, "budget": updated})
 }
 
 func (bc *BudgetController) RejectBudget(c *gin.Context) {
 	id := c.Param("id")
-	if err := bc.BudgetUC.RejectBudget(id); err != nil {
+	comment, err := decisionComment(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "rejected"})
+	updated, err := bc.BudgetUC.RejectBudget(id, actorFrom(c), comment)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "rejected", "budget": updated})
 }
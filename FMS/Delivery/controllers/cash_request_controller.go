@@ -4,7 +4,6 @@ import (
 	"FMS/Domain"
 	"FMS/Usecases"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,21 +17,27 @@ func NewCashRequestController(c Usecases.CashRequestUsecase) *CashRequestControl
 }
 
 func (cc *CashRequestController) GetAllCashRequests(c *gin.Context) {
-	list, err := cc.CashRequestUC.GetAllCashRequests()
+	q, err := listQuery(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	list, page, err := cc.CashRequestUC.GetAllCashRequests(q, actorFrom(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"cash_requests": list})
+	c.JSON(http.StatusOK, gin.H{"cash_requests": list, "pagination": page})
 }
 
 func (cc *CashRequestController) GetCashRequest(c *gin.Context) {
 	id := c.Param("id")
-	r, err := cc.CashRequestUC.GetCashRequestByID(id)
+	r, err := cc.CashRequestUC.GetCashRequestByID(id, actorFrom(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c, r.Version)
 	c.JSON(http.StatusOK, gin.H{"cash_request": r})
 }
 
@@ -42,38 +47,77 @@ func (cc *CashRequestController) CreateCashRequest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	payload.CreatedAt = time.Now().UTC()
-	created, err := cc.CashRequestUC.CreateCashRequest(&payload)
+	created, err := cc.CashRequestUC.CreateCashRequest(&payload, actorFrom(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c, created.Version)
 	c.JSON(http.StatusCreated, gin.H{"cash_request": created})
 }
 
 func (cc *CashRequestController) ApproveCashRequest(c *gin.Context) {
 	id := c.Param("id")
-	if err := cc.CashRequestUC.ApproveCashRequest(id); err != nil {
+	comment, err := decisionComment(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "approved"})
+	updated, err := cc.CashRequestUC.ApproveCashRequest(id, actorFrom(c), comment)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	message := "approved"
+	if updated.Status == "pending" {
+		message = "approval recorded"
+	}
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": message, "cash_request": updated})
 }
 
 func (cc *CashRequestController) RejectCashRequest(c *gin.Context) {
 	id := c.Param("id")
-	if err := cc.CashRequestUC.RejectCashRequest(id); err != nil {
+	comment, err := decisionComment(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "rejected"})
+	updated, err := cc.CashRequestUC.RejectCashRequest(id, actorFrom(c), comment)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "rejected", "cash_request": updated})
 }
 
 func (cc *CashRequestController) DisburseCashRequest(c *gin.Context) {
 	id := c.Param("id")
-	if err := cc.CashRequestUC.DisburseCashRequest(id); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := cc.CashRequestUC.DisburseCashRequest(id, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "disbursed"})
 }
+
+func (cc *CashRequestController) GetReconciliation(c *gin.Context) {
+	id := c.Param("id")
+	rec, err := cc.CashRequestUC.GetReconciliation(id, actorFrom(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reconciliation": rec})
+}
+
+func (cc *CashRequestController) SettleCashRequest(c *gin.Context) {
+	id := c.Param("id")
+	settled, err := cc.CashRequestUC.SettleCashRequest(id, actorFrom(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	setETag(c, settled.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "settled", "cash_request": settled})
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"FMS/Domain"
+	"FMS/Usecases"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CurrencyController struct {
+	CurrencyUC Usecases.CurrencyUsecase
+}
+
+func NewCurrencyController(cu Usecases.CurrencyUsecase) *CurrencyController {
+	return &CurrencyController{CurrencyUC: cu}
+}
+
+func (cc *CurrencyController) GetBaseCurrency(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"base_currency": cc.CurrencyUC.BaseCurrency()})
+}
+
+// GetRates lists exchange rates, newest first, optionally for one ?currency=
+func (cc *CurrencyController) GetRates(c *gin.Context) {
+	rates, err := cc.CurrencyUC.GetRates(c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"base_currency": cc.CurrencyUC.BaseCurrency(), "rates": rates})
+}
+
+func (cc *CurrencyController) AddRate(c *gin.Context) {
+	var payload Domain.ExchangeRate
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rate, err := cc.CurrencyUC.AddRate(&payload, actorFrom(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"rate": rate})
+}
+
+func (cc *CurrencyController) DeleteRate(c *gin.Context) {
+	if err := cc.CurrencyUC.DeleteRate(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
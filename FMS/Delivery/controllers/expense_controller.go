@@ -4,7 +4,8 @@ import (
 	"FMS/Domain"
 	"FMS/Usecases"
 	"net/http"
-	"time"
+	"path/filepath"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,28 +19,34 @@ func NewExpenseController(e Usecases.ExpenseUsecase) *ExpenseController {
 }
 
 func (ec *ExpenseController) GetAllExpenses(c *gin.Context) {
-	list, err := ec.ExpenseUC.GetAllExpenses()
+	q, err := listQuery(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"expenses": list})
+	list, page, err := ec.ExpenseUC.GetAllExpenses(q, actorFrom(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"expenses": list, "pagination": page})
 }
 
 func (ec *ExpenseController) GetExpense(c *gin.Context) {
 	id := c.Param("id")
-	e, err := ec.ExpenseUC.GetExpenseByID(id)
+	e, err := ec.ExpenseUC.GetExpenseByID(id, actorFrom(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c, e.Version)
 	c.JSON(http.StatusOK, gin.H{"expense": e})
 }
 
 func (ec *ExpenseController) GetExpenseSummary(c *gin.Context) {
 	id := c.Param("id")
 	// simple summary: return expense details for now
-	e, err := ec.ExpenseUC.GetExpenseByID(id)
+	e, err := ec.ExpenseUC.GetExpenseByID(id, actorFrom(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -53,17 +60,22 @@ func (ec *ExpenseController) CreateExpense(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	payload.CreatedAt = time.Now().UTC()
-	created, err := ec.ExpenseUC.CreateExpense(&payload)
+	created, err := ec.ExpenseUC.CreateExpense(&payload, actorFrom(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c, created.Version)
 	c.JSON(http.StatusCreated, gin.H{"expense": created})
 }
 
+// CreateExpenseReceipt accepts a multipart "file" upload, or a JSON receipt_url for receipts hosted elsewhere
 func (ec *ExpenseController) CreateExpenseReceipt(c *gin.Context) {
 	id := c.Param("id")
+	if c.ContentType() == "multipart/form-data" {
+		ec.uploadExpenseReceipt(c, id)
+		return
+	}
 	var payload struct {
 		ReceiptURL string `json:"receipt_url"`
 	}
@@ -71,18 +83,76 @@ func (ec *ExpenseController) CreateExpenseReceipt(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "receipt_url required"})
 		return
 	}
-	if err := ec.ExpenseUC.AttachReceipt(id, payload.ReceiptURL); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := ec.ExpenseUC.AttachReceipt(id, payload.ReceiptURL, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "receipt attached"})
 }
 
+func (ec *ExpenseController) uploadExpenseReceipt(c *gin.Context, id string) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
+		return
+	}
+	if fh.Size > Usecases.MaxReceiptSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "receipt file exceeds 10MB"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	receipt, err := ec.ExpenseUC.UploadReceipt(id, filepath.Base(fh.Filename), f, actorFrom(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"receipt": receipt})
+}
+
+func (ec *ExpenseController) DownloadExpenseReceipt(c *gin.Context) {
+	receipt, body, err := ec.ExpenseUC.OpenReceipt(c.Param("id"), c.Param("rid"), actorFrom(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+
+	filename := receipt.Filename
+	if filename == "" {
+		filename = receipt.ID.Hex()
+	}
+	c.DataFromReader(http.StatusOK, receipt.Size, receipt.ContentType, body, map[string]string{
+		"Content-Disposition": `attachment; filename="` + strings.ReplaceAll(filename, `"`, "") + `"`,
+	})
+}
+
 func (ec *ExpenseController) VerifyExpense(c *gin.Context) {
 	id := c.Param("id")
-	if err := ec.ExpenseUC.VerifyExpense(id); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := ec.ExpenseUC.VerifyExpense(id, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "verified"})
 }
+
+// RejectExpense takes an optional {"comment"} giving the reason
+func (ec *ExpenseController) RejectExpense(c *gin.Context) {
+	comment, err := decisionComment(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updated, err := ec.ExpenseUC.RejectExpense(c.Param("id"), actorFrom(c), comment)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "rejected", "expense": updated})
+}
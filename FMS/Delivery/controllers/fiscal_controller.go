@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"FMS/Domain"
+	"FMS/Usecases"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FiscalController struct {
+	FiscalUC Usecases.FiscalUsecase
+}
+
+func NewFiscalController(f Usecases.FiscalUsecase) *FiscalController {
+	return &FiscalController{FiscalUC: f}
+}
+
+func (fc *FiscalController) GetPeriods(c *gin.Context) {
+	periods, err := fc.FiscalUC.GetPeriods()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"periods": periods})
+}
+
+func (fc *FiscalController) GetPeriod(c *gin.Context) {
+	period, err := fc.FiscalUC.GetPeriod(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"period": period})
+}
+
+// CreateYear takes {"year": 2025, "start_date": "2025-07-01"}; start_date defaults to 1 January
+func (fc *FiscalController) CreateYear(c *gin.Context) {
+	var body struct {
+		Year      int    `json:"year"`
+		StartDate string `json:"start_date"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	start, err := parseDate(body.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date"})
+		return
+	}
+	periods, err := fc.FiscalUC.CreateYear(body.Year, start, actorFrom(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"periods": periods})
+}
+
+// ClosePeriod takes an optional {"carry_forward": true}
+func (fc *FiscalController) ClosePeriod(c *gin.Context) {
+	var body struct {
+		CarryForward bool `json:"carry_forward"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := fc.FiscalUC.ClosePeriod(c.Param("id"), body.CarryForward, actorFrom(c))
+	if err != nil && result == nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		// carrying forward or the close itself stopped part way; result shows what was carried
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "close": result})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"close": result})
+}
+
+func (fc *FiscalController) GenerateBudgets(c *gin.Context) {
+	budgets, err := fc.FiscalUC.GenerateBudgets(c.Param("id"), actorFrom(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error(), "budgets": budgets})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"budgets": budgets})
+}
+
+func (fc *FiscalController) GetTemplates(c *gin.Context) {
+	templates, err := fc.FiscalUC.GetTemplates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+func (fc *FiscalController) CreateTemplate(c *gin.Context) {
+	var payload Domain.BudgetTemplate
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template, err := fc.FiscalUC.CreateTemplate(&payload, actorFrom(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"template": template})
+}
+
+func (fc *FiscalController) UpdateTemplate(c *gin.Context) {
+	var payload Domain.BudgetTemplate
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := fc.FiscalUC.UpdateTemplate(c.Param("id"), &payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "updated"})
+}
+
+func (fc *FiscalController) DeleteTemplate(c *gin.Context) {
+	if err := fc.FiscalUC.DeleteTemplate(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"FMS/Domain"
+	"FMS/Usecases"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerController struct {
+	LedgerUC Usecases.LedgerUsecase
+}
+
+func NewLedgerController(l Usecases.LedgerUsecase) *LedgerController {
+	return &LedgerController{LedgerUC: l}
+}
+
+// GetLedger lists journal entries, optionally filtered by budget_id, from and to
+func (lc *LedgerController) GetLedger(c *gin.Context) {
+	filter := Domain.LedgerFilter{BudgetID: c.Query("budget_id")}
+
+	var err error
+	if filter.From, err = parseDate(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+		return
+	}
+	if filter.To, err = parseDate(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+		return
+	}
+
+	entries, err := lc.LedgerUC.GetEntries(filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+func (lc *LedgerController) CheckConsistency(c *gin.Context) {
+	results, err := lc.LedgerUC.CheckConsistency()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	consistent := true
+	for _, r := range results {
+		if !r.Consistent {
+			consistent = false
+			break
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"consistent": consistent, "budgets": results})
+}
+
+// parseDate accepts RFC3339 timestamps or plain YYYY-MM-DD dates; empty means no bound
+func parseDate(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
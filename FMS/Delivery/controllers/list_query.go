@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"FMS/Domain"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// listQuery reads the paging, sort and filter parameters shared by the list
+// endpoints: page, limit, cursor, sort (a field, "-" first for descending),
+// status, from, to, min_amount, max_amount, budget_id and requester
+func listQuery(c *gin.Context) (Domain.ListQuery, error) {
+	q := Domain.ListQuery{
+		Cursor:    c.Query("cursor"),
+		Status:    c.Query("status"),
+		Requester: c.Query("requester"),
+	}
+	invalid := func(param string) error {
+		return fmt.Errorf("%w: invalid %s", Domain.ErrInvalidQuery, param)
+	}
+
+	var err error
+	if v := c.Query("page"); v != "" {
+		if q.Page, err = strconv.Atoi(v); err != nil {
+			return q, invalid("page")
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil {
+			return q, invalid("limit")
+		}
+	}
+	if v := c.Query("sort"); v != "" {
+		q.Sort, q.Desc = strings.TrimPrefix(v, "-"), strings.HasPrefix(v, "-")
+	}
+	if q.From, err = parseDate(c.Query("from")); err != nil {
+		return q, invalid("from date")
+	}
+	if q.To, err = parseDate(c.Query("to")); err != nil {
+		return q, invalid("to date")
+	}
+	if v := c.Query("min_amount"); v != "" {
+		if q.MinAmount, err = Domain.ParseMoney(v); err != nil {
+			return q, invalid("min_amount")
+		}
+	}
+	if v := c.Query("max_amount"); v != "" {
+		if q.MaxAmount, err = Domain.ParseMoney(v); err != nil {
+			return q, invalid("max_amount")
+		}
+	}
+	if v := c.Query("budget_id"); v != "" {
+		if q.BudgetID, err = primitive.ObjectIDFromHex(v); err != nil {
+			return q, invalid("budget_id")
+		}
+	}
+	return q, nil
+}
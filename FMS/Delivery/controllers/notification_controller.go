@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"FMS/Domain"
+	"FMS/Usecases"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationController struct {
+	NotificationUC Usecases.NotificationUsecase
+}
+
+func NewNotificationController(nu Usecases.NotificationUsecase) *NotificationController {
+	return &NotificationController{NotificationUC: nu}
+}
+
+// GetInbox lists the caller's notifications, newest first; ?unread=true hides read ones
+func (nc *NotificationController) GetInbox(c *gin.Context) {
+	list, err := nc.NotificationUC.GetInbox(actorFrom(c), c.Query("unread") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"notifications": list})
+}
+
+func (nc *NotificationController) MarkRead(c *gin.Context) {
+	if err := nc.NotificationUC.MarkRead(c.Param("id"), actorFrom(c)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "read"})
+}
+
+func (nc *NotificationController) GetPreferences(c *gin.Context) {
+	prefs, err := nc.NotificationUC.GetPreferences(actorFrom(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"preferences": prefs})
+}
+
+func (nc *NotificationController) UpdatePreferences(c *gin.Context) {
+	var payload Domain.NotificationPreferences
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	prefs, err := nc.NotificationUC.UpdatePreferences(&payload, actorFrom(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"preferences": prefs})
+}
@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"FMS/Domain"
+	"FMS/Infrastructure"
 	"FMS/Usecases"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,7 +20,15 @@ func NewReportController(r Usecases.ReportUsecase) *ReportController {
 }
 
 func (rc *ReportController) GetOverviewReport(c *gin.Context) {
-	o, err := rc.ReportUC.GetOverview()
+	filter, ok := reportFilter(c)
+	if !ok {
+		return
+	}
+	if wantsExport(c) {
+		rc.export(c, "overview", filter, rc.ReportUC.ExportOverview)
+		return
+	}
+	o, err := rc.ReportUC.GetOverview(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -25,28 +37,103 @@ func (rc *ReportController) GetOverviewReport(c *gin.Context) {
 }
 
 func (rc *ReportController) GetCashRequestReport(c *gin.Context) {
-	list, err := rc.ReportUC.GetCashRequestReport()
+	filter, ok := reportFilter(c)
+	if !ok {
+		return
+	}
+	if wantsExport(c) {
+		rc.export(c, "cash-requests", filter, rc.ReportUC.ExportCashRequests)
+		return
+	}
+	report, err := rc.ReportUC.GetCashRequestReport(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"cash_requests": list})
+	c.JSON(http.StatusOK, gin.H{"cash_requests": report})
 }
 
 func (rc *ReportController) GetBudgetReport(c *gin.Context) {
-	list, err := rc.ReportUC.GetBudgetReport()
+	filter, ok := reportFilter(c)
+	if !ok {
+		return
+	}
+	if wantsExport(c) {
+		rc.export(c, "budgets", filter, rc.ReportUC.ExportBudgets)
+		return
+	}
+	report, err := rc.ReportUC.GetBudgetReport(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"budgets": list})
+	c.JSON(http.StatusOK, gin.H{"budgets": report})
 }
 
 func (rc *ReportController) GetExpenseReport(c *gin.Context) {
-	list, err := rc.ReportUC.GetExpenseReport()
+	filter, ok := reportFilter(c)
+	if !ok {
+		return
+	}
+	if wantsExport(c) {
+		rc.export(c, "expenses", filter, rc.ReportUC.ExportExpenses)
+		return
+	}
+	report, err := rc.ReportUC.GetExpenseReport(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"expenses": list})
+	c.JSON(http.StatusOK, gin.H{"expenses": report})
+}
+
+// wantsExport is true when ?format= asks for anything other than JSON
+func wantsExport(c *gin.Context) bool {
+	format := c.Query("format")
+	return format != "" && format != "json"
+}
+
+// export streams a report straight to the response in the requested format
+func (rc *ReportController) export(c *gin.Context, name string, filter Domain.ReportFilter, write func(Domain.ReportFilter, Infrastructure.Exporter) error) {
+	exp, err := Infrastructure.NewExporter(c.Query("format"), c.Writer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := name + "-report-" + time.Now().UTC().Format("20060102") + "." + exp.Extension()
+	c.Header("Content-Type", exp.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	err = write(filter, exp)
+	if err == nil {
+		err = exp.Close()
+	}
+	if err != nil {
+		// once bytes are on the wire the status can no longer change
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("report export %s: %v", name, err)
+		_ = c.Error(err)
+	}
+}
+
+// reportFilter reads the optional from/to query params, writing a 400 when they are malformed
+func reportFilter(c *gin.Context) (Domain.ReportFilter, bool) {
+	var filter Domain.ReportFilter
+	var err error
+	if filter.From, err = parseDate(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+		return filter, false
+	}
+	if filter.To, err = parseDate(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+		return filter, false
+	}
+	return filter, true
 }
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"FMS/Domain"
+	"FMS/Usecases"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchController struct {
+	SearchUC Usecases.SearchUsecase
+}
+
+func NewSearchController(s Usecases.SearchUsecase) *SearchController {
+	return &SearchController{SearchUC: s}
+}
+
+// Search answers GET /search?q=, optionally narrowed with a comma separated
+// ?type= of budget, cash_request and expense, and capped by ?limit=
+func (sc *SearchController) Search(c *gin.Context) {
+	q := Domain.SearchQuery{Text: c.Query("q")}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		q.Limit = limit
+	}
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			q.Types = append(q.Types, t)
+		}
+	}
+
+	results, err := sc.SearchUC.Search(q, actorFrom(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
@@ -1,9 +1,13 @@
 package controllers
 
 import (
+	"FMS/Domain"
 	"FMS/Infrastructure"
 	"FMS/Usecases"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,19 +27,20 @@ func (uc *UserController) Home(c *gin.Context) {
 
 func (uc *UserController) Register(c *gin.Context) {
 	var payload struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username   string `json:"username"`
+		Password   string `json:"password"`
+		Department string `json:"department"`
 	}
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	user, err := uc.UserUC.Register(payload.Username, payload.Password)
+	user, err := uc.UserUC.Register(payload.Username, payload.Password, payload.Department)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{"username": user.Username, "role": user.Role, "created_at": user.CreatedAt})
+	c.JSON(http.StatusCreated, gin.H{"username": user.Username, "role": user.Role, "department": user.Department, "created_at": user.CreatedAt})
 }
 
 func (uc *UserController) Login(c *gin.Context) {
@@ -47,22 +52,203 @@ func (uc *UserController) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	token, err := uc.UserUC.Login(payload.Username, payload.Password)
+	result, err := uc.UserUC.Login(payload.Username, payload.Password, c.ClientIP())
+	if respondThrottled(c, err) {
+		return
+	}
+	if errors.Is(err, Domain.ErrAccountDeactivated) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"token": token, "expires_in": 24 * 3600})
+	c.JSON(http.StatusOK, result)
+}
+
+// respondThrottled answers 429 with Retry-After when err is a *Domain.ThrottleError
+func respondThrottled(c *gin.Context, err error) bool {
+	var throttled *Domain.ThrottleError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	// round up so a client that waits exactly this long is let through
+	c.Header("Retry-After", strconv.Itoa(int((throttled.RetryAfter+time.Second-1)/time.Second)))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "locked": throttled.Locked})
+	return true
+}
+
+// CompleteLogin is the second step of a login with two-factor authentication
+func (uc *UserController) CompleteLogin(c *gin.Context) {
+	var payload struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.MFAToken == "" || payload.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and code required"})
+		return
+	}
+	pair, err := uc.UserUC.CompleteLogin(payload.MFAToken, payload.Code, c.ClientIP())
+	if respondThrottled(c, err) {
+		return
+	}
+	if errors.Is(err, Domain.ErrAccountDeactivated) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, Domain.ErrInvalidMFACode) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, pair)
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token
+func (uc *UserController) RefreshToken(c *gin.Context) {
+	var payload struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token required"})
+		return
+	}
+	pair, err := uc.UserUC.Refresh(payload.RefreshToken)
+	if errors.Is(err, Domain.ErrAccountDeactivated) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, Domain.ErrInvalidRefreshToken) || errors.Is(err, Domain.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, pair)
+}
+
+// Logout ends the session of the refresh token; "all" ends every session of the user
+func (uc *UserController) Logout(c *gin.Context) {
+	var payload struct {
+		RefreshToken string `json:"refresh_token"`
+		All          bool   `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token required"})
+		return
+	}
+	if err := uc.UserUC.Logout(payload.RefreshToken, payload.All); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, Domain.ErrInvalidRefreshToken) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
 
+// JWKS publishes the public keys access tokens are signed with, for other
+// services that verify FMS tokens
+func (uc *UserController) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, uc.JWT.JWKS())
+}
+
+// GetAllUsers lists users page by page; ?q= searches usernames
 func (uc *UserController) GetAllUsers(c *gin.Context) {
-	// Not implemented fully - passthrough to UserUC if available
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "not implemented"})
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	result, err := uc.UserUC.ListUsers(Domain.UserQuery{Search: c.Query("q"), Page: page, Limit: limit})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (uc *UserController) GetUser(c *gin.Context) {
+	user, err := uc.UserUC.GetUser(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
 }
 
 func (uc *UserController) GetMyProfile(c *gin.Context) {
 	uname, _ := c.Get("username")
-	c.JSON(http.StatusOK, gin.H{"username": uname})
+	c.JSON(http.StatusOK, gin.H{
+		"username":        uname,
+		"role":            c.GetString("role"),
+		"department":      c.GetString("department"),
+		"department_head": c.GetBool("department_head"),
+		"two_factor":      c.GetBool("mfa"),
+	})
+}
+
+// SetupTOTP starts enrollment; the provisioning URI is meant to be shown as a QR code
+func (uc *UserController) SetupTOTP(c *gin.Context) {
+	setup, err := uc.UserUC.SetupTOTP(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+func (uc *UserController) EnableTOTP(c *gin.Context) {
+	var payload struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code required"})
+		return
+	}
+	codes, err := uc.UserUC.EnableTOTP(payload.Code, actorFrom(c))
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication enabled", "recovery_codes": codes})
+}
+
+func (uc *UserController) DisableTOTP(c *gin.Context) {
+	var payload struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code required"})
+		return
+	}
+	if err := uc.UserUC.DisableTOTP(payload.Code, actorFrom(c)); err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// ResetTOTP lets an admin turn off two-factor authentication for a user who lost their device
+func (uc *UserController) ResetTOTP(c *gin.Context) {
+	id := c.Param("id")
+	if err := uc.UserUC.ResetTOTP(id, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication reset", "id": id})
+}
+
+func mfaErrorStatus(err error) int {
+	if errors.Is(err, Domain.ErrInvalidMFACode) {
+		return http.StatusUnauthorized
+	}
+	return errorStatus(err, http.StatusBadRequest)
 }
 
 func (uc *UserController) UpdateUser(c *gin.Context) {
@@ -74,10 +260,70 @@ func (uc *UserController) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "role required"})
 		return
 	}
-	if err := uc.UserUC.Promote(payload.Role); err != nil {
-		// Promote is being reused; adjust in real implementation
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := uc.UserUC.SetRole(id, payload.Role, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "role updated", "id": id})
 }
+
+func (uc *UserController) UpdateDepartment(c *gin.Context) {
+	id := c.Param("id")
+	var payload struct {
+		Department string `json:"department"`
+		Head       bool   `json:"head"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := uc.UserUC.SetDepartment(id, payload.Department, payload.Head, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "department updated", "id": id})
+}
+
+func (uc *UserController) DeactivateUser(c *gin.Context) {
+	id := c.Param("id")
+	if err := uc.UserUC.Deactivate(id, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deactivated", "id": id})
+}
+
+func (uc *UserController) ReactivateUser(c *gin.Context) {
+	id := c.Param("id")
+	if err := uc.UserUC.Reactivate(id, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "reactivated", "id": id})
+}
+
+// UnlockUser lifts a lockout after too many failed logins
+func (uc *UserController) UnlockUser(c *gin.Context) {
+	id := c.Param("id")
+	if err := uc.UserUC.Unlock(id, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "unlocked", "id": id})
+}
+
+func (uc *UserController) ResetPassword(c *gin.Context) {
+	id := c.Param("id")
+	var payload struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password required"})
+		return
+	}
+	if err := uc.UserUC.ResetPassword(id, payload.Password, actorFrom(c)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password reset", "id": id})
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"FMS/Domain"
+	"FMS/Usecases"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookController struct {
+	WebhookUC Usecases.WebhookUsecase
+}
+
+func NewWebhookController(wu Usecases.WebhookUsecase) *WebhookController {
+	return &WebhookController{WebhookUC: wu}
+}
+
+// GetEvents lists the event types a subscription can name
+func (wc *WebhookController) GetEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"events": Domain.WebhookEvents})
+}
+
+// CreateSubscription answers with the signing secret, which is not shown again
+func (wc *WebhookController) CreateSubscription(c *gin.Context) {
+	var payload Domain.WebhookSubscription
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub, err := wc.WebhookUC.CreateSubscription(&payload, actorFrom(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"subscription": sub, "secret": sub.Secret})
+}
+
+func (wc *WebhookController) GetSubscriptions(c *gin.Context) {
+	subs, err := wc.WebhookUC.GetSubscriptions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subscriptions": subs})
+}
+
+func (wc *WebhookController) GetSubscription(c *gin.Context) {
+	sub, err := wc.WebhookUC.GetSubscription(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subscription": sub})
+}
+
+// UpdateSubscription changes url, events or active; omitted fields keep their value
+func (wc *WebhookController) UpdateSubscription(c *gin.Context) {
+	payload, err := wc.WebhookUC.GetSubscription(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.ShouldBindJSON(payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub, err := wc.WebhookUC.UpdateSubscription(c.Param("id"), payload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subscription": sub})
+}
+
+func (wc *WebhookController) DeleteSubscription(c *gin.Context) {
+	if err := wc.WebhookUC.DeleteSubscription(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// GetDeliveries is the delivery log, newest first, filtered by
+// ?subscription_id=, ?status=, ?event= and ?limit=
+func (wc *WebhookController) GetDeliveries(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	deliveries, err := wc.WebhookUC.GetDeliveries(Domain.WebhookDeliveryFilter{
+		SubscriptionID: c.Query("subscription_id"),
+		Status:         c.Query("status"),
+		Event:          c.Query("event"),
+		Limit:          limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+func (wc *WebhookController) GetDelivery(c *gin.Context) {
+	delivery, err := wc.WebhookUC.GetDelivery(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"delivery": delivery})
+}
+
+func (wc *WebhookController) RetryDelivery(c *gin.Context) {
+	if err := wc.WebhookUC.RetryDelivery(c.Param("id")); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "queued"})
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"FMS/Delivery/routers"
+	"FMS/Domain"
 	"FMS/Infrastructure"
 	"FMS/Repositories"
 	"FMS/Usecases"
 	"log"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -15,20 +18,121 @@ func main() {
 	}
 	defer Infrastructure.CloseMongo()
 
+	// refuse to start rather than sign tokens with a missing key
+	jwtSvc, err := Infrastructure.NewJWTService()
+	if err != nil {
+		log.Fatalf("token signing: %v", err)
+	}
+
+	// amounts used to be float64; convert any left over to integer minor units
+	if n, err := Repositories.MigrateMoneyToMinorUnits(Infrastructure.GetDB()); err != nil {
+		log.Fatalf("money migration: %v", err)
+	} else if n > 0 {
+		log.Printf("money migration: converted %d documents to minor units", n)
+	}
+	// expenses used to hold a single receipt_url
+	if n, err := Repositories.MigrateReceiptURLs(Infrastructure.GetDB()); err != nil {
+		log.Fatalf("receipt migration: %v", err)
+	} else if n > 0 {
+		log.Printf("receipt migration: moved the receipt_url of %d expenses into receipts", n)
+	}
+
+	if err := Repositories.EnsureIndexes(Infrastructure.GetDB()); err != nil {
+		log.Fatalf("indexes: %v", err)
+	}
+
 	// create repository implementations
 	userRepo := Repositories.NewMongoUserRepository(Infrastructure.GetDB())
 	budgetRepo := Repositories.NewMongoBudgetRepository(Infrastructure.GetDB())
 	cashRepo := Repositories.NewMongoCashRequestRepository(Infrastructure.GetDB())
 	expenseRepo := Repositories.NewMongoExpenseRepository(Infrastructure.GetDB())
+	ledgerRepo := Repositories.NewMongoLedgerRepository(Infrastructure.GetDB())
+	reportStatsRepo := Repositories.NewMongoReportStatsRepository(Infrastructure.GetDB())
+	approvalPolicyRepo := Repositories.NewMongoApprovalPolicyRepository(Infrastructure.GetDB())
+	exchangeRateRepo := Repositories.NewMongoExchangeRateRepository(Infrastructure.GetDB())
+	fiscalPeriodRepo := Repositories.NewMongoFiscalPeriodRepository(Infrastructure.GetDB())
+	budgetTemplateRepo := Repositories.NewMongoBudgetTemplateRepository(Infrastructure.GetDB())
+	notificationRepo := Repositories.NewMongoNotificationRepository(Infrastructure.GetDB())
+	webhookSubRepo := Repositories.NewMongoWebhookSubscriptionRepository(Infrastructure.GetDB())
+	webhookDeliveryRepo := Repositories.NewMongoWebhookDeliveryRepository(Infrastructure.GetDB())
+	searchRepo := Repositories.NewMongoSearchRepository(Infrastructure.GetDB())
+	refreshTokenRepo := Repositories.NewMongoRefreshTokenRepository(Infrastructure.GetDB())
+	loginAttemptRepo := Repositories.NewMongoLoginAttemptRepository(Infrastructure.GetDB())
+	auditRepo, err := Repositories.NewMongoAuditRepository(Infrastructure.GetDB())
+	if err != nil {
+		log.Fatalf("audit log: %v", err)
+	}
+
+	receiptStorage, err := Infrastructure.NewBlobStorage(Infrastructure.GetDB())
+	if err != nil {
+		log.Fatalf("receipt storage: %v", err)
+	}
 
-	userUC := Usecases.NewUserUsecase(userRepo, Infrastructure.NewPasswordService(), Infrastructure.NewJWTService())
-	budgetUC := Usecases.NewBudgetUsecase(budgetRepo)
-	cashUC := Usecases.NewCashRequestUsecase(cashRepo)
-	expenseUC := Usecases.NewExpenseUsecase(expenseRepo)
-	reportUC := Usecases.NewReportUsecase(budgetRepo, cashRepo, expenseRepo)
+	baseCurrency, err := Domain.NormalizeCurrency(Infrastructure.GetEnv("BASE_CURRENCY", Domain.DefaultBaseCurrency))
+	if err != nil {
+		log.Fatalf("BASE_CURRENCY: %v", err)
+	}
+	currencyUC := Usecases.NewCurrencyUsecase(exchangeRateRepo, baseCurrency)
+
+	thresholds := Usecases.DefaultThresholds
+	if v := Infrastructure.GetEnv("NOTIFY_THRESHOLDS", ""); v != "" {
+		if thresholds, err = Usecases.ParseThresholds(v); err != nil {
+			log.Fatalf("NOTIFY_THRESHOLDS: %v", err)
+		}
+	}
+	webhookClient := Infrastructure.NewWebhookClient(10 * time.Second)
+	channels := []Usecases.NotificationChannel{
+		Usecases.NewInAppChannel(notificationRepo),
+		Usecases.NewWebhookChannel(Infrastructure.NewPublicWebhookClient(5 * time.Second)),
+	}
+	if mailer := Infrastructure.NewMailerFromEnv(); mailer != nil {
+		channels = append(channels, Usecases.NewEmailChannel(mailer))
+	}
+	notificationUC := Usecases.NewNotificationUsecase(notificationRepo, userRepo, budgetRepo, thresholds, channels...)
+	stopNotifications := make(chan struct{})
+	defer close(stopNotifications)
+	go notificationUC.RunDelivery(stopNotifications)
+
+	// outbound webhooks are queued in the outbox and sent by a background worker
+	webhookUC := Usecases.NewWebhookUsecase(webhookSubRepo, webhookDeliveryRepo, webhookClient)
+	stopWebhooks := make(chan struct{})
+	defer close(stopWebhooks)
+	go Usecases.RunWebhookWorker(webhookUC, 10*time.Second, stopWebhooks)
+
+	var breached Infrastructure.BreachedPasswords
+	if path := Infrastructure.GetEnv("BREACHED_PASSWORDS_FILE", ""); path != "" {
+		if breached, err = Infrastructure.LoadBreachedPasswords(path); err != nil {
+			log.Fatalf("BREACHED_PASSWORDS_FILE: %v", err)
+		}
+	} else {
+		log.Printf("BREACHED_PASSWORDS_FILE not set: new passwords are not checked against a breached list")
+	}
+	auditUC := Usecases.NewAuditUsecase(auditRepo)
+	userUC := Usecases.NewAuditedUserUsecase(Usecases.NewUserUsecase(userRepo, Infrastructure.NewPasswordService(), jwtSvc, refreshTokenRepo, loginAttemptRepo, breached), userRepo, auditUC)
+	// mutations of financial records are written to the audit trail, then notified
+	// and published to webhook subscribers
+	budgetUC := Usecases.NewPublishedBudgetUsecase(Usecases.NewNotifyingBudgetUsecase(Usecases.NewAuditedBudgetUsecase(Usecases.NewBudgetUsecase(budgetRepo, ledgerRepo, approvalPolicyRepo, currencyUC, fiscalPeriodRepo), budgetRepo, auditUC), budgetRepo, notificationUC), webhookUC)
+	cashUC := Usecases.NewPublishedCashRequestUsecase(Usecases.NewNotifyingCashRequestUsecase(Usecases.NewAuditedCashRequestUsecase(Usecases.NewCashRequestUsecase(cashRepo, budgetRepo, ledgerRepo, approvalPolicyRepo, currencyUC, expenseRepo, fiscalPeriodRepo), cashRepo, auditUC), cashRepo, budgetRepo, notificationUC), cashRepo, webhookUC)
+	expenseUC := Usecases.NewPublishedExpenseUsecase(Usecases.NewNotifyingExpenseUsecase(Usecases.NewAuditedExpenseUsecase(Usecases.NewExpenseUsecase(expenseRepo, budgetRepo, ledgerRepo, receiptStorage, currencyUC, fiscalPeriodRepo, cashRepo), expenseRepo, auditUC), expenseRepo, notificationUC), expenseRepo, webhookUC)
+	reportUC := Usecases.NewReportUsecase(reportStatsRepo, currencyUC)
+	ledgerUC := Usecases.NewLedgerUsecase(ledgerRepo, budgetRepo)
+	searchUC := Usecases.NewSearchUsecase(searchRepo, budgetRepo)
+	approvalUC := Usecases.NewApprovalUsecase(approvalPolicyRepo, budgetRepo, cashRepo)
+	fiscalUC := Usecases.NewPublishedFiscalUsecase(Usecases.NewAuditedFiscalUsecase(Usecases.NewFiscalUsecase(fiscalPeriodRepo, budgetTemplateRepo, budgetRepo, ledgerRepo, budgetUC, expenseRepo, cashRepo), auditUC), webhookUC)
 
 	// create router with controllers wired to usecases
-	r := routers.SetupRouter(userUC, budgetUC, cashUC, expenseUC, reportUC)
+	r := routers.SetupRouter(userUC, budgetUC, cashUC, expenseUC, reportUC, ledgerUC, approvalUC, auditUC, currencyUC, fiscalUC, notificationUC, webhookUC, searchUC, jwtSvc)
+	// login failures are counted per client address, so X-Forwarded-For is
+	// only believed from known proxies
+	var proxies []string
+	for _, p := range strings.Split(Infrastructure.GetEnv("TRUSTED_PROXIES", ""), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("TRUSTED_PROXIES: %v", err)
+	}
 
 	port := Infrastructure.GetEnv("PORT", "8080")
 
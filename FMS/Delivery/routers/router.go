@@ -2,44 +2,70 @@ package routers
 
 import (
 	"FMS/Delivery/controllers"
+	"FMS/Domain"
 	"FMS/Infrastructure"
 	"FMS/Usecases"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(userUC Usecases.UserUsecase, budgetUC Usecases.BudgetUsecase, cashRequestUC Usecases.CashRequestUsecase, expenseUC Usecases.ExpenseUsecase, reportUC Usecases.ReportUsecase) *gin.Engine {
+func SetupRouter(userUC Usecases.UserUsecase, budgetUC Usecases.BudgetUsecase, cashRequestUC Usecases.CashRequestUsecase, expenseUC Usecases.ExpenseUsecase, reportUC Usecases.ReportUsecase, ledgerUC Usecases.LedgerUsecase, approvalUC Usecases.ApprovalUsecase, auditUC Usecases.AuditUsecase, currencyUC Usecases.CurrencyUsecase, fiscalUC Usecases.FiscalUsecase, notificationUC Usecases.NotificationUsecase, webhookUC Usecases.WebhookUsecase, searchUC Usecases.SearchUsecase, jwtSvc Infrastructure.JWTService) *gin.Engine {
 	r := gin.Default()
-
-	jwtSvc := Infrastructure.NewJWTService()
+	r.Use(Infrastructure.RequestID())
 
 	userCtr := controllers.NewUserController(userUC, jwtSvc)
 	budgetCtr := controllers.NewBudgetController(budgetUC)
 	cashRequestCtr := controllers.NewCashRequestController(cashRequestUC)
 	expenseCtr := controllers.NewExpenseController(expenseUC)
 	reportCtr := controllers.NewReportController(reportUC)
+	ledgerCtr := controllers.NewLedgerController(ledgerUC)
+	approvalCtr := controllers.NewApprovalController(approvalUC)
+	auditCtr := controllers.NewAuditController(auditUC)
+	currencyCtr := controllers.NewCurrencyController(currencyUC)
+	fiscalCtr := controllers.NewFiscalController(fiscalUC)
+	notificationCtr := controllers.NewNotificationController(notificationUC)
+	webhookCtr := controllers.NewWebhookController(webhookUC)
+	searchCtr := controllers.NewSearchController(searchUC)
 
 
 	// public
 	r.POST("/register", userCtr.Register)
 	r.POST("/login", userCtr.Login)
+	r.POST("/login/2fa", userCtr.CompleteLogin)
+	r.POST("/token/refresh", userCtr.RefreshToken)
+	r.POST("/logout", userCtr.Logout)
+	r.GET("/.well-known/jwks.json", userCtr.JWKS)
 	r.GET("/", userCtr.Home)
 
 	user := r.Group("/users")
-	user.Use(Infrastructure.AuthMiddleware(jwtSvc))
+	user.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC))
 	{
-		user.GET("/", userCtr.GetAllUsers)
 		user.GET("/me", userCtr.GetMyProfile)
+		user.POST("/me/2fa/setup", userCtr.SetupTOTP)
+		user.POST("/me/2fa/enable", userCtr.EnableTOTP)
+		user.POST("/me/2fa/disable", userCtr.DisableTOTP)
+	}
+
+	user.Use(Infrastructure.RequireRole(Domain.RoleAdmin, Domain.RoleFinance))
+	{
+		user.GET("/", userCtr.GetAllUsers)
+		user.GET("/:id", userCtr.GetUser)
 	}
 
-	user.Use(Infrastructure.AuthMiddleware(jwtSvc), Infrastructure.FinanceOnly())
+	user.Use(Infrastructure.AdminOnly())
 	{
 		user.PUT("/:id/role", userCtr.UpdateUser)
+		user.PUT("/:id/department", userCtr.UpdateDepartment)
+		user.POST("/:id/deactivate", userCtr.DeactivateUser)
+		user.POST("/:id/reactivate", userCtr.ReactivateUser)
+		user.POST("/:id/unlock", userCtr.UnlockUser)
+		user.POST("/:id/password", userCtr.ResetPassword)
+		user.POST("/:id/2fa/reset", userCtr.ResetTOTP)
 	}
 
 	// protected
 	budget := r.Group("/budgets")
-	budget.Use(Infrastructure.AuthMiddleware(jwtSvc))
+	budget.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC))
 	{
 
 		budget.GET("/", budgetCtr.GetAllBudgets)
@@ -48,33 +74,34 @@ func SetupRouter(userUC Usecases.UserUsecase, budgetUC Usecases.BudgetUsecase, c
 
 		budget.PUT("/:id", budgetCtr.UpdateBudget)
 		budget.POST("/", budgetCtr.CreateBudget)
-	}
 
-	budget.Use(Infrastructure.AuthMiddleware(jwtSvc), Infrastructure.FinanceOnly())
-	{
+		// who may approve is decided per level by the approval chain
 		budget.POST("/:id/approve", budgetCtr.ApproveBudget)
 		budget.POST("/:id/reject", budgetCtr.RejectBudget)
 	}
 
 	cashRequest := r.Group("/cash-requests")
-	cashRequest.Use(Infrastructure.AuthMiddleware(jwtSvc))
+	cashRequest.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC))
 	{
 
 		cashRequest.GET("/", cashRequestCtr.GetAllCashRequests)
 		cashRequest.GET("/:id", cashRequestCtr.GetCashRequest)
+		cashRequest.GET("/:id/reconciliation", cashRequestCtr.GetReconciliation)
 
 		cashRequest.POST("/", cashRequestCtr.CreateCashRequest)
-	}
 
-	cashRequest.Use(Infrastructure.AuthMiddleware(jwtSvc), Infrastructure.FinanceOnly())
-	{
 		cashRequest.POST("/:id/approve", cashRequestCtr.ApproveCashRequest)
 		cashRequest.POST("/:id/reject", cashRequestCtr.RejectCashRequest)
+	}
+
+	cashRequest.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC), Infrastructure.FinanceOnly())
+	{
 		cashRequest.POST("/:id/disburse", cashRequestCtr.DisburseCashRequest)
+		cashRequest.POST("/:id/settle", cashRequestCtr.SettleCashRequest)
 	}
 
 	expense := r.Group("/expenses")
-	expense.Use(Infrastructure.AuthMiddleware(jwtSvc))
+	expense.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC))
 	{
 
 		expense.GET("/", expenseCtr.GetAllExpenses)
@@ -83,15 +110,23 @@ func SetupRouter(userUC Usecases.UserUsecase, budgetUC Usecases.BudgetUsecase, c
 
 		expense.POST("/", expenseCtr.CreateExpense)
 		expense.POST("/:id/receipts", expenseCtr.CreateExpenseReceipt)
+		expense.GET("/:id/receipts/:rid", expenseCtr.DownloadExpenseReceipt)
 	}
 
-	expense.Use(Infrastructure.AuthMiddleware(jwtSvc), Infrastructure.FinanceOnly())
+	expense.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC), Infrastructure.FinanceOnly())
 	{
 		expense.PUT("/:id/verify", expenseCtr.VerifyExpense)
+		expense.PUT("/:id/reject", expenseCtr.RejectExpense)
+	}
+
+	search := r.Group("/search")
+	search.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC))
+	{
+		search.GET("/", searchCtr.Search)
 	}
 
 	report := r.Group("/reports")
-	report.Use(Infrastructure.AuthMiddleware(jwtSvc), Infrastructure.FinanceOnly())
+	report.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC), Infrastructure.FinanceOnly())
 	{
 		report.GET("/overview", reportCtr.GetOverviewReport)
 		report.GET("/cash-requests", reportCtr.GetCashRequestReport)
@@ -99,5 +134,88 @@ func SetupRouter(userUC Usecases.UserUsecase, budgetUC Usecases.BudgetUsecase, c
 		report.GET("/expenses", reportCtr.GetExpenseReport)
 	}
 
+	ledger := r.Group("/ledger")
+	ledger.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC), Infrastructure.FinanceOnly())
+	{
+		ledger.GET("/", ledgerCtr.GetLedger)
+		ledger.GET("/consistency", ledgerCtr.CheckConsistency)
+	}
+
+	approval := r.Group("/approvals")
+	approval.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC))
+	{
+		approval.GET("/pending", approvalCtr.GetPendingApprovals)
+	}
+
+	approval.Use(Infrastructure.AdminOnly())
+	{
+		approval.GET("/policies", approvalCtr.GetPolicies)
+		approval.POST("/policies", approvalCtr.SavePolicy)
+		approval.DELETE("/policies/:id", approvalCtr.DeletePolicy)
+	}
+
+	audit := r.Group("/audit")
+	audit.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC), Infrastructure.FinanceOnly())
+	{
+		audit.GET("/", auditCtr.GetAuditLog)
+		audit.GET("/verify", auditCtr.VerifyAuditLog)
+	}
+
+	currency := r.Group("/currencies")
+	currency.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC))
+	{
+		currency.GET("/base", currencyCtr.GetBaseCurrency)
+		currency.GET("/rates", currencyCtr.GetRates)
+	}
+
+	currency.Use(Infrastructure.FinanceOnly())
+	{
+		currency.POST("/rates", currencyCtr.AddRate)
+		currency.DELETE("/rates/:id", currencyCtr.DeleteRate)
+	}
+
+	fiscal := r.Group("/fiscal")
+	fiscal.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC))
+	{
+		fiscal.GET("/periods", fiscalCtr.GetPeriods)
+		fiscal.GET("/periods/:id", fiscalCtr.GetPeriod)
+	}
+
+	fiscal.Use(Infrastructure.FinanceOnly())
+	{
+		fiscal.POST("/years", fiscalCtr.CreateYear)
+		fiscal.POST("/periods/:id/close", fiscalCtr.ClosePeriod)
+		fiscal.POST("/periods/:id/generate", fiscalCtr.GenerateBudgets)
+
+		fiscal.GET("/templates", fiscalCtr.GetTemplates)
+		fiscal.POST("/templates", fiscalCtr.CreateTemplate)
+		fiscal.PUT("/templates/:id", fiscalCtr.UpdateTemplate)
+		fiscal.DELETE("/templates/:id", fiscalCtr.DeleteTemplate)
+	}
+
+	notification := r.Group("/notifications")
+	notification.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC))
+	{
+		notification.GET("/", notificationCtr.GetInbox)
+		notification.POST("/:id/read", notificationCtr.MarkRead)
+		notification.GET("/preferences", notificationCtr.GetPreferences)
+		notification.PUT("/preferences", notificationCtr.UpdatePreferences)
+	}
+
+	webhook := r.Group("/webhooks")
+	webhook.Use(Infrastructure.AuthMiddleware(jwtSvc, userUC), Infrastructure.AdminOnly())
+	{
+		webhook.GET("/events", webhookCtr.GetEvents)
+		webhook.GET("/subscriptions", webhookCtr.GetSubscriptions)
+		webhook.POST("/subscriptions", webhookCtr.CreateSubscription)
+		webhook.GET("/subscriptions/:id", webhookCtr.GetSubscription)
+		webhook.PUT("/subscriptions/:id", webhookCtr.UpdateSubscription)
+		webhook.DELETE("/subscriptions/:id", webhookCtr.DeleteSubscription)
+
+		webhook.GET("/deliveries", webhookCtr.GetDeliveries)
+		webhook.GET("/deliveries/:id", webhookCtr.GetDelivery)
+		webhook.POST("/deliveries/:id/retry", webhookCtr.RetryDelivery)
+	}
+
 	return r
 }
@@ -0,0 +1,56 @@
+package Domain
+
+import "strings"
+
+// Actor is the authenticated caller a usecase runs on behalf of
+type Actor struct {
+	UserID         string
+	Username       string
+	Role           string
+	Department     string
+	DepartmentHead bool // may see the budgets of Department and the spend against them
+	Finance        bool // passes FinanceOnly and may see every record
+	MFA            bool // the user has two-factor authentication on
+
+	// request metadata kept in the audit trail
+	IP        string
+	RequestID string
+
+	// IfMatch is the record version the request's If-Match header expects; 0
+	// when the header is absent
+	IfMatch int64
+}
+
+// CanView reports whether the actor may read a record owned by owner
+func (a Actor) CanView(owner string) bool {
+	return a.Finance || (owner != "" && owner == a.UserID)
+}
+
+// HeadOf reports whether the actor heads department
+func (a Actor) HeadOf(department string) bool {
+	return a.DepartmentHead && a.Department != "" && strings.EqualFold(a.Department, department)
+}
+
+// CanApproveAt reports whether the actor may sign off at an approval level for a
+// record belonging to department. The finance and director levels release
+// money, so they also need two-factor authentication.
+func (a Actor) CanApproveAt(level, department string) bool {
+	switch level {
+	case LevelManager:
+		return a.HeadOf(department)
+	case LevelFinance:
+		return a.Finance && a.MFA
+	case LevelDirector:
+		return a.Role == RoleAdmin && a.MFA
+	}
+	return false
+}
+
+// CheckVersion returns a stale *ConflictError when the actor's If-Match names
+// a version other than the one the record is at
+func (a Actor) CheckVersion(entity, id string, version int64) error {
+	if a.IfMatch == 0 || a.IfMatch == version {
+		return nil
+	}
+	return &ConflictError{Entity: entity, ID: id, Version: a.IfMatch, Stale: true}
+}
@@ -0,0 +1,76 @@
+package Domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// approval levels; who may act at each level is decided in Actor.CanApproveAt
+const (
+	LevelManager  = "manager"  // head of the record's department
+	LevelFinance  = "finance"  // passes FinanceOnly
+	LevelDirector = "director" // admin role
+)
+
+// entity types; approval policies apply to budgets and cash requests
+const (
+	EntityBudget       = "budget"
+	EntityCashRequest  = "cash_request"
+	EntityExpense      = "expense"
+	EntityFiscalPeriod = "fiscal_period"
+	EntityUser         = "user"
+)
+
+// approval decisions
+const (
+	DecisionApproved = "approved"
+	DecisionRejected = "rejected"
+)
+
+// ApprovalStep requires Level to sign off when the amount is at least MinAmount
+type ApprovalStep struct {
+	Level     string `bson:"level" json:"level"`
+	MinAmount Money  `bson:"min_amount" json:"min_amount"`
+}
+
+// ApprovalPolicy defines the approval chain for one entity type, optionally per
+// department. An empty Department is the default for departments without a policy.
+type ApprovalPolicy struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EntityType string             `bson:"entity_type" json:"entity_type"`
+	Department string             `bson:"department,omitempty" json:"department,omitempty"`
+	Steps      []ApprovalStep     `bson:"steps" json:"steps"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ApprovalRecord is one approver's decision on a record
+type ApprovalRecord struct {
+	Level      string    `bson:"level" json:"level"`
+	ApproverID string    `bson:"approver_id" json:"approver_id"`
+	Approver   string    `bson:"approver,omitempty" json:"approver,omitempty"`
+	Decision   string    `bson:"decision" json:"decision"`
+	Comment    string    `bson:"comment,omitempty" json:"comment,omitempty"`
+	At         time.Time `bson:"at" json:"at"`
+}
+
+// PendingApproval is an inbox item waiting on the current user
+type PendingApproval struct {
+	EntityType string             `json:"entity_type"`
+	EntityID   primitive.ObjectID `json:"entity_id"`
+	Title      string             `json:"title"`
+	Department string             `json:"department,omitempty"`
+	Amount     Money              `json:"amount"`
+	Level      string             `json:"level"`
+	Requester  string             `json:"requester,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// ValidLevel reports whether level is a known approval level
+func ValidLevel(level string) bool {
+	switch level {
+	case LevelManager, LevelFinance, LevelDirector:
+		return true
+	}
+	return false
+}
@@ -9,13 +9,92 @@ import (
 // Budget represents an allocated budget for a period or department
 type Budget struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Version     int64              `bson:"version" json:"version"` // bumped by every write; see ConflictError
 	Title       string             `bson:"title" json:"title"`
 	Description string             `bson:"description,omitempty" json:"description"`
-	Amount      float64            `bson:"amount" json:"amount"`
-	Remaining   float64            `bson:"remaining" json:"remaining"`
+	Amount      Money              `bson:"amount" json:"amount"`
+	Remaining   Money              `bson:"remaining" json:"remaining"`
 	Department  string             `bson:"department,omitempty" json:"department,omitempty"`
 	DueDate     time.Time          `bson:"due_date,omitempty" json:"due_date"`
 	Status      string             `bson:"status,omitempty" json:"status"`
 	CreatedBy   string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
 	CreatedAt   time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	// Currency is what Amount is in; ExchangeRate converts it to the base
+	// currency at the rate effective on CreatedAt, giving BaseAmount
+	Currency     string  `bson:"currency,omitempty" json:"currency"`
+	ExchangeRate float64 `bson:"exchange_rate,omitempty" json:"exchange_rate,omitempty"`
+	BaseAmount   Money   `bson:"base_amount,omitempty" json:"base_amount"`
+	// PeriodID is the fiscal period the budget funds; TemplateID is set on
+	// budgets created from a recurring template
+	PeriodID   primitive.ObjectID `bson:"period_id,omitempty" json:"period_id,omitempty"`
+	TemplateID primitive.ObjectID `bson:"template_id,omitempty" json:"template_id,omitempty"`
+	// CarriedIn is the part of Amount carried forward from the previous period;
+	// CarriedOut is what was left unspent and moved on when this period closed
+	CarriedIn  Money `bson:"carried_in,omitempty" json:"carried_in,omitempty"`
+	CarriedOut Money `bson:"carried_out,omitempty" json:"carried_out,omitempty"`
+	// LineItems split Amount into categories; any amount they leave unallocated
+	// cannot be spent while the budget has line items
+	LineItems []BudgetLineItem `bson:"line_items,omitempty" json:"line_items,omitempty"`
+	// AlertedThresholds are the utilisation percentages already notified
+	AlertedThresholds []int `bson:"alerted_thresholds,omitempty" json:"alerted_thresholds,omitempty"`
+	// ApprovalChain lists the levels that must approve, fixed when the budget is submitted
+	ApprovalChain []string           `bson:"approval_chain,omitempty" json:"approval_chain,omitempty"`
+	Approvals     []ApprovalRecord   `bson:"approvals,omitempty" json:"approvals,omitempty"`
+	History       []StatusTransition `bson:"history,omitempty" json:"history,omitempty"`
+}
+
+// BudgetLineItem is one category's allocation within a budget. Spend tagged to
+// the line item draws on its Remaining as well as the budget's.
+type BudgetLineItem struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Category    string             `bson:"category" json:"category"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Amount      Money              `bson:"amount" json:"amount"`
+	Remaining   Money              `bson:"remaining" json:"remaining"`
+	CarriedOut  Money              `bson:"carried_out,omitempty" json:"carried_out,omitempty"`
+}
+
+// CategoryUsage is how much of one line item has been spent
+type CategoryUsage struct {
+	LineItemID primitive.ObjectID `json:"line_item_id"`
+	Category   string             `json:"category"`
+	Amount     Money              `json:"amount"`
+	Remaining  Money              `json:"remaining"`
+	Spent      Money              `json:"spent"`
+	CarriedOut Money              `json:"carried_out,omitempty"`
+}
+
+// LineItem returns the line item with id, or nil when the budget has none
+func (b *Budget) LineItem(id primitive.ObjectID) *BudgetLineItem {
+	for i := range b.LineItems {
+		if b.LineItems[i].ID == id {
+			return &b.LineItems[i]
+		}
+	}
+	return nil
+}
+
+// Allocated is the part of Amount assigned to line items
+func (b *Budget) Allocated() Money {
+	var total Money
+	for _, li := range b.LineItems {
+		total += li.Amount
+	}
+	return total
+}
+
+// CategoryUsage reports spend per line item
+func (b *Budget) CategoryUsage() []CategoryUsage {
+	usage := make([]CategoryUsage, 0, len(b.LineItems))
+	for _, li := range b.LineItems {
+		usage = append(usage, CategoryUsage{
+			LineItemID: li.ID,
+			Category:   li.Category,
+			Amount:     li.Amount,
+			Remaining:  li.Remaining,
+			Spent:      li.Amount - li.Remaining - li.CarriedOut,
+			CarriedOut: li.CarriedOut,
+		})
+	}
+	return usage
 }
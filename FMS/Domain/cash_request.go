@@ -8,11 +8,49 @@ import (
 
 type CashRequest struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Version     int64              `bson:"version" json:"version"` // bumped by every write; see ConflictError
 	Title       string             `bson:"title" json:"title"`
 	Description string             `bson:"description,omitempty" json:"description"`
-	Amount      float64            `bson:"amount" json:"amount"`
+	Amount      Money              `bson:"amount" json:"amount"`
 	BudgetID    primitive.ObjectID `bson:"budget_id,omitempty" json:"budget_id,omitempty"`
+	LineItemID  primitive.ObjectID `bson:"line_item_id,omitempty" json:"line_item_id,omitempty"`
 	Requester   string             `bson:"requester,omitempty" json:"requester,omitempty"`
 	CreatedAt   time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
 	Status      string             `bson:"status,omitempty" json:"status"`
+	// BaseAmount is Amount in the base currency, see Budget
+	Currency     string  `bson:"currency,omitempty" json:"currency"`
+	ExchangeRate float64 `bson:"exchange_rate,omitempty" json:"exchange_rate,omitempty"`
+	BaseAmount   Money   `bson:"base_amount,omitempty" json:"base_amount"`
+	// ApprovalChain lists the levels that must approve, fixed when the request is submitted
+	ApprovalChain []string           `bson:"approval_chain,omitempty" json:"approval_chain,omitempty"`
+	Approvals     []ApprovalRecord   `bson:"approvals,omitempty" json:"approvals,omitempty"`
+	History       []StatusTransition `bson:"history,omitempty" json:"history,omitempty"`
+	// Settlement is set when a disbursed request is settled against its expenses
+	Settlement *CashSettlement `bson:"settlement,omitempty" json:"settlement,omitempty"`
+}
+
+// CashSettlement closes out a cash advance. Amounts are in the request's
+// currency: Returned is cash handed back to the budget, TopUp is paid out to
+// cover expenses beyond the advance.
+type CashSettlement struct {
+	Spent     Money     `bson:"spent" json:"spent"`
+	Returned  Money     `bson:"returned,omitempty" json:"returned,omitempty"`
+	TopUp     Money     `bson:"top_up,omitempty" json:"top_up,omitempty"`
+	SettledBy string    `bson:"settled_by" json:"settled_by"`
+	SettledAt time.Time `bson:"settled_at" json:"settled_at"`
+}
+
+// CashReconciliation compares a cash advance with the expenses recorded against
+// it. Unreturned is the advance less verified spend; a negative value is owed
+// to the requester.
+type CashReconciliation struct {
+	CashRequestID primitive.ObjectID `json:"cash_request_id"`
+	Status        string             `json:"status"`
+	Currency      string             `json:"currency"`
+	Advance       Money              `json:"advance"`
+	Spent         Money              `json:"spent"`
+	Pending       Money              `json:"pending"`
+	Unreturned    Money              `json:"unreturned"`
+	Expenses      []Expense          `json:"expenses"`
+	Settlement    *CashSettlement    `json:"settlement,omitempty"`
 }
@@ -0,0 +1,59 @@
+package Domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultBaseCurrency is the reporting currency when BASE_CURRENCY is not set
+const DefaultBaseCurrency = "USD"
+
+// ErrNoExchangeRate is returned when no rate is effective for a currency on a date
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+// ErrUnsupportedCurrency is returned for a currency whose minor unit is not a
+// hundredth, since Money always counts hundredths
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// nonCentDecimals lists the ISO 4217 currencies whose minor unit is not a
+// hundredth, with their number of decimal places
+var nonCentDecimals = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// ExchangeRate converts Currency into the base currency: one unit of Currency
+// is worth Rate units of base from EffectiveFrom until the next rate takes effect
+type ExchangeRate struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Currency      string             `bson:"currency" json:"currency"`
+	Rate          float64            `bson:"rate" json:"rate"`
+	EffectiveFrom time.Time          `bson:"effective_from" json:"effective_from"`
+	CreatedBy     string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 style code and checks it is three
+// letters. Currencies that do not use two decimal places are rejected: an
+// amount of 12.50 could not be stored exactly in them, nor rounded correctly.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", errors.New("currency must be a three-letter code")
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", errors.New("currency must be a three-letter code")
+		}
+	}
+	if decimals, ok := nonCentDecimals[code]; ok {
+		return "", fmt.Errorf("%w: %s has %d decimal places, only currencies with two are supported", ErrUnsupportedCurrency, code, decimals)
+	}
+	return code, nil
+}
@@ -0,0 +1,33 @@
+package Domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrForbidden is returned when the caller may see a record but not change it
+var ErrForbidden = errors.New("forbidden")
+
+// ErrConflict is matched by every ConflictError
+var ErrConflict = errors.New("version conflict")
+
+// ErrPreconditionFailed is matched by a ConflictError raised because the
+// caller's If-Match named a version that is no longer current
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ConflictError reports a write against a version of the record that has since
+// changed. Stale is set when the caller asked for that version explicitly.
+type ConflictError struct {
+	Entity  string
+	ID      string
+	Version int64
+	Stale   bool
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s has changed since version %d", e.Entity, e.ID, e.Version)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict || (e.Stale && target == ErrPreconditionFailed)
+}
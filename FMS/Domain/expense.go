@@ -8,13 +8,38 @@ import (
 
 type Expense struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Version     int64              `bson:"version" json:"version"` // bumped by every write; see ConflictError
 	Title       string             `bson:"title" json:"title"`
 	Description string             `bson:"description,omitempty" json:"description"`
-	Amount      float64            `bson:"amount" json:"amount"`
-	ReceiptURL  string             `bson:"receipt_url,omitempty" json:"receipt_url,omitempty"`
+	Amount      Money              `bson:"amount" json:"amount"`
+	Receipts    []Receipt          `bson:"receipts,omitempty" json:"receipts,omitempty"`
 	BudgetID    primitive.ObjectID `bson:"budget_id,omitempty" json:"budget_id,omitempty"`
-	CreatedBy   string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
-	CreatedAt   time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	DueDate     time.Time          `bson:"due_date,omitempty" json:"due_date"`
-	Status      string             `bson:"status,omitempty" json:"status"`
+	LineItemID  primitive.ObjectID `bson:"line_item_id,omitempty" json:"line_item_id,omitempty"`
+	// CashRequestID links an expense paid out of a cash advance
+	CashRequestID primitive.ObjectID `bson:"cash_request_id,omitempty" json:"cash_request_id,omitempty"`
+	CreatedBy     string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	DueDate       time.Time          `bson:"due_date,omitempty" json:"due_date"`
+	Status        string             `bson:"status,omitempty" json:"status"`
+	// BaseAmount is Amount in the base currency, see Budget
+	Currency     string             `bson:"currency,omitempty" json:"currency"`
+	ExchangeRate float64            `bson:"exchange_rate,omitempty" json:"exchange_rate,omitempty"`
+	BaseAmount   Money              `bson:"base_amount,omitempty" json:"base_amount"`
+	History      []StatusTransition `bson:"history,omitempty" json:"history,omitempty"`
+	// RejectionComment is finance's reason for rejecting the expense
+	RejectionComment string `bson:"rejection_comment,omitempty" json:"rejection_comment,omitempty"`
+}
+
+// Receipt is a file attached to an expense. Uploaded files are stored in blob
+// storage under StorageKey; receipts hosted elsewhere only carry a URL.
+type Receipt struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Filename    string             `bson:"filename,omitempty" json:"filename,omitempty"`
+	ContentType string             `bson:"content_type,omitempty" json:"content_type,omitempty"`
+	Size        int64              `bson:"size,omitempty" json:"size,omitempty"`
+	SHA256      string             `bson:"sha256,omitempty" json:"sha256,omitempty"`
+	StorageKey  string             `bson:"storage_key,omitempty" json:"-"`
+	URL         string             `bson:"url,omitempty" json:"url,omitempty"`
+	UploadedBy  string             `bson:"uploaded_by,omitempty" json:"uploaded_by,omitempty"`
+	UploadedAt  time.Time          `bson:"uploaded_at" json:"uploaded_at"`
 }
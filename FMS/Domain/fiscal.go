@@ -0,0 +1,127 @@
+package Domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fiscal period types
+const (
+	PeriodYear    = "year"
+	PeriodQuarter = "quarter"
+)
+
+// fiscal period statuses
+const (
+	PeriodOpen   = "open"
+	PeriodClosed = "closed"
+)
+
+// template recurrences; each matches one period type
+const (
+	RecurYearly    = "yearly"
+	RecurQuarterly = "quarterly"
+)
+
+// ErrPeriodClosed is returned when recording spend against a closed fiscal period
+var ErrPeriodClosed = errors.New("fiscal period is closed")
+
+// ErrPeriodInUse is returned when closing a period whose budgets still have
+// pending expenses or cash advances that are not settled
+var ErrPeriodInUse = errors.New("fiscal period has open expenses or cash requests")
+
+// FiscalPeriod is a fiscal year or one of its four quarters. EndDate is
+// exclusive, so a quarter ends where the next one starts.
+type FiscalPeriod struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type      string             `bson:"type" json:"type"`
+	Name      string             `bson:"name" json:"name"`
+	Year      int                `bson:"year" json:"year"`
+	Quarter   int                `bson:"quarter,omitempty" json:"quarter,omitempty"`
+	YearID    primitive.ObjectID `bson:"year_id,omitempty" json:"year_id,omitempty"`
+	StartDate time.Time          `bson:"start_date" json:"start_date"`
+	EndDate   time.Time          `bson:"end_date" json:"end_date"`
+	Status    string             `bson:"status" json:"status"`
+	ClosedBy  string             `bson:"closed_by,omitempty" json:"closed_by,omitempty"`
+	ClosedAt  time.Time          `bson:"closed_at,omitempty" json:"closed_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// NewFiscalYear lays out fiscal year year starting at start, with its quarters
+// three months apart. Quarters are linked to the year once it has an ID.
+func NewFiscalYear(year int, start time.Time) (FiscalPeriod, []FiscalPeriod) {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	now := time.Now().UTC()
+	fy := FiscalPeriod{
+		Type:      PeriodYear,
+		Name:      fmt.Sprintf("FY%d", year),
+		Year:      year,
+		StartDate: start,
+		EndDate:   start.AddDate(1, 0, 0),
+		Status:    PeriodOpen,
+		CreatedAt: now,
+	}
+	quarters := make([]FiscalPeriod, 4)
+	for i := range quarters {
+		quarters[i] = FiscalPeriod{
+			Type:      PeriodQuarter,
+			Name:      fmt.Sprintf("FY%d-Q%d", year, i+1),
+			Year:      year,
+			Quarter:   i + 1,
+			StartDate: start.AddDate(0, 3*i, 0),
+			EndDate:   start.AddDate(0, 3*(i+1), 0),
+			Status:    PeriodOpen,
+			CreatedAt: now,
+		}
+	}
+	return fy, quarters
+}
+
+// Recurrence is the template recurrence that creates budgets for this period
+func (p FiscalPeriod) Recurrence() string {
+	if p.Type == PeriodQuarter {
+		return RecurQuarterly
+	}
+	return RecurYearly
+}
+
+// BudgetTemplate describes a budget that recurs every fiscal year or quarter
+type BudgetTemplate struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Title       string             `bson:"title" json:"title"`
+	Description string             `bson:"description,omitempty" json:"description"`
+	Department  string             `bson:"department,omitempty" json:"department,omitempty"`
+	Amount      Money              `bson:"amount" json:"amount"`
+	Currency    string             `bson:"currency,omitempty" json:"currency"`
+	Recurrence  string             `bson:"recurrence" json:"recurrence"`
+	Active      bool               `bson:"active" json:"active"`
+	CreatedBy   string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// CarryForward is unspent money moved from a closed period's budget into the
+// same template's budget for the next period
+type CarryForward struct {
+	FromBudgetID primitive.ObjectID `json:"from_budget_id"`
+	ToBudgetID   primitive.ObjectID `json:"to_budget_id"`
+	Amount       Money              `json:"amount"`
+	Currency     string             `json:"currency"`
+}
+
+// CarrySkip is a budget whose remaining amount stayed in the closed period
+type CarrySkip struct {
+	BudgetID  primitive.ObjectID `json:"budget_id"`
+	Remaining Money              `json:"remaining"`
+	Reason    string             `json:"reason"`
+}
+
+// PeriodClose reports the outcome of closing a fiscal period
+type PeriodClose struct {
+	Period       FiscalPeriod       `json:"period"`
+	NextPeriodID primitive.ObjectID `json:"next_period_id,omitempty"`
+	Carried      []CarryForward     `json:"carried"`
+	Skipped      []CarrySkip        `json:"skipped"`
+}
@@ -0,0 +1,68 @@
+package Domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ledger entry sides
+const (
+	LedgerDebit  = "debit"
+	LedgerCredit = "credit"
+)
+
+// events that move money
+const (
+	EventBudgetApproval      = "budget_approval"
+	EventCashDisbursement    = "cash_request_disbursement"
+	EventExpenseVerification = "expense_verification"
+	EventCarryForward        = "carry_forward"
+	EventCashReturn          = "cash_return"
+	EventCashTopUp           = "cash_top_up"
+)
+
+// counter accounts used opposite a budget account
+const (
+	AccountAllocations  = "allocations"
+	AccountCashAdvances = "cash_advances"
+	AccountExpenses     = "expenses"
+)
+
+// LedgerEntry is one immutable side of a double-entry journal transaction.
+// Every transaction has one debit and one credit line of the same amount.
+type LedgerEntry struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TransactionID primitive.ObjectID `bson:"transaction_id" json:"transaction_id"`
+	Account       string             `bson:"account" json:"account"`
+	BudgetID      primitive.ObjectID `bson:"budget_id,omitempty" json:"budget_id,omitempty"`
+	Type          string             `bson:"type" json:"type"` // "debit" or "credit"
+	Amount        Money              `bson:"amount" json:"amount"`
+	Currency      string             `bson:"currency,omitempty" json:"currency,omitempty"` // the budget's currency
+	Event         string             `bson:"event" json:"event"`
+	ReferenceID   string             `bson:"reference_id,omitempty" json:"reference_id,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// LedgerFilter narrows ledger queries; zero values are ignored
+type LedgerFilter struct {
+	BudgetID string
+	From     time.Time
+	To       time.Time
+}
+
+// LedgerConsistency is the result of checking one budget against its ledger
+type LedgerConsistency struct {
+	BudgetID   string `json:"budget_id"`
+	Title      string `json:"title"`
+	Amount     Money  `json:"amount"`
+	Remaining  Money  `json:"remaining"`
+	Debits     Money  `json:"debits"`
+	Expected   Money  `json:"expected"`
+	Consistent bool   `json:"consistent"`
+}
+
+// BudgetAccount returns the ledger account name of a budget
+func BudgetAccount(budgetID primitive.ObjectID) string {
+	return "budget:" + budgetID.Hex()
+}
@@ -0,0 +1,53 @@
+package Domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTooManyAttempts is matched by every ThrottleError
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// ErrWeakPassword is returned for a password the password policy rejects
+var ErrWeakPassword = errors.New("password does not meet the password policy")
+
+// LoginAttempts counts the recent failed logins for one username or client
+// address, kept under Key as "user:<name>" or "ip:<address>"
+type LoginAttempts struct {
+	Key         string    `bson:"_id"`
+	Failures    int       `bson:"failures"`
+	LastFailure time.Time `bson:"last_failure"`
+	LockedUntil time.Time `bson:"locked_until,omitempty"`
+}
+
+// ThrottleError refuses a login attempt that came before RetryAfter had
+// passed. Locked is set for a lockout rather than a delay.
+type ThrottleError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *ThrottleError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed login attempts, locked for %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottleError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// PasswordPolicyError says which rule of the password policy a password broke
+type PasswordPolicyError struct {
+	Reason string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password " + e.Reason
+}
+
+func (e *PasswordPolicyError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
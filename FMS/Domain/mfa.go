@@ -0,0 +1,33 @@
+package Domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidMFACode is returned for a wrong or reused two-factor code and for
+// an unknown or expired login challenge
+var ErrInvalidMFACode = errors.New("invalid two-factor code")
+
+// MFAChallenge is the pending second step of a login. Only the hash of the
+// challenge token handed to the client is stored.
+type MFAChallenge struct {
+	Hash      string    `bson:"hash"`
+	ExpiresAt time.Time `bson:"expires_at"`
+	Attempts  int       `bson:"attempts"`
+}
+
+// TOTPSetup is what a user scans into an authenticator app. The secret is also
+// given for manual entry.
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"provisioning_uri"`
+}
+
+// LoginResult is a token pair or, for a user with two-factor authentication,
+// a challenge to answer with a code at POST /login/2fa
+type LoginResult struct {
+	*TokenPair
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
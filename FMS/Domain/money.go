@@ -0,0 +1,100 @@
+package Domain
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MinorUnits is the number of minor units (cents) in one major unit of every
+// supported currency; NormalizeCurrency rejects the others
+const MinorUnits = 100
+
+var ErrInvalidMoney = errors.New("amount must be a decimal number with at most two decimal places")
+
+// Money is an exact amount in minor units. It is stored in Mongo as an integer
+// and written to JSON as a decimal number such as 12.50; JSON input may be a
+// number or a string.
+type Money int64
+
+// NewMoney builds an amount from its major and minor parts, e.g. NewMoney(12, 50) is 12.50
+func NewMoney(major, minor int64) Money {
+	return Money(major*MinorUnits + minor)
+}
+
+// ParseMoney reads a decimal string exactly, without going through float64
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	// big.Rat also reads fractions and hex, which are not amounts
+	if s == "" || strings.ContainsAny(s, "/xXpP_") {
+		return 0, ErrInvalidMoney
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, ErrInvalidMoney
+	}
+	r.Mul(r, big.NewRat(MinorUnits, 1))
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return 0, ErrInvalidMoney
+	}
+	return Money(r.Num().Int64()), nil
+}
+
+// Minor returns the amount in minor units
+func (m Money) Minor() int64 {
+	return int64(m)
+}
+
+// Float is the amount in major units, for ratios and display only
+func (m Money) Float() float64 {
+	return float64(m) / MinorUnits
+}
+
+// MulRate multiplies by a rate such as an exchange rate, rounding half away
+// from zero to the nearest minor unit
+func (m Money) MulRate(rate float64) Money {
+	return Money(math.Round(float64(m) * rate))
+}
+
+// String renders the amount with two decimals and no separators, e.g. -1234.05
+func (m Money) String() string {
+	v := int64(m)
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	frac := strconv.FormatInt(v%MinorUnits, 10)
+	if len(frac) < 2 {
+		frac = "0" + frac
+	}
+	return sign + strconv.FormatInt(v/MinorUnits, 10) + "." + frac
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return ErrInvalidMoney
+		}
+		s = unquoted
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
@@ -0,0 +1,56 @@
+package Domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// notification kinds
+const (
+	NotifyBudgetThreshold = "budget_threshold"
+	NotifyPendingApproval = "pending_approval"
+	NotifyRejected        = "rejected"
+)
+
+// notification channels
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// NotificationKinds lists every kind a user can set preferences for
+var NotificationKinds = []string{NotifyBudgetThreshold, NotifyPendingApproval, NotifyRejected}
+
+// Notification is one message to one user. Every notification is kept in the
+// user's in-app inbox when that channel is enabled for its kind.
+type Notification struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	Kind       string             `bson:"kind" json:"kind"`
+	Subject    string             `bson:"subject" json:"subject"`
+	Body       string             `bson:"body" json:"body"`
+	EntityType string             `bson:"entity_type,omitempty" json:"entity_type,omitempty"`
+	EntityID   primitive.ObjectID `bson:"entity_id,omitempty" json:"entity_id,omitempty"`
+	Read       bool               `bson:"read" json:"read"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// NotificationPreferences chooses the channels each kind is sent on. Kinds
+// missing from Channels go to the in-app inbox only; an empty list mutes the kind.
+type NotificationPreferences struct {
+	UserID     string              `bson:"_id" json:"user_id"`
+	Email      string              `bson:"email,omitempty" json:"email,omitempty"`
+	WebhookURL string              `bson:"webhook_url,omitempty" json:"webhook_url,omitempty"`
+	Channels   map[string][]string `bson:"channels,omitempty" json:"channels,omitempty"`
+	UpdatedAt  time.Time           `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// ChannelsFor returns the channels kind is delivered on
+func (p *NotificationPreferences) ChannelsFor(kind string) []string {
+	if channels, ok := p.Channels[kind]; ok {
+		return channels
+	}
+	return []string{ChannelInApp}
+}
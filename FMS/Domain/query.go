@@ -0,0 +1,63 @@
+package Domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sort fields accepted by the budget, cash request and expense lists
+const (
+	SortCreatedAt = "created_at"
+	SortAmount    = "amount"
+	SortDueDate   = "due_date"
+	SortTitle     = "title"
+	SortStatus    = "status"
+)
+
+// ErrInvalidQuery is matched by every error about a malformed ListQuery
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ListQuery pages, sorts and filters the budget, cash request and expense
+// lists; zero values match everything. Paging is by Page, or by Cursor when it
+// is set: a cursor continues after the last record of the previous page and
+// stays stable while records are added.
+type ListQuery struct {
+	Page   int
+	Limit  int
+	Cursor string
+	// Sort is one of the Sort* fields; Desc orders it newest or largest first
+	Sort string
+	Desc bool
+
+	Status    string
+	From      time.Time // created_at bounds, inclusive
+	To        time.Time
+	MinAmount Money // in each record's own currency
+	MaxAmount Money
+	BudgetID  primitive.ObjectID
+	Requester string // user ID of whoever created the record
+
+	// Scope limits the list to what a non-finance caller may see; it is set by
+	// the usecases, never from query parameters
+	Scope *ListScope
+}
+
+// ListScope matches records owned by OwnerID, or belonging to Department or to
+// one of BudgetIDs
+type ListScope struct {
+	OwnerID    string
+	Department string
+	BudgetIDs  []primitive.ObjectID
+}
+
+// Pagination describes the page a list response holds. NextCursor continues
+// the list and is empty on the last page.
+type Pagination struct {
+	Total      int64  `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -12,3 +12,68 @@ type Report struct {
 	DueDate     time.Time          `bson:"due_date,omitempty" json:"due_date"`
 	Status      string             `bson:"status,omitempty" json:"status"`
 }
+
+// ReportFilter restricts aggregate reports to records created in a date range
+type ReportFilter struct {
+	From time.Time
+	To   time.Time
+}
+
+// GroupTotal is a count and amount total for one group key (status, department, month, requester,
+// currency). Total is in the base currency, except per currency, where it adds up the original amounts
+// and BaseTotal adds them up converted to the base currency.
+type GroupTotal struct {
+	Key       string `bson:"_id" json:"key"`
+	Count     int64  `bson:"count" json:"count"`
+	Total     Money  `bson:"total" json:"total"`
+	BaseTotal Money  `bson:"base_total,omitempty" json:"base_total,omitempty"`
+}
+
+// BudgetUtilization compares spend against allocation for one approved budget, in the budget's currency
+type BudgetUtilization struct {
+	BudgetID    primitive.ObjectID `bson:"_id" json:"budget_id"`
+	Title       string             `bson:"title" json:"title"`
+	Department  string             `bson:"department" json:"department"`
+	Currency    string             `bson:"currency,omitempty" json:"currency,omitempty"`
+	Amount      Money              `bson:"amount" json:"amount"`
+	Remaining   Money              `bson:"remaining" json:"remaining"`
+	Spent       Money              `bson:"spent" json:"spent"`
+	Utilization float64            `bson:"utilization" json:"utilization"` // spent / amount
+}
+
+// BudgetReport totals are in BaseCurrency; ByCurrency also carries the original totals
+type BudgetReport struct {
+	BaseCurrency   string              `json:"base_currency"`
+	Count          int64               `json:"count"`
+	TotalAmount    Money               `json:"total_amount"`
+	TotalRemaining Money               `json:"total_remaining"`
+	ByCurrency     []GroupTotal        `json:"by_currency"`
+	ByStatus       []GroupTotal        `json:"by_status"`
+	ByDepartment   []GroupTotal        `json:"by_department"`
+	ByMonth        []GroupTotal        `json:"by_month"`
+	Utilization    []BudgetUtilization `json:"utilization"`
+}
+
+// SpendReport aggregates cash requests or expenses; Total is in BaseCurrency
+type SpendReport struct {
+	BaseCurrency  string       `json:"base_currency"`
+	Count         int64        `json:"count"`
+	Total         Money        `json:"total"`
+	ByCurrency    []GroupTotal `json:"by_currency"`
+	ByStatus      []GroupTotal `json:"by_status"`
+	ByDepartment  []GroupTotal `json:"by_department"`
+	ByMonth       []GroupTotal `json:"by_month"`
+	TopRequesters []GroupTotal `json:"top_requesters"`
+}
+
+// ReportOverview totals are in BaseCurrency
+type ReportOverview struct {
+	BaseCurrency       string `json:"base_currency"`
+	BudgetsCount       int64  `json:"budgets_count"`
+	CashRequestsCount  int64  `json:"cash_requests_count"`
+	ExpensesCount      int64  `json:"expenses_count"`
+	TotalAllocated     Money  `json:"total_allocated"`
+	TotalRemaining     Money  `json:"total_remaining"`
+	TotalDisbursed     Money  `json:"total_disbursed"`
+	TotalVerifiedSpend Money  `json:"total_verified_spend"`
+}
@@ -0,0 +1,32 @@
+package Domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SearchQuery is a full-text search over budgets, cash requests and expenses.
+// Types limits it to some of EntityBudget, EntityCashRequest and EntityExpense.
+type SearchQuery struct {
+	Text  string
+	Types []string
+	Limit int
+	// Scopes limit each type to what a non-finance caller may see; set by the
+	// usecase, keyed by entity type, and nil for finance
+	Scopes map[string]*ListScope
+}
+
+// SearchResult is one matching record, tagged with its entity type. Results are
+// ranked by Score, highest first.
+type SearchResult struct {
+	Type        string             `json:"type"`
+	ID          primitive.ObjectID `json:"id"`
+	Title       string             `json:"title"`
+	Description string             `json:"description,omitempty"`
+	Status      string             `json:"status"`
+	Amount      Money              `json:"amount"`
+	Currency    string             `json:"currency,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Score       float64            `json:"score"`
+}
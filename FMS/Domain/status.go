@@ -0,0 +1,103 @@
+package Domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// record statuses shared by budgets, cash requests and expenses
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusDisbursed = "disbursed"
+	StatusVerified  = "verified"
+	StatusSettled   = "settled"
+)
+
+// ErrInvalidTransition is matched by every TransitionError
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// TransitionError reports a status change the entity's state machine does not allow
+type TransitionError struct {
+	Entity string
+	From   string
+	To     string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s cannot move from %q to %q", e.Entity, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// StatusTransition is one entry of a record's status history. From is empty
+// for the transition that created the record.
+type StatusTransition struct {
+	From string    `bson:"from" json:"from"`
+	To   string    `bson:"to" json:"to"`
+	By   string    `bson:"by,omitempty" json:"by,omitempty"`
+	At   time.Time `bson:"at" json:"at"`
+}
+
+// StateMachine lists the statuses each status may move to. The empty status is
+// the state of a record that has not been created yet.
+type StateMachine struct {
+	Entity      string
+	Transitions map[string][]string
+}
+
+// Check returns a *TransitionError unless from may move to to
+func (m StateMachine) Check(from, to string) error {
+	for _, next := range m.Transitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return &TransitionError{Entity: m.Entity, From: from, To: to}
+}
+
+// apply moves *status to to and records the change in history
+func (m StateMachine) apply(status *string, history *[]StatusTransition, to, by string) error {
+	if err := m.Check(*status, to); err != nil {
+		return err
+	}
+	*history = append(*history, StatusTransition{From: *status, To: to, By: by, At: time.Now().UTC()})
+	*status = to
+	return nil
+}
+
+var BudgetStates = StateMachine{Entity: "budget", Transitions: map[string][]string{
+	"":            {StatusPending},
+	StatusPending: {StatusApproved, StatusRejected},
+}}
+
+var CashRequestStates = StateMachine{Entity: "cash request", Transitions: map[string][]string{
+	"":              {StatusPending},
+	StatusPending:   {StatusApproved, StatusRejected},
+	StatusApproved:  {StatusDisbursed},
+	StatusDisbursed: {StatusSettled},
+}}
+
+var ExpenseStates = StateMachine{Entity: "expense", Transitions: map[string][]string{
+	"":            {StatusPending},
+	StatusPending: {StatusVerified, StatusRejected},
+}}
+
+// TransitionTo moves the budget to status on behalf of by
+func (b *Budget) TransitionTo(status, by string) error {
+	return BudgetStates.apply(&b.Status, &b.History, status, by)
+}
+
+// TransitionTo moves the cash request to status on behalf of by
+func (r *CashRequest) TransitionTo(status, by string) error {
+	return CashRequestStates.apply(&r.Status, &r.History, status, by)
+}
+
+// TransitionTo moves the expense to status on behalf of by
+func (e *Expense) TransitionTo(status, by string) error {
+	return ExpenseStates.apply(&e.Status, &e.History, status, by)
+}
@@ -0,0 +1,40 @@
+package Domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidRefreshToken is returned for a refresh token that is unknown,
+// expired or revoked
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// ErrRefreshTokenReused is returned when a refresh token is presented after it
+// was already exchanged. Its family is revoked along with the user's access tokens.
+var ErrRefreshTokenReused = errors.New("refresh token reused, session revoked")
+
+// TokenPair is what login and refresh hand the client. Lifetimes are in seconds.
+type TokenPair struct {
+	AccessToken      string `json:"token"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"`
+}
+
+// RefreshToken is one token of a family started by a login. Only its SHA-256
+// is stored. Every refresh uses the token up and issues the next one of the
+// family, so a used token that comes back has been copied.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	FamilyID  primitive.ObjectID `bson:"family_id"`
+	UserID    string             `bson:"user_id"`
+	Hash      string             `bson:"token_hash"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty"`
+	// MFA is set when the login that started the family passed a second factor
+	MFA bool `bson:"mfa,omitempty"`
+}
@@ -1,14 +1,64 @@
 package Domain
 
 import (
+	"errors"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
+// user roles
+const (
+	RoleStaff   = "staff"
+	RoleFinance = "finance"
+	RoleAdmin   = "admin"
+)
+
+// FinanceDepartment passes FinanceOnly, so it can only be assigned by an admin
+const FinanceDepartment = "finance"
+
+// ErrAccountDeactivated is returned when a deactivated user logs in or uses a token
+var ErrAccountDeactivated = errors.New("account deactivated")
+
 type User struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
-	Username     string             `bson:"username" json:"username"`
-	PasswordHash string             `bson:"password_hash" json:"-"`
-	Role         string             `bson:"role" json:"role"` // "admin" or "user"
-	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Username       string             `bson:"username" json:"username"`
+	PasswordHash   string             `bson:"password_hash" json:"-"`
+	Role           string             `bson:"role" json:"role"` // "staff", "finance" or "admin"
+	Department     string             `bson:"department,omitempty" json:"department,omitempty"`
+	DepartmentHead bool               `bson:"department_head,omitempty" json:"department_head"` // sees the department's budgets and spend
+	Deactivated    bool               `bson:"deactivated,omitempty" json:"deactivated"`
+	TokenVersion   int64              `bson:"token_version,omitempty" json:"-"` // carried by access tokens; raising it revokes every one issued before
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+
+	// TOTPSecret is set from setup on; TOTPEnabled once a code has confirmed it.
+	// TOTPLastStep is the time step of the last code accepted, which cannot be
+	// used again, and RecoveryCodes hold the hashes of the unused recovery codes.
+	TOTPSecret    string        `bson:"totp_secret,omitempty" json:"-"`
+	TOTPEnabled   bool          `bson:"totp_enabled,omitempty" json:"totp_enabled"`
+	TOTPLastStep  int64         `bson:"totp_last_step,omitempty" json:"-"`
+	RecoveryCodes []string      `bson:"recovery_codes,omitempty" json:"-"`
+	MFAChallenge  *MFAChallenge `bson:"mfa_challenge,omitempty" json:"-"`
+}
+
+// ValidRole reports whether role is one of the assignable roles
+func ValidRole(role string) bool {
+	switch role {
+	case RoleStaff, RoleFinance, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// UserQuery pages through users, optionally matching Search against the username
+type UserQuery struct {
+	Search string
+	Page   int
+	Limit  int
+}
+
+type UserPage struct {
+	Users []User `json:"users"`
+	Total int64  `json:"total"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
 }
@@ -0,0 +1,91 @@
+package Domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// webhook event types
+const (
+	EventBudgetCreated        = "budget.created"
+	EventBudgetApproved       = "budget.approved"
+	EventBudgetRejected       = "budget.rejected"
+	EventCashRequestCreated   = "cash_request.created"
+	EventCashRequestApproved  = "cash_request.approved"
+	EventCashRequestRejected  = "cash_request.rejected"
+	EventCashRequestDisbursed = "cash_request.disbursed"
+	EventCashRequestSettled   = "cash_request.settled"
+	EventExpenseCreated       = "expense.created"
+	EventExpenseVerified      = "expense.verified"
+	EventExpenseRejected      = "expense.rejected"
+	EventFiscalPeriodClosed   = "fiscal_period.closed"
+)
+
+// WebhookEvents lists every event type a subscription can name
+var WebhookEvents = []string{
+	EventBudgetCreated, EventBudgetApproved, EventBudgetRejected,
+	EventCashRequestCreated, EventCashRequestApproved, EventCashRequestRejected, EventCashRequestDisbursed, EventCashRequestSettled,
+	EventExpenseCreated, EventExpenseVerified, EventExpenseRejected,
+	EventFiscalPeriodClosed,
+}
+
+// webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed" // gave up after the last retry
+)
+
+// WebhookSubscription sends the listed events to URL. Payloads are signed with
+// Secret, which is only shown when the subscription is created.
+type WebhookSubscription struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL       string             `bson:"url" json:"url"`
+	Events    []string           `bson:"events" json:"events"`
+	Secret    string             `bson:"secret" json:"-"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedBy string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Subscribes reports whether the subscription wants event
+func (s *WebhookSubscription) Subscribes(event string) bool {
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event queued in the outbox for one subscription, with
+// the log of every attempt to deliver it
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `bson:"_id" json:"id"`
+	SubscriptionID primitive.ObjectID `bson:"subscription_id" json:"subscription_id"`
+	Event          string             `bson:"event" json:"event"`
+	Payload        string             `bson:"payload" json:"payload"`
+	Status         string             `bson:"status" json:"status"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastError      string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	Log            []WebhookAttempt   `bson:"log,omitempty" json:"log,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	DeliveredAt    time.Time          `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+}
+
+// WebhookAttempt is one try at a delivery; Error is empty when it succeeded
+type WebhookAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	DurationMS int64     `bson:"duration_ms" json:"duration_ms"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// WebhookDeliveryFilter narrows the delivery log
+type WebhookDeliveryFilter struct {
+	SubscriptionID string
+	Status         string
+	Event          string
+	Limit          int
+}
@@ -7,9 +7,17 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// UserStatusChecker reports whether the account behind a token may still be
+// used and the token version its access tokens must carry
+type UserStatusChecker interface {
+	TokenStatus(userID string) (active bool, tokenVersion int64, err error)
+}
+
 // Auth middleware expects Authorization: Bearer <token>
-// places (username, role, user_id) into gin.Context keys
-func AuthMiddleware(jwtSrv JWTService) gin.HandlerFunc {
+// places (username, role, user_id, mfa) into gin.Context keys
+// and rejects tokens of deactivated users, or issued before the user's token
+// version was raised, when users is set
+func AuthMiddleware(jwtSrv JWTService, users UserStatusChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		h := c.GetHeader("Authorization")
 		if h == "" {
@@ -27,7 +35,7 @@ func AuthMiddleware(jwtSrv JWTService) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token", "detail": err.Error()})
 			return
 		}
-		// expected claims: username, role, sub, department
+		// expected claims: username, role, sub, department, department_head
 		if uname, ok := claims["username"].(string); ok {
 			c.Set("username", uname)
 		}
@@ -40,27 +48,103 @@ func AuthMiddleware(jwtSrv JWTService) gin.HandlerFunc {
 		if dept, ok := claims["department"].(string); ok {
 			c.Set("department", dept)
 		}
+		if head, ok := claims["department_head"].(bool); ok {
+			c.Set("department_head", head)
+		}
+		// tokens issued before two-factor authentication existed carry no mfa claim
+		mfa, _ := claims["mfa"].(bool)
+		c.Set("mfa", mfa)
+		if users != nil {
+			active, version, err := users.TokenStatus(c.GetString("user_id"))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token", "detail": err.Error()})
+				return
+			}
+			if !active {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "account deactivated"})
+				return
+			}
+			// tokens from before versions existed carry none and count as version 0
+			tv, _ := claims["tv"].(float64)
+			if int64(tv) != version {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+				return
+			}
+		}
 		c.Next()
 	}
 }
 
-// Financial middleware - allows either Finance role OR department == "finance"
-func FinanceOnly() gin.HandlerFunc {
+// RequireRole allows only callers whose role is one of roles, and only with
+// two-factor authentication on since every such role is a privileged one
+func RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// allow when role == "finance"
-		if rv, ok := c.Get("role"); ok {
-			if role, ok2 := rv.(string); ok2 && strings.ToLower(role) == "finance" {
-				c.Next()
+		role := strings.ToLower(c.GetString("role"))
+		for _, r := range roles {
+			if role == r {
+				if mfaPassed(c) {
+					c.Next()
+				}
 				return
 			}
 		}
-		// allow when department == "finance"
-		if dv, ok := c.Get("department"); ok {
-			if dept, ok2 := dv.(string); ok2 && strings.ToLower(dept) == "finance" {
-				c.Next()
-				return
-			}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": strings.Join(roles, " or ") + " role required"})
+	}
+}
+
+// AdminOnly allows only the admin role, and only once the admin has
+// two-factor authentication on
+func AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.ToLower(c.GetString("role")) != "admin" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+			return
+		}
+		if !mfaPassed(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// Financial middleware - allows either Finance role OR department == "finance",
+// and only with two-factor authentication on since these routes move money
+func FinanceOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsFinance(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "finance role or department required"})
+			return
+		}
+		if !mfaPassed(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// mfaPassed aborts with 403 unless the caller's token was issued to a user
+// with two-factor authentication enabled
+func mfaPassed(c *gin.Context) bool {
+	if c.GetBool("mfa") {
+		return true
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "two-factor authentication required", "enroll": "/users/me/2fa/setup"})
+	return false
+}
+
+// IsFinance reports whether the caller would pass FinanceOnly
+func IsFinance(c *gin.Context) bool {
+	// allow when role == "finance"
+	if rv, ok := c.Get("role"); ok {
+		if role, ok2 := rv.(string); ok2 && strings.ToLower(role) == "finance" {
+			return true
+		}
+	}
+	// allow when department == "finance"
+	if dv, ok := c.Get("department"); ok {
+		if dept, ok2 := dv.(string); ok2 && strings.ToLower(dept) == "finance" {
+			return true
 		}
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "finance role or department required"})
 	}
+	return false
 }
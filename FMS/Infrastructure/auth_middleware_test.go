@@ -0,0 +1,36 @@
+package Infrastructure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireRole_NeedsTwoFactor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, c := range []struct {
+		role string
+		mfa  bool
+		want int
+	}{
+		{"finance", true, http.StatusOK},
+		{"admin", true, http.StatusOK},
+		{"finance", false, http.StatusForbidden},
+		{"admin", false, http.StatusForbidden},
+		{"staff", true, http.StatusForbidden},
+	} {
+		r := gin.New()
+		r.GET("/", func(ctx *gin.Context) {
+			ctx.Set("role", c.role)
+			ctx.Set("mfa", c.mfa)
+		}, RequireRole("admin", "finance"), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != c.want {
+			t.Fatalf("role %s with mfa=%v: got %d, want %d", c.role, c.mfa, w.Code, c.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package Infrastructure
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrBlobNotFound is returned by Open when no blob is stored under the key
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStorage stores opaque files under slash separated keys, e.g. "receipts/<sha256>"
+type BlobStorage interface {
+	Put(key, contentType string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Exists(key string) (bool, error)
+	Delete(key string) error
+}
+
+// NewBlobStorage picks the backend from RECEIPT_STORAGE ("local" or "gridfs")
+func NewBlobStorage(db *mongo.Database) (BlobStorage, error) {
+	switch GetEnv("RECEIPT_STORAGE", "local") {
+	case "gridfs":
+		return NewGridFSBlobStorage(db, "receipts")
+	case "local":
+		return NewLocalBlobStorage(GetEnv("RECEIPT_DIR", "data/receipts"))
+	default:
+		return nil, errors.New("RECEIPT_STORAGE must be local or gridfs")
+	}
+}
+
+type localBlobStorage struct {
+	root string
+}
+
+func NewLocalBlobStorage(root string) (BlobStorage, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &localBlobStorage{root: root}, nil
+}
+
+func (s *localBlobStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.New("invalid blob key")
+	}
+	return filepath.Join(s.root, clean), nil
+}
+
+// Put writes to a temp file first so readers never see a partial blob
+func (s *localBlobStorage) Put(key, contentType string, r io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *localBlobStorage) Open(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return f, err
+}
+
+func (s *localBlobStorage) Exists(key string) (bool, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *localBlobStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// gridFSBlobStorage uses the blob key as the GridFS file id
+type gridFSBlobStorage struct {
+	bucket *gridfs.Bucket
+}
+
+func NewGridFSBlobStorage(db *mongo.Database, name string) (BlobStorage, error) {
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(name))
+	if err != nil {
+		return nil, err
+	}
+	return &gridFSBlobStorage{bucket: bucket}, nil
+}
+
+func (s *gridFSBlobStorage) Put(key, contentType string, r io.Reader) error {
+	opts := options.GridFSUpload().SetMetadata(bson.M{"content_type": contentType})
+	return s.bucket.UploadFromStreamWithID(key, key, r, opts)
+}
+
+func (s *gridFSBlobStorage) Open(key string) (io.ReadCloser, error) {
+	stream, err := s.bucket.OpenDownloadStream(key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (s *gridFSBlobStorage) Exists(key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	n, err := s.bucket.GetFilesCollection().CountDocuments(ctx, bson.M{"_id": key})
+	return n > 0, err
+}
+
+func (s *gridFSBlobStorage) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.bucket.DeleteContext(ctx, key); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
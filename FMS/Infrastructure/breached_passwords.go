@@ -0,0 +1,67 @@
+package Infrastructure
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BreachedPasswords tells whether a password is known from a breach
+type BreachedPasswords interface {
+	Contains(password string) bool
+}
+
+type breachedPasswordList struct {
+	hashes map[[sha1.Size]byte]struct{}
+}
+
+// LoadBreachedPasswords reads a local list with one entry per line: the
+// password itself or, as in the Have I Been Pwned downloads, its SHA-1 in hex
+// followed by an optional ":count". Blank lines and lines starting with # are
+// skipped. Only hashes are kept in memory.
+func LoadBreachedPasswords(path string) (BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := &breachedPasswordList{hashes: map[[sha1.Size]byte]struct{}{}}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimRight(scanner.Text(), "\r")
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		if sum, ok := parseSHA1(entry); ok {
+			list.hashes[sum] = struct{}{}
+			continue
+		}
+		list.hashes[sha1.Sum([]byte(entry))] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return list, nil
+}
+
+// parseSHA1 reads "<40 hex digits>" or "<40 hex digits>:<count>"
+func parseSHA1(entry string) ([sha1.Size]byte, bool) {
+	var sum [sha1.Size]byte
+	digest, _, _ := strings.Cut(entry, ":")
+	if len(digest) != 2*sha1.Size {
+		return sum, false
+	}
+	if _, err := hex.Decode(sum[:], []byte(digest)); err != nil {
+		return sum, false
+	}
+	return sum, true
+}
+
+func (l *breachedPasswordList) Contains(password string) bool {
+	_, ok := l.hashes[sha1.Sum([]byte(password))]
+	return ok
+}
@@ -0,0 +1,67 @@
+package Infrastructure
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// rows written between flushes of the underlying writer
+const csvFlushEvery = 500
+
+// a spreadsheet opening the file evaluates text starting with one of these as
+// a formula, so such text is prefixed with a quote
+const csvFormulaStart = "=+-@\t\r"
+
+type csvExporter struct {
+	w     *csv.Writer
+	rows  int
+	kinds []ColumnKind
+	cells []string
+}
+
+func NewCSVExporter(w io.Writer) Exporter {
+	return &csvExporter{w: csv.NewWriter(w)}
+}
+
+func (e *csvExporter) ContentType() string { return "text/csv" }
+func (e *csvExporter) Extension() string   { return "csv" }
+
+func (e *csvExporter) WriteHeader(columns []Column) error {
+	e.kinds = make([]ColumnKind, len(columns))
+	for i, c := range columns {
+		e.kinds[i] = c.Kind
+	}
+	return e.write(columnNames(columns))
+}
+
+// WriteRow neutralises text that a spreadsheet would run as a formula. Amount
+// and count columns are left alone: the report writes those itself, and a
+// negative amount must stay a number.
+func (e *csvExporter) WriteRow(values []string) error {
+	e.cells = e.cells[:0]
+	for i, v := range values {
+		if v != "" && strings.IndexByte(csvFormulaStart, v[0]) >= 0 && (i >= len(e.kinds) || e.kinds[i] == ColumnText) {
+			v = "'" + v
+		}
+		e.cells = append(e.cells, v)
+	}
+	return e.write(e.cells)
+}
+
+func (e *csvExporter) write(record []string) error {
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%csvFlushEvery == 0 {
+		e.w.Flush()
+		return e.w.Error()
+	}
+	return nil
+}
+
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,42 @@
+package Infrastructure
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestCSVExporter_NeutralisesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	exp := NewCSVExporter(&buf)
+	_ = exp.WriteHeader([]Column{{Name: "Title"}, {Name: "Created By"}, {Name: "Amount", Kind: ColumnAmount}, {Name: "Count", Kind: ColumnCount}})
+	_ = exp.WriteRow([]string{`=HYPERLINK("http://evil.example","x")`, "@SUM(A1)", "-1,250.00", "-3"})
+	_ = exp.WriteRow([]string{"+1 trip", "\tpadded", "0.50", ""})
+	_ = exp.WriteRow([]string{"-minus", "\rreturn", "12.00", "4"})
+	_ = exp.WriteRow([]string{"Plain = fine", "", "1.00", "1"})
+	if err := exp.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("unreadable CSV: %v", err)
+	}
+	want := [][]string{
+		{"Title", "Created By", "Amount", "Count"},
+		{`'=HYPERLINK("http://evil.example","x")`, "'@SUM(A1)", "-1,250.00", "-3"},
+		{"'+1 trip", "'\tpadded", "0.50", ""},
+		{"'-minus", "'\rreturn", "12.00", "4"},
+		{"Plain = fine", "", "1.00", "1"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("expected %d records, got %q", len(want), records)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("record %d column %d: got %q, want %q", i, j, records[i][j], want[i][j])
+			}
+		}
+	}
+}
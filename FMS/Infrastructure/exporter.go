@@ -0,0 +1,106 @@
+package Infrastructure
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ColumnKind says what a report column holds, so formats can write amounts
+// and counts as numbers
+type ColumnKind int
+
+const (
+	ColumnText ColumnKind = iota
+	// ColumnAmount values are amounts as FormatCurrency renders them
+	ColumnAmount
+	// ColumnCount values are whole numbers
+	ColumnCount
+)
+
+// Column is one column of a report as its definition declares it
+type Column struct {
+	Name string
+	Kind ColumnKind
+}
+
+func columnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// Exporter writes tabular report data to a file format row by row so that
+// large result sets never have to be held in memory
+type Exporter interface {
+	ContentType() string
+	Extension() string
+	// WriteHeader names the columns; their kinds apply to every row that follows
+	WriteHeader(columns []Column) error
+	WriteRow(values []string) error
+	// Close flushes any buffered output and writes the format trailer
+	Close() error
+}
+
+// ExporterFactory builds an exporter writing to w
+type ExporterFactory func(w io.Writer) Exporter
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]ExporterFactory{
+		"csv":  NewCSVExporter,
+		"xlsx": NewXLSXExporter,
+		"pdf":  NewPDFExporter,
+	}
+)
+
+// RegisterExporter makes an export format available to NewExporter
+func RegisterExporter(format string, factory ExporterFactory) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exporters[strings.ToLower(format)] = factory
+}
+
+// NewExporter returns the exporter registered for format
+func NewExporter(format string, w io.Writer) (Exporter, error) {
+	exportersMu.RLock()
+	factory, ok := exporters[strings.ToLower(format)]
+	exportersMu.RUnlock()
+	if !ok {
+		return nil, errors.New("unsupported export format: " + format)
+	}
+	return factory(w), nil
+}
+
+// FormatCurrency renders an amount given in minor units with two decimals and
+// thousands separators, e.g. 123450 as 1,234.50
+func FormatCurrency(minor int64) string {
+	neg := minor < 0
+	cents := minor
+	if neg {
+		cents = -cents
+	}
+	whole := strconv.FormatInt(cents/100, 10)
+
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	for i, ch := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(ch)
+	}
+	frac := cents % 100
+	b.WriteByte('.')
+	if frac < 10 {
+		b.WriteByte('0')
+	}
+	b.WriteString(strconv.FormatInt(frac, 10))
+	return b.String()
+}
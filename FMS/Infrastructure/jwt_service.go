@@ -2,49 +2,121 @@ package Infrastructure
 
 import (
 	"errors"
-	"os"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenClaims are the user attributes embedded in an access token
+type TokenClaims struct {
+	UserID         string
+	Username       string
+	Role           string
+	Department     string
+	DepartmentHead bool
+	// TokenVersion is the user's token version when the token was issued
+	TokenVersion int64
+	// MFA is set when the user has two-factor authentication enabled
+	MFA bool
+}
+
 type JWTService interface {
-	Generate(userID, username, role string, ttl time.Duration) (string, error)
+	Generate(claims TokenClaims, ttl time.Duration) (string, error)
 	Validate(tokenStr string) (*jwt.RegisteredClaims, map[string]interface{}, error)
+	// JWKS lists the public keys tokens may be signed with; it is empty for a shared secret
+	JWKS() JWKSet
 }
 
+// jwtService signs with the newest active key of keys, or with secret (HS256)
+// when no key set is configured
 type jwtService struct {
-	secret string
+	keys   []SigningKey
+	secret []byte
 }
 
 func GetJWTSecret() string {
 	return GetEnv("JWT_SECRET", "")
 }
 
-func NewJWTService() JWTService {
+// NewJWTService signs with the key set in the JWT_KEYS_FILE manifest or, for
+// deployments without one, HS256 with JWT_SECRET. It fails when neither is set
+// so a server never signs with an empty key.
+func NewJWTService() (JWTService, error) {
+	if path := GetEnv("JWT_KEYS_FILE", ""); path != "" {
+		keys, err := LoadSigningKeys(path)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_KEYS_FILE: %w", err)
+		}
+		return NewKeySetJWTService(keys, time.Now())
+	}
 	secret := GetJWTSecret()
-	return &jwtService{secret: secret}
+	if secret == "" {
+		return nil, errors.New("no token signing key configured: set JWT_KEYS_FILE or JWT_SECRET")
+	}
+	return &jwtService{secret: []byte(secret)}, nil
+}
+
+// NewKeySetJWTService signs with keys. Kids must be unique and one key must be
+// able to sign at now.
+func NewKeySetJWTService(keys []SigningKey, now time.Time) (JWTService, error) {
+	seen := map[string]bool{}
+	for i := range keys {
+		if err := checkKey(&keys[i]); err != nil {
+			return nil, err
+		}
+		if seen[keys[i].Kid] {
+			return nil, fmt.Errorf("duplicate signing key %q", keys[i].Kid)
+		}
+		seen[keys[i].Kid] = true
+	}
+	j := &jwtService{keys: keys}
+	if j.signingKey(now) == nil {
+		return nil, errors.New("no signing key is active now")
+	}
+	return j, nil
 }
 
-func (j *jwtService) Generate(userID, username, role string, ttl time.Duration) (string, error) {
-	if j.secret == "" {
-		j.secret = os.Getenv("JWT_SECRET")
+// signingKey is the key with the latest NotBefore among those that may sign at now
+func (j *jwtService) signingKey(now time.Time) *SigningKey {
+	var current *SigningKey
+	for i := range j.keys {
+		k := &j.keys[i]
+		if k.signs(now) && (current == nil || k.NotBefore.After(current.NotBefore)) {
+			current = k
+		}
 	}
+	return current
+}
+
+func (j *jwtService) Generate(tc TokenClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"sub":      userID,
-		"username": username,
-		"role":     role,
-		"exp":      time.Now().Add(ttl).Unix(),
-		"iat":      time.Now().Unix(),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secret))
+		"sub":             tc.UserID,
+		"username":        tc.Username,
+		"role":            tc.Role,
+		"department":      tc.Department,
+		"department_head": tc.DepartmentHead,
+		"tv":              tc.TokenVersion,
+		"mfa":             tc.MFA,
+		"exp":             now.Add(ttl).Unix(),
+		"iat":             now.Unix(),
+	}
+	if j.secret != nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(j.secret)
+	}
+
+	k := j.signingKey(now)
+	if k == nil {
+		return "", errors.New("no signing key is active")
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.Alg), claims)
+	token.Header["kid"] = k.Kid
+	return token.SignedString(k.Private)
 }
 
 func (j *jwtService) Validate(tokenStr string) (*jwt.RegisteredClaims, map[string]interface{}, error) {
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		return []byte(j.secret), nil
-	})
+	token, err := jwt.Parse(tokenStr, j.verificationKey, jwt.WithValidMethods(j.methods()))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -54,11 +126,55 @@ func (j *jwtService) Validate(tokenStr string) (*jwt.RegisteredClaims, map[strin
 	claimsMap := map[string]interface{}{}
 	if mc, ok := token.Claims.(jwt.MapClaims); ok {
 		for k, v := range mc {
-			if k == "exp" || k == "iat" || k == "sub" {
-			}
 			claimsMap[k] = v
 		}
 	}
 	// note: we return nil RegisteredClaims because parsing into RegisteredClaims would require re-parsing
 	return nil, claimsMap, nil
 }
+
+// verificationKey picks the key named by the token's kid. The token's alg must
+// be the one that key was configured with, so a token cannot choose how it is
+// checked.
+func (j *jwtService) verificationKey(t *jwt.Token) (interface{}, error) {
+	if j.secret != nil {
+		return j.secret, nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	now := time.Now()
+	for i := range j.keys {
+		k := &j.keys[i]
+		if k.Kid != kid || k.retired(now) {
+			continue
+		}
+		if t.Method.Alg() != k.Alg {
+			return nil, fmt.Errorf("key %q does not sign with %s", kid, t.Method.Alg())
+		}
+		return k.Private.Public(), nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (j *jwtService) methods() []string {
+	if j.secret != nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+	var algs []string
+	for _, k := range j.keys {
+		algs = append(algs, k.Alg)
+	}
+	return algs
+}
+
+// JWKS publishes every key that has not retired, including keys scheduled to
+// sign later, so verifiers know them before the first token arrives
+func (j *jwtService) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	now := time.Now()
+	for i := range j.keys {
+		if !j.keys[i].retired(now) {
+			set.Keys = append(set.Keys, j.keys[i].jwk())
+		}
+	}
+	return set
+}
@@ -0,0 +1,225 @@
+package Infrastructure
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testRSAKey *rsa.PrivateKey
+
+func rsaKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	if testRSAKey == nil {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("generate RSA key: %v", err)
+		}
+		testRSAKey = key
+	}
+	return testRSAKey
+}
+
+func edKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate Ed25519 key: %v", err)
+	}
+	return key
+}
+
+func newKeySetService(t *testing.T, keys ...SigningKey) JWTService {
+	t.Helper()
+	svc, err := NewKeySetJWTService(keys, time.Now())
+	if err != nil {
+		t.Fatalf("key set rejected: %v", err)
+	}
+	return svc
+}
+
+// tokenKid returns the kid header of a token without verifying it
+func tokenKid(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("malformed token: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+var testClaims = TokenClaims{UserID: "u1", Username: "alice", Role: "finance", TokenVersion: 3, MFA: true}
+
+func TestJWTService_RoundTrips(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	for _, k := range []SigningKey{
+		{Kid: "rsa-1", Private: rsaKey(t), NotBefore: since},
+		{Kid: "ed-1", Private: edKey(t), NotBefore: since},
+	} {
+		svc := newKeySetService(t, k)
+		token, err := svc.Generate(testClaims, time.Minute)
+		if err != nil {
+			t.Fatalf("%s: generate failed: %v", k.Kid, err)
+		}
+		if kid := tokenKid(t, token); kid != k.Kid {
+			t.Fatalf("%s: token names kid %q", k.Kid, kid)
+		}
+		_, claims, err := svc.Validate(token)
+		if err != nil {
+			t.Fatalf("%s: validate failed: %v", k.Kid, err)
+		}
+		if claims["sub"] != "u1" || claims["role"] != "finance" || claims["tv"] != float64(3) || claims["mfa"] != true {
+			t.Fatalf("%s: unexpected claims %v", k.Kid, claims)
+		}
+	}
+
+	shared := &jwtService{secret: []byte("shared secret")}
+	token, _ := shared.Generate(testClaims, time.Minute)
+	if _, claims, err := shared.Validate(token); err != nil || claims["username"] != "alice" {
+		t.Fatalf("HS256 round trip failed: %v %v", claims, err)
+	}
+	if len(shared.JWKS().Keys) != 0 {
+		t.Fatalf("a shared secret must not be published")
+	}
+}
+
+func TestJWTService_SignsWithNewestActiveKey(t *testing.T) {
+	now := time.Now()
+	svc := newKeySetService(t,
+		SigningKey{Kid: "old", Private: edKey(t), NotBefore: now.Add(-48 * time.Hour)},
+		SigningKey{Kid: "current", Private: edKey(t), NotBefore: now.Add(-time.Hour)},
+		SigningKey{Kid: "next", Private: edKey(t), NotBefore: now.Add(24 * time.Hour)},
+	)
+	token, err := svc.Generate(testClaims, time.Minute)
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	if kid := tokenKid(t, token); kid != "current" {
+		t.Fatalf("expected the newest key whose NotBefore has passed, got %q", kid)
+	}
+
+	if _, err := NewKeySetJWTService([]SigningKey{{Kid: "next", Private: edKey(t), NotBefore: now.Add(time.Hour)}}, now); err == nil {
+		t.Fatalf("expected a key set with no key active now to be rejected")
+	}
+	if _, err := NewKeySetJWTService([]SigningKey{{Kid: "a", Private: edKey(t)}, {Kid: "a", Private: edKey(t)}}, now); err == nil {
+		t.Fatalf("expected duplicate kids to be rejected")
+	}
+}
+
+func TestJWTService_RotatedKeyVerifiesButNoLongerSigns(t *testing.T) {
+	now := time.Now()
+	old := SigningKey{Kid: "old", Private: rsaKey(t), NotBefore: now.Add(-48 * time.Hour)}
+	issued, err := newKeySetService(t, old).Generate(testClaims, time.Hour)
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	rotated := newKeySetService(t, old, SigningKey{Kid: "new", Private: edKey(t), NotBefore: now.Add(-time.Minute)})
+	if _, _, err := rotated.Validate(issued); err != nil {
+		t.Fatalf("a token from the superseded key must still verify: %v", err)
+	}
+	token, _ := rotated.Generate(testClaims, time.Minute)
+	if kid := tokenKid(t, token); kid != "new" {
+		t.Fatalf("expected the superseded key to stop signing, got %q", kid)
+	}
+
+	// once NotAfter passes the key is dropped: its tokens fail and it is no longer published
+	old.NotAfter = now.Add(-time.Second)
+	dropped := newKeySetService(t, old, SigningKey{Kid: "new", Private: edKey(t), NotBefore: now.Add(-time.Minute)})
+	if _, _, err := dropped.Validate(issued); err == nil {
+		t.Fatalf("expected a token from a key past NotAfter to be rejected")
+	}
+	if keys := dropped.JWKS().Keys; len(keys) != 1 || keys[0].Kid != "new" {
+		t.Fatalf("expected only the new key published, got %+v", keys)
+	}
+}
+
+func TestJWTService_RejectsUnknownKid(t *testing.T) {
+	now := time.Now().Add(-time.Hour)
+	issuer := newKeySetService(t, SigningKey{Kid: "elsewhere", Private: edKey(t), NotBefore: now})
+	token, _ := issuer.Generate(testClaims, time.Minute)
+
+	svc := newKeySetService(t, SigningKey{Kid: "ours", Private: edKey(t), NotBefore: now})
+	if _, _, err := svc.Validate(token); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("expected unknown kid to be rejected, got %v", err)
+	}
+}
+
+func TestJWTService_RejectsAlgorithmMismatch(t *testing.T) {
+	key := rsaKey(t)
+	now := time.Now().Add(-time.Hour)
+	svc := newKeySetService(t,
+		SigningKey{Kid: "rsa", Private: key, NotBefore: now},
+		SigningKey{Kid: "ed", Private: edKey(t), NotBefore: now.Add(-time.Hour)},
+	)
+	claims := jwt.MapClaims{"sub": "u1", "role": "admin", "exp": time.Now().Add(time.Minute).Unix()}
+
+	// HS256 keyed with the RSA public key, which anyone can fetch from the JWKS
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	public := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "rsa"
+	hs, _ := forged.SignedString(public)
+	if _, _, err := svc.Validate(hs); err == nil {
+		t.Fatalf("expected an HS256 token to be rejected by an RS256 key set")
+	}
+
+	// a real signature, but by a key the token's kid does not configure for that algorithm
+	mismatched := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	mismatched.Header["kid"] = "ed"
+	rs, _ := mismatched.SignedString(key)
+	if _, _, err := svc.Validate(rs); err == nil {
+		t.Fatalf("expected an RS256 token naming the EdDSA key to be rejected")
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	unsigned.Header["kid"] = "rsa"
+	none, _ := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, _, err := svc.Validate(none); err == nil {
+		t.Fatalf("expected an unsigned token to be rejected")
+	}
+}
+
+func TestJWTService_JWKS(t *testing.T) {
+	rsaPrivate, edPrivate := rsaKey(t), edKey(t)
+	now := time.Now()
+	svc := newKeySetService(t,
+		SigningKey{Kid: "rsa", Private: rsaPrivate, NotBefore: now.Add(-time.Hour)},
+		SigningKey{Kid: "ed-next", Private: edPrivate, NotBefore: now.Add(time.Hour)},
+	)
+	keys := svc.JWKS().Keys
+	if len(keys) != 2 {
+		t.Fatalf("expected the active and the scheduled key, got %+v", keys)
+	}
+	r, e := keys[0], keys[1]
+	if r.Kid != "rsa" || r.Kty != "RSA" || r.Alg != AlgRS256 || r.Use != "sig" || r.E != "AQAB" || r.N == "" || r.X != "" {
+		t.Fatalf("unexpected RSA JWK %+v", r)
+	}
+	if e.Kid != "ed-next" || e.Kty != "OKP" || e.Crv != "Ed25519" || e.Alg != AlgEdDSA || e.N != "" {
+		t.Fatalf("unexpected Ed25519 JWK %+v", e)
+	}
+	if want := 43; len(e.X) != want { // 32 bytes, unpadded base64url
+		t.Fatalf("expected a %d character x, got %q", want, e.X)
+	}
+}
+
+func TestNewJWTService_NeedsAKey(t *testing.T) {
+	t.Setenv("JWT_KEYS_FILE", "")
+	t.Setenv("JWT_SECRET", "")
+	if _, err := NewJWTService(); err == nil || !strings.Contains(err.Error(), "no token signing key configured") {
+		t.Fatalf("expected an error without JWT_KEYS_FILE or JWT_SECRET, got %v", err)
+	}
+
+	t.Setenv("JWT_SECRET", "shared secret")
+	if _, err := NewJWTService(); err != nil {
+		t.Fatalf("expected JWT_SECRET to be enough, got %v", err)
+	}
+}
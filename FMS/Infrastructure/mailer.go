@@ -0,0 +1,112 @@
+package Infrastructure
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds a whole SMTP conversation, from dialling to QUIT
+const smtpTimeout = 15 * time.Second
+
+// Mailer sends plain-text email
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+type smtpMailer struct {
+	addr string
+	host string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer sends through the SMTP server at host:port, authenticating with
+// PLAIN when username is set. net/smtp upgrades to STARTTLS when the server
+// offers it and only sends credentials over TLS or to localhost.
+func NewSMTPMailer(host, port, username, password, from string) Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &smtpMailer{addr: net.JoinHostPort(host, port), host: host, from: from, auth: auth}
+}
+
+// NewMailerFromEnv configures SMTP from SMTP_HOST, SMTP_PORT, SMTP_USERNAME,
+// SMTP_PASSWORD and SMTP_FROM, and returns nil when SMTP_HOST is unset
+func NewMailerFromEnv() Mailer {
+	host := GetEnv("SMTP_HOST", "")
+	if host == "" {
+		return nil
+	}
+	return NewSMTPMailer(host, GetEnv("SMTP_PORT", "587"), GetEnv("SMTP_USERNAME", ""), GetEnv("SMTP_PASSWORD", ""), GetEnv("SMTP_FROM", "fms@localhost"))
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	// header values come from user preferences and record titles
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return errors.New("invalid email header")
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return m.deliver(to, []byte(msg.String()))
+}
+
+// deliver is smtp.SendMail with a deadline, so a server that stops answering
+// cannot hold up the notification worker
+func (m *smtpMailer) deliver(to string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", m.addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(m.auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
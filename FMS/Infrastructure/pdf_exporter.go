@@ -0,0 +1,357 @@
+package Infrastructure
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// landscape A4 page laid out as a monospaced table in Courier, whose glyphs
+// are 0.6em wide
+const (
+	pdfPageWidth    = 842
+	pdfPageHeight   = 595
+	pdfMargin       = 36
+	pdfGlyphWidth   = 0.6
+	pdfLeadingRatio = 1.375
+	// the font shrinks from pdfMaxFontSize to fit the table on the page, but
+	// no further than pdfMinFontSize
+	pdfMaxFontSize = 8.0
+	pdfMinFontSize = 5.0
+	pdfColumnGap   = 2
+	// longer values, such as titles, wrap onto further lines
+	pdfMaxColumn = 34
+	pdfMinColumn = 8
+	// rows measured to size the columns before the first page is written
+	pdfLayoutRows = 200
+)
+
+// fixed object numbers; page objects are numbered from pdfFirstPageObj
+const (
+	pdfCatalogObj   = 1
+	pdfPagesObj     = 2
+	pdfFontObj      = 3
+	pdfFirstPageObj = 4
+)
+
+// pdfExporter writes each page as soon as it is full, so only one page of
+// rows is ever buffered. The page tree is written last once all pages are known.
+// Column widths and the font size are fixed from the header and the first
+// pdfLayoutRows rows; a value wider than its column wraps rather than being
+// cut, so no amount or ID is ever lost.
+type pdfExporter struct {
+	w        *countingWriter
+	offsets  map[int]int64
+	nextObj  int
+	pages    []int
+	columns  []string
+	widths   []int // nil until the layout is fixed
+	fontSize float64
+	header   []string
+	pending  [][]string // rows waiting for the layout
+	lines    []string
+	started  bool
+}
+
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func NewPDFExporter(w io.Writer) Exporter {
+	return &pdfExporter{
+		w:       &countingWriter{w: bufio.NewWriter(w)},
+		offsets: map[int]int64{},
+		nextObj: pdfFirstPageObj,
+	}
+}
+
+func (e *pdfExporter) ContentType() string { return "application/pdf" }
+func (e *pdfExporter) Extension() string   { return "pdf" }
+
+func (e *pdfExporter) WriteHeader(columns []Column) error {
+	e.columns = columnNames(columns)
+	return nil
+}
+
+func (e *pdfExporter) WriteRow(values []string) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if e.widths != nil {
+		return e.addRow(values)
+	}
+	e.pending = append(e.pending, append([]string(nil), values...))
+	if len(e.pending) < pdfLayoutRows {
+		return nil
+	}
+	return e.layout()
+}
+
+// layout sizes each column to its widest value, up to pdfMaxColumn, then
+// shrinks the font until the table fits the page. Only when it still does not
+// fit at pdfMinFontSize are the widest columns narrowed, and their values wrap.
+func (e *pdfExporter) layout() error {
+	n := len(e.columns)
+	for _, row := range e.pending {
+		n = max(n, len(row))
+	}
+	widths := make([]int, n)
+	measure := func(values []string) {
+		for i, v := range values {
+			widths[i] = max(widths[i], min(utf8.RuneCountInString(v)+pdfColumnGap, pdfMaxColumn))
+		}
+	}
+	measure(e.columns)
+	for _, row := range e.pending {
+		measure(row)
+	}
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+
+	e.fontSize = pdfMaxFontSize
+	if total > pdfLineChars(e.fontSize) {
+		e.fontSize = max(pdfMinFontSize, math.Floor(pdfTextWidth/(pdfGlyphWidth*float64(total))*10)/10)
+	}
+	for limit := pdfLineChars(e.fontSize); total > limit; total-- {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= pdfMinColumn {
+			break
+		}
+		widths[widest]--
+	}
+	e.widths = widths
+	if e.columns != nil {
+		e.header = e.formatRow(e.columns)
+	}
+
+	pending := e.pending
+	e.pending = nil
+	for _, row := range pending {
+		if err := e.addRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addRow adds the lines of one row to the page, starting a new page first
+// when the row would not fit on this one
+func (e *pdfExporter) addRow(values []string) error {
+	lines := e.formatRow(values)
+	if len(e.lines) > 0 && len(e.lines)+len(lines) > e.rowsPerPage() {
+		if err := e.flushPage(); err != nil {
+			return err
+		}
+	}
+	e.lines = append(e.lines, lines...)
+	if len(e.lines) >= e.rowsPerPage() {
+		return e.flushPage()
+	}
+	return nil
+}
+
+func (e *pdfExporter) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if e.widths == nil {
+		if err := e.layout(); err != nil {
+			return err
+		}
+	}
+	if len(e.lines) > 0 || len(e.pages) == 0 {
+		if err := e.flushPage(); err != nil {
+			return err
+		}
+	}
+
+	kids := make([]string, len(e.pages))
+	for i, p := range e.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", p)
+	}
+	e.writeObj(pdfPagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(e.pages)))
+	e.writeObj(pdfCatalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObj))
+
+	xref := e.w.n
+	fmt.Fprintf(e.w, "xref\n0 %d\n0000000000 65535 f \n", e.nextObj)
+	for obj := 1; obj < e.nextObj; obj++ {
+		fmt.Fprintf(e.w, "%010d 00000 n \n", e.offsets[obj])
+	}
+	fmt.Fprintf(e.w, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", e.nextObj, pdfCatalogObj, xref)
+	return e.w.w.Flush()
+}
+
+func (e *pdfExporter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	if _, err := io.WriteString(e.w, "%PDF-1.4\n"); err != nil {
+		return err
+	}
+	e.writeObj(pdfFontObj, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	return nil
+}
+
+// pdfTextWidth is the width of the page between the margins
+const pdfTextWidth = pdfPageWidth - 2*pdfMargin
+
+// pdfLineChars is how many characters fit on a line at fontSize
+func pdfLineChars(fontSize float64) int {
+	return int(pdfTextWidth / (pdfGlyphWidth * fontSize))
+}
+
+func (e *pdfExporter) leading() float64 {
+	return e.fontSize * pdfLeadingRatio
+}
+
+func (e *pdfExporter) rowsPerPage() int {
+	lines := int((pdfPageHeight - 2*pdfMargin) / e.leading())
+	if e.header == nil {
+		return lines
+	}
+	// header lines plus a rule under them
+	return lines - len(e.header) - 1
+}
+
+// flushPage writes the buffered rows as one page, repeating the header on top
+func (e *pdfExporter) flushPage() error {
+	var content strings.Builder
+	fmt.Fprintf(&content, "BT /F1 %s Tf %s TL %d %d Td\n", pdfNumber(e.fontSize), pdfNumber(e.leading()), pdfMargin, pdfPageHeight-pdfMargin)
+	if e.header != nil {
+		rule := 0
+		for _, w := range e.widths {
+			rule += w
+		}
+		for _, line := range e.header {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+		}
+		fmt.Fprintf(&content, "(%s) Tj T*\n", strings.Repeat("-", max(rule-pdfColumnGap, 0)))
+	}
+	for _, line := range e.lines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+	}
+	content.WriteString("ET")
+	e.lines = e.lines[:0]
+
+	contentObj := e.nextObj
+	pageObj := e.nextObj + 1
+	e.nextObj += 2
+
+	e.writeObj(contentObj, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	e.writeObj(pageObj, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesObj, pdfPageWidth, pdfPageHeight, pdfFontObj, contentObj))
+	e.pages = append(e.pages, pageObj)
+	return e.w.w.Flush()
+}
+
+func (e *pdfExporter) writeObj(num int, body string) {
+	e.offsets[num] = e.w.n
+	fmt.Fprintf(e.w, "%d 0 obj\n%s\nendobj\n", num, body)
+}
+
+// formatRow pads each value to its column width. A row whose values do not
+// fit their columns takes several lines.
+func (e *pdfExporter) formatRow(values []string) []string {
+	cells := make([][]string, len(values))
+	height := 1
+	for i, v := range values {
+		cells[i] = wrapCell(v, e.columnWidth(i)-pdfColumnGap)
+		height = max(height, len(cells[i]))
+	}
+	lines := make([]string, height)
+	for l := range lines {
+		var b strings.Builder
+		for i, cell := range cells {
+			part := ""
+			if l < len(cell) {
+				part = cell[l]
+			}
+			b.WriteString(part)
+			b.WriteString(strings.Repeat(" ", e.columnWidth(i)-utf8.RuneCountInString(part)))
+		}
+		lines[l] = strings.TrimRight(b.String(), " ")
+	}
+	return lines
+}
+
+func (e *pdfExporter) columnWidth(i int) int {
+	if i < len(e.widths) {
+		return e.widths[i]
+	}
+	return pdfMaxColumn
+}
+
+// wrapCell splits v into lines of at most width characters, at a space where
+// there is one
+func wrapCell(v string, width int) []string {
+	width = max(width, 1)
+	r := []rune(v)
+	var parts []string
+	for len(r) > width {
+		cut := width
+		for i := width; i > 0; i-- {
+			if r[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		parts = append(parts, strings.TrimRight(string(r[:cut]), " "))
+		r = []rune(strings.TrimLeft(string(r[cut:]), " "))
+	}
+	return append(parts, string(r))
+}
+
+func pdfNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// winAnsiExtra maps the characters WinAnsiEncoding places in 0x80-0x9F, where
+// it differs from Latin-1
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// pdfEscape escapes string delimiters and writes characters beyond ASCII as
+// octal escapes of their WinAnsiEncoding byte. Characters the font's encoding
+// has no byte for become '?'.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r <= 126:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case winAnsiExtra[r] != 0:
+			fmt.Fprintf(&b, "\\%03o", winAnsiExtra[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package Infrastructure
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var budgetColumns = []string{"ID", "Title", "Department", "Status", "Amount", "Remaining", "Spent", "Currency", "Base Amount", "Due Date", "Created By", "Created At"}
+
+func budgetRow(title string) []string {
+	return []string{"65f1c0ffee0123456789abcd", title, "operations", "approved", "12,345,678.90", "1,234,567.89", "11,111,111.01", "USD", "12,345,678.90", "2026-12-31", "65f1c0ffee0123456789abce", "2026-01-02"}
+}
+
+func writePDF(t *testing.T, header []string, rows ...[]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	exp := NewPDFExporter(&buf)
+	columns := make([]Column, len(header))
+	for i, name := range header {
+		columns[i] = Column{Name: name}
+	}
+	if err := exp.WriteHeader(columns); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	for _, row := range rows {
+		if err := exp.WriteRow(row); err != nil {
+			t.Fatalf("row: %v", err)
+		}
+	}
+	if err := exp.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.String()
+}
+
+func TestPDFExporter_NeverTruncatesValues(t *testing.T) {
+	out := writePDF(t, budgetColumns, budgetRow("Fleet"))
+
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("not a complete PDF")
+	}
+	for _, want := range []string{"12,345,678.90", "1,234,567.89", "11,111,111.01", "65f1c0ffee0123456789abcd", "65f1c0ffee0123456789abce", "Base Amount"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q missing from the PDF", want)
+		}
+	}
+	// the table is wider than 8pt Courier allows, so the font shrinks instead
+	if strings.Contains(out, "/F1 8 Tf") {
+		t.Errorf("expected a smaller font for a wide table")
+	}
+}
+
+func TestPDFExporter_WrapsLongValues(t *testing.T) {
+	title := "Replacement of the entire delivery fleet including trailers and maintenance contracts"
+	out := writePDF(t, budgetColumns, budgetRow(title))
+
+	for _, word := range strings.Fields(title) {
+		if !strings.Contains(out, word) {
+			t.Errorf("word %q of a wrapped title is missing", word)
+		}
+	}
+	if strings.Contains(out, title) {
+		t.Errorf("a title longer than its column should wrap")
+	}
+	if got := wrapCell("12,345,678.90", 6); strings.Join(got, "") != "12,345,678.90" {
+		t.Errorf("wrapping lost characters: %q", got)
+	}
+}
+
+func TestPDFExporter_PagesAndEscaping(t *testing.T) {
+	rows := make([][]string, 300)
+	for i := range rows {
+		rows[i] = []string{"row (a)", "back\\slash"}
+	}
+	out := writePDF(t, []string{"A", "B"}, rows...)
+
+	if pages := strings.Count(out, "/Type /Page "); pages < 2 {
+		t.Fatalf("expected rows to spill onto several pages, got %d", pages)
+	}
+	if !strings.Contains(out, `/Count `) || strings.Count(out, "(A") != strings.Count(out, "/Type /Page ") {
+		t.Errorf("the header should repeat on every page")
+	}
+	if !strings.Contains(out, `row \(a\)`) || !strings.Contains(out, `back\\slash`) {
+		t.Errorf("string delimiters must be escaped")
+	}
+}
+
+func TestPDFExporter_EncodesLatin1(t *testing.T) {
+	out := writePDF(t, []string{"Title"}, []string{"Café Zürich – €5 “ok” 日本"})
+	if want := `(Caf\351 Z\374rich \226 \2005 \223ok\224 ??)`; !strings.Contains(out, want) {
+		t.Fatalf("expected %s in the page content", want)
+	}
+}
@@ -0,0 +1,27 @@
+package Infrastructure
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request id in and out of the API
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID keeps the caller's X-Request-ID, or generates one, and places it
+// into the "request_id" context key and the response headers
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			buf := make([]byte, 16)
+			_, _ = rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
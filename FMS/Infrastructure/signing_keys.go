@@ -0,0 +1,164 @@
+package Infrastructure
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// signing algorithms a key set may use
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey is one key of the set access tokens are signed with. A key is
+// published and accepted from the moment it is loaded, signs from NotBefore
+// and is dropped at NotAfter, so a rotation is scheduled by adding the next
+// key with a later NotBefore.
+type SigningKey struct {
+	Kid       string
+	Alg       string
+	Private   crypto.Signer
+	NotBefore time.Time
+	NotAfter  time.Time // zero never retires the key
+}
+
+func (k *SigningKey) retired(now time.Time) bool {
+	return !k.NotAfter.IsZero() && !now.Before(k.NotAfter)
+}
+
+func (k *SigningKey) signs(now time.Time) bool {
+	return !now.Before(k.NotBefore) && !k.retired(now)
+}
+
+// keyManifestEntry is one key of the JWT_KEYS_FILE manifest
+type keyManifestEntry struct {
+	Kid       string    `json:"kid"`
+	Alg       string    `json:"alg"`  // RS256 or EdDSA; taken from the key when empty
+	File      string    `json:"file"` // PEM private key, relative to the manifest
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// LoadSigningKeys reads a key manifest: a JSON array of {kid, alg, file,
+// not_before, not_after} whose files hold PKCS#8 or PKCS#1 PEM private keys
+func LoadSigningKeys(path string) ([]SigningKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []keyManifestEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	keys := make([]SigningKey, 0, len(entries))
+	for _, e := range entries {
+		file := e.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		pemBytes, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", e.Kid, err)
+		}
+		private, err := parsePrivateKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", e.Kid, err)
+		}
+		keys = append(keys, SigningKey{Kid: e.Kid, Alg: e.Alg, Private: private, NotBefore: e.NotBefore, NotAfter: e.NotAfter})
+	}
+	return keys, nil
+}
+
+func parsePrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
+}
+
+// checkKey fills in a missing Alg and rejects keys that do not match their
+// algorithm or are too weak
+func checkKey(k *SigningKey) error {
+	if k.Kid == "" {
+		return errors.New("signing key without kid")
+	}
+	switch private := k.Private.(type) {
+	case *rsa.PrivateKey:
+		if k.Alg == "" {
+			k.Alg = AlgRS256
+		}
+		if k.Alg != AlgRS256 {
+			return fmt.Errorf("key %q is an RSA key, not %s", k.Kid, k.Alg)
+		}
+		if private.N.BitLen() < 2048 {
+			return fmt.Errorf("key %q: RSA keys must have at least 2048 bits", k.Kid)
+		}
+	case ed25519.PrivateKey:
+		if k.Alg == "" {
+			k.Alg = AlgEdDSA
+		}
+		if k.Alg != AlgEdDSA {
+			return fmt.Errorf("key %q is an Ed25519 key, not %s", k.Kid, k.Alg)
+		}
+	default:
+		return fmt.Errorf("key %q: only RSA and Ed25519 keys are supported", k.Kid)
+	}
+	return nil
+}
+
+// JWK is the public half of a signing key as a JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *SigningKey) jwk() JWK {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := JWK{Kid: k.Kid, Use: "sig", Alg: k.Alg}
+	switch public := k.Private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(public.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+		jwk.X = b64(public)
+	}
+	return jwk
+}
@@ -0,0 +1,73 @@
+package Infrastructure
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestLoadSigningKeys_ReadsManifest(t *testing.T) {
+	dir := t.TempDir()
+	writePEM(t, filepath.Join(dir, "rsa.pem"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey(t)))
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edKey(t))
+	writePEM(t, filepath.Join(dir, "ed.pem"), "PRIVATE KEY", edDER)
+	manifest := filepath.Join(dir, "keys.json")
+	_ = os.WriteFile(manifest, []byte(`[
+		{"kid": "2031-01", "file": "rsa.pem", "not_before": "2031-01-01T00:00:00Z", "not_after": "2031-07-01T00:00:00Z"},
+		{"kid": "2031-04", "alg": "EdDSA", "file": "ed.pem", "not_before": "2031-04-01T00:00:00Z"}
+	]`), 0o600)
+
+	keys, err := LoadSigningKeys(manifest)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0].Kid != "2031-01" || !keys[0].NotAfter.Equal(time.Date(2031, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected keys %+v", keys)
+	}
+	if _, ok := keys[0].Private.(*rsa.PrivateKey); !ok {
+		t.Fatalf("expected the PKCS#1 file to load as RSA, got %T", keys[0].Private)
+	}
+
+	svc, err := NewKeySetJWTService(keys, time.Date(2031, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("key set rejected: %v", err)
+	}
+	if keys := svc.JWKS().Keys; len(keys) == 0 || keys[0].Alg != AlgRS256 {
+		t.Fatalf("expected the RSA key's algorithm to be filled in, got %+v", keys)
+	}
+
+	_ = os.WriteFile(manifest, []byte(`[{"kid": "gone", "file": "missing.pem"}]`), 0o600)
+	if _, err := LoadSigningKeys(manifest); err == nil || !strings.Contains(err.Error(), `key "gone"`) {
+		t.Fatalf("expected a missing key file to name the key, got %v", err)
+	}
+}
+
+func TestCheckKey_RejectsMismatchedAndWeakKeys(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	for name, k := range map[string]SigningKey{
+		"no kid":           {Private: edKey(t)},
+		"RSA as EdDSA":     {Kid: "k", Alg: AlgEdDSA, Private: rsaKey(t)},
+		"Ed25519 as RS256": {Kid: "k", Alg: AlgRS256, Private: edKey(t)},
+		"1024-bit RSA":     {Kid: "k", Private: weak},
+	} {
+		if err := checkKey(&k); err == nil {
+			t.Fatalf("%s: expected the key to be rejected", name)
+		}
+	}
+}
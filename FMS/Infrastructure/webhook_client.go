@@ -0,0 +1,91 @@
+package Infrastructure
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// WebhookClient posts JSON payloads to subscriber URLs
+type WebhookClient interface {
+	Post(url string, payload []byte, headers map[string]string) error
+}
+
+type httpWebhookClient struct {
+	client *http.Client
+}
+
+func NewWebhookClient(timeout time.Duration) WebhookClient {
+	return &httpWebhookClient{client: &http.Client{Timeout: timeout}}
+}
+
+// NewPublicWebhookClient is a WebhookClient for URLs chosen by ordinary users.
+// It connects only to public addresses, checked after the host name is
+// resolved and again on every redirect, and never through a proxy.
+func NewPublicWebhookClient(timeout time.Duration) WebhookClient {
+	dialer := &net.Dialer{Timeout: timeout, Control: func(_, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+			return fmt.Errorf("webhook address %s is not public", host)
+		}
+		return nil
+	}}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &httpWebhookClient{client: &http.Client{Timeout: timeout, Transport: transport}}
+}
+
+// shared address space (RFC 6598) and "this network", which Go does not count as private
+var nonPublicNets = []*net.IPNet{mustCIDR("100.64.0.0/10"), mustCIDR("0.0.0.0/8")}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// IsPublicIP reports whether ip is a globally routable unicast address: not
+// loopback, link-local, private, multicast or unspecified
+func IsPublicIP(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Post treats any status outside 2xx as a failed delivery
+func (w *httpWebhookClient) Post(url string, payload []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package Infrastructure
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.0.0.8":         false,
+		"172.20.1.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00:ec2::254":    false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"224.0.0.1":        false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if got := IsPublicIP(net.ParseIP(addr)); got != want {
+			t.Fatalf("IsPublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestPublicWebhookClient_RefusesLocalAddresses(t *testing.T) {
+	var hit atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hit.Store(true) }))
+	defer srv.Close()
+
+	if err := NewWebhookClient(time.Second).Post(srv.URL, []byte("{}"), nil); err != nil || !hit.Load() {
+		t.Fatalf("expected the internal client to reach the test server, got %v", err)
+	}
+	hit.Store(false)
+	// the test server listens on loopback, as an internal service would
+	err := NewPublicWebhookClient(time.Second).Post(srv.URL, []byte("{}"), nil)
+	if err == nil || !strings.Contains(err.Error(), "not public") || hit.Load() {
+		t.Fatalf("expected the public client to refuse a loopback address, got %v", err)
+	}
+	if err := NewPublicWebhookClient(time.Second).Post(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1), []byte("{}"), nil); err == nil || hit.Load() {
+		t.Fatalf("expected a name resolving to loopback to be refused too, got %v", err)
+	}
+}
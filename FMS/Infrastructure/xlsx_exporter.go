@@ -0,0 +1,154 @@
+package Infrastructure
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// static parts of a single-sheet workbook; the sheet itself is streamed
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Report" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`},
+	// cell style 1 is the built-in number format 4, #,##0.00
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>
+</styleSheet>`},
+}
+
+// xlsxExporter writes text cells as inline strings so no shared-string table
+// has to be built in memory. Values in the amount and count columns become
+// numeric cells that can be summed; text columns stay text even when a value
+// looks like a number, so IDs and codes keep their leading zeros.
+type xlsxExporter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	kinds []ColumnKind
+}
+
+func NewXLSXExporter(w io.Writer) Exporter {
+	return &xlsxExporter{zw: zip.NewWriter(w)}
+}
+
+func (e *xlsxExporter) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+func (e *xlsxExporter) Extension() string { return "xlsx" }
+
+func (e *xlsxExporter) WriteHeader(columns []Column) error {
+	e.kinds = nil
+	if err := e.WriteRow(columnNames(columns)); err != nil {
+		return err
+	}
+	e.kinds = make([]ColumnKind, len(columns))
+	for i, c := range columns {
+		e.kinds[i] = c.Kind
+	}
+	return nil
+}
+
+func (e *xlsxExporter) WriteRow(values []string) error {
+	if err := e.open(); err != nil {
+		return err
+	}
+	e.sheet.WriteString("<row>")
+	for i, v := range values {
+		kind := ColumnText
+		if i < len(e.kinds) {
+			kind = e.kinds[i]
+		}
+		switch {
+		case kind == ColumnAmount && xlsxNumeric(strings.ReplaceAll(v, ",", "")):
+			e.sheet.WriteString(`<c s="1"><v>` + strings.ReplaceAll(v, ",", "") + "</v></c>")
+		case kind == ColumnCount && xlsxNumeric(v):
+			e.sheet.WriteString("<c><v>" + v + "</v></c>")
+		default:
+			e.sheet.WriteString(`<c t="inlineStr"><is><t>`)
+			if err := xml.EscapeText(e.sheet, []byte(v)); err != nil {
+				return err
+			}
+			e.sheet.WriteString("</t></is></c>")
+		}
+	}
+	_, err := e.sheet.WriteString("</row>")
+	return err
+}
+
+// xlsxNumeric reports whether v is a plain decimal number, the only form a
+// numeric cell can hold; anything else in a numeric column is written as text
+func xlsxNumeric(v string) bool {
+	digits, dot := 0, false
+	for i, r := range v {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '.' && !dot:
+			dot = true
+		case r == '-' && i == 0:
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+func (e *xlsxExporter) Close() error {
+	if err := e.open(); err != nil {
+		return err
+	}
+	e.sheet.WriteString("</sheetData></worksheet>")
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
+
+// open writes the static parts and starts the sheet entry on first use
+func (e *xlsxExporter) open() error {
+	if e.sheet != nil {
+		return nil
+	}
+	for _, p := range xlsxParts {
+		f, err := e.zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return err
+		}
+	}
+	f, err := e.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = bufio.NewWriter(f)
+	_, err = e.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return err
+}
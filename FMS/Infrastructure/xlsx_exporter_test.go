@@ -0,0 +1,63 @@
+package Infrastructure
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readZipEntry(t *testing.T, data []byte, name string) string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	f, err := zr.Open(name)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	defer f.Close()
+	body, _ := io.ReadAll(f)
+	return string(body)
+}
+
+func TestXLSXExporter_AmountsAreNumbers(t *testing.T) {
+	var buf bytes.Buffer
+	exp := NewXLSXExporter(&buf)
+	_ = exp.WriteHeader([]Column{{Name: "ID"}, {Name: "Title"}, {Name: "Amount", Kind: ColumnAmount}, {Name: "Count", Kind: ColumnCount}})
+	_ = exp.WriteRow([]string{"65f1c0ffee0123456789abcd", "Fleet & <trailers>", "1,234,567.89", "42"})
+	_ = exp.WriteRow([]string{"000000000000000000000123", "Refund", "-0.50", "0"})
+	// numbers in text columns stay text; empty or odd values in numeric ones too
+	_ = exp.WriteRow([]string{"123456", "1,000.00", "", "1e9"})
+	if err := exp.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	sheet := readZipEntry(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	for _, want := range []string{
+		`<c s="1"><v>1234567.89</v></c>`,
+		`<c s="1"><v>-0.50</v></c>`,
+		`<c><v>42</v></c>`,
+		`<t>65f1c0ffee0123456789abcd</t>`,
+		`<t>000000000000000000000123</t>`,
+		`<t>Fleet &amp; &lt;trailers&gt;</t>`,
+		`<t>Amount</t>`,
+		`<t>Count</t>`,
+		`<t>123456</t>`,
+		`<t>1,000.00</t>`,
+		`<t>1e9</t>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet lacks %s:\n%s", want, sheet)
+		}
+	}
+	if styles := readZipEntry(t, buf.Bytes(), "xl/styles.xml"); !strings.Contains(styles, `numFmtId="4"`) {
+		t.Errorf("amounts need the #,##0.00 number format")
+	}
+	rels := readZipEntry(t, buf.Bytes(), "xl/_rels/workbook.xml.rels")
+	if !strings.Contains(rels, "styles.xml") {
+		t.Errorf("styles are not linked from the workbook")
+	}
+}
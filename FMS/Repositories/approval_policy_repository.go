@@ -0,0 +1,102 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ApprovalPolicyRepository interface {
+	GetAll() ([]Domain.ApprovalPolicy, error)
+	// Find returns the policy for entityType and department, or nil when none is defined
+	Find(entityType, department string) (*Domain.ApprovalPolicy, error)
+	// Save creates or replaces the policy for the policy's entity type and department
+	Save(p *Domain.ApprovalPolicy) error
+	Delete(id string) error
+}
+
+type mongoApprovalPolicyRepo struct {
+	coll *mongo.Collection
+}
+
+func NewMongoApprovalPolicyRepository(db *mongo.Database) ApprovalPolicyRepository {
+	return &mongoApprovalPolicyRepo{coll: db.Collection("approval_policies")}
+}
+
+func (r *mongoApprovalPolicyRepo) GetAll() ([]Domain.ApprovalPolicy, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "entity_type", Value: 1}, {Key: "department", Value: 1}})
+	cursor, err := r.coll.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	policies := []Domain.ApprovalPolicy{}
+	if err := cursor.All(ctx, &policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (r *mongoApprovalPolicyRepo) Find(entityType, department string) (*Domain.ApprovalPolicy, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p Domain.ApprovalPolicy
+	err := r.coll.FindOne(ctx, policyKey(entityType, department)).Decode(&p)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *mongoApprovalPolicyRepo) Save(p *Domain.ApprovalPolicy) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set":         bson.M{"steps": p.Steps, "updated_at": p.UpdatedAt},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	return r.coll.FindOneAndUpdate(ctx, policyKey(p.EntityType, p.Department), update, opts).Decode(p)
+}
+
+func (r *mongoApprovalPolicyRepo) Delete(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return errors.New("approval policy not found")
+	}
+	return nil
+}
+
+// policyKey matches the default policy (no department) when department is empty
+func policyKey(entityType, department string) bson.M {
+	if department == "" {
+		return bson.M{"entity_type": entityType, "department": bson.M{"$exists": false}}
+	}
+	return bson.M{"entity_type": entityType, "department": department}
+}
@@ -0,0 +1,145 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRepository is append-only: entries are never updated or deleted
+type AuditRepository interface {
+	// Last returns the entry with the highest sequence number, or nil when the log is empty
+	Last() (*Domain.AuditEntry, error)
+	// Append inserts e, returning Domain.ErrAuditSeqTaken if e.Seq is already used
+	Append(e *Domain.AuditEntry) error
+	Find(filter Domain.AuditFilter) ([]Domain.AuditEntry, int64, error)
+	// Each calls fn for every entry in sequence order
+	Each(fn func(Domain.AuditEntry) error) error
+}
+
+type mongoAuditRepo struct {
+	coll *mongo.Collection
+}
+
+// NewMongoAuditRepository ensures the unique sequence index that serializes
+// concurrent writers on the hash chain
+func NewMongoAuditRepository(db *mongo.Database) (AuditRepository, error) {
+	coll := db.Collection("audit_logs")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "entity_type", Value: 1}, {Key: "entity_id", Value: 1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &mongoAuditRepo{coll: coll}, nil
+}
+
+func (r *mongoAuditRepo) Last() (*Domain.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var e Domain.AuditEntry
+	opts := options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})
+	err := r.coll.FindOne(ctx, bson.M{}, opts).Decode(&e)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *mongoAuditRepo) Append(e *Domain.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.coll.InsertOne(ctx, e)
+	if mongo.IsDuplicateKeyError(err) {
+		return Domain.ErrAuditSeqTaken
+	}
+	return err
+}
+
+func (r *mongoAuditRepo) Find(filter Domain.AuditFilter) ([]Domain.AuditEntry, int64, error) {
+	query := bson.M{}
+	if filter.ActorID != "" {
+		query["actor_id"] = filter.ActorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.EntityType != "" {
+		query["entity_type"] = filter.EntityType
+	}
+	if filter.EntityID != "" {
+		query["entity_id"] = filter.EntityID
+	}
+	created := bson.M{}
+	if !filter.From.IsZero() {
+		created["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		created["$lte"] = filter.To
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	total, err := r.coll.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "seq", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit))
+	cursor, err := r.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []Domain.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+func (r *mongoAuditRepo) Each(fn func(Domain.AuditEntry) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}})
+	cursor, err := r.coll.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var e Domain.AuditEntry
+		if err := cursor.Decode(&e); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
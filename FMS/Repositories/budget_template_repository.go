@@ -0,0 +1,136 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BudgetTemplateRepository interface {
+	Create(t *Domain.BudgetTemplate) error
+	GetAll() ([]Domain.BudgetTemplate, error)
+	// GetActive lists the active templates with the given recurrence
+	GetActive(recurrence string) ([]Domain.BudgetTemplate, error)
+	GetByID(id string) (*Domain.BudgetTemplate, error)
+	Update(id string, t *Domain.BudgetTemplate) error
+	Delete(id string) error
+}
+
+type mongoBudgetTemplateRepo struct {
+	coll *mongo.Collection
+}
+
+func NewMongoBudgetTemplateRepository(db *mongo.Database) BudgetTemplateRepository {
+	return &mongoBudgetTemplateRepo{coll: db.Collection("budget_templates")}
+}
+
+func (r *mongoBudgetTemplateRepo) Create(t *Domain.BudgetTemplate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if t.ID.IsZero() {
+		t.ID = primitive.NewObjectID()
+	}
+	_, err := r.coll.InsertOne(ctx, t)
+	return err
+}
+
+func (r *mongoBudgetTemplateRepo) GetAll() ([]Domain.BudgetTemplate, error) {
+	return r.find(bson.M{})
+}
+
+func (r *mongoBudgetTemplateRepo) GetActive(recurrence string) ([]Domain.BudgetTemplate, error) {
+	return r.find(bson.M{"recurrence": recurrence, "active": true})
+}
+
+func (r *mongoBudgetTemplateRepo) find(filter bson.M) ([]Domain.BudgetTemplate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "title", Value: 1}})
+	cursor, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	templates := []Domain.BudgetTemplate{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (r *mongoBudgetTemplateRepo) GetByID(id string) (*Domain.BudgetTemplate, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var t Domain.BudgetTemplate
+	if err := r.coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&t); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("budget template not found")
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *mongoBudgetTemplateRepo) Update(id string, t *Domain.BudgetTemplate) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"title":       t.Title,
+			"description": t.Description,
+			"department":  t.Department,
+			"amount":      t.Amount,
+			"currency":    t.Currency,
+			"recurrence":  t.Recurrence,
+			"active":      t.Active,
+		},
+	}
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("budget template not found")
+	}
+	return nil
+}
+
+func (r *mongoBudgetTemplateRepo) Delete(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return errors.New("budget template not found")
+	}
+	return nil
+}
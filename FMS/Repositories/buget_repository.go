@@ -14,9 +14,21 @@ import (
 type BudgetRepository interface {
 	Create(t *Domain.Budget) error
 	GetAll() ([]Domain.Budget, error)
+	// List pages through the records matching q
+	List(q Domain.ListQuery) ([]Domain.Budget, *Domain.Pagination, error)
+	GetByStatus(status string) ([]Domain.Budget, error)
+	GetByDepartment(department string) ([]Domain.Budget, error)
+	GetByPeriod(periodID primitive.ObjectID) ([]Domain.Budget, error)
 	GetByID(id string) (*Domain.Budget, error)
+	// Update writes t only while the stored budget is still at t.Version and
+	// returns a *Domain.ConflictError once it has moved on
 	Update(id string, t *Domain.Budget) error
 	Delete(id string) error
+	// Debit and Credit also move the line item's remaining when lineItemID is set
+	Debit(id string, lineItemID primitive.ObjectID, amount Domain.Money) error
+	Credit(id string, lineItemID primitive.ObjectID, amount Domain.Money) error
+	// MarkThresholdAlerted records a utilisation alert and reports false when it was already recorded
+	MarkThresholdAlerted(id string, threshold int) (bool, error)
 }
 
 type mongoBudgetRepo struct {
@@ -29,6 +41,7 @@ func NewMongoBudgetRepository(db *mongo.Database) BudgetRepository {
 
 func (r *mongoBudgetRepo) Create(t *Domain.Budget) error {
 	t.ID = primitive.NewObjectID()
+	t.Version = 1
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -55,6 +68,64 @@ func (r *mongoBudgetRepo) GetAll() ([]Domain.Budget, error) {
 	return budgets, nil
 }
 
+func (r *mongoBudgetRepo) List(q Domain.ListQuery) ([]Domain.Budget, *Domain.Pagination, error) {
+	return findPage[Domain.Budget](r.coll, q, budgetListFields)
+}
+
+func (r *mongoBudgetRepo) GetByDepartment(department string) ([]Domain.Budget, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.coll.Find(ctx, bson.M{"department": department})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var budgets []Domain.Budget
+	if err := cursor.All(ctx, &budgets); err != nil {
+		return nil, err
+	}
+
+	return budgets, nil
+}
+
+func (r *mongoBudgetRepo) GetByStatus(status string) ([]Domain.Budget, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.coll.Find(ctx, bson.M{"status": status})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var budgets []Domain.Budget
+	if err := cursor.All(ctx, &budgets); err != nil {
+		return nil, err
+	}
+
+	return budgets, nil
+}
+
+func (r *mongoBudgetRepo) GetByPeriod(periodID primitive.ObjectID) ([]Domain.Budget, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.coll.Find(ctx, bson.M{"period_id": periodID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var budgets []Domain.Budget
+	if err := cursor.All(ctx, &budgets); err != nil {
+		return nil, err
+	}
+
+	return budgets, nil
+}
+
 func (r *mongoBudgetRepo) GetByID(id string) (*Domain.Budget, error) {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -81,32 +152,92 @@ func (r *mongoBudgetRepo) Update(id string, t *Domain.Budget) error {
 		return errors.New("invalid ID")
 	}
 
+	set := bson.M{
+		"title":          t.Title,
+		"description":    t.Description,
+		"department":     t.Department,
+		"status":         t.Status,
+		"due_date":       t.DueDate,
+		"amount":         t.Amount,
+		"currency":       t.Currency,
+		"exchange_rate":  t.ExchangeRate,
+		"base_amount":    t.BaseAmount,
+		"remaining":      t.Remaining,
+		"approval_chain": t.ApprovalChain,
+		"approvals":      t.Approvals,
+		"history":        t.History,
+		"carried_in":     t.CarriedIn,
+		"carried_out":    t.CarriedOut,
+		"line_items":     t.LineItems,
+	}
+	// a budget never leaves its period or template, so unset IDs are not written
+	if !t.PeriodID.IsZero() {
+		set["period_id"] = t.PeriodID
+	}
+	if !t.TemplateID.IsZero() {
+		set["template_id"] = t.TemplateID
+	}
+	return updateVersioned(r.coll, "budget", objID, &t.Version, set)
+}
+
+func (r *mongoBudgetRepo) Delete(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	update := bson.M{
-		"$set": bson.M{
-			"title":       t.Title,
-			"description": t.Description,
-			"status":      t.Status,
-			"due_date":    t.DueDate,
-			"amount":      t.Amount,
-			"remaining":   t.Remaining,
-		},
+	res, err := r.coll.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return errors.New("budget not found")
 	}
 
-	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	return nil
+}
+
+// Debit atomically decrements the remaining amount of an approved budget.
+// The update only matches when the budget, and the line item if any, has enough funds left.
+// Debit and Credit move the budget's version on, so an Update read before the
+// spend cannot write back the old remaining.
+func (r *mongoBudgetRepo) Debit(id string, lineItemID primitive.ObjectID, amount Domain.Money) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":       objID,
+		"status":    "approved",
+		"remaining": bson.M{"$gte": amount},
+	}
+	inc := bson.M{"remaining": -amount, "version": 1}
+	if !lineItemID.IsZero() {
+		filter["line_items"] = bson.M{"$elemMatch": bson.M{"_id": lineItemID, "remaining": bson.M{"$gte": amount}}}
+		inc["line_items.$.remaining"] = -amount
+	}
+	res, err := r.coll.UpdateOne(ctx, filter, bson.M{"$inc": inc})
 	if err != nil {
 		return err
 	}
 	if res.MatchedCount == 0 {
-		return errors.New("budget not found")
+		return errors.New("budget not approved or insufficient funds")
 	}
 
 	return nil
 }
 
-func (r *mongoBudgetRepo) Delete(id string) error {
+// Credit gives back an amount to the budget, used to compensate a failed debit
+// and to return unspent cash.
+func (r *mongoBudgetRepo) Credit(id string, lineItemID primitive.ObjectID, amount Domain.Money) error {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return errors.New("invalid ID")
@@ -115,14 +246,41 @@ func (r *mongoBudgetRepo) Delete(id string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	res, err := r.coll.DeleteOne(ctx, bson.M{"_id": objID})
+	filter := bson.M{"_id": objID}
+	inc := bson.M{"remaining": amount, "version": 1}
+	if !lineItemID.IsZero() {
+		filter["line_items._id"] = lineItemID
+		inc["line_items.$.remaining"] = amount
+	}
+	res, err := r.coll.UpdateOne(ctx, filter, bson.M{"$inc": inc})
 	if err != nil {
 		return err
 	}
-
-	if res.DeletedCount == 0 {
+	if res.MatchedCount == 0 {
 		return errors.New("budget not found")
 	}
 
 	return nil
 }
+
+// MarkThresholdAlerted adds threshold to the budget's alerted thresholds. The
+// update only matches while the threshold is missing, so concurrent spend
+// alerts once.
+func (r *mongoBudgetRepo) MarkThresholdAlerted(id string, threshold int) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": objID, "alerted_thresholds": bson.M{"$ne": threshold}},
+		bson.M{"$push": bson.M{"alerted_thresholds": threshold}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
@@ -14,7 +14,12 @@ import (
 type CashRequestRepository interface {
 	Create(t *Domain.CashRequest) error
 	GetAll() ([]Domain.CashRequest, error)
+	// List pages through the records matching q
+	List(q Domain.ListQuery) ([]Domain.CashRequest, *Domain.Pagination, error)
+	GetByStatus(status string) ([]Domain.CashRequest, error)
+	CountByBudgets(budgetIDs []primitive.ObjectID, statuses []string) (int64, error)
 	GetByID(id string) (*Domain.CashRequest, error)
+	// Update is conditional on t.Version, like BudgetRepository.Update
 	Update(id string, t *Domain.CashRequest) error
 	Delete(id string) error
 }
@@ -29,6 +34,7 @@ func NewMongoCashRequestRepository(db *mongo.Database) CashRequestRepository {
 
 func (r *mongoCashRequestRepo) Create(t *Domain.CashRequest) error {
 	t.ID = primitive.NewObjectID()
+	t.Version = 1
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -55,6 +61,36 @@ func (r *mongoCashRequestRepo) GetAll() ([]Domain.CashRequest, error) {
 	return tasks, nil
 }
 
+func (r *mongoCashRequestRepo) List(q Domain.ListQuery) ([]Domain.CashRequest, *Domain.Pagination, error) {
+	return findPage[Domain.CashRequest](r.coll, q, cashListFields)
+}
+
+func (r *mongoCashRequestRepo) GetByStatus(status string) ([]Domain.CashRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.coll.Find(ctx, bson.M{"status": status})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []Domain.CashRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// CountByBudgets counts the cash requests charged to any of budgetIDs whose status is one of statuses
+func (r *mongoCashRequestRepo) CountByBudgets(budgetIDs []primitive.ObjectID, statuses []string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return r.coll.CountDocuments(ctx, bson.M{"budget_id": bson.M{"$in": budgetIDs}, "status": bson.M{"$in": statuses}})
+}
+
 func (r *mongoCashRequestRepo) GetByID(id string) (*Domain.CashRequest, error) {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -81,30 +117,23 @@ func (r *mongoCashRequestRepo) Update(id string, t *Domain.CashRequest) error {
 		return errors.New("invalid ID")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	update := bson.M{
-		"$set": bson.M{
-			"title":       t.Title,
-			"description": t.Description,
-			"amount":      t.Amount,
-			"budget_id":   t.BudgetID,
-			"requester":   t.Requester,
-			"created_at":  t.CreatedAt,
-			"status":      t.Status,
-		},
+	set := bson.M{
+		"title":          t.Title,
+		"description":    t.Description,
+		"amount":         t.Amount,
+		"currency":       t.Currency,
+		"exchange_rate":  t.ExchangeRate,
+		"base_amount":    t.BaseAmount,
+		"budget_id":      t.BudgetID,
+		"requester":      t.Requester,
+		"created_at":     t.CreatedAt,
+		"status":         t.Status,
+		"approval_chain": t.ApprovalChain,
+		"approvals":      t.Approvals,
+		"history":        t.History,
+		"settlement":     t.Settlement,
 	}
-
-	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": objID}, update)
-	if err != nil {
-		return err
-	}
-	if res.MatchedCount == 0 {
-		return errors.New("cash request not found")
-	}
-
-	return nil
+	return updateVersioned(r.coll, "cash request", objID, &t.Version, set)
 }
 
 func (r *mongoCashRequestRepo) Delete(id string) error {
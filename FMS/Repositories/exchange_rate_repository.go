@@ -0,0 +1,99 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExchangeRateRepository interface {
+	Create(rate *Domain.ExchangeRate) error
+	// List returns the rates of currency, or of every currency when it is empty, newest first
+	List(currency string) ([]Domain.ExchangeRate, error)
+	// FindEffective returns the latest rate of currency effective at, or nil when there is none
+	FindEffective(currency string, at time.Time) (*Domain.ExchangeRate, error)
+	Delete(id string) error
+}
+
+type mongoExchangeRateRepo struct {
+	coll *mongo.Collection
+}
+
+func NewMongoExchangeRateRepository(db *mongo.Database) ExchangeRateRepository {
+	return &mongoExchangeRateRepo{coll: db.Collection("exchange_rates")}
+}
+
+func (r *mongoExchangeRateRepo) Create(rate *Domain.ExchangeRate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if rate.ID.IsZero() {
+		rate.ID = primitive.NewObjectID()
+	}
+	_, err := r.coll.InsertOne(ctx, rate)
+	return err
+}
+
+func (r *mongoExchangeRateRepo) List(currency string) ([]Domain.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if currency != "" {
+		filter["currency"] = currency
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "currency", Value: 1}, {Key: "effective_from", Value: -1}})
+	cursor, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rates := []Domain.ExchangeRate{}
+	if err := cursor.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (r *mongoExchangeRateRepo) FindEffective(currency string, at time.Time) (*Domain.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var rate Domain.ExchangeRate
+	filter := bson.M{"currency": currency, "effective_from": bson.M{"$lte": at}}
+	opts := options.FindOne().SetSort(bson.D{{Key: "effective_from", Value: -1}, {Key: "created_at", Value: -1}})
+	err := r.coll.FindOne(ctx, filter, opts).Decode(&rate)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (r *mongoExchangeRateRepo) Delete(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return errors.New("exchange rate not found")
+	}
+	return nil
+}
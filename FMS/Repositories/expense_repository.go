@@ -14,7 +14,12 @@ import (
 type ExpenseRepository interface {
 	Create(t *Domain.Expense) error
 	GetAll() ([]Domain.Expense, error)
+	// List pages through the records matching q
+	List(q Domain.ListQuery) ([]Domain.Expense, *Domain.Pagination, error)
+	GetByCashRequest(cashRequestID primitive.ObjectID) ([]Domain.Expense, error)
+	CountByBudgets(budgetIDs []primitive.ObjectID, statuses []string) (int64, error)
 	GetByID(id string) (*Domain.Expense, error)
+	// Update is conditional on t.Version, like BudgetRepository.Update
 	Update(id string, t *Domain.Expense) error
 	Delete(id string) error
 }
@@ -29,6 +34,7 @@ func NewMongoExpenseRepository(db *mongo.Database) ExpenseRepository {
 
 func (r *mongoExpenseRepo) Create(t *Domain.Expense) error {
 	t.ID = primitive.NewObjectID()
+	t.Version = 1
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -55,6 +61,37 @@ func (r *mongoExpenseRepo) GetAll() ([]Domain.Expense, error) {
 	return tasks, nil
 }
 
+func (r *mongoExpenseRepo) List(q Domain.ListQuery) ([]Domain.Expense, *Domain.Pagination, error) {
+	return findPage[Domain.Expense](r.coll, q, expenseListFields)
+}
+
+// GetByCashRequest lists the expenses paid out of a cash advance
+func (r *mongoExpenseRepo) GetByCashRequest(cashRequestID primitive.ObjectID) ([]Domain.Expense, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.coll.Find(ctx, bson.M{"cash_request_id": cashRequestID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	expenses := []Domain.Expense{}
+	if err := cursor.All(ctx, &expenses); err != nil {
+		return nil, err
+	}
+
+	return expenses, nil
+}
+
+// CountByBudgets counts the expenses charged to any of budgetIDs whose status is one of statuses
+func (r *mongoExpenseRepo) CountByBudgets(budgetIDs []primitive.ObjectID, statuses []string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return r.coll.CountDocuments(ctx, bson.M{"budget_id": bson.M{"$in": budgetIDs}, "status": bson.M{"$in": statuses}})
+}
+
 func (r *mongoExpenseRepo) GetByID(id string) (*Domain.Expense, error) {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -81,31 +118,21 @@ func (r *mongoExpenseRepo) Update(id string, t *Domain.Expense) error {
 		return errors.New("invalid ID")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	update := bson.M{
-		"$set": bson.M{
-			"title":       t.Title,
-			"description": t.Description,
-			"amount":      t.Amount,
-			"receipt_url": t.ReceiptURL,
-			"budget_id":   t.BudgetID,
-			"created_at":  t.CreatedAt,
-			"status":      t.Status,
-			"due_date":    t.DueDate,
-		},
+	set := bson.M{
+		"title":         t.Title,
+		"description":   t.Description,
+		"amount":        t.Amount,
+		"currency":      t.Currency,
+		"exchange_rate": t.ExchangeRate,
+		"base_amount":   t.BaseAmount,
+		"receipts":      t.Receipts,
+		"budget_id":     t.BudgetID,
+		"created_at":    t.CreatedAt,
+		"status":        t.Status,
+		"history":       t.History,
+		"due_date":      t.DueDate,
 	}
-
-	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": objID}, update)
-	if err != nil {
-		return err
-	}
-	if res.MatchedCount == 0 {
-		return errors.New("expense not found")
-	}
-
-	return nil
+	return updateVersioned(r.coll, "expense", objID, &t.Version, set)
 }
 
 func (r *mongoExpenseRepo) Delete(id string) error {
@@ -0,0 +1,129 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FiscalPeriodRepository interface {
+	Create(p *Domain.FiscalPeriod) error
+	// GetAll lists periods by start date, each year before its first quarter
+	GetAll() ([]Domain.FiscalPeriod, error)
+	GetByID(id string) (*Domain.FiscalPeriod, error)
+	// FindNext returns the first period of periodType starting at or after from, or nil
+	FindNext(periodType string, from time.Time) (*Domain.FiscalPeriod, error)
+	// Overlaps reports whether a period of periodType intersects [start, end)
+	Overlaps(periodType string, start, end time.Time) (bool, error)
+	// Close marks an open period closed; it returns Domain.ErrPeriodClosed when it already was
+	Close(id primitive.ObjectID, closedBy string, at time.Time) error
+}
+
+type mongoFiscalPeriodRepo struct {
+	coll *mongo.Collection
+}
+
+func NewMongoFiscalPeriodRepository(db *mongo.Database) FiscalPeriodRepository {
+	return &mongoFiscalPeriodRepo{coll: db.Collection("fiscal_periods")}
+}
+
+func (r *mongoFiscalPeriodRepo) Create(p *Domain.FiscalPeriod) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if p.ID.IsZero() {
+		p.ID = primitive.NewObjectID()
+	}
+	_, err := r.coll.InsertOne(ctx, p)
+	return err
+}
+
+func (r *mongoFiscalPeriodRepo) GetAll() ([]Domain.FiscalPeriod, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "start_date", Value: 1}, {Key: "type", Value: -1}})
+	cursor, err := r.coll.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	periods := []Domain.FiscalPeriod{}
+	if err := cursor.All(ctx, &periods); err != nil {
+		return nil, err
+	}
+	return periods, nil
+}
+
+func (r *mongoFiscalPeriodRepo) GetByID(id string) (*Domain.FiscalPeriod, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p Domain.FiscalPeriod
+	if err := r.coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&p); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("fiscal period not found")
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *mongoFiscalPeriodRepo) FindNext(periodType string, from time.Time) (*Domain.FiscalPeriod, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p Domain.FiscalPeriod
+	filter := bson.M{"type": periodType, "start_date": bson.M{"$gte": from}}
+	opts := options.FindOne().SetSort(bson.D{{Key: "start_date", Value: 1}})
+	err := r.coll.FindOne(ctx, filter, opts).Decode(&p)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *mongoFiscalPeriodRepo) Overlaps(periodType string, start, end time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n, err := r.coll.CountDocuments(ctx, bson.M{
+		"type":       periodType,
+		"start_date": bson.M{"$lt": end},
+		"end_date":   bson.M{"$gt": start},
+	})
+	return n > 0, err
+}
+
+func (r *mongoFiscalPeriodRepo) Close(id primitive.ObjectID, closedBy string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// only an open period matches, so two concurrent closes cannot both carry forward
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": id, "status": Domain.PeriodOpen},
+		bson.M{"$set": bson.M{"status": Domain.PeriodClosed, "closed_by": closedBy, "closed_at": at}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return Domain.ErrPeriodClosed
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LedgerRepository is append-only: entries are never updated or deleted
+type LedgerRepository interface {
+	Append(entries []Domain.LedgerEntry) error
+	Find(filter Domain.LedgerFilter) ([]Domain.LedgerEntry, error)
+	DebitTotals() (map[string]Domain.Money, error)
+}
+
+type mongoLedgerRepo struct {
+	coll *mongo.Collection
+}
+
+func NewMongoLedgerRepository(db *mongo.Database) LedgerRepository {
+	return &mongoLedgerRepo{coll: db.Collection("ledger")}
+}
+
+func (r *mongoLedgerRepo) Append(entries []Domain.LedgerEntry) error {
+	if len(entries) == 0 {
+		return errors.New("no ledger entries")
+	}
+
+	docs := make([]interface{}, 0, len(entries))
+	for i := range entries {
+		entries[i].ID = primitive.NewObjectID()
+		docs = append(docs, entries[i])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.coll.InsertMany(ctx, docs)
+	return err
+}
+
+func (r *mongoLedgerRepo) Find(filter Domain.LedgerFilter) ([]Domain.LedgerEntry, error) {
+	query := bson.M{}
+	if filter.BudgetID != "" {
+		objID, err := primitive.ObjectIDFromHex(filter.BudgetID)
+		if err != nil {
+			return nil, errors.New("invalid ID")
+		}
+		query["budget_id"] = objID
+	}
+	created := bson.M{}
+	if !filter.From.IsZero() {
+		created["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		created["$lte"] = filter.To
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []Domain.LedgerEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// DebitTotals sums the debits posted to each budget account, less cash returned
+// to it, keyed by budget ID hex
+func (r *mongoLedgerRepo) DebitTotals() (map[string]Domain.Money, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"account": bson.M{"$regex": "^budget:"},
+			"$or": bson.A{
+				bson.M{"type": Domain.LedgerDebit},
+				bson.M{"type": Domain.LedgerCredit, "event": Domain.EventCashReturn},
+			},
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": "$budget_id", "total": bson.M{"$sum": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$type", Domain.LedgerDebit}}, "$amount", bson.M{"$multiply": bson.A{"$amount", -1}},
+		}}}}}},
+	}
+	cursor, err := r.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		BudgetID primitive.ObjectID `bson:"_id"`
+		Total    Domain.Money       `bson:"total"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]Domain.Money, len(rows))
+	for _, row := range rows {
+		totals[row.BudgetID.Hex()] = row.Total
+	}
+	return totals, nil
+}
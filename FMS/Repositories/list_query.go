@@ -0,0 +1,278 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// listFields names the fields of a collection that a Domain.ListQuery filters on
+type listFields struct {
+	owner      string // created_by or requester
+	budget     string // the budget reference; empty for budgets themselves
+	department bool   // the scope may match on department
+	sorts      []string
+}
+
+var (
+	budgetListFields  = listFields{owner: "created_by", department: true, sorts: []string{Domain.SortCreatedAt, Domain.SortAmount, Domain.SortDueDate, Domain.SortTitle, Domain.SortStatus}}
+	cashListFields    = listFields{owner: "requester", budget: "budget_id", sorts: []string{Domain.SortCreatedAt, Domain.SortAmount, Domain.SortTitle, Domain.SortStatus}}
+	expenseListFields = listFields{owner: "created_by", budget: "budget_id", sorts: []string{Domain.SortCreatedAt, Domain.SortAmount, Domain.SortDueDate, Domain.SortTitle, Domain.SortStatus}}
+)
+
+func (f listFields) sortable(field string) bool {
+	for _, s := range f.sorts {
+		if s == field {
+			return true
+		}
+	}
+	return false
+}
+
+// listFilter translates the filters and scope of q, leaving out paging
+func listFilter(q Domain.ListQuery, f listFields) bson.M {
+	var and []bson.M
+	if q.Status != "" {
+		and = append(and, bson.M{"status": q.Status})
+	}
+	if r := bounds(q.From, q.To, !q.From.IsZero(), !q.To.IsZero()); r != nil {
+		and = append(and, bson.M{"created_at": r})
+	}
+	if r := bounds(q.MinAmount, q.MaxAmount, q.MinAmount > 0, q.MaxAmount > 0); r != nil {
+		and = append(and, bson.M{"amount": r})
+	}
+	if !q.BudgetID.IsZero() && f.budget != "" {
+		and = append(and, bson.M{f.budget: q.BudgetID})
+	}
+	if q.Requester != "" {
+		and = append(and, bson.M{f.owner: q.Requester})
+	}
+	if s := q.Scope; s != nil {
+		or := []bson.M{{f.owner: s.OwnerID}}
+		if s.Department != "" && f.department {
+			or = append(or, bson.M{"department": s.Department})
+		}
+		if len(s.BudgetIDs) > 0 && f.budget != "" {
+			or = append(or, bson.M{f.budget: bson.M{"$in": s.BudgetIDs}})
+		}
+		and = append(and, bson.M{"$or": or})
+	}
+
+	switch len(and) {
+	case 0:
+		return bson.M{}
+	case 1:
+		return and[0]
+	}
+	return bson.M{"$and": and}
+}
+
+func bounds(min, max interface{}, hasMin, hasMax bool) bson.M {
+	if !hasMin && !hasMax {
+		return nil
+	}
+	r := bson.M{}
+	if hasMin {
+		r["$gte"] = min
+	}
+	if hasMax {
+		r["$lte"] = max
+	}
+	return r
+}
+
+// listCursor is the position after the last record of a page: its sort value
+// and ID, and the sort it was issued for
+type listCursor struct {
+	Sort  string             `bson:"s"`
+	Value bson.RawValue      `bson:"v"`
+	ID    primitive.ObjectID `bson:"id"`
+}
+
+// a cursor must come from a page with the same sort
+var errInvalidCursor = fmt.Errorf("%w: cursor does not belong to this sort", Domain.ErrInvalidQuery)
+
+func sortKey(q Domain.ListQuery) string {
+	if q.Desc {
+		return "-" + q.Sort
+	}
+	return q.Sort
+}
+
+func encodeCursor(c listCursor) (string, error) {
+	raw, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(s string, q Domain.ListQuery) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c listCursor
+	if err := bson.Unmarshal(raw, &c); err != nil || c.Sort != sortKey(q) || c.Value.Type == 0 || c.ID.IsZero() {
+		return nil, errInvalidCursor
+	}
+	return &c, nil
+}
+
+// after matches the records that sort after c. Records missing the sort field
+// sort before every value ascending and after every value descending.
+func (c *listCursor) after(field string, desc bool) bson.M {
+	idOp, valueOp := "$gt", "$gt"
+	if desc {
+		idOp, valueOp = "$lt", "$lt"
+	}
+	tie := bson.M{field: c.Value, "_id": bson.M{idOp: c.ID}}
+	null := c.Value.Type == bson.TypeNull
+	switch {
+	case null && desc:
+		return bson.M{field: nil, "_id": bson.M{idOp: c.ID}}
+	case null:
+		return bson.M{"$or": []bson.M{{field: bson.M{"$ne": nil}}, {field: nil, "_id": bson.M{idOp: c.ID}}}}
+	case desc:
+		return bson.M{"$or": []bson.M{{field: bson.M{valueOp: c.Value}}, tie, {field: nil}}}
+	}
+	return bson.M{"$or": []bson.M{{field: bson.M{valueOp: c.Value}}, tie}}
+}
+
+// findPage runs q against coll. The caller has already defaulted Sort and
+// clamped Page and Limit.
+func findPage[T any](coll *mongo.Collection, q Domain.ListQuery, f listFields) ([]T, *Domain.Pagination, error) {
+	if !f.sortable(q.Sort) {
+		return nil, nil, fmt.Errorf("%w: cannot sort by %s", Domain.ErrInvalidQuery, q.Sort)
+	}
+	filter := listFilter(q, f)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	total, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir := 1
+	if q.Desc {
+		dir = -1
+	}
+	// one extra record tells whether another page follows
+	opts := options.Find().
+		SetSort(bson.D{{Key: q.Sort, Value: dir}, {Key: "_id", Value: dir}}).
+		SetLimit(int64(q.Limit + 1))
+	page := &Domain.Pagination{Total: total, Limit: q.Limit}
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor, q)
+		if err != nil {
+			return nil, nil, err
+		}
+		filter = bson.M{"$and": []bson.M{filter, c.after(q.Sort, q.Desc)}}
+	} else {
+		page.Page = q.Page
+		opts.SetSkip(int64((q.Page - 1) * q.Limit))
+	}
+
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var raws []bson.Raw
+	if err := cursor.All(ctx, &raws); err != nil {
+		return nil, nil, err
+	}
+	if len(raws) > q.Limit {
+		raws = raws[:q.Limit]
+		page.HasMore = true
+	}
+
+	items := make([]T, len(raws))
+	for i, raw := range raws {
+		if err := bson.Unmarshal(raw, &items[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if page.HasMore {
+		last := raws[len(raws)-1]
+		next := listCursor{Sort: sortKey(q), Value: last.Lookup(q.Sort)}
+		if next.Value.Type == 0 {
+			next.Value = bson.RawValue{Type: bson.TypeNull}
+		}
+		next.ID, _ = last.Lookup("_id").ObjectIDOK()
+		if page.NextCursor, err = encodeCursor(next); err != nil {
+			return nil, nil, err
+		}
+	}
+	return items, page, nil
+}
+
+// EnsureIndexes creates the indexes behind the filters and sorts of the list
+// endpoints, search, the webhook outbox, refresh tokens and login attempts.
+// Creating an existing index is a no-op.
+func EnsureIndexes(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	byCreated := func(fields ...string) mongo.IndexModel {
+		keys := bson.D{}
+		for _, f := range fields {
+			keys = append(keys, bson.E{Key: f, Value: 1})
+		}
+		keys = append(keys, bson.E{Key: "created_at", Value: -1}, bson.E{Key: "_id", Value: -1})
+		return mongo.IndexModel{Keys: keys}
+	}
+	// a collection has at most one text index; titles weigh more than descriptions
+	text := mongo.IndexModel{
+		Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+		Options: options.Index().SetName("search_text").SetWeights(bson.M{"title": 3, "description": 1}),
+	}
+	indexes := map[string][]mongo.IndexModel{
+		"budgets": {
+			byCreated(), byCreated("status"), byCreated("created_by"), byCreated("department"), text,
+			{Keys: bson.D{{Key: "amount", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "due_date", Value: 1}, {Key: "_id", Value: 1}}},
+		},
+		"cash_requests": {
+			byCreated(), byCreated("status"), byCreated("requester"), byCreated("budget_id"), text,
+			{Keys: bson.D{{Key: "amount", Value: 1}, {Key: "_id", Value: 1}}},
+		},
+		"expenses": {
+			byCreated(), byCreated("status"), byCreated("created_by"), byCreated("budget_id"), text,
+			{Keys: bson.D{{Key: "amount", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "due_date", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "cash_request_id", Value: 1}}},
+		},
+		"webhook_deliveries": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+			{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		},
+		// expired refresh tokens are useless, so Mongo drops them
+		"refresh_tokens": {
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "family_id", Value: 1}}},
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		// failures are only counted for minutes; a day later the record can go
+		"login_attempts": {
+			{Keys: bson.D{{Key: "last_failure", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(24 * 60 * 60)},
+		},
+	}
+	for coll, models := range indexes {
+		if _, err := db.Collection(coll).Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LoginAttemptRepository interface {
+	// Get returns the attempts recorded under key; a key without any has a zero record
+	Get(key string) (*Domain.LoginAttempts, error)
+	// RecordFailure counts a failure at at and returns the new count. The count
+	// starts over when the previous failure is older than window.
+	RecordFailure(key string, at time.Time, window time.Duration) (*Domain.LoginAttempts, error)
+	Lock(key string, until time.Time) error
+	// Clear forgets the failures of key and lifts its lockout
+	Clear(key string) error
+}
+
+type mongoLoginAttemptRepo struct {
+	coll *mongo.Collection
+}
+
+func NewMongoLoginAttemptRepository(db *mongo.Database) LoginAttemptRepository {
+	return &mongoLoginAttemptRepo{coll: db.Collection("login_attempts")}
+}
+
+func (r *mongoLoginAttemptRepo) Get(key string) (*Domain.LoginAttempts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a := Domain.LoginAttempts{Key: key}
+	if err := r.coll.FindOne(ctx, bson.M{"_id": key}).Decode(&a); err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// RecordFailure counts in a single pipeline update, so concurrent failures
+// are all counted
+func (r *mongoLoginAttemptRepo) RecordFailure(key string, at time.Time, window time.Duration) (*Domain.LoginAttempts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"failures": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$last_failure", at.Add(-window)}},
+			bson.M{"$add": bson.A{"$failures", 1}},
+			1,
+		}},
+		"last_failure": at,
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var a Domain.LoginAttempts
+	if err := r.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *mongoLoginAttemptRepo) Lock(key string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.coll.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"locked_until": until}}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *mongoLoginAttemptRepo) Clear(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.coll.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
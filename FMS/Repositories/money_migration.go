@@ -0,0 +1,64 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// moneyFields lists the amounts that used to be stored as float64 major units
+var moneyFields = map[string][]string{
+	"budgets":       {"amount", "remaining", "base_amount"},
+	"cash_requests": {"amount", "base_amount"},
+	"expenses":      {"amount", "base_amount"},
+	"ledger":        {"amount"},
+}
+
+// MigrateMoneyToMinorUnits rewrites amounts still stored as doubles to the
+// integer minor units of Domain.Money and returns how many documents changed.
+// Only doubles are touched, so it is safe to run on every start.
+// Audit entries keep the amounts they were hashed with.
+func MigrateMoneyToMinorUnits(db *mongo.Database) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var migrated int64
+	for collection, fields := range moneyFields {
+		coll := db.Collection(collection)
+		for _, field := range fields {
+			res, err := coll.UpdateMany(ctx,
+				bson.M{field: bson.M{"$type": "double"}},
+				mongo.Pipeline{{{Key: "$set", Value: bson.M{field: toMinorUnits("$" + field)}}}},
+			)
+			if err != nil {
+				return migrated, err
+			}
+			migrated += res.ModifiedCount
+		}
+	}
+
+	// approval thresholds live inside the steps array
+	res, err := db.Collection("approval_policies").UpdateMany(ctx,
+		bson.M{"steps.min_amount": bson.M{"$type": "double"}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"steps": bson.M{"$map": bson.M{
+			"input": "$steps",
+			"as":    "s",
+			"in": bson.M{"$mergeObjects": bson.A{"$$s", bson.M{"min_amount": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$type": "$$s.min_amount"}, "double"}},
+				toMinorUnits("$$s.min_amount"),
+				"$$s.min_amount",
+			}}}}},
+		}}}}}},
+	)
+	if err != nil {
+		return migrated, err
+	}
+	return migrated + res.ModifiedCount, nil
+}
+
+func toMinorUnits(field string) bson.M {
+	return bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{field, Domain.MinorUnits}}, 0}}}
+}
@@ -0,0 +1,110 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NotificationRepository stores the in-app inbox and each user's notification preferences
+type NotificationRepository interface {
+	Create(n *Domain.Notification) error
+	GetByUser(userID string, unreadOnly bool) ([]Domain.Notification, error)
+	MarkRead(id, userID string) error
+	GetPreferences(userID string) (*Domain.NotificationPreferences, error)
+	SavePreferences(p *Domain.NotificationPreferences) error
+}
+
+type mongoNotificationRepo struct {
+	coll        *mongo.Collection
+	preferences *mongo.Collection
+}
+
+func NewMongoNotificationRepository(db *mongo.Database) NotificationRepository {
+	return &mongoNotificationRepo{coll: db.Collection("notifications"), preferences: db.Collection("notification_preferences")}
+}
+
+func (r *mongoNotificationRepo) Create(n *Domain.Notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.InsertOne(ctx, n)
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		n.ID = oid
+	}
+	return nil
+}
+
+// GetByUser lists a user's inbox, newest first
+func (r *mongoNotificationRepo) GetByUser(userID string, unreadOnly bool) ([]Domain.Notification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if unreadOnly {
+		filter["read"] = false
+	}
+	cursor, err := r.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []Domain.Notification{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkRead only matches the user's own notifications
+func (r *mongoNotificationRepo) MarkRead(id, userID string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": objID, "user_id": userID}, bson.M{"$set": bson.M{"read": true}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("notification not found")
+	}
+	return nil
+}
+
+// GetPreferences returns the defaults for users who have not saved any
+func (r *mongoNotificationRepo) GetPreferences(userID string) (*Domain.NotificationPreferences, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p Domain.NotificationPreferences
+	if err := r.preferences.FindOne(ctx, bson.M{"_id": userID}).Decode(&p); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return &Domain.NotificationPreferences{UserID: userID}, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *mongoNotificationRepo) SavePreferences(p *Domain.NotificationPreferences) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.preferences.ReplaceOne(ctx, bson.M{"_id": p.UserID}, p, options.Replace().SetUpsert(true))
+	return err
+}
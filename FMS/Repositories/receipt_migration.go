@@ -0,0 +1,70 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// legacyReceiptExpense is the part of an expense stored before receipt
+// uploads, when an expense held a single receipt_url
+type legacyReceiptExpense struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	ReceiptURL string             `bson:"receipt_url"`
+	CreatedBy  string             `bson:"created_by,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at,omitempty"`
+}
+
+// MigrateReceiptURLs moves the receipt_url of older expenses into their
+// receipts list and returns how many expenses changed. Only expenses that
+// still have receipt_url are touched, so it is safe to run on every start.
+func MigrateReceiptURLs(db *mongo.Database) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	coll := db.Collection("expenses")
+	cursor, err := coll.Find(ctx, bson.M{"receipt_url": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"receipt_url": 1, "created_by": 1, "created_at": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var migrated int64
+	for cursor.Next(ctx) {
+		var e legacyReceiptExpense
+		if err := cursor.Decode(&e); err != nil {
+			return migrated, err
+		}
+		// matching the URL too leaves an expense alone if it changed since it was read
+		res, err := coll.UpdateOne(ctx, bson.M{"_id": e.ID, "receipt_url": e.ReceiptURL}, receiptURLUpdate(e))
+		if err != nil {
+			return migrated, err
+		}
+		migrated += res.ModifiedCount
+	}
+	return migrated, cursor.Err()
+}
+
+// receiptURLUpdate turns e's receipt_url into a receipt uploaded by the
+// expense's creator when the expense was created. An empty URL is dropped.
+func receiptURLUpdate(e legacyReceiptExpense) bson.M {
+	update := bson.M{
+		"$unset": bson.M{"receipt_url": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+	if e.ReceiptURL != "" {
+		update["$push"] = bson.M{"receipts": Domain.Receipt{
+			ID:         primitive.NewObjectID(),
+			URL:        e.ReceiptURL,
+			UploadedBy: e.CreatedBy,
+			UploadedAt: e.CreatedAt,
+		}}
+	}
+	return update
+}
@@ -0,0 +1,61 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReceiptURLUpdate_MovesURLIntoReceipts(t *testing.T) {
+	created := time.Date(2031, 3, 4, 10, 0, 0, 0, time.UTC)
+	raw, _ := bson.Marshal(bson.M{
+		"_id":         primitive.NewObjectID(),
+		"title":       "Taxi",
+		"receipt_url": "https://files.example.com/taxi.pdf",
+		"created_by":  "u1",
+		"created_at":  created,
+	})
+	var legacy legacyReceiptExpense
+	if err := bson.Unmarshal(raw, &legacy); err != nil {
+		t.Fatalf("decode legacy expense: %v", err)
+	}
+
+	update := receiptURLUpdate(legacy)
+	if unset, _ := update["$unset"].(bson.M); unset == nil || len(unset) != 1 || unset["receipt_url"] == nil {
+		t.Fatalf("expected receipt_url to be removed, got %v", update)
+	}
+	if inc, _ := update["$inc"].(bson.M); inc["version"] != 1 {
+		t.Fatalf("expected the version to move on, got %v", update)
+	}
+
+	// the pushed receipt must decode as a receipt of the current model
+	push, _ := update["$push"].(bson.M)
+	doc, err := bson.Marshal(bson.M{"receipts": bson.A{push["receipts"]}})
+	if err != nil {
+		t.Fatalf("encode receipt: %v", err)
+	}
+	var migrated Domain.Expense
+	if err := bson.Unmarshal(doc, &migrated); err != nil {
+		t.Fatalf("decode migrated expense: %v", err)
+	}
+	if len(migrated.Receipts) != 1 {
+		t.Fatalf("expected one receipt, got %+v", migrated.Receipts)
+	}
+	r := migrated.Receipts[0]
+	if r.ID.IsZero() || r.URL != "https://files.example.com/taxi.pdf" || r.UploadedBy != "u1" || !r.UploadedAt.Equal(created) || r.StorageKey != "" {
+		t.Fatalf("unexpected receipt %+v", r)
+	}
+}
+
+func TestReceiptURLUpdate_DropsEmptyURL(t *testing.T) {
+	update := receiptURLUpdate(legacyReceiptExpense{ID: primitive.NewObjectID()})
+	if _, ok := update["$push"]; ok {
+		t.Fatalf("an empty receipt_url must not become a receipt, got %v", update)
+	}
+	if _, ok := update["$unset"]; !ok {
+		t.Fatalf("expected the empty receipt_url to be removed, got %v", update)
+	}
+}
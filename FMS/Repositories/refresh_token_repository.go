@@ -0,0 +1,85 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type RefreshTokenRepository interface {
+	Create(t *Domain.RefreshToken) error
+	FindByHash(hash string) (*Domain.RefreshToken, error)
+	// Use marks the token used and reports false when it already was, or has
+	// been revoked, so only one of two concurrent refreshes wins
+	Use(id primitive.ObjectID, at time.Time) (bool, error)
+	RevokeFamily(familyID primitive.ObjectID, at time.Time) error
+	RevokeUser(userID string, at time.Time) error
+}
+
+type mongoRefreshTokenRepo struct {
+	coll *mongo.Collection
+}
+
+func NewMongoRefreshTokenRepository(db *mongo.Database) RefreshTokenRepository {
+	return &mongoRefreshTokenRepo{coll: db.Collection("refresh_tokens")}
+}
+
+func (r *mongoRefreshTokenRepo) Create(t *Domain.RefreshToken) error {
+	t.ID = primitive.NewObjectID()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.coll.InsertOne(ctx, t)
+	return err
+}
+
+func (r *mongoRefreshTokenRepo) FindByHash(hash string) (*Domain.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var t Domain.RefreshToken
+	if err := r.coll.FindOne(ctx, bson.M{"token_hash": hash}).Decode(&t); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("refresh token not found")
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *mongoRefreshTokenRepo) Use(id primitive.ObjectID, at time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": id, "used_at": nil, "revoked_at": nil},
+		bson.M{"$set": bson.M{"used_at": at}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *mongoRefreshTokenRepo) RevokeFamily(familyID primitive.ObjectID, at time.Time) error {
+	return r.revoke(bson.M{"family_id": familyID}, at)
+}
+
+func (r *mongoRefreshTokenRepo) RevokeUser(userID string, at time.Time) error {
+	return r.revoke(bson.M{"user_id": userID}, at)
+}
+
+func (r *mongoRefreshTokenRepo) revoke(filter bson.M, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter["revoked_at"] = nil
+	_, err := r.coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": at}})
+	return err
+}
@@ -0,0 +1,378 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// topRequestersLimit caps the requester leaderboard in spend reports
+const topRequestersLimit = 10
+
+// utilizationLimit caps the most utilized budgets listed in the budget report,
+// keeping the $facet document far below Mongo's 16MB limit
+const utilizationLimit = 50
+
+// exportTimeout bounds how long a streamed export may keep its cursor open
+const exportTimeout = 2 * time.Minute
+
+// ReportStatsRepository computes report aggregates inside Mongo so that
+// documents are never loaded into memory
+type ReportStatsRepository interface {
+	Overview(filter Domain.ReportFilter) (*Domain.ReportOverview, error)
+	BudgetStats(filter Domain.ReportFilter) (*Domain.BudgetReport, error)
+	CashRequestStats(filter Domain.ReportFilter) (*Domain.SpendReport, error)
+	ExpenseStats(filter Domain.ReportFilter) (*Domain.SpendReport, error)
+	// Each* iterate the matching records through a cursor, oldest first
+	EachBudget(filter Domain.ReportFilter, fn func(*Domain.Budget) error) error
+	EachCashRequest(filter Domain.ReportFilter, fn func(*Domain.CashRequest) error) error
+	EachExpense(filter Domain.ReportFilter, fn func(*Domain.Expense) error) error
+}
+
+type mongoReportStatsRepo struct {
+	budgets      *mongo.Collection
+	cashRequests *mongo.Collection
+	expenses     *mongo.Collection
+}
+
+func NewMongoReportStatsRepository(db *mongo.Database) ReportStatsRepository {
+	return &mongoReportStatsRepo{
+		budgets:      db.Collection("budgets"),
+		cashRequests: db.Collection("cash_requests"),
+		expenses:     db.Collection("expenses"),
+	}
+}
+
+// totals row produced by the $group stages below
+type statsTotals struct {
+	Count     int64        `bson:"count"`
+	Total     Domain.Money `bson:"total"`
+	Remaining Domain.Money `bson:"remaining"`
+	Settled   Domain.Money `bson:"settled"`
+}
+
+func (r *mongoReportStatsRepo) Overview(filter Domain.ReportFilter) (*Domain.ReportOverview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	b, err := aggregateTotals(ctx, r.budgets, filter, baseOf("$amount"), baseOf("$remaining"))
+	if err != nil {
+		return nil, err
+	}
+	c, err := aggregateTotals(ctx, r.cashRequests, filter, baseOf("$amount"), nil, Domain.StatusDisbursed, Domain.StatusSettled)
+	if err != nil {
+		return nil, err
+	}
+	e, err := aggregateTotals(ctx, r.expenses, filter, baseOf("$amount"), nil, Domain.StatusVerified)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Domain.ReportOverview{
+		BudgetsCount:       b.Count,
+		CashRequestsCount:  c.Count,
+		ExpensesCount:      e.Count,
+		TotalAllocated:     b.Total,
+		TotalRemaining:     b.Remaining,
+		TotalDisbursed:     c.Settled,
+		TotalVerifiedSpend: e.Settled,
+	}, nil
+}
+
+func (r *mongoReportStatsRepo) BudgetStats(filter Domain.ReportFilter) (*Domain.BudgetReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// money carried forward to the next period was not spent
+	spent := bson.M{"$subtract": bson.A{
+		bson.M{"$subtract": bson.A{"$amount", "$remaining"}},
+		bson.M{"$ifNull": bson.A{"$carried_out", 0}},
+	}}
+	facet := bson.M{
+		"totals": bson.A{
+			bson.M{"$group": bson.M{
+				"_id":       nil,
+				"count":     bson.M{"$sum": 1},
+				"total":     bson.M{"$sum": baseOf("$amount")},
+				"remaining": bson.M{"$sum": baseOf("$remaining")},
+			}},
+		},
+		"by_currency":   groupByCurrency(),
+		"by_status":     groupBy(bson.M{"$ifNull": bson.A{"$status", "unknown"}}),
+		"by_department": groupBy(bson.M{"$ifNull": bson.A{"$department", "unassigned"}}),
+		"by_month":      groupBy(monthKey),
+		"utilization": bson.A{
+			bson.M{"$match": bson.M{"status": "approved"}},
+			bson.M{"$project": bson.M{
+				"title":      1,
+				"department": 1,
+				"currency":   1,
+				"amount":     1,
+				"remaining":  1,
+				"spent":      spent,
+				"utilization": bson.M{"$cond": bson.A{
+					bson.M{"$gt": bson.A{"$amount", 0}},
+					bson.M{"$divide": bson.A{spent, "$amount"}},
+					0,
+				}},
+			}},
+			bson.M{"$sort": bson.D{{Key: "utilization", Value: -1}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": utilizationLimit},
+		},
+	}
+
+	var row struct {
+		Totals       []statsTotals              `bson:"totals"`
+		ByCurrency   []Domain.GroupTotal        `bson:"by_currency"`
+		ByStatus     []Domain.GroupTotal        `bson:"by_status"`
+		ByDepartment []Domain.GroupTotal        `bson:"by_department"`
+		ByMonth      []Domain.GroupTotal        `bson:"by_month"`
+		Utilization  []Domain.BudgetUtilization `bson:"utilization"`
+	}
+	if err := aggregateFacet(ctx, r.budgets, filter, facet, &row); err != nil {
+		return nil, err
+	}
+
+	report := &Domain.BudgetReport{
+		ByCurrency:   row.ByCurrency,
+		ByStatus:     row.ByStatus,
+		ByDepartment: row.ByDepartment,
+		ByMonth:      row.ByMonth,
+		Utilization:  row.Utilization,
+	}
+	if len(row.Totals) > 0 {
+		report.Count = row.Totals[0].Count
+		report.TotalAmount = row.Totals[0].Total
+		report.TotalRemaining = row.Totals[0].Remaining
+	}
+	return report, nil
+}
+
+func (r *mongoReportStatsRepo) CashRequestStats(filter Domain.ReportFilter) (*Domain.SpendReport, error) {
+	return r.spendStats(r.cashRequests, filter, "$requester")
+}
+
+func (r *mongoReportStatsRepo) ExpenseStats(filter Domain.ReportFilter) (*Domain.SpendReport, error) {
+	return r.spendStats(r.expenses, filter, "$created_by")
+}
+
+// spendStats aggregates a collection of amounts linked to budgets; the department
+// of each record comes from its budget
+func (r *mongoReportStatsRepo) spendStats(coll *mongo.Collection, filter Domain.ReportFilter, requesterField string) (*Domain.SpendReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	facet := bson.M{
+		"totals": bson.A{
+			bson.M{"$group": bson.M{"_id": nil, "count": bson.M{"$sum": 1}, "total": bson.M{"$sum": baseOf("$amount")}}},
+		},
+		"by_currency": groupByCurrency(),
+		"by_status":   groupBy(bson.M{"$ifNull": bson.A{"$status", "unknown"}}),
+		"by_department": append(bson.A{
+			bson.M{"$lookup": bson.M{"from": r.budgets.Name(), "localField": "budget_id", "foreignField": "_id", "as": "budget"}},
+		}, groupBy(bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$budget.department", 0}}, "unassigned"}})...),
+		"by_month": groupBy(monthKey),
+		"top_requesters": bson.A{
+			bson.M{"$group": bson.M{
+				"_id":   bson.M{"$ifNull": bson.A{requesterField, "unknown"}},
+				"count": bson.M{"$sum": 1},
+				"total": bson.M{"$sum": baseOf("$amount")},
+			}},
+			bson.M{"$sort": bson.D{{Key: "total", Value: -1}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": topRequestersLimit},
+		},
+	}
+
+	var row struct {
+		Totals        []statsTotals       `bson:"totals"`
+		ByCurrency    []Domain.GroupTotal `bson:"by_currency"`
+		ByStatus      []Domain.GroupTotal `bson:"by_status"`
+		ByDepartment  []Domain.GroupTotal `bson:"by_department"`
+		ByMonth       []Domain.GroupTotal `bson:"by_month"`
+		TopRequesters []Domain.GroupTotal `bson:"top_requesters"`
+	}
+	if err := aggregateFacet(ctx, coll, filter, facet, &row); err != nil {
+		return nil, err
+	}
+
+	report := &Domain.SpendReport{
+		ByCurrency:    row.ByCurrency,
+		ByStatus:      row.ByStatus,
+		ByDepartment:  row.ByDepartment,
+		ByMonth:       row.ByMonth,
+		TopRequesters: row.TopRequesters,
+	}
+	if len(row.Totals) > 0 {
+		report.Count = row.Totals[0].Count
+		report.Total = row.Totals[0].Total
+	}
+	return report, nil
+}
+
+// monthKey buckets records by the month they were created in, e.g. "2025-03"
+var monthKey = bson.M{"$ifNull": bson.A{
+	bson.M{"$dateToString": bson.M{"format": "%Y-%m", "date": "$created_at"}},
+	"unknown",
+}}
+
+// currencyKey groups by currency; records from before currencies were tracked
+// have none and are reported under "" for the usecase to fold into the base currency
+var currencyKey = bson.M{"$ifNull": bson.A{"$currency", ""}}
+
+// baseOf converts a money field to the base currency with the record's stored rate,
+// rounded to whole minor units per record like Money.MulRate
+func baseOf(field string) bson.M {
+	return bson.M{"$round": bson.A{
+		bson.M{"$multiply": bson.A{field, bson.M{"$ifNull": bson.A{"$exchange_rate", 1}}}}, 0,
+	}}
+}
+
+// groupBy returns facet stages that count and sum amounts per key, converted
+// to the base currency since a key may span currencies, ordered by key
+func groupBy(key interface{}) bson.A {
+	return bson.A{
+		bson.M{"$group": bson.M{
+			"_id":   key,
+			"count": bson.M{"$sum": 1},
+			"total": bson.M{"$sum": baseOf("$amount")},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+}
+
+// groupByCurrency is groupBy per currency, where the amounts as entered can
+// be added up too
+func groupByCurrency() bson.A {
+	return bson.A{
+		bson.M{"$group": bson.M{
+			"_id":        currencyKey,
+			"count":      bson.M{"$sum": 1},
+			"total":      bson.M{"$sum": "$amount"},
+			"base_total": bson.M{"$sum": baseOf("$amount")},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+}
+
+// createdBetween turns a report filter into a $match on created_at
+func createdBetween(filter Domain.ReportFilter) bson.M {
+	created := bson.M{}
+	if !filter.From.IsZero() {
+		created["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		created["$lte"] = filter.To
+	}
+	if len(created) == 0 {
+		return bson.M{}
+	}
+	return bson.M{"created_at": created}
+}
+
+func aggregateFacet(ctx context.Context, coll *mongo.Collection, filter Domain.ReportFilter, facet bson.M, out interface{}) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: createdBetween(filter)}},
+		{{Key: "$facet", Value: facet}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	// $facet always yields exactly one document
+	if cursor.Next(ctx) {
+		return cursor.Decode(out)
+	}
+	return cursor.Err()
+}
+
+// aggregateTotals counts a collection and sums the amount expression; remaining is
+// summed when set, and settledStatuses sums the amounts of records in any of those statuses
+func aggregateTotals(ctx context.Context, coll *mongo.Collection, filter Domain.ReportFilter, amount, remaining interface{}, settledStatuses ...string) (*statsTotals, error) {
+	group := bson.M{
+		"_id":   nil,
+		"count": bson.M{"$sum": 1},
+		"total": bson.M{"$sum": amount},
+	}
+	if remaining != nil {
+		group["remaining"] = bson.M{"$sum": remaining}
+	}
+	if len(settledStatuses) > 0 {
+		group["settled"] = bson.M{"$sum": bson.M{"$cond": bson.A{
+			bson.M{"$in": bson.A{"$status", settledStatuses}}, amount, 0,
+		}}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: createdBetween(filter)}},
+		{{Key: "$group", Value: group}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals statsTotals
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&totals); err != nil {
+			return nil, err
+		}
+	}
+	return &totals, cursor.Err()
+}
+
+func (r *mongoReportStatsRepo) EachBudget(filter Domain.ReportFilter, fn func(*Domain.Budget) error) error {
+	return each(r.budgets, filter, func(cursor *mongo.Cursor) error {
+		var b Domain.Budget
+		if err := cursor.Decode(&b); err != nil {
+			return err
+		}
+		return fn(&b)
+	})
+}
+
+func (r *mongoReportStatsRepo) EachCashRequest(filter Domain.ReportFilter, fn func(*Domain.CashRequest) error) error {
+	return each(r.cashRequests, filter, func(cursor *mongo.Cursor) error {
+		var cr Domain.CashRequest
+		if err := cursor.Decode(&cr); err != nil {
+			return err
+		}
+		return fn(&cr)
+	})
+}
+
+func (r *mongoReportStatsRepo) EachExpense(filter Domain.ReportFilter, fn func(*Domain.Expense) error) error {
+	return each(r.expenses, filter, func(cursor *mongo.Cursor) error {
+		var e Domain.Expense
+		if err := cursor.Decode(&e); err != nil {
+			return err
+		}
+		return fn(&e)
+	})
+}
+
+// each walks a cursor one document at a time instead of loading the result set
+func each(coll *mongo.Collection, filter Domain.ReportFilter, decode func(*mongo.Cursor) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := coll.Find(ctx, createdBetween(filter), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if err := decode(cursor); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,109 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SearchRepository runs full-text searches on the text indexes of the budget,
+// cash request and expense collections
+type SearchRepository interface {
+	Search(q Domain.SearchQuery) ([]Domain.SearchResult, error)
+}
+
+type mongoSearchRepo struct {
+	collections map[string]*mongo.Collection
+	fields      map[string]listFields
+}
+
+func NewMongoSearchRepository(db *mongo.Database) SearchRepository {
+	return &mongoSearchRepo{
+		collections: map[string]*mongo.Collection{
+			Domain.EntityBudget:      db.Collection("budgets"),
+			Domain.EntityCashRequest: db.Collection("cash_requests"),
+			Domain.EntityExpense:     db.Collection("expenses"),
+		},
+		fields: map[string]listFields{
+			Domain.EntityBudget:      budgetListFields,
+			Domain.EntityCashRequest: cashListFields,
+			Domain.EntityExpense:     expenseListFields,
+		},
+	}
+}
+
+// searchHit is the part of a budget, cash request or expense a result shows
+type searchHit struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Title       string             `bson:"title"`
+	Description string             `bson:"description"`
+	Status      string             `bson:"status"`
+	Amount      Domain.Money       `bson:"amount"`
+	Currency    string             `bson:"currency"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	Score       float64            `bson:"score"`
+}
+
+// Search takes the best q.Limit matches of each collection and merges them, so
+// the overall top q.Limit are always among them
+func (r *mongoSearchRepo) Search(q Domain.SearchQuery) ([]Domain.SearchResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"title": 1, "description": 1, "status": 1, "amount": 1, "currency": 1, "created_at": 1, "score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}}).
+		SetLimit(int64(q.Limit))
+
+	results := []Domain.SearchResult{}
+	for _, entity := range q.Types {
+		coll, ok := r.collections[entity]
+		if !ok {
+			continue
+		}
+		filter := listFilter(Domain.ListQuery{Scope: q.Scopes[entity]}, r.fields[entity])
+		filter["$text"] = bson.M{"$search": q.Text}
+
+		cursor, err := coll.Find(ctx, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+		var hits []searchHit
+		err = cursor.All(ctx, &hits)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hits {
+			results = append(results, Domain.SearchResult{
+				Type:        entity,
+				ID:          h.ID,
+				Title:       h.Title,
+				Description: h.Description,
+				Status:      h.Status,
+				Amount:      h.Amount,
+				Currency:    h.Currency,
+				CreatedAt:   h.CreatedAt,
+				Score:       h.Score,
+			})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
@@ -4,17 +4,39 @@ import (
 	"FMS/Domain"
 	"context"
 	"errors"
+	"regexp"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type UserRepository interface {
 	Create(u *Domain.User) error
 	FindByUsername(username string) (*Domain.User, error)
+	FindByID(id string) (*Domain.User, error)
+	List(q Domain.UserQuery) ([]Domain.User, int64, error)
+	FindActive() ([]Domain.User, error)
 	Count() (int64, error)
-	Promote(username string) error
+	UpdateRole(id, role string) error
+	UpdateDepartment(id, department string, head bool) error
+	SetDeactivated(id string, deactivated bool) error
+	UpdatePassword(id, passwordHash string) error
+	// BumpTokenVersion raises the user's token version, revoking their access tokens
+	BumpTokenVersion(id string) error
+	// SetTOTP stores a TOTP secret and the hashes of its recovery codes; an
+	// empty secret turns two-factor authentication off
+	SetTOTP(id, secret string, enabled bool, recoveryHashes []string) error
+	// UseTOTPStep records step as used and reports false when it, or a later
+	// step, already was
+	UseTOTPStep(id string, step int64) (bool, error)
+	// UseRecoveryCode removes the recovery code hash and reports false when it was already gone
+	UseRecoveryCode(id, hash string) (bool, error)
+	// SetMFAChallenge replaces the pending login challenge; nil clears it
+	SetMFAChallenge(id string, c *Domain.MFAChallenge) error
+	CountMFAAttempt(id string) error
 }
 
 type mongoUserRepo struct {
@@ -26,6 +48,7 @@ func NewMongoUserRepository(db *mongo.Database) UserRepository {
 }
 
 func (r *mongoUserRepo) Create(u *Domain.User) error {
+	u.ID = primitive.NewObjectID()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	_, err := r.coll.InsertOne(ctx, u)
@@ -45,16 +68,150 @@ func (r *mongoUserRepo) FindByUsername(username string) (*Domain.User, error) {
 	return &u, nil
 }
 
+func (r *mongoUserRepo) FindByID(id string) (*Domain.User, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid ID")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var u Domain.User
+	if err := r.coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&u); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+// List returns one page of users ordered by username together with the total match count
+func (r *mongoUserRepo) List(q Domain.UserQuery) ([]Domain.User, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if q.Search != "" {
+		filter["username"] = bson.M{"$regex": regexp.QuoteMeta(q.Search), "$options": "i"}
+	}
+	total, err := r.coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "username", Value: 1}}).
+		SetSkip(int64((q.Page - 1) * q.Limit)).
+		SetLimit(int64(q.Limit))
+	cursor, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []Domain.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// FindActive lists every user who has not been deactivated
+func (r *mongoUserRepo) FindActive() ([]Domain.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cursor, err := r.coll.Find(ctx, bson.M{"deactivated": bson.M{"$ne": true}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	users := []Domain.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 func (r *mongoUserRepo) Count() (int64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	return r.coll.CountDocuments(ctx, bson.D{})
 }
 
-func (r *mongoUserRepo) Promote(username string) error {
+func (r *mongoUserRepo) UpdateRole(id, role string) error {
+	return r.set(id, bson.M{"role": role})
+}
+
+func (r *mongoUserRepo) UpdateDepartment(id, department string, head bool) error {
+	return r.set(id, bson.M{"department": department, "department_head": head})
+}
+
+func (r *mongoUserRepo) SetDeactivated(id string, deactivated bool) error {
+	return r.set(id, bson.M{"deactivated": deactivated})
+}
+
+func (r *mongoUserRepo) UpdatePassword(id, passwordHash string) error {
+	return r.set(id, bson.M{"password_hash": passwordHash})
+}
+
+func (r *mongoUserRepo) BumpTokenVersion(id string) error {
+	return r.update(id, bson.M{"$inc": bson.M{"token_version": 1}})
+}
+
+func (r *mongoUserRepo) SetTOTP(id, secret string, enabled bool, recoveryHashes []string) error {
+	if secret == "" {
+		return r.update(id, bson.M{"$unset": bson.M{"totp_secret": "", "totp_enabled": "", "recovery_codes": "", "mfa_challenge": ""}})
+	}
+	return r.set(id, bson.M{"totp_secret": secret, "totp_enabled": enabled, "recovery_codes": recoveryHashes})
+}
+
+func (r *mongoUserRepo) UseTOTPStep(id string, step int64) (bool, error) {
+	return r.updateIf(id, bson.M{"totp_last_step": bson.M{"$not": bson.M{"$gte": step}}}, bson.M{"$set": bson.M{"totp_last_step": step}})
+}
+
+func (r *mongoUserRepo) UseRecoveryCode(id, hash string) (bool, error) {
+	return r.updateIf(id, bson.M{"recovery_codes": hash}, bson.M{"$pull": bson.M{"recovery_codes": hash}})
+}
+
+func (r *mongoUserRepo) SetMFAChallenge(id string, c *Domain.MFAChallenge) error {
+	if c == nil {
+		return r.update(id, bson.M{"$unset": bson.M{"mfa_challenge": ""}})
+	}
+	return r.set(id, bson.M{"mfa_challenge": c})
+}
+
+func (r *mongoUserRepo) CountMFAAttempt(id string) error {
+	return r.update(id, bson.M{"$inc": bson.M{"mfa_challenge.attempts": 1}})
+}
+
+// updateIf applies update only while the user also matches cond, and reports whether it did
+func (r *mongoUserRepo) updateIf(id string, cond, update bson.M) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errors.New("invalid ID")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cond["_id"] = objID
+	res, err := r.coll.UpdateOne(ctx, cond, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *mongoUserRepo) set(id string, fields bson.M) error {
+	return r.update(id, bson.M{"$set": fields})
+}
+
+func (r *mongoUserRepo) update(id string, update bson.M) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	res, err := r.coll.UpdateOne(ctx, bson.M{"username": username}, bson.M{"$set": bson.M{"role": "admin"}})
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": objID}, update)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,50 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// versionMatch matches a record at version. Records written before versions
+// existed have no version field and count as version 0.
+func versionMatch(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
+
+// updateVersioned sets fields on the record only while it is still at
+// *version, and moves *version on to the version it wrote. A record that has
+// moved on is reported as a *Domain.ConflictError.
+func updateVersioned(coll *mongo.Collection, entity string, objID primitive.ObjectID, version *int64, set bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := coll.UpdateOne(ctx,
+		bson.M{"_id": objID, "version": versionMatch(*version)},
+		bson.M{"$set": set, "$inc": bson.M{"version": 1}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		n, err := coll.CountDocuments(ctx, bson.M{"_id": objID})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errors.New(entity + " not found")
+		}
+		return &Domain.ConflictError{Entity: entity, ID: objID.Hex(), Version: *version}
+	}
+
+	*version++
+	return nil
+}
@@ -0,0 +1,282 @@
+package Repositories
+
+import (
+	"FMS/Domain"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookSubscriptionRepository interface {
+	Create(s *Domain.WebhookSubscription) error
+	GetAll() ([]Domain.WebhookSubscription, error)
+	GetByID(id string) (*Domain.WebhookSubscription, error)
+	GetByEvent(event string) ([]Domain.WebhookSubscription, error)
+	Update(id string, s *Domain.WebhookSubscription) error
+	Delete(id string) error
+}
+
+type mongoWebhookSubscriptionRepo struct {
+	coll *mongo.Collection
+}
+
+func NewMongoWebhookSubscriptionRepository(db *mongo.Database) WebhookSubscriptionRepository {
+	return &mongoWebhookSubscriptionRepo{coll: db.Collection("webhook_subscriptions")}
+}
+
+func (r *mongoWebhookSubscriptionRepo) Create(s *Domain.WebhookSubscription) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.InsertOne(ctx, s)
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		s.ID = oid
+	}
+	return nil
+}
+
+func (r *mongoWebhookSubscriptionRepo) GetAll() ([]Domain.WebhookSubscription, error) {
+	return r.find(bson.M{})
+}
+
+// GetByEvent lists the active subscriptions to event
+func (r *mongoWebhookSubscriptionRepo) GetByEvent(event string) ([]Domain.WebhookSubscription, error) {
+	return r.find(bson.M{"events": event, "active": true})
+}
+
+func (r *mongoWebhookSubscriptionRepo) find(filter bson.M) ([]Domain.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subs := []Domain.WebhookSubscription{}
+	if err := cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+func (r *mongoWebhookSubscriptionRepo) GetByID(id string) (*Domain.WebhookSubscription, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var s Domain.WebhookSubscription
+	if err := r.coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&s); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("webhook subscription not found")
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Update changes the URL, events and active flag; the secret is kept
+func (r *mongoWebhookSubscriptionRepo) Update(id string, s *Domain.WebhookSubscription) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{
+		"url":    s.URL,
+		"events": s.Events,
+		"active": s.Active,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("webhook subscription not found")
+	}
+	return nil
+}
+
+func (r *mongoWebhookSubscriptionRepo) Delete(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return errors.New("webhook subscription not found")
+	}
+	return nil
+}
+
+// WebhookDeliveryRepository is the persistent outbox of webhook deliveries
+type WebhookDeliveryRepository interface {
+	Create(d *Domain.WebhookDelivery) error
+	// ClaimDue leases the oldest pending delivery due at now until leaseUntil,
+	// so concurrent workers never send it twice, and returns nil when none is due
+	ClaimDue(now, leaseUntil time.Time) (*Domain.WebhookDelivery, error)
+	// RecordAttempt stores the outcome of an attempt and its log entry
+	RecordAttempt(d *Domain.WebhookDelivery, attempt Domain.WebhookAttempt) error
+	GetByID(id string) (*Domain.WebhookDelivery, error)
+	Find(filter Domain.WebhookDeliveryFilter) ([]Domain.WebhookDelivery, error)
+	Retry(id string, at time.Time) error
+}
+
+type mongoWebhookDeliveryRepo struct {
+	coll *mongo.Collection
+}
+
+func NewMongoWebhookDeliveryRepository(db *mongo.Database) WebhookDeliveryRepository {
+	return &mongoWebhookDeliveryRepo{coll: db.Collection("webhook_deliveries")}
+}
+
+func (r *mongoWebhookDeliveryRepo) Create(d *Domain.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if d.ID.IsZero() {
+		d.ID = primitive.NewObjectID()
+	}
+	_, err := r.coll.InsertOne(ctx, d)
+	return err
+}
+
+func (r *mongoWebhookDeliveryRepo) ClaimDue(now, leaseUntil time.Time) (*Domain.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+	var d Domain.WebhookDelivery
+	err := r.coll.FindOneAndUpdate(ctx,
+		bson.M{"status": Domain.DeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": leaseUntil}},
+		opts,
+	).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *mongoWebhookDeliveryRepo) RecordAttempt(d *Domain.WebhookDelivery, attempt Domain.WebhookAttempt) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"next_attempt_at": d.NextAttemptAt,
+		"last_error":      d.LastError,
+	}
+	if !d.DeliveredAt.IsZero() {
+		set["delivered_at"] = d.DeliveredAt
+	}
+	_, err := r.coll.UpdateOne(ctx, bson.M{"_id": d.ID}, bson.M{"$set": set, "$push": bson.M{"log": attempt}})
+	return err
+}
+
+func (r *mongoWebhookDeliveryRepo) GetByID(id string) (*Domain.WebhookDelivery, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var d Domain.WebhookDelivery
+	if err := r.coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&d); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("webhook delivery not found")
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Find lists deliveries newest first
+func (r *mongoWebhookDeliveryRepo) Find(filter Domain.WebhookDeliveryFilter) ([]Domain.WebhookDelivery, error) {
+	query := bson.M{}
+	if filter.SubscriptionID != "" {
+		objID, err := primitive.ObjectIDFromHex(filter.SubscriptionID)
+		if err != nil {
+			return nil, errors.New("invalid ID")
+		}
+		query["subscription_id"] = objID
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Event != "" {
+		query["event"] = filter.Event
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	cursor, err := r.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []Domain.WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Retry puts a failed delivery back in the outbox with a fresh set of attempts
+func (r *mongoWebhookDeliveryRepo) Retry(id string, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": objID, "status": Domain.DeliveryFailed},
+		bson.M{"$set": bson.M{"status": Domain.DeliveryPending, "attempts": 0, "next_attempt_at": at}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("only failed deliveries can be retried")
+	}
+	return nil
+}
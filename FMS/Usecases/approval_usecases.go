@@ -0,0 +1,243 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// defaultApprovalChain applies when no policy is configured, matching the
+// single finance sign-off budgets and cash requests had before policies existed
+var defaultApprovalChain = []string{Domain.LevelFinance}
+
+// ApprovalUsecase manages approval policies and the approver's inbox
+type ApprovalUsecase interface {
+	GetPending(actor Domain.Actor) ([]Domain.PendingApproval, error)
+	GetPolicies() ([]Domain.ApprovalPolicy, error)
+	SavePolicy(p *Domain.ApprovalPolicy) (*Domain.ApprovalPolicy, error)
+	DeletePolicy(id string) error
+}
+
+type approvalUsecase struct {
+	policyRepo Repositories.ApprovalPolicyRepository
+	budgetRepo Repositories.BudgetRepository
+	cashRepo   Repositories.CashRequestRepository
+}
+
+func NewApprovalUsecase(policies Repositories.ApprovalPolicyRepository, budgetRepo Repositories.BudgetRepository, cashRepo Repositories.CashRequestRepository) ApprovalUsecase {
+	return &approvalUsecase{policyRepo: policies, budgetRepo: budgetRepo, cashRepo: cashRepo}
+}
+
+// GetPending lists the pending budgets and cash requests whose next approval
+// level the actor can sign off, oldest first
+func (u *approvalUsecase) GetPending(actor Domain.Actor) ([]Domain.PendingApproval, error) {
+	pending := []Domain.PendingApproval{}
+
+	budgets, err := u.budgetRepo.GetByStatus("pending")
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range budgets {
+		chain, err := resolveChain(u.policyRepo, Domain.EntityBudget, b.Department, inBase(b.Amount, b.ExchangeRate), b.ApprovalChain)
+		if err != nil {
+			return nil, err
+		}
+		level, ok := awaitingActor(actor, chain, b.Approvals, b.Department, b.CreatedBy)
+		if !ok {
+			continue
+		}
+		pending = append(pending, Domain.PendingApproval{
+			EntityType: Domain.EntityBudget,
+			EntityID:   b.ID,
+			Title:      b.Title,
+			Department: b.Department,
+			Amount:     b.Amount,
+			Level:      level,
+			Requester:  b.CreatedBy,
+			CreatedAt:  b.CreatedAt,
+		})
+	}
+
+	requests, err := u.cashRepo.GetByStatus("pending")
+	if err != nil {
+		return nil, err
+	}
+	departments := map[string]string{}
+	for _, r := range requests {
+		dept, seen := departments[r.BudgetID.Hex()]
+		if !seen {
+			dept = cashRequestDepartment(u.budgetRepo, &r)
+			departments[r.BudgetID.Hex()] = dept
+		}
+		chain, err := resolveChain(u.policyRepo, Domain.EntityCashRequest, dept, inBase(r.Amount, r.ExchangeRate), r.ApprovalChain)
+		if err != nil {
+			return nil, err
+		}
+		level, ok := awaitingActor(actor, chain, r.Approvals, dept, r.Requester)
+		if !ok {
+			continue
+		}
+		pending = append(pending, Domain.PendingApproval{
+			EntityType: Domain.EntityCashRequest,
+			EntityID:   r.ID,
+			Title:      r.Title,
+			Department: dept,
+			Amount:     r.Amount,
+			Level:      level,
+			Requester:  r.Requester,
+			CreatedAt:  r.CreatedAt,
+		})
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	return pending, nil
+}
+
+func (u *approvalUsecase) GetPolicies() ([]Domain.ApprovalPolicy, error) {
+	return u.policyRepo.GetAll()
+}
+
+// SavePolicy creates or replaces the policy for the entity type and department
+func (u *approvalUsecase) SavePolicy(p *Domain.ApprovalPolicy) (*Domain.ApprovalPolicy, error) {
+	if p.EntityType != Domain.EntityBudget && p.EntityType != Domain.EntityCashRequest {
+		return nil, errors.New("entity_type must be budget or cash_request")
+	}
+	if len(p.Steps) == 0 {
+		return nil, errors.New("at least one approval step is required")
+	}
+	seen := map[string]bool{}
+	for _, s := range p.Steps {
+		if !Domain.ValidLevel(s.Level) {
+			return nil, fmt.Errorf("invalid approval level %q", s.Level)
+		}
+		if seen[s.Level] {
+			return nil, fmt.Errorf("approval level %q appears more than once", s.Level)
+		}
+		if s.MinAmount < 0 {
+			return nil, errors.New("min_amount cannot be negative")
+		}
+		seen[s.Level] = true
+	}
+	p.Department = normalizeDepartment(p.Department)
+	p.UpdatedAt = time.Now().UTC()
+	if err := u.policyRepo.Save(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (u *approvalUsecase) DeletePolicy(id string) error {
+	return u.policyRepo.Delete(id)
+}
+
+// approvalChain resolves the levels that must approve a record of entityType
+// in department for amount: the department's policy, else the default policy,
+// else defaultApprovalChain. Manager steps are dropped when the department is
+// unknown since nobody could sign them off.
+func approvalChain(policies Repositories.ApprovalPolicyRepository, entityType, department string, amount Domain.Money) ([]string, error) {
+	var policy *Domain.ApprovalPolicy
+	var err error
+	if department != "" {
+		if policy, err = policies.Find(entityType, department); err != nil {
+			return nil, err
+		}
+	}
+	if policy == nil {
+		if policy, err = policies.Find(entityType, ""); err != nil {
+			return nil, err
+		}
+	}
+	if policy == nil {
+		return append([]string(nil), defaultApprovalChain...), nil
+	}
+
+	var chain []string
+	for _, s := range policy.Steps {
+		if amount < s.MinAmount || (s.Level == Domain.LevelManager && department == "") {
+			continue
+		}
+		chain = append(chain, s.Level)
+	}
+	if len(chain) == 0 {
+		return append([]string(nil), defaultApprovalChain...), nil
+	}
+	return chain, nil
+}
+
+// resolveChain returns the chain fixed on a record, resolving it from the
+// policies for records submitted before it was stored
+func resolveChain(policies Repositories.ApprovalPolicyRepository, entityType, department string, amount Domain.Money, stored []string) ([]string, error) {
+	if len(stored) > 0 {
+		return stored, nil
+	}
+	return approvalChain(policies, entityType, department, amount)
+}
+
+// awaitingActor reports the level a record is waiting on and whether the actor
+// may sign it off
+func awaitingActor(actor Domain.Actor, chain []string, approvals []Domain.ApprovalRecord, department, owner string) (string, bool) {
+	if len(approvals) >= len(chain) {
+		return "", false
+	}
+	level := chain[len(approvals)]
+	return level, checkApprover(actor, level, approvals, department, owner) == nil
+}
+
+// checkApprover enforces who may decide at level: the level's role, never the
+// requester, and no one twice in the same chain
+func checkApprover(actor Domain.Actor, level string, approvals []Domain.ApprovalRecord, department, owner string) error {
+	if !actor.CanApproveAt(level, department) {
+		withMFA := actor
+		withMFA.MFA = true
+		if withMFA.CanApproveAt(level, department) {
+			return fmt.Errorf("%w: two-factor authentication required for %s approval", Domain.ErrForbidden, level)
+		}
+		return fmt.Errorf("%w: %s approval required", Domain.ErrForbidden, level)
+	}
+	if owner != "" && owner == actor.UserID {
+		return fmt.Errorf("%w: requesters cannot approve their own submissions", Domain.ErrForbidden)
+	}
+	for _, a := range approvals {
+		if a.ApproverID == actor.UserID {
+			return fmt.Errorf("%w: already signed off at the %s level", Domain.ErrForbidden, a.Level)
+		}
+	}
+	return nil
+}
+
+// recordDecision appends the actor's decision at the next level of chain and
+// reports whether the chain is now complete. A rejection ends the chain.
+func recordDecision(chain []string, approvals []Domain.ApprovalRecord, actor Domain.Actor, department, owner, decision, comment string) ([]Domain.ApprovalRecord, bool, error) {
+	if len(approvals) >= len(chain) {
+		return nil, false, errors.New("approval chain is already complete")
+	}
+	level := chain[len(approvals)]
+	if err := checkApprover(actor, level, approvals, department, owner); err != nil {
+		return nil, false, err
+	}
+	approvals = append(approvals, Domain.ApprovalRecord{
+		Level:      level,
+		ApproverID: actor.UserID,
+		Approver:   actor.Username,
+		Decision:   decision,
+		Comment:    comment,
+		At:         time.Now().UTC(),
+	})
+	return approvals, decision == Domain.DecisionApproved && len(approvals) == len(chain), nil
+}
+
+// cashRequestDepartment is the department of the budget a request draws on
+func cashRequestDepartment(budgetRepo Repositories.BudgetRepository, r *Domain.CashRequest) string {
+	if r.BudgetID.IsZero() {
+		return ""
+	}
+	// a dangling budget reference leaves the request without a department
+	b, err := budgetRepo.GetByID(r.BudgetID.Hex())
+	if err != nil {
+		return ""
+	}
+	return b.Department
+}
@@ -0,0 +1,219 @@
+package Usecases
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"FMS/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mock approval policy repo keyed by entity type and department
+type mockApprovalPolicyRepo struct {
+	store map[string]*Domain.ApprovalPolicy
+}
+
+func newMockApprovalPolicyRepo() *mockApprovalPolicyRepo {
+	return &mockApprovalPolicyRepo{store: make(map[string]*Domain.ApprovalPolicy)}
+}
+func (m *mockApprovalPolicyRepo) GetAll() ([]Domain.ApprovalPolicy, error) {
+	res := []Domain.ApprovalPolicy{}
+	for _, v := range m.store {
+		res = append(res, *v)
+	}
+	return res, nil
+}
+func (m *mockApprovalPolicyRepo) Find(entityType, department string) (*Domain.ApprovalPolicy, error) {
+	if v, ok := m.store[entityType+"/"+department]; ok {
+		return v, nil
+	}
+	return nil, nil
+}
+func (m *mockApprovalPolicyRepo) Save(p *Domain.ApprovalPolicy) error {
+	if p.ID.IsZero() {
+		p.ID = primitive.NewObjectID()
+	}
+	m.store[p.EntityType+"/"+p.Department] = p
+	return nil
+}
+func (m *mockApprovalPolicyRepo) Delete(id string) error {
+	for k, v := range m.store {
+		if v.ID.Hex() == id {
+			delete(m.store, k)
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+var (
+	opsHead  = Domain.Actor{UserID: "head-1", Username: "head", Department: "ops", DepartmentHead: true}
+	director = Domain.Actor{UserID: "admin-1", Username: "admin", Role: Domain.RoleAdmin, MFA: true}
+)
+
+// tieredPolicy needs the department head, then finance, then a director above 50k
+func tieredPolicy(entityType string) *Domain.ApprovalPolicy {
+	return &Domain.ApprovalPolicy{EntityType: entityType, Steps: []Domain.ApprovalStep{
+		{Level: Domain.LevelManager},
+		{Level: Domain.LevelFinance},
+		{Level: Domain.LevelDirector, MinAmount: 50000},
+	}}
+}
+
+func TestApproval_BudgetApprovedOnlyWhenChainCompletes(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	ledger := newMockLedgerRepo()
+	policies := newMockApprovalPolicyRepo()
+	_ = policies.Save(tieredPolicy(Domain.EntityBudget))
+	uc := NewBudgetUsecase(budgets, ledger, policies, testCurrencies(), newMockFiscalPeriodRepo())
+
+	b, err := uc.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "Fleet", Department: "ops", Amount: 80000}, staffActor("u1"))
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if len(b.ApprovalChain) != 3 {
+		t.Fatalf("expected manager, finance and director levels, got %v", b.ApprovalChain)
+	}
+
+	if _, err := uc.ApproveBudget(b.ID.Hex(), financeActor, ""); !errors.Is(err, Domain.ErrForbidden) {
+		t.Fatalf("finance must wait for the manager level, got %v", err)
+	}
+	if _, err := uc.ApproveBudget(b.ID.Hex(), opsHead, "fine by me"); err != nil {
+		t.Fatalf("manager approval failed: %v", err)
+	}
+	if got, _ := uc.ApproveBudget(b.ID.Hex(), financeActor, ""); got == nil || got.Status != "pending" {
+		t.Fatalf("budget must stay pending until the director signs, got %+v", got)
+	}
+	if len(ledger.entries) != 0 {
+		t.Fatalf("nothing should be posted before the chain completes")
+	}
+
+	got, err := uc.ApproveBudget(b.ID.Hex(), director, "")
+	if err != nil {
+		t.Fatalf("director approval failed: %v", err)
+	}
+	if got.Status != "approved" || got.Remaining != 80000 || len(ledger.entries) != 2 {
+		t.Fatalf("expected approved budget with posted allocation, got %+v", got)
+	}
+	rec := got.Approvals[0]
+	if rec.Level != Domain.LevelManager || rec.ApproverID != "head-1" || rec.Comment != "fine by me" || rec.At.IsZero() {
+		t.Fatalf("approval record not kept, got %+v", rec)
+	}
+}
+
+func TestApproval_ThresholdAndDepartmentPolicy(t *testing.T) {
+	policies := newMockApprovalPolicyRepo()
+	_ = policies.Save(tieredPolicy(Domain.EntityBudget))
+	_ = policies.Save(&Domain.ApprovalPolicy{EntityType: Domain.EntityBudget, Department: "hr", Steps: []Domain.ApprovalStep{{Level: Domain.LevelDirector}}})
+
+	chain, _ := approvalChain(policies, Domain.EntityBudget, "ops", 1000)
+	if len(chain) != 2 || chain[0] != Domain.LevelManager || chain[1] != Domain.LevelFinance {
+		t.Fatalf("small ops budgets skip the director, got %v", chain)
+	}
+	if chain, _ := approvalChain(policies, Domain.EntityBudget, "hr", 1000); len(chain) != 1 || chain[0] != Domain.LevelDirector {
+		t.Fatalf("department policy should override the default, got %v", chain)
+	}
+	if chain, _ := approvalChain(policies, Domain.EntityCashRequest, "ops", 1000); len(chain) != 1 || chain[0] != Domain.LevelFinance {
+		t.Fatalf("without a policy finance approves alone, got %v", chain)
+	}
+}
+
+func TestApproval_RequesterCannotApproveOwn(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	cash := newMockCashRepo()
+	uc := NewCashRequestUsecase(cash, budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo())
+	b := newApprovedBudget(budgets, 1000)
+
+	r, _ := uc.CreateCashRequest(&Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Advance", Amount: 100, BudgetID: b.ID}, financeActor)
+	if _, err := uc.ApproveCashRequest(r.ID.Hex(), financeActor, ""); !errors.Is(err, Domain.ErrForbidden) {
+		t.Fatalf("expected self-approval to be forbidden, got %v", err)
+	}
+
+	other := Domain.Actor{UserID: "finance-2", Finance: true, MFA: true}
+	got, err := uc.RejectCashRequest(r.ID.Hex(), other, "no receipt")
+	if err != nil || got.Status != "rejected" {
+		t.Fatalf("expected rejection, got %+v (%v)", got, err)
+	}
+	if _, err := uc.ApproveCashRequest(r.ID.Hex(), other, ""); err == nil {
+		t.Fatalf("rejected requests cannot be approved")
+	}
+}
+
+func TestApproval_FinanceAndDirectorNeedTwoFactor(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	policies := newMockApprovalPolicyRepo()
+	_ = policies.Save(&Domain.ApprovalPolicy{EntityType: Domain.EntityBudget, Steps: []Domain.ApprovalStep{
+		{Level: Domain.LevelFinance},
+		{Level: Domain.LevelDirector},
+	}})
+	uc := NewBudgetUsecase(budgets, newMockLedgerRepo(), policies, testCurrencies(), newMockFiscalPeriodRepo())
+	b, _ := uc.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "Fleet", Department: "ops", Amount: 800}, staffActor("u1"))
+
+	noMFA := financeActor
+	noMFA.MFA = false
+	if _, err := uc.ApproveBudget(b.ID.Hex(), noMFA, ""); !errors.Is(err, Domain.ErrForbidden) || !strings.Contains(err.Error(), "two-factor") {
+		t.Fatalf("finance without 2FA must not sign off, got %v", err)
+	}
+	if _, err := uc.RejectBudget(b.ID.Hex(), noMFA, "no"); !errors.Is(err, Domain.ErrForbidden) {
+		t.Fatalf("finance without 2FA must not reject either, got %v", err)
+	}
+	if _, err := uc.ApproveBudget(b.ID.Hex(), financeActor, ""); err != nil {
+		t.Fatalf("finance approval failed: %v", err)
+	}
+	admin := director
+	admin.MFA = false
+	if _, err := uc.ApproveBudget(b.ID.Hex(), admin, ""); !errors.Is(err, Domain.ErrForbidden) {
+		t.Fatalf("an admin without 2FA must not sign off as director, got %v", err)
+	}
+	if got, err := uc.ApproveBudget(b.ID.Hex(), director, ""); err != nil || got.Status != "approved" {
+		t.Fatalf("director approval failed: %+v %v", got, err)
+	}
+}
+
+func TestApproval_PendingInbox(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	cash := newMockCashRepo()
+	policies := newMockApprovalPolicyRepo()
+	_ = policies.Save(tieredPolicy(Domain.EntityBudget))
+	budgetUC := NewBudgetUsecase(budgets, newMockLedgerRepo(), policies, testCurrencies(), newMockFiscalPeriodRepo())
+	cashUC := NewCashRequestUsecase(cash, budgets, newMockLedgerRepo(), policies, testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo())
+	approvalUC := NewApprovalUsecase(policies, budgets, cash)
+
+	ops, _ := budgetUC.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "Ops", Department: "ops", Amount: 500}, staffActor("u1"))
+	_, _ = budgetUC.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "HR", Department: "hr", Amount: 500}, staffActor("u2"))
+	funded := newApprovedBudget(budgets, 1000)
+	_, _ = cashUC.CreateCashRequest(&Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Advance", Amount: 50, BudgetID: funded.ID}, staffActor("u3"))
+
+	inbox, err := approvalUC.GetPending(opsHead)
+	if err != nil || len(inbox) != 1 || inbox[0].EntityID != ops.ID || inbox[0].Level != Domain.LevelManager {
+		t.Fatalf("ops head should only see the ops budget, got %v (%v)", inbox, err)
+	}
+	if inbox, _ := approvalUC.GetPending(financeActor); len(inbox) != 1 || inbox[0].EntityType != Domain.EntityCashRequest {
+		t.Fatalf("finance should only see the cash request until managers sign, got %v", inbox)
+	}
+
+	_, _ = budgetUC.ApproveBudget(ops.ID.Hex(), opsHead, "")
+	if inbox, _ := approvalUC.GetPending(opsHead); len(inbox) != 0 {
+		t.Fatalf("signed items leave the inbox, got %v", inbox)
+	}
+	if inbox, _ := approvalUC.GetPending(financeActor); len(inbox) != 2 {
+		t.Fatalf("ops budget should now wait on finance, got %v", inbox)
+	}
+}
+
+func TestApproval_SavePolicyValidates(t *testing.T) {
+	uc := NewApprovalUsecase(newMockApprovalPolicyRepo(), newMockBudgetRepo(), newMockCashRepo())
+
+	if _, err := uc.SavePolicy(&Domain.ApprovalPolicy{EntityType: "expense", Steps: []Domain.ApprovalStep{{Level: Domain.LevelFinance}}}); err == nil {
+		t.Fatalf("expected unknown entity type to be rejected")
+	}
+	if _, err := uc.SavePolicy(&Domain.ApprovalPolicy{EntityType: Domain.EntityBudget, Steps: []Domain.ApprovalStep{{Level: "ceo"}}}); err == nil {
+		t.Fatalf("expected unknown level to be rejected")
+	}
+	p, err := uc.SavePolicy(&Domain.ApprovalPolicy{EntityType: Domain.EntityBudget, Department: " Ops ", Steps: []Domain.ApprovalStep{{Level: Domain.LevelManager}}})
+	if err != nil || p.Department != "ops" {
+		t.Fatalf("expected normalized department, got %+v (%v)", p, err)
+	}
+}
@@ -0,0 +1,195 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+	// auditAppendAttempts bounds retries when another instance takes the next sequence number
+	auditAppendAttempts = 5
+)
+
+// auditIgnoredFields are derived from fields that are already diffed
+var auditIgnoredFields = map[string]bool{"history": true}
+
+// AuditUsecase appends to and reads the hash-chained audit trail
+type AuditUsecase interface {
+	// Record appends an entry for action on the entity, diffing before and after.
+	// before is nil for creations; both are nil for actions, like a password
+	// reset, whose change is not visible in the record. A before and after that
+	// do not differ record nothing.
+	Record(actor Domain.Actor, action, entityType, entityID string, before, after interface{}) error
+	GetEntries(filter Domain.AuditFilter) (*Domain.AuditPage, error)
+	// Verify walks the whole chain and reports the first entry that does not match
+	Verify() (*Domain.AuditVerification, error)
+}
+
+type auditUsecase struct {
+	repo Repositories.AuditRepository
+	// mu serializes appends from this instance; the unique seq index covers the rest
+	mu sync.Mutex
+}
+
+func NewAuditUsecase(repo Repositories.AuditRepository) AuditUsecase {
+	return &auditUsecase{repo: repo}
+}
+
+func (u *auditUsecase) Record(actor Domain.Actor, action, entityType, entityID string, before, after interface{}) error {
+	changes, unchanged, err := diffFields(before, after)
+	if err != nil {
+		return err
+	}
+	// e.g. a receipt uploaded twice; the trail only holds changes
+	if unchanged {
+		return nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for attempt := 0; attempt < auditAppendAttempts; attempt++ {
+		last, err := u.repo.Last()
+		if err != nil {
+			return err
+		}
+		e := &Domain.AuditEntry{
+			Seq:        1,
+			ActorID:    actor.UserID,
+			Actor:      actor.Username,
+			Role:       actor.Role,
+			Action:     action,
+			EntityType: entityType,
+			EntityID:   entityID,
+			Changes:    changes,
+			IP:         actor.IP,
+			RequestID:  actor.RequestID,
+			CreatedAt:  time.Now().UTC().Truncate(time.Millisecond),
+		}
+		if last != nil {
+			e.Seq = last.Seq + 1
+			e.PrevHash = last.Hash
+		}
+		e.Hash = e.ComputeHash()
+
+		err = u.repo.Append(e)
+		if !errors.Is(err, Domain.ErrAuditSeqTaken) {
+			return err
+		}
+	}
+	return errors.New("audit log is busy, entry not recorded")
+}
+
+func (u *auditUsecase) GetEntries(filter Domain.AuditFilter) (*Domain.AuditPage, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return nil, errors.New("from must be before to")
+	}
+
+	entries, total, err := u.repo.Find(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &Domain.AuditPage{Entries: entries, Total: total, Page: filter.Page, Limit: filter.Limit}, nil
+}
+
+func (u *auditUsecase) Verify() (*Domain.AuditVerification, error) {
+	result := &Domain.AuditVerification{Valid: true}
+	var prev *Domain.AuditEntry
+
+	err := u.repo.Each(func(e Domain.AuditEntry) error {
+		result.Checked++
+		switch {
+		case prev == nil && (e.Seq != 1 || e.PrevHash != ""):
+			result.Reason = "chain does not start at sequence 1"
+		case prev != nil && e.Seq != prev.Seq+1:
+			result.Reason = fmt.Sprintf("entry missing before sequence %d", e.Seq)
+		case prev != nil && e.PrevHash != prev.Hash:
+			result.Reason = "previous hash does not match"
+		case e.Hash != e.ComputeHash():
+			result.Reason = "entry content does not match its hash"
+		}
+		if result.Reason != "" {
+			result.Valid = false
+			result.BrokenAt = e.Seq
+			return errStopAudit
+		}
+		prev = &e
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopAudit) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// errStopAudit ends the walk at the first broken entry
+var errStopAudit = errors.New("stop")
+
+// diffFields compares the JSON form of before and after field by field.
+// unchanged reports a record given before and after that did not change.
+func diffFields(before, after interface{}) (changes []Domain.FieldChange, unchanged bool, err error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, false, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fields := make([]string, 0, len(a))
+	for k := range a {
+		fields = append(fields, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	changes = []Domain.FieldChange{}
+	for _, f := range fields {
+		if auditIgnoredFields[f] || bytes.Equal(b[f], a[f]) {
+			continue
+		}
+		changes = append(changes, Domain.FieldChange{Field: f, Before: string(b[f]), After: string(a[f])})
+	}
+	return changes, len(changes) == 0 && len(b) > 0 && len(a) > 0, nil
+}
+
+func jsonFields(v interface{}) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if v == nil {
+		return fields, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(raw, []byte("null")) {
+		return fields, nil
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,240 @@
+package Usecases
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"FMS/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mock audit repo; taken makes the next Append lose the race for a sequence
+// number and failures makes it fail outright
+type mockAuditRepo struct {
+	entries  []Domain.AuditEntry
+	taken    int
+	failures int
+}
+
+func (m *mockAuditRepo) Last() (*Domain.AuditEntry, error) {
+	if len(m.entries) == 0 {
+		return nil, nil
+	}
+	e := m.entries[len(m.entries)-1]
+	return &e, nil
+}
+func (m *mockAuditRepo) Append(e *Domain.AuditEntry) error {
+	if m.taken > 0 {
+		m.taken--
+		return Domain.ErrAuditSeqTaken
+	}
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("connection reset")
+	}
+	m.entries = append(m.entries, *e)
+	return nil
+}
+func (m *mockAuditRepo) Find(filter Domain.AuditFilter) ([]Domain.AuditEntry, int64, error) {
+	res := []Domain.AuditEntry{}
+	for _, e := range m.entries {
+		if filter.EntityID == "" || e.EntityID == filter.EntityID {
+			res = append(res, e)
+		}
+	}
+	return res, int64(len(res)), nil
+}
+func (m *mockAuditRepo) Each(fn func(Domain.AuditEntry) error) error {
+	for _, e := range m.entries {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestAudit_RecordsMutationsWithDiff(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	auditRepo := &mockAuditRepo{}
+	auditUC := NewAuditUsecase(auditRepo)
+	uc := NewAuditedBudgetUsecase(NewBudgetUsecase(budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo()), budgets, auditUC)
+
+	owner := staffActor("u1")
+	owner.IP = "10.0.0.7"
+	owner.RequestID = "req-1"
+	b, err := uc.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "Travel", Amount: 300}, owner)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := uc.ApproveBudget(b.ID.Hex(), owner, ""); err == nil {
+		t.Fatalf("expected self-approval to fail")
+	}
+	if _, err := uc.ApproveBudget(b.ID.Hex(), financeActor, "ok"); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+
+	if len(auditRepo.entries) != 2 {
+		t.Fatalf("expected create and approve entries only, got %d", len(auditRepo.entries))
+	}
+	created, approved := auditRepo.entries[0], auditRepo.entries[1]
+	if created.Action != Domain.AuditCreate || created.ActorID != "u1" || created.IP != "10.0.0.7" || created.RequestID != "req-1" {
+		t.Fatalf("unexpected create entry %+v", created)
+	}
+	var status *Domain.FieldChange
+	for i, ch := range approved.Changes {
+		if ch.Field == "history" {
+			t.Fatalf("history should not be diffed")
+		}
+		if ch.Field == "status" {
+			status = &approved.Changes[i]
+		}
+	}
+	if approved.Action != Domain.AuditApprove || status == nil || status.Before != `"pending"` || status.After != `"approved"` {
+		t.Fatalf("expected status diff on approval, got %+v", approved.Changes)
+	}
+	if approved.PrevHash != created.Hash || approved.Seq != 2 {
+		t.Fatalf("entries should be chained")
+	}
+}
+
+func TestAudit_VerifyDetectsTampering(t *testing.T) {
+	auditRepo := &mockAuditRepo{}
+	uc := NewAuditUsecase(auditRepo)
+	for i := 0; i < 3; i++ {
+		if err := uc.Record(financeActor, Domain.AuditUpdate, Domain.EntityBudget, "b1", map[string]int{"amount": i}, map[string]int{"amount": i + 1}); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+	}
+
+	result, err := uc.Verify()
+	if err != nil || !result.Valid || result.Checked != 3 {
+		t.Fatalf("expected intact chain, got %+v (%v)", result, err)
+	}
+
+	auditRepo.entries[1].ActorID = "someone-else"
+	if result, _ := uc.Verify(); result.Valid || result.BrokenAt != 2 {
+		t.Fatalf("expected tampering at seq 2, got %+v", result)
+	}
+
+	auditRepo.entries[1].ActorID = financeActor.UserID
+	auditRepo.entries = append(auditRepo.entries[:1], auditRepo.entries[2:]...)
+	if result, _ := uc.Verify(); result.Valid || result.BrokenAt != 3 {
+		t.Fatalf("expected removed entry to break the chain at seq 3, got %+v", result)
+	}
+}
+
+func TestAudit_RetriesWhenSequenceTaken(t *testing.T) {
+	auditRepo := &mockAuditRepo{taken: 2}
+	uc := NewAuditUsecase(auditRepo)
+
+	if err := uc.Record(financeActor, Domain.AuditCreate, Domain.EntityExpense, "e1", nil, map[string]string{"title": "Taxi"}); err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if len(auditRepo.entries) != 1 || auditRepo.entries[0].Changes[0].After != `"Taxi"` {
+		t.Fatalf("unexpected entries %+v", auditRepo.entries)
+	}
+
+	auditRepo.taken = auditAppendAttempts
+	if err := uc.Record(financeActor, Domain.AuditCreate, Domain.EntityExpense, "e2", nil, map[string]string{"title": "Bus"}); err == nil {
+		t.Fatalf("expected error once every attempt loses")
+	}
+}
+
+func TestAudit_EmptyChangesVerifyAfterDecoding(t *testing.T) {
+	auditRepo := &mockAuditRepo{}
+	uc := NewAuditUsecase(auditRepo)
+	if err := uc.Record(adminActor, Domain.AuditUnlock, Domain.EntityUser, "u1", nil, nil); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+	// omitempty drops the empty changes, so they come back from Mongo as nil
+	auditRepo.entries[0].Changes = nil
+	if result, err := uc.Verify(); err != nil || !result.Valid {
+		t.Fatalf("expected entry without changes to verify, got %+v (%v)", result, err)
+	}
+}
+
+func TestAudit_SkipsNoOpMutations(t *testing.T) {
+	auditRepo := &mockAuditRepo{}
+	uc := NewAuditUsecase(auditRepo)
+	same, _ := json.Marshal(map[string]string{"receipt": "r.pdf"})
+	if err := uc.Record(financeActor, Domain.AuditAttachReceipt, Domain.EntityExpense, "e1", json.RawMessage(same), json.RawMessage(same)); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+	if len(auditRepo.entries) != 0 {
+		t.Fatalf("expected unchanged record to be skipped, got %+v", auditRepo.entries)
+	}
+}
+
+func TestAudit_DecoratorFailsWhenEntryCannotBeWritten(t *testing.T) {
+	delay := auditRetryDelay
+	auditRetryDelay = 0
+	defer func() { auditRetryDelay = delay }()
+
+	budgets := newMockBudgetRepo()
+	auditRepo := &mockAuditRepo{failures: auditRecordAttempts - 1}
+	uc := NewAuditedBudgetUsecase(NewBudgetUsecase(budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo()), budgets, NewAuditUsecase(auditRepo))
+
+	if _, err := uc.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "Travel", Amount: 300}, staffActor("u1")); err != nil {
+		t.Fatalf("expected retries to record the entry, got %v", err)
+	}
+	auditRepo.failures = auditRecordAttempts
+	if _, err := uc.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "Hotel", Amount: 300}, staffActor("u1")); !errors.Is(err, Domain.ErrAuditFailed) {
+		t.Fatalf("expected ErrAuditFailed, got %v", err)
+	}
+	if len(auditRepo.entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(auditRepo.entries))
+	}
+}
+
+func TestAudit_RecordsUserChanges(t *testing.T) {
+	inner, users := newTestUserUsecase()
+	auditRepo := &mockAuditRepo{}
+	uc := NewAuditedUserUsecase(inner, users, NewAuditUsecase(auditRepo))
+
+	admin, _ := uc.Register("alice", testPassword, "")
+	bob, err := uc.Register("bob", testPassword, "")
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	actor := Domain.Actor{UserID: admin.ID.Hex(), Username: admin.Username, Role: admin.Role, MFA: true}
+	id := bob.ID.Hex()
+	if err := uc.SetRole(id, Domain.RoleFinance, actor); err != nil {
+		t.Fatalf("set role failed: %v", err)
+	}
+	// already finance: nothing to record
+	if err := uc.SetRole(id, Domain.RoleFinance, actor); err != nil {
+		t.Fatalf("set role failed: %v", err)
+	}
+	if err := uc.Deactivate(id, actor); err != nil {
+		t.Fatalf("deactivate failed: %v", err)
+	}
+	if err := uc.ResetPassword(id, "another long one", actor); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+
+	entries := auditRepo.entries
+	want := []string{Domain.AuditCreate, Domain.AuditCreate, Domain.AuditUpdate, Domain.AuditDeactivate, Domain.AuditResetPassword}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), entries)
+	}
+	for i, action := range want {
+		if entries[i].Action != action || entries[i].EntityType != Domain.EntityUser {
+			t.Fatalf("entry %d: expected user %s, got %+v", i, action, entries[i])
+		}
+	}
+	if entries[1].ActorID != id {
+		t.Fatalf("expected registration to be recorded as the new user, got %q", entries[1].ActorID)
+	}
+	role := entries[2].Changes
+	if entries[2].ActorID != actor.UserID || len(role) != 1 || role[0].Field != "role" || role[0].After != `"finance"` {
+		t.Fatalf("unexpected role change %+v", entries[2])
+	}
+	if c := entries[3].Changes; len(c) != 1 || c[0].Field != "deactivated" || c[0].After != "true" {
+		t.Fatalf("unexpected deactivation %+v", entries[3])
+	}
+	for _, c := range entries[4].Changes {
+		t.Fatalf("password reset should not record fields, got %+v", c)
+	}
+}
@@ -0,0 +1,323 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// a failed audit write is retried after auditRetryDelay, doubling each time
+var (
+	auditRecordAttempts = 3
+	auditRetryDelay     = 100 * time.Millisecond
+)
+
+// The audited usecases decorate the budget, cash request, expense, fiscal and
+// user usecases, recording every successful mutation in the audit trail. Reads
+// pass through. A mutation whose entry cannot be written, even after retries,
+// returns ErrAuditFailed so a gap in the trail never goes unnoticed.
+
+type auditedBudgetUsecase struct {
+	BudgetUsecase
+	repo  Repositories.BudgetRepository
+	audit AuditUsecase
+}
+
+func NewAuditedBudgetUsecase(inner BudgetUsecase, repo Repositories.BudgetRepository, audit AuditUsecase) BudgetUsecase {
+	return &auditedBudgetUsecase{BudgetUsecase: inner, repo: repo, audit: audit}
+}
+
+func (u *auditedBudgetUsecase) CreateBudget(input *Domain.Budget, actor Domain.Actor) (*Domain.Budget, error) {
+	created, err := u.BudgetUsecase.CreateBudget(input, actor)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditCreate, Domain.EntityBudget, created.ID.Hex(), nil, created)
+	}
+	return created, err
+}
+
+func (u *auditedBudgetUsecase) UpdateBudget(id string, input *Domain.Budget, actor Domain.Actor) error {
+	before := snapshot(u.repo.GetByID(id))
+	if err := u.BudgetUsecase.UpdateBudget(id, input, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditUpdate, Domain.EntityBudget, id, before, snapshot(u.repo.GetByID(id)))
+}
+
+func (u *auditedBudgetUsecase) ApproveBudget(id string, actor Domain.Actor, comment string) (*Domain.Budget, error) {
+	before := snapshot(u.repo.GetByID(id))
+	b, err := u.BudgetUsecase.ApproveBudget(id, actor, comment)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditApprove, Domain.EntityBudget, id, before, b)
+	}
+	return b, err
+}
+
+func (u *auditedBudgetUsecase) RejectBudget(id string, actor Domain.Actor, comment string) (*Domain.Budget, error) {
+	before := snapshot(u.repo.GetByID(id))
+	b, err := u.BudgetUsecase.RejectBudget(id, actor, comment)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditReject, Domain.EntityBudget, id, before, b)
+	}
+	return b, err
+}
+
+type auditedCashRequestUsecase struct {
+	CashRequestUsecase
+	repo  Repositories.CashRequestRepository
+	audit AuditUsecase
+}
+
+func NewAuditedCashRequestUsecase(inner CashRequestUsecase, repo Repositories.CashRequestRepository, audit AuditUsecase) CashRequestUsecase {
+	return &auditedCashRequestUsecase{CashRequestUsecase: inner, repo: repo, audit: audit}
+}
+
+func (u *auditedCashRequestUsecase) CreateCashRequest(input *Domain.CashRequest, actor Domain.Actor) (*Domain.CashRequest, error) {
+	created, err := u.CashRequestUsecase.CreateCashRequest(input, actor)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditCreate, Domain.EntityCashRequest, created.ID.Hex(), nil, created)
+	}
+	return created, err
+}
+
+func (u *auditedCashRequestUsecase) ApproveCashRequest(id string, actor Domain.Actor, comment string) (*Domain.CashRequest, error) {
+	before := snapshot(u.repo.GetByID(id))
+	r, err := u.CashRequestUsecase.ApproveCashRequest(id, actor, comment)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditApprove, Domain.EntityCashRequest, id, before, r)
+	}
+	return r, err
+}
+
+func (u *auditedCashRequestUsecase) RejectCashRequest(id string, actor Domain.Actor, comment string) (*Domain.CashRequest, error) {
+	before := snapshot(u.repo.GetByID(id))
+	r, err := u.CashRequestUsecase.RejectCashRequest(id, actor, comment)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditReject, Domain.EntityCashRequest, id, before, r)
+	}
+	return r, err
+}
+
+func (u *auditedCashRequestUsecase) DisburseCashRequest(id string, actor Domain.Actor) error {
+	before := snapshot(u.repo.GetByID(id))
+	if err := u.CashRequestUsecase.DisburseCashRequest(id, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditDisburse, Domain.EntityCashRequest, id, before, snapshot(u.repo.GetByID(id)))
+}
+
+func (u *auditedCashRequestUsecase) SettleCashRequest(id string, actor Domain.Actor) (*Domain.CashRequest, error) {
+	before := snapshot(u.repo.GetByID(id))
+	r, err := u.CashRequestUsecase.SettleCashRequest(id, actor)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditSettle, Domain.EntityCashRequest, id, before, r)
+	}
+	return r, err
+}
+
+type auditedExpenseUsecase struct {
+	ExpenseUsecase
+	repo  Repositories.ExpenseRepository
+	audit AuditUsecase
+}
+
+func NewAuditedExpenseUsecase(inner ExpenseUsecase, repo Repositories.ExpenseRepository, audit AuditUsecase) ExpenseUsecase {
+	return &auditedExpenseUsecase{ExpenseUsecase: inner, repo: repo, audit: audit}
+}
+
+func (u *auditedExpenseUsecase) CreateExpense(input *Domain.Expense, actor Domain.Actor) (*Domain.Expense, error) {
+	created, err := u.ExpenseUsecase.CreateExpense(input, actor)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditCreate, Domain.EntityExpense, created.ID.Hex(), nil, created)
+	}
+	return created, err
+}
+
+func (u *auditedExpenseUsecase) AttachReceipt(id, receiptURL string, actor Domain.Actor) error {
+	before := snapshot(u.repo.GetByID(id))
+	if err := u.ExpenseUsecase.AttachReceipt(id, receiptURL, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditAttachReceipt, Domain.EntityExpense, id, before, snapshot(u.repo.GetByID(id)))
+}
+
+func (u *auditedExpenseUsecase) UploadReceipt(id, filename string, r io.Reader, actor Domain.Actor) (*Domain.Receipt, error) {
+	before := snapshot(u.repo.GetByID(id))
+	receipt, err := u.ExpenseUsecase.UploadReceipt(id, filename, r, actor)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditAttachReceipt, Domain.EntityExpense, id, before, snapshot(u.repo.GetByID(id)))
+	}
+	return receipt, err
+}
+
+func (u *auditedExpenseUsecase) VerifyExpense(id string, actor Domain.Actor) error {
+	before := snapshot(u.repo.GetByID(id))
+	if err := u.ExpenseUsecase.VerifyExpense(id, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditVerify, Domain.EntityExpense, id, before, snapshot(u.repo.GetByID(id)))
+}
+
+func (u *auditedExpenseUsecase) RejectExpense(id string, actor Domain.Actor, comment string) (*Domain.Expense, error) {
+	before := snapshot(u.repo.GetByID(id))
+	e, err := u.ExpenseUsecase.RejectExpense(id, actor, comment)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditReject, Domain.EntityExpense, id, before, e)
+	}
+	return e, err
+}
+
+type auditedFiscalUsecase struct {
+	FiscalUsecase
+	audit AuditUsecase
+}
+
+// NewAuditedFiscalUsecase audits period closes and the budgets they carry money between.
+// Budgets created from templates are audited by the budget usecase that creates them.
+func NewAuditedFiscalUsecase(inner FiscalUsecase, audit AuditUsecase) FiscalUsecase {
+	return &auditedFiscalUsecase{FiscalUsecase: inner, audit: audit}
+}
+
+func (u *auditedFiscalUsecase) ClosePeriod(id string, carryForward bool, actor Domain.Actor) (*Domain.PeriodClose, error) {
+	before := snapshot(u.FiscalUsecase.GetPeriod(id))
+	result, err := u.FiscalUsecase.ClosePeriod(id, carryForward, actor)
+	if result == nil {
+		return result, err
+	}
+	audits := []error{err}
+	// a close that failed after carrying leaves the period open; only the carries happened
+	if result.Period.Status == Domain.PeriodClosed {
+		audits = append(audits, recordAudit(u.audit, actor, Domain.AuditClose, Domain.EntityFiscalPeriod, id, before, result.Period))
+	}
+	for _, c := range result.Carried {
+		audits = append(audits,
+			recordAudit(u.audit, actor, Domain.AuditCarryForward, Domain.EntityBudget, c.FromBudgetID.Hex(), nil, c),
+			recordAudit(u.audit, actor, Domain.AuditCarryForward, Domain.EntityBudget, c.ToBudgetID.Hex(), nil, c))
+	}
+	return result, errors.Join(audits...)
+}
+
+type auditedUserUsecase struct {
+	UserUsecase
+	repo  Repositories.UserRepository
+	audit AuditUsecase
+}
+
+// NewAuditedUserUsecase audits registrations and every change to a user's
+// role, department, status, password and two-factor authentication.
+func NewAuditedUserUsecase(inner UserUsecase, repo Repositories.UserRepository, audit AuditUsecase) UserUsecase {
+	return &auditedUserUsecase{UserUsecase: inner, repo: repo, audit: audit}
+}
+
+func (u *auditedUserUsecase) Register(username, password, department string) (*Domain.User, error) {
+	created, err := u.UserUsecase.Register(username, password, department)
+	if err == nil {
+		self := Domain.Actor{UserID: created.ID.Hex(), Username: created.Username, Role: created.Role}
+		err = recordAudit(u.audit, self, Domain.AuditCreate, Domain.EntityUser, created.ID.Hex(), nil, created)
+	}
+	return created, err
+}
+
+func (u *auditedUserUsecase) SetRole(id, role string, actor Domain.Actor) error {
+	before := snapshot(u.repo.FindByID(id))
+	if err := u.UserUsecase.SetRole(id, role, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditUpdate, Domain.EntityUser, id, before, snapshot(u.repo.FindByID(id)))
+}
+
+func (u *auditedUserUsecase) SetDepartment(id, department string, head bool, actor Domain.Actor) error {
+	before := snapshot(u.repo.FindByID(id))
+	if err := u.UserUsecase.SetDepartment(id, department, head, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditUpdate, Domain.EntityUser, id, before, snapshot(u.repo.FindByID(id)))
+}
+
+func (u *auditedUserUsecase) Deactivate(id string, actor Domain.Actor) error {
+	before := snapshot(u.repo.FindByID(id))
+	if err := u.UserUsecase.Deactivate(id, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditDeactivate, Domain.EntityUser, id, before, snapshot(u.repo.FindByID(id)))
+}
+
+func (u *auditedUserUsecase) Reactivate(id string, actor Domain.Actor) error {
+	before := snapshot(u.repo.FindByID(id))
+	if err := u.UserUsecase.Reactivate(id, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditReactivate, Domain.EntityUser, id, before, snapshot(u.repo.FindByID(id)))
+}
+
+// ResetPassword and Unlock change nothing the record shows, so their entries carry no diff
+func (u *auditedUserUsecase) ResetPassword(id, password string, actor Domain.Actor) error {
+	if err := u.UserUsecase.ResetPassword(id, password, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditResetPassword, Domain.EntityUser, id, nil, nil)
+}
+
+func (u *auditedUserUsecase) Unlock(id string, actor Domain.Actor) error {
+	if err := u.UserUsecase.Unlock(id, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditUnlock, Domain.EntityUser, id, nil, nil)
+}
+
+func (u *auditedUserUsecase) EnableTOTP(code string, actor Domain.Actor) ([]string, error) {
+	before := snapshot(u.repo.FindByID(actor.UserID))
+	recovery, err := u.UserUsecase.EnableTOTP(code, actor)
+	if err == nil {
+		err = recordAudit(u.audit, actor, Domain.AuditEnableTOTP, Domain.EntityUser, actor.UserID, before, snapshot(u.repo.FindByID(actor.UserID)))
+	}
+	return recovery, err
+}
+
+func (u *auditedUserUsecase) DisableTOTP(code string, actor Domain.Actor) error {
+	before := snapshot(u.repo.FindByID(actor.UserID))
+	if err := u.UserUsecase.DisableTOTP(code, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditDisableTOTP, Domain.EntityUser, actor.UserID, before, snapshot(u.repo.FindByID(actor.UserID)))
+}
+
+func (u *auditedUserUsecase) ResetTOTP(userID string, actor Domain.Actor) error {
+	before := snapshot(u.repo.FindByID(userID))
+	if err := u.UserUsecase.ResetTOTP(userID, actor); err != nil {
+		return err
+	}
+	return recordAudit(u.audit, actor, Domain.AuditDisableTOTP, Domain.EntityUser, userID, before, snapshot(u.repo.FindByID(userID)))
+}
+
+// snapshot captures a record as JSON before the wrapped usecase mutates it in place
+func snapshot[T any](record *T, err error) json.RawMessage {
+	if err != nil || record == nil {
+		return nil
+	}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// recordAudit writes the entry for a change that has been applied, retrying a
+// failed write before giving up with ErrAuditFailed
+func recordAudit(audit AuditUsecase, actor Domain.Actor, action, entityType, entityID string, before, after interface{}) error {
+	var err error
+	for attempt := 0; attempt < auditRecordAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(auditRetryDelay << (attempt - 1))
+		}
+		if err = audit.Record(actor, action, entityType, entityID, before, after); err == nil {
+			return nil
+		}
+	}
+	log.Printf("audit %s %s %s: %v", action, entityType, entityID, err)
+	return fmt.Errorf("%w: %v", Domain.ErrAuditFailed, err)
+}
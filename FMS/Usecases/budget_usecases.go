@@ -9,35 +9,85 @@ import (
 
 // BudgetUsecase defines business operations for budgets
 type BudgetUsecase interface {
-	CreateBudget(input *Domain.Budget) (*Domain.Budget, error)
-	GetAllBudgets() ([]Domain.Budget, error)
-	GetBudgetByID(id string) (*Domain.Budget, error)
-	GetBudgetSummary(id string) (map[string]interface{}, error)
-	UpdateBudget(id string, input *Domain.Budget) error
-	ApproveBudget(id string) error
-	RejectBudget(id string) error
+	CreateBudget(input *Domain.Budget, actor Domain.Actor) (*Domain.Budget, error)
+	GetAllBudgets(q Domain.ListQuery, actor Domain.Actor) ([]Domain.Budget, *Domain.Pagination, error)
+	GetBudgetByID(id string, actor Domain.Actor) (*Domain.Budget, error)
+	GetBudgetSummary(id string, actor Domain.Actor) (map[string]interface{}, error)
+	UpdateBudget(id string, input *Domain.Budget, actor Domain.Actor) error
+	ApproveBudget(id string, actor Domain.Actor, comment string) (*Domain.Budget, error)
+	RejectBudget(id string, actor Domain.Actor, comment string) (*Domain.Budget, error)
 }
 
 type budgetUsecase struct {
 	budgetRepo Repositories.BudgetRepository
+	ledgerRepo Repositories.LedgerRepository
+	policyRepo Repositories.ApprovalPolicyRepository
+	currencies CurrencyUsecase
+	periodRepo Repositories.FiscalPeriodRepository
 }
 
-func NewBudgetUsecase(repo Repositories.BudgetRepository) BudgetUsecase {
-	return &budgetUsecase{budgetRepo: repo}
+func NewBudgetUsecase(repo Repositories.BudgetRepository, ledger Repositories.LedgerRepository, policies Repositories.ApprovalPolicyRepository, currencies CurrencyUsecase, periods Repositories.FiscalPeriodRepository) BudgetUsecase {
+	return &budgetUsecase{budgetRepo: repo, ledgerRepo: ledger, policyRepo: policies, currencies: currencies, periodRepo: periods}
 }
 
-func (u *budgetUsecase) CreateBudget(input *Domain.Budget) (*Domain.Budget, error) {
+// CreateBudget submits a budget owned by the actor, defaulting to their department
+func (u *budgetUsecase) CreateBudget(input *Domain.Budget, actor Domain.Actor) (*Domain.Budget, error) {
 	if input.Title == "" {
 		return nil, errors.New("title is required")
 	}
 
+	input.CreatedBy = actor.UserID
+	input.CreatedAt = time.Now().UTC()
+	if input.Department == "" {
+		input.Department = actor.Department
+	}
+
+	// a budget for a fiscal period is due when the period ends
+	if !input.PeriodID.IsZero() {
+		period, err := u.periodRepo.GetByID(input.PeriodID.Hex())
+		if err != nil {
+			return nil, err
+		}
+		if period.Status == Domain.PeriodClosed {
+			return nil, Domain.ErrPeriodClosed
+		}
+		if input.DueDate.IsZero() {
+			input.DueDate = period.EndDate
+		}
+	}
 	if input.DueDate.IsZero() {
 		input.DueDate = time.Now().Add(24 * time.Hour)
 	}
+	input.CarriedIn, input.CarriedOut = 0, 0
+	input.AlertedThresholds = nil
+
+	input.Department = normalizeDepartment(input.Department)
 
 	// initialize status and remaining amount
-	input.Status = "pending"
+	input.Status = ""
+	input.History = nil
+	if err := input.TransitionTo(Domain.StatusPending, input.CreatedBy); err != nil {
+		return nil, err
+	}
 	input.Remaining = input.Amount
+	if err := prepareLineItems(input); err != nil {
+		return nil, err
+	}
+
+	rate, base, err := priceInBase(u.currencies, &input.Currency, "", input.Amount, input.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	input.ExchangeRate, input.BaseAmount = rate, base
+
+	// the chain is fixed at submission so later policy edits do not move the goalposts;
+	// thresholds are in the base currency
+	chain, err := approvalChain(u.policyRepo, Domain.EntityBudget, input.Department, input.BaseAmount)
+	if err != nil {
+		return nil, err
+	}
+	input.ApprovalChain = chain
+	input.Approvals = nil
 
 	if err := u.budgetRepo.Create(input); err != nil {
 		return nil, err
@@ -46,51 +96,191 @@ func (u *budgetUsecase) CreateBudget(input *Domain.Budget) (*Domain.Budget, erro
 	return input, nil
 }
 
-func (u *budgetUsecase) GetAllBudgets() ([]Domain.Budget, error) {
-	return u.budgetRepo.GetAll()
+// GetAllBudgets pages through every budget for finance, the department's
+// budgets plus their own for department heads, and only the caller's own
+// budgets otherwise
+func (u *budgetUsecase) GetAllBudgets(q Domain.ListQuery, actor Domain.Actor) ([]Domain.Budget, *Domain.Pagination, error) {
+	if err := normalizeListQuery(&q); err != nil {
+		return nil, nil, err
+	}
+	q.Scope = budgetScope(actor)
+	return u.budgetRepo.List(q)
 }
 
-func (u *budgetUsecase) GetBudgetByID(id string) (*Domain.Budget, error) {
-	return u.budgetRepo.GetByID(id)
+func (u *budgetUsecase) GetBudgetByID(id string, actor Domain.Actor) (*Domain.Budget, error) {
+	b, err := u.budgetRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	// other users' budgets are reported as missing so their IDs are not confirmed
+	if !actor.CanView(b.CreatedBy) && !actor.HeadOf(b.Department) {
+		return nil, errors.New("budget not found")
+	}
+	return b, nil
 }
 
-func (u *budgetUsecase) GetBudgetSummary(id string) (map[string]interface{}, error) {
-	b, err := u.budgetRepo.GetByID(id)
+func (u *budgetUsecase) GetBudgetSummary(id string, actor Domain.Actor) (map[string]interface{}, error) {
+	b, err := u.GetBudgetByID(id, actor)
 	if err != nil {
 		return nil, err
 	}
 	summary := map[string]interface{}{
-		"id":        b.ID.Hex(),
-		"title":     b.Title,
-		"amount":    b.Amount,
-		"remaining": b.Remaining,
-		"status":    b.Status,
+		"id":         b.ID.Hex(),
+		"title":      b.Title,
+		"department": b.Department,
+		"amount":     b.Amount,
+		"remaining":  b.Remaining,
+		"spent":      b.Amount - b.Remaining - b.CarriedOut,
+		"status":     b.Status,
+	}
+	if b.CarriedIn != 0 || b.CarriedOut != 0 {
+		summary["carried_in"] = b.CarriedIn
+		summary["carried_out"] = b.CarriedOut
+	}
+	if len(b.LineItems) > 0 {
+		summary["categories"] = b.CategoryUsage()
+		summary["unallocated"] = b.Amount - b.Allocated()
 	}
 	return summary, nil
 }
 
-func (u *budgetUsecase) UpdateBudget(id string, input *Domain.Budget) error {
+// UpdateBudget lets the owner (or finance) edit a budget while it is still pending
+func (u *budgetUsecase) UpdateBudget(id string, input *Domain.Budget, actor Domain.Actor) error {
 	if input.Title == "" {
 		return errors.New("title is required")
 	}
+	existing, err := u.GetBudgetByID(id, actor)
+	if err != nil {
+		return err
+	}
+	if !actor.Finance && existing.CreatedBy != actor.UserID {
+		return Domain.ErrForbidden
+	}
+	if existing.Status != Domain.StatusPending {
+		return errors.New("only pending budgets can be updated")
+	}
+	if err := actor.CheckVersion(Domain.BudgetStates.Entity, id, existing.Version); err != nil {
+		return err
+	}
+	input.Version = existing.Version
+
+	if input.Department == "" {
+		input.Department = existing.Department
+	}
+	input.Department = normalizeDepartment(input.Department)
+
+	// status and balance are owned by the approval flow, not by edits
+	input.Status = existing.Status
+	input.History = existing.History
+	input.Remaining = input.Amount
+
+	// so are the period links; money carried in has already been moved here
+	input.PeriodID, input.TemplateID = existing.PeriodID, existing.TemplateID
+	input.CarriedIn, input.CarriedOut = existing.CarriedIn, existing.CarriedOut
+	if input.Amount < input.CarriedIn {
+		return errors.New("amount cannot be less than the amount carried forward into the budget")
+	}
+	if err := prepareLineItems(input); err != nil {
+		return err
+	}
+
+	// the budget keeps its submission date, so it is repriced at the same rate date
+	rate, base, err := priceInBase(u.currencies, &input.Currency, existing.Currency, input.Amount, existing.CreatedAt)
+	if err != nil {
+		return err
+	}
+	input.ExchangeRate, input.BaseAmount = rate, base
+
+	// an edited budget starts its approval chain again
+	chain, err := approvalChain(u.policyRepo, Domain.EntityBudget, input.Department, input.BaseAmount)
+	if err != nil {
+		return err
+	}
+	input.ApprovalChain = chain
+	input.Approvals = nil
 	return u.budgetRepo.Update(id, input)
 }
 
-func (u *budgetUsecase) ApproveBudget(id string) error {
+// ApproveBudget records the actor's sign-off at the next level of the budget's
+// approval chain. The budget is approved, and its allocation posted, only once
+// every level has signed off.
+func (u *budgetUsecase) ApproveBudget(id string, actor Domain.Actor, comment string) (*Domain.Budget, error) {
 	b, err := u.budgetRepo.GetByID(id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := actor.CheckVersion(Domain.BudgetStates.Entity, id, b.Version); err != nil {
+		return nil, err
+	}
+	// a decided budget cannot be approved again, which would reset Remaining and wipe its spend
+	if err := Domain.BudgetStates.Check(b.Status, Domain.StatusApproved); err != nil {
+		return nil, err
+	}
+	chain, err := resolveChain(u.policyRepo, Domain.EntityBudget, b.Department, inBase(b.Amount, b.ExchangeRate), b.ApprovalChain)
+	if err != nil {
+		return nil, err
+	}
+	approvals, complete, err := recordDecision(chain, b.Approvals, actor, b.Department, b.CreatedBy, Domain.DecisionApproved, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *b
+	b.ApprovalChain = chain
+	b.Approvals = approvals
+	if !complete {
+		return b, u.budgetRepo.Update(id, b)
+	}
+
+	if err := b.TransitionTo(Domain.StatusApproved, actor.UserID); err != nil {
+		return nil, err
 	}
-	b.Status = "approved"
 	b.Remaining = b.Amount
-	return u.budgetRepo.Update(id, b)
+	b.LineItems = append([]Domain.BudgetLineItem(nil), b.LineItems...)
+	for i := range b.LineItems {
+		b.LineItems[i].Remaining = b.LineItems[i].Amount
+	}
+	if err := u.budgetRepo.Update(id, b); err != nil {
+		return nil, err
+	}
+
+	// the allocation is credited to the budget account; carried-in money was
+	// credited when the previous period closed
+	if err := postTransfer(u.ledgerRepo, Domain.EventBudgetApproval, b.ID.Hex(), b.ID, Domain.AccountAllocations, Domain.BudgetAccount(b.ID), b.Amount-b.CarriedIn, b.Currency); err != nil {
+		previous.Version = b.Version
+		return nil, rollbackFailed("ledger write", err, u.budgetRepo.Update(id, &previous))
+	}
+	return b, nil
 }
 
-func (u *budgetUsecase) RejectBudget(id string) error {
+// RejectBudget records the actor's rejection at the next level of the chain,
+// which rejects the budget outright
+func (u *budgetUsecase) RejectBudget(id string, actor Domain.Actor, comment string) (*Domain.Budget, error) {
 	b, err := u.budgetRepo.GetByID(id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := actor.CheckVersion(Domain.BudgetStates.Entity, id, b.Version); err != nil {
+		return nil, err
+	}
+	if err := Domain.BudgetStates.Check(b.Status, Domain.StatusRejected); err != nil {
+		return nil, err
+	}
+	chain, err := resolveChain(u.policyRepo, Domain.EntityBudget, b.Department, inBase(b.Amount, b.ExchangeRate), b.ApprovalChain)
+	if err != nil {
+		return nil, err
+	}
+	approvals, _, err := recordDecision(chain, b.Approvals, actor, b.Department, b.CreatedBy, Domain.DecisionRejected, comment)
+	if err != nil {
+		return nil, err
+	}
+	b.ApprovalChain = chain
+	b.Approvals = approvals
+	if err := b.TransitionTo(Domain.StatusRejected, actor.UserID); err != nil {
+		return nil, err
+	}
+	if err := u.budgetRepo.Update(id, b); err != nil {
+		return nil, err
 	}
-	b.Status = "rejected"
-	return u.budgetRepo.Update(id, b)
+	return b, nil
 }
@@ -0,0 +1,237 @@
+package Usecases
+
+import (
+	"errors"
+	"testing"
+
+	"FMS/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var financeActor = Domain.Actor{UserID: "finance-user", Role: "finance", Finance: true, MFA: true}
+
+func staffActor(id string) Domain.Actor {
+	return Domain.Actor{UserID: id, Role: "user"}
+}
+
+func TestBudgetUsecase_OwnershipScoping(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	uc := NewBudgetUsecase(budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo())
+
+	mine := &Domain.Budget{ID: primitive.NewObjectID(), Title: "Mine", Amount: 100, Status: "pending", CreatedBy: "u1"}
+	theirs := &Domain.Budget{ID: primitive.NewObjectID(), Title: "Theirs", Amount: 100, Status: "pending", CreatedBy: "u2"}
+	_ = budgets.Create(mine)
+	_ = budgets.Create(theirs)
+
+	list, _, err := uc.GetAllBudgets(Domain.ListQuery{}, staffActor("u1"))
+	if err != nil || len(list) != 1 || list[0].Title != "Mine" {
+		t.Fatalf("staff should only list own budgets, got %v (%v)", list, err)
+	}
+	if all, _, _ := uc.GetAllBudgets(Domain.ListQuery{}, financeActor); len(all) != 2 {
+		t.Fatalf("finance should list every budget")
+	}
+	if _, err := uc.GetBudgetByID(theirs.ID.Hex(), staffActor("u1")); err == nil {
+		t.Fatalf("staff should not read other users' budgets")
+	}
+
+	if err := uc.UpdateBudget(mine.ID.Hex(), &Domain.Budget{Title: "Mine v2", Amount: 150, Status: "approved"}, staffActor("u1")); err != nil {
+		t.Fatalf("owner update failed: %v", err)
+	}
+	updated := budgets.store[mine.ID.Hex()]
+	if updated.Status != "pending" || updated.Remaining != 150 {
+		t.Fatalf("update must not change status, got %+v", updated)
+	}
+}
+
+func TestBudgetUsecase_RejectsUpdateOfOthersBudget(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	uc := NewBudgetUsecase(budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo())
+	theirs := &Domain.Budget{ID: primitive.NewObjectID(), Title: "Theirs", Amount: 100, Status: "pending", CreatedBy: "u2"}
+	_ = budgets.Create(theirs)
+
+	if err := uc.UpdateBudget(theirs.ID.Hex(), &Domain.Budget{Title: "Hijack"}, staffActor("u1")); err == nil {
+		t.Fatalf("expected update of another user's budget to fail")
+	}
+	if err := uc.UpdateBudget(theirs.ID.Hex(), &Domain.Budget{Title: "Fix typo", Amount: 100}, financeActor); err != nil {
+		t.Fatalf("finance update failed: %v", err)
+	}
+	if err := uc.UpdateBudget(primitive.NewObjectID().Hex(), &Domain.Budget{Title: "x"}, financeActor); err == nil || errors.Is(err, Domain.ErrForbidden) {
+		t.Fatalf("expected not found for missing budget, got %v", err)
+	}
+}
+
+func TestBudgetUsecase_DepartmentHeadSeesDepartment(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	cash := newMockCashRepo()
+	uc := NewBudgetUsecase(budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo())
+	cashUC := NewCashRequestUsecase(cash, budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo())
+
+	ops := &Domain.Budget{ID: primitive.NewObjectID(), Title: "Ops", Department: "ops", Amount: 100, Remaining: 60, Status: "approved", CreatedBy: "u2"}
+	hr := &Domain.Budget{ID: primitive.NewObjectID(), Title: "HR", Department: "hr", Amount: 100, Status: "approved", CreatedBy: "u3"}
+	_ = budgets.Create(ops)
+	_ = budgets.Create(hr)
+	cr := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Advance", Amount: 40, BudgetID: ops.ID, Requester: "u2"}
+	_ = cash.Create(cr)
+
+	head := Domain.Actor{UserID: "u1", Department: "Ops", DepartmentHead: true}
+	list, _, err := uc.GetAllBudgets(Domain.ListQuery{}, head)
+	if err != nil || len(list) != 1 || list[0].Title != "Ops" {
+		t.Fatalf("head should see the ops budget only, got %v (%v)", list, err)
+	}
+	summary, err := uc.GetBudgetSummary(ops.ID.Hex(), head)
+	if err != nil || summary["spent"].(Domain.Money) != 40 {
+		t.Fatalf("head should see ops spend, got %v (%v)", summary, err)
+	}
+	if _, err := uc.GetBudgetByID(hr.ID.Hex(), head); err == nil {
+		t.Fatalf("head should not see other departments")
+	}
+	if reqs, _, _ := cashUC.GetAllCashRequests(Domain.ListQuery{}, head); len(reqs) != 1 {
+		t.Fatalf("head should see cash requests against department budgets")
+	}
+	if err := uc.UpdateBudget(ops.ID.Hex(), &Domain.Budget{Title: "Edit"}, head); err == nil {
+		t.Fatalf("head should not edit budgets they do not own")
+	}
+
+	member := Domain.Actor{UserID: "u1", Department: "ops"}
+	if list, _, _ := uc.GetAllBudgets(Domain.ListQuery{}, member); len(list) != 0 {
+		t.Fatalf("non-head members only see their own budgets")
+	}
+}
+
+func TestBudgetUsecase_DecidedBudgetsCannotBeReapproved(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	ledger := newMockLedgerRepo()
+	uc := NewBudgetUsecase(budgets, ledger, newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo())
+
+	spent := newApprovedBudget(budgets, 1000)
+	spent.Remaining = 400
+	if _, err := uc.ApproveBudget(spent.ID.Hex(), financeActor, ""); !errors.Is(err, Domain.ErrInvalidTransition) {
+		t.Fatalf("expected re-approval to be an invalid transition, got %v", err)
+	}
+	if budgets.store[spent.ID.Hex()].Remaining != 400 {
+		t.Fatalf("re-approval must not reset remaining")
+	}
+
+	b, _ := uc.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "Events", Amount: 500}, staffActor("u1"))
+	if _, err := uc.RejectBudget(b.ID.Hex(), financeActor, ""); err != nil {
+		t.Fatalf("reject failed: %v", err)
+	}
+	if _, err := uc.ApproveBudget(b.ID.Hex(), financeActor, ""); !errors.Is(err, Domain.ErrInvalidTransition) {
+		t.Fatalf("expected rejected budget to stay rejected, got %v", err)
+	}
+	if len(ledger.entries) != 0 {
+		t.Fatalf("no allocation should be posted")
+	}
+	h := budgets.store[b.ID.Hex()].History
+	if len(h) != 2 || h[0].To != "pending" || h[1].To != "rejected" || h[1].By != financeActor.UserID {
+		t.Fatalf("expected submission and rejection in history, got %+v", h)
+	}
+}
+
+func TestBudgetUsecase_LineItemsTrackSpendPerCategory(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	uc := NewBudgetUsecase(budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo())
+	expenses := NewExpenseUsecase(newMockExpenseRepo(), budgets, newMockLedgerRepo(), newMockBlobStorage(), testCurrencies(), newMockFiscalPeriodRepo(), newMockCashRepo())
+
+	over := &Domain.Budget{Title: "Ops", Amount: 500, LineItems: []Domain.BudgetLineItem{{Category: "travel", Amount: 400}, {Category: "supplies", Amount: 200}}}
+	if _, err := uc.CreateBudget(over, staffActor("u1")); err == nil {
+		t.Fatalf("expected line items over the budget amount to be rejected")
+	}
+
+	b, err := uc.CreateBudget(&Domain.Budget{Title: "Ops", Amount: 1000, LineItems: []Domain.BudgetLineItem{{Category: " Travel ", Amount: 600}, {Category: "supplies", Amount: 300}}}, staffActor("u1"))
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := uc.ApproveBudget(b.ID.Hex(), financeActor, ""); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+	travel, supplies := b.LineItems[0], b.LineItems[1]
+	if travel.Category != "travel" || travel.ID.IsZero() {
+		t.Fatalf("expected normalized category with an id, got %+v", travel)
+	}
+
+	if _, err := expenses.CreateExpense(&Domain.Expense{Title: "Taxi", Amount: 50, BudgetID: b.ID}, staffActor("u1")); err == nil {
+		t.Fatalf("expected untagged expense on a split budget to be rejected")
+	}
+	spend := func(lineItemID primitive.ObjectID, amount Domain.Money) error {
+		e, err := expenses.CreateExpense(&Domain.Expense{Title: "Spend", Amount: amount, BudgetID: b.ID, LineItemID: lineItemID}, staffActor("u1"))
+		if err != nil {
+			t.Fatalf("create expense failed: %v", err)
+		}
+		return expenses.VerifyExpense(e.ID.Hex(), financeActor)
+	}
+	if err := spend(supplies.ID, 250); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	// the budget still has 750 left but supplies only 50
+	if err := spend(supplies.ID, 100); err == nil {
+		t.Fatalf("expected overspend on the supplies line to be rejected")
+	}
+	if err := spend(travel.ID, 100); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+
+	summary, err := uc.GetBudgetSummary(b.ID.Hex(), financeActor)
+	if err != nil {
+		t.Fatalf("summary failed: %v", err)
+	}
+	usage := summary["categories"].([]Domain.CategoryUsage)
+	if len(usage) != 2 || usage[0].Spent != 100 || usage[0].Remaining != 500 || usage[1].Spent != 250 || usage[1].Remaining != 50 {
+		t.Fatalf("unexpected category usage %+v", usage)
+	}
+	if summary["unallocated"] != Domain.Money(100) || summary["remaining"] != Domain.Money(650) {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+}
+
+func TestBudgetUsecase_StaleWritesConflict(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	uc := NewBudgetUsecase(budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo())
+	b, _ := uc.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "Travel", Amount: 500}, staffActor("u1"))
+	id := b.ID.Hex()
+
+	// the client read version 1 and sends it back in If-Match
+	editor := financeActor
+	editor.IfMatch = 1
+	if err := uc.UpdateBudget(id, &Domain.Budget{Title: "Travel Q1", Amount: 600}, editor); err != nil {
+		t.Fatalf("update at the current version failed: %v", err)
+	}
+	if v := budgets.store[id].Version; v != 2 {
+		t.Fatalf("expected the update to move the version to 2, got %d", v)
+	}
+	err := uc.UpdateBudget(id, &Domain.Budget{Title: "Travel Q2", Amount: 700}, editor)
+	if !errors.Is(err, Domain.ErrPreconditionFailed) {
+		t.Fatalf("expected a stale If-Match to fail the precondition, got %v", err)
+	}
+	if budgets.store[id].Title != "Travel Q1" {
+		t.Fatalf("a stale write must not apply")
+	}
+
+	// two writers that read the same version: the second one loses
+	first, _ := budgets.GetByID(id)
+	second, _ := budgets.GetByID(id)
+	first.Title = "first"
+	second.Title = "second"
+	if err := budgets.Update(id, first); err != nil {
+		t.Fatalf("first write failed: %v", err)
+	}
+	err = budgets.Update(id, second)
+	if !errors.Is(err, Domain.ErrConflict) || errors.Is(err, Domain.ErrPreconditionFailed) {
+		t.Fatalf("expected a plain conflict for the second write, got %v", err)
+	}
+
+	// spend between a read and a write also moves the version, so the write
+	// cannot restore the balance from before the spend
+	approved := newApprovedBudget(budgets, 1000)
+	read, _ := budgets.GetByID(approved.ID.Hex())
+	if err := budgets.Debit(approved.ID.Hex(), primitive.NilObjectID, 300); err != nil {
+		t.Fatalf("debit failed: %v", err)
+	}
+	if err := budgets.Update(approved.ID.Hex(), read); !errors.Is(err, Domain.ErrConflict) {
+		t.Fatalf("expected a write read before the debit to conflict, got %v", err)
+	}
+	if budgets.store[approved.ID.Hex()].Remaining != 700 {
+		t.Fatalf("the debit must survive the stale write")
+	}
+}
@@ -8,71 +8,328 @@ import (
 )
 
 type CashRequestUsecase interface {
-	CreateCashRequest(input *Domain.CashRequest) (*Domain.CashRequest, error)
-	GetAllCashRequests() ([]Domain.CashRequest, error)
-	GetCashRequestByID(id string) (*Domain.CashRequest, error)
-	ApproveCashRequest(id string) error
-	RejectCashRequest(id string) error
-	DisburseCashRequest(id string) error
+	CreateCashRequest(input *Domain.CashRequest, actor Domain.Actor) (*Domain.CashRequest, error)
+	GetAllCashRequests(q Domain.ListQuery, actor Domain.Actor) ([]Domain.CashRequest, *Domain.Pagination, error)
+	GetCashRequestByID(id string, actor Domain.Actor) (*Domain.CashRequest, error)
+	ApproveCashRequest(id string, actor Domain.Actor, comment string) (*Domain.CashRequest, error)
+	RejectCashRequest(id string, actor Domain.Actor, comment string) (*Domain.CashRequest, error)
+	DisburseCashRequest(id string, actor Domain.Actor) error
+	GetReconciliation(id string, actor Domain.Actor) (*Domain.CashReconciliation, error)
+	SettleCashRequest(id string, actor Domain.Actor) (*Domain.CashRequest, error)
 }
 
 type cashRequestUsecase struct {
-	repo Repositories.CashRequestRepository
+	repo        Repositories.CashRequestRepository
+	budgetRepo  Repositories.BudgetRepository
+	ledgerRepo  Repositories.LedgerRepository
+	policyRepo  Repositories.ApprovalPolicyRepository
+	currencies  CurrencyUsecase
+	expenseRepo Repositories.ExpenseRepository
+	periodRepo  Repositories.FiscalPeriodRepository
 }
 
-func NewCashRequestUsecase(repo Repositories.CashRequestRepository) CashRequestUsecase {
-	return &cashRequestUsecase{repo: repo}
+func NewCashRequestUsecase(repo Repositories.CashRequestRepository, budgetRepo Repositories.BudgetRepository, ledger Repositories.LedgerRepository, policies Repositories.ApprovalPolicyRepository, currencies CurrencyUsecase, expenses Repositories.ExpenseRepository, periods Repositories.FiscalPeriodRepository) CashRequestUsecase {
+	return &cashRequestUsecase{repo: repo, budgetRepo: budgetRepo, ledgerRepo: ledger, policyRepo: policies, currencies: currencies, expenseRepo: expenses, periodRepo: periods}
 }
 
-func (u *cashRequestUsecase) CreateCashRequest(input *Domain.CashRequest) (*Domain.CashRequest, error) {
+func (u *cashRequestUsecase) CreateCashRequest(input *Domain.CashRequest, actor Domain.Actor) (*Domain.CashRequest, error) {
 	if input.Title == "" {
 		return nil, errors.New("title is required")
 	}
 	if input.Amount <= 0 {
 		return nil, errors.New("amount must be greater than zero")
 	}
-	input.Status = "pending"
+	if err := checkPeriodOpen(u.periodRepo, u.budgetRepo, input.BudgetID); err != nil {
+		return nil, err
+	}
+	input.Requester = actor.UserID
+	input.Status = ""
+	input.History = nil
+	if err := input.TransitionTo(Domain.StatusPending, input.Requester); err != nil {
+		return nil, err
+	}
 	input.CreatedAt = time.Now().UTC()
+
+	// requests default to the currency of the budget they draw on
+	rate, base, err := priceInBase(u.currencies, &input.Currency, budgetCurrency(u.budgetRepo, input.BudgetID), input.Amount, input.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	input.ExchangeRate, input.BaseAmount = rate, base
+
+	// requests follow the approval policy of the department whose budget they draw on
+	chain, err := approvalChain(u.policyRepo, Domain.EntityCashRequest, cashRequestDepartment(u.budgetRepo, input), input.BaseAmount)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLineItem(u.budgetRepo, input.BudgetID, input.LineItemID); err != nil {
+		return nil, err
+	}
+	input.ApprovalChain = chain
+	input.Approvals = nil
 	if err := u.repo.Create(input); err != nil {
 		return nil, err
 	}
 	return input, nil
 }
 
-func (u *cashRequestUsecase) GetAllCashRequests() ([]Domain.CashRequest, error) {
-	return u.repo.GetAll()
+// GetAllCashRequests pages through the requests the caller may see; department
+// heads also see requests drawn on their department's budgets
+func (u *cashRequestUsecase) GetAllCashRequests(q Domain.ListQuery, actor Domain.Actor) ([]Domain.CashRequest, *Domain.Pagination, error) {
+	if err := normalizeListQuery(&q); err != nil {
+		return nil, nil, err
+	}
+	scope, err := spendScope(u.budgetRepo, actor)
+	if err != nil {
+		return nil, nil, err
+	}
+	q.Scope = scope
+	return u.repo.List(q)
+}
+
+func (u *cashRequestUsecase) GetCashRequestByID(id string, actor Domain.Actor) (*Domain.CashRequest, error) {
+	r, err := u.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.CanView(r.Requester) && !headsBudget(u.budgetRepo, actor, r.BudgetID) {
+		return nil, errors.New("cash request not found")
+	}
+	return r, nil
+}
+
+// ApproveCashRequest records the actor's sign-off at the next level of the
+// request's approval chain; the request is approved once every level has signed
+func (u *cashRequestUsecase) ApproveCashRequest(id string, actor Domain.Actor, comment string) (*Domain.CashRequest, error) {
+	return u.decide(id, actor, Domain.DecisionApproved, Domain.StatusApproved, comment)
 }
 
-func (u *cashRequestUsecase) GetCashRequestByID(id string) (*Domain.CashRequest, error) {
-	return u.repo.GetByID(id)
+// RejectCashRequest records the actor's rejection, which rejects the request outright
+func (u *cashRequestUsecase) RejectCashRequest(id string, actor Domain.Actor, comment string) (*Domain.CashRequest, error) {
+	return u.decide(id, actor, Domain.DecisionRejected, Domain.StatusRejected, comment)
 }
 
-func (u *cashRequestUsecase) ApproveCashRequest(id string) error {
+// decide records the decision and moves the request to status once the decision
+// is final: on rejection, or when the last level approves
+func (u *cashRequestUsecase) decide(id string, actor Domain.Actor, decision, status, comment string) (*Domain.CashRequest, error) {
 	r, err := u.repo.GetByID(id)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	r.Status = "approved"
-	return u.repo.Update(id, r)
+	if err := actor.CheckVersion(Domain.CashRequestStates.Entity, id, r.Version); err != nil {
+		return nil, err
+	}
+	if err := Domain.CashRequestStates.Check(r.Status, status); err != nil {
+		return nil, err
+	}
+	department := cashRequestDepartment(u.budgetRepo, r)
+	chain, err := resolveChain(u.policyRepo, Domain.EntityCashRequest, department, inBase(r.Amount, r.ExchangeRate), r.ApprovalChain)
+	if err != nil {
+		return nil, err
+	}
+	approvals, complete, err := recordDecision(chain, r.Approvals, actor, department, r.Requester, decision, comment)
+	if err != nil {
+		return nil, err
+	}
+	r.ApprovalChain = chain
+	r.Approvals = approvals
+	if decision == Domain.DecisionRejected || complete {
+		if err := r.TransitionTo(status, actor.UserID); err != nil {
+			return nil, err
+		}
+	}
+	if err := u.repo.Update(id, r); err != nil {
+		return nil, err
+	}
+	return r, nil
 }
 
-func (u *cashRequestUsecase) RejectCashRequest(id string) error {
+func (u *cashRequestUsecase) DisburseCashRequest(id string, actor Domain.Actor) error {
 	r, err := u.repo.GetByID(id)
 	if err != nil {
 		return err
 	}
-	r.Status = "rejected"
-	return u.repo.Update(id, r)
+	if err := actor.CheckVersion(Domain.CashRequestStates.Entity, id, r.Version); err != nil {
+		return err
+	}
+	if err := Domain.CashRequestStates.Check(r.Status, Domain.StatusDisbursed); err != nil {
+		return err
+	}
+	if r.BudgetID.IsZero() {
+		return errors.New("cash request is not linked to a budget")
+	}
+	// a request approved before its period closed is not paid out of the closed period
+	if err := checkPeriodOpen(u.periodRepo, u.budgetRepo, r.BudgetID); err != nil {
+		return err
+	}
+
+	budgetID := r.BudgetID.Hex()
+	b, err := u.budgetRepo.GetByID(budgetID)
+	if err != nil {
+		return err
+	}
+	if b.Status != Domain.StatusApproved {
+		return errors.New("budget is not approved")
+	}
+	// the budget is drawn down in its own currency at the rate on the request date
+	amount, err := u.currencies.Convert(r.Amount, r.Currency, b.Currency, r.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if b.Remaining < amount {
+		return errors.New("insufficient budget balance")
+	}
+	if err := checkLineBalance(b, r.LineItemID, amount); err != nil {
+		return err
+	}
+
+	// debit is conditional in the repository so concurrent disbursements cannot overdraw
+	if err := u.budgetRepo.Debit(budgetID, r.LineItemID, amount); err != nil {
+		return err
+	}
+
+	previous := *r
+	if err := r.TransitionTo(Domain.StatusDisbursed, actor.UserID); err != nil {
+		return err
+	}
+	if err := u.repo.Update(id, r); err != nil {
+		// compensate the debit so the budget is left untouched
+		return rollbackFailed("disbursement", err, u.budgetRepo.Credit(budgetID, r.LineItemID, amount))
+	}
+
+	if err := postTransfer(u.ledgerRepo, Domain.EventCashDisbursement, id, r.BudgetID, Domain.BudgetAccount(r.BudgetID), Domain.AccountCashAdvances, amount, b.Currency); err != nil {
+		previous.Version = r.Version
+		return rollbackFailed("ledger write", err, u.repo.Update(id, &previous), u.budgetRepo.Credit(budgetID, r.LineItemID, amount))
+	}
+	return nil
+}
+
+// GetReconciliation compares a disbursed advance with the expenses recorded against it
+func (u *cashRequestUsecase) GetReconciliation(id string, actor Domain.Actor) (*Domain.CashReconciliation, error) {
+	r, err := u.GetCashRequestByID(id, actor)
+	if err != nil {
+		return nil, err
+	}
+	return u.reconcile(r)
+}
+
+func (u *cashRequestUsecase) reconcile(r *Domain.CashRequest) (*Domain.CashReconciliation, error) {
+	if r.Status != Domain.StatusDisbursed && r.Status != Domain.StatusSettled {
+		return nil, errors.New("cash request has not been disbursed")
+	}
+	expenses, err := u.expenseRepo.GetByCashRequest(r.ID)
+	if err != nil {
+		return nil, err
+	}
+	rec := &Domain.CashReconciliation{
+		CashRequestID: r.ID,
+		Status:        r.Status,
+		Currency:      advanceCurrency(u.currencies, r),
+		Advance:       r.Amount,
+		Expenses:      expenses,
+		Settlement:    r.Settlement,
+	}
+	for _, e := range expenses {
+		switch e.Status {
+		case Domain.StatusVerified:
+			rec.Spent += e.Amount
+		case Domain.StatusPending:
+			rec.Pending += e.Amount
+		}
+	}
+	rec.Unreturned = rec.Advance - rec.Spent
+	if r.Settlement != nil {
+		rec.Unreturned += r.Settlement.TopUp - r.Settlement.Returned
+	}
+	return rec, nil
 }
 
-func (u *cashRequestUsecase) DisburseCashRequest(id string) error {
+// SettleCashRequest closes out a disbursed advance once every expense against it
+// is verified. Unspent cash is returned to the budget; spend beyond the advance
+// is topped up from it.
+func (u *cashRequestUsecase) SettleCashRequest(id string, actor Domain.Actor) (*Domain.CashRequest, error) {
 	r, err := u.repo.GetByID(id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := actor.CheckVersion(Domain.CashRequestStates.Entity, id, r.Version); err != nil {
+		return nil, err
+	}
+	if err := Domain.CashRequestStates.Check(r.Status, Domain.StatusSettled); err != nil {
+		return nil, err
+	}
+	rec, err := u.reconcile(r)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Pending > 0 {
+		return nil, errors.New("cash request has unverified expenses")
+	}
+
+	budgetID := r.BudgetID.Hex()
+	b, err := u.budgetRepo.GetByID(budgetID)
+	if err != nil {
+		return nil, err
+	}
+	// the budget side is valued the way the disbursement and each expense were
+	// posted, at the rate on the request date, so cash advances nets to zero
+	balance, err := u.currencies.Convert(r.Amount, r.Currency, b.Currency, r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range rec.Expenses {
+		if e.Status != Domain.StatusVerified {
+			continue
+		}
+		spent, err := u.currencies.Convert(e.Amount, e.Currency, b.Currency, r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		balance -= spent
+	}
+
+	previous := *r
+	if err := r.TransitionTo(Domain.StatusSettled, actor.UserID); err != nil {
+		return nil, err
+	}
+	r.Settlement = &Domain.CashSettlement{Spent: rec.Spent, SettledBy: actor.UserID, SettledAt: time.Now().UTC()}
+	if rec.Unreturned > 0 {
+		r.Settlement.Returned = rec.Unreturned
+	} else {
+		r.Settlement.TopUp = -rec.Unreturned
+	}
+
+	// a top-up is debited conditionally, like a disbursement
+	move, undo := u.budgetRepo.Credit, u.budgetRepo.Debit
+	if balance < 0 {
+		move, undo = u.budgetRepo.Debit, u.budgetRepo.Credit
+	}
+	amount := balance
+	if amount < 0 {
+		amount = -amount
+	}
+	if amount != 0 {
+		if err := move(budgetID, r.LineItemID, amount); err != nil {
+			return nil, err
+		}
+	}
+	if err := u.repo.Update(id, r); err != nil {
+		if amount != 0 {
+			return nil, rollbackFailed("settlement", err, undo(budgetID, r.LineItemID, amount))
+		}
+		return nil, err
+	}
+	if amount == 0 {
+		return r, nil
+	}
+
+	event, from, to := Domain.EventCashReturn, Domain.AccountCashAdvances, Domain.BudgetAccount(r.BudgetID)
+	if balance < 0 {
+		event, from, to = Domain.EventCashTopUp, Domain.BudgetAccount(r.BudgetID), Domain.AccountCashAdvances
 	}
-	if r.Status != "approved" {
-		return errors.New("only approved requests can be disbursed")
+	if err := postTransfer(u.ledgerRepo, event, id, r.BudgetID, from, to, amount, b.Currency); err != nil {
+		previous.Version = r.Version
+		return nil, rollbackFailed("ledger write", err, u.repo.Update(id, &previous), undo(budgetID, r.LineItemID, amount))
 	}
-	r.Status = "disbursed"
-	return u.repo.Update(id, r)
+	return r, nil
 }
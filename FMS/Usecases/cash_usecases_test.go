@@ -1,7 +1,10 @@
 package Usecases
 
 import (
+	"encoding/json"
 	"errors"
+	"slices"
+	"strings"
 	"testing"
 
 	"FMS/Domain"
@@ -31,6 +34,30 @@ func (m *mockCashRepo) GetAll() ([]Domain.CashRequest, error) {
 	}
 	return res, nil
 }
+func (m *mockCashRepo) List(q Domain.ListQuery) ([]Domain.CashRequest, *Domain.Pagination, error) {
+	all, _ := m.GetAll()
+	return mockList(all, q, func(r Domain.CashRequest) listFields {
+		return listFields{id: r.ID, owner: r.Requester, budgetID: r.BudgetID, status: r.Status, amount: r.Amount, createdAt: r.CreatedAt}
+	})
+}
+func (m *mockCashRepo) GetByStatus(status string) ([]Domain.CashRequest, error) {
+	res := []Domain.CashRequest{}
+	for _, v := range m.store {
+		if v.Status == status {
+			res = append(res, *v)
+		}
+	}
+	return res, nil
+}
+func (m *mockCashRepo) CountByBudgets(budgetIDs []primitive.ObjectID, statuses []string) (int64, error) {
+	var n int64
+	for _, v := range m.store {
+		if slices.Contains(budgetIDs, v.BudgetID) && slices.Contains(statuses, v.Status) {
+			n++
+		}
+	}
+	return n, nil
+}
 func (m *mockCashRepo) GetByID(id string) (*Domain.CashRequest, error) {
 	if v, ok := m.store[id]; ok {
 		return v, nil
@@ -38,9 +65,14 @@ func (m *mockCashRepo) GetByID(id string) (*Domain.CashRequest, error) {
 	return nil, errors.New("not found")
 }
 func (m *mockCashRepo) Update(id string, t *Domain.CashRequest) error {
-	if _, ok := m.store[id]; !ok {
+	v, ok := m.store[id]
+	if !ok {
 		return errors.New("not found")
 	}
+	if v.Version != t.Version {
+		return &Domain.ConflictError{Entity: "cash request", ID: id, Version: t.Version}
+	}
+	t.Version++
 	m.store[id] = t
 	return nil
 }
@@ -52,12 +84,175 @@ func (m *mockCashRepo) Delete(id string) error {
 	return nil
 }
 
+// mock budget repo
+type mockBudgetRepo struct {
+	store map[string]*Domain.Budget
+}
+
+func newMockBudgetRepo() *mockBudgetRepo {
+	return &mockBudgetRepo{store: make(map[string]*Domain.Budget)}
+}
+func (m *mockBudgetRepo) Create(t *Domain.Budget) error {
+	if t == nil {
+		return errors.New("nil")
+	}
+	if t.ID.IsZero() {
+		t.ID = primitive.NewObjectID()
+	}
+	t.Version = 1
+	m.store[t.ID.Hex()] = t
+	return nil
+}
+func (m *mockBudgetRepo) GetAll() ([]Domain.Budget, error) {
+	res := make([]Domain.Budget, 0, len(m.store))
+	for _, v := range m.store {
+		res = append(res, *v)
+	}
+	return res, nil
+}
+func (m *mockBudgetRepo) List(q Domain.ListQuery) ([]Domain.Budget, *Domain.Pagination, error) {
+	all, _ := m.GetAll()
+	return mockList(all, q, func(b Domain.Budget) listFields {
+		return listFields{id: b.ID, owner: b.CreatedBy, department: b.Department, status: b.Status, amount: b.Amount, createdAt: b.CreatedAt}
+	})
+}
+func (m *mockBudgetRepo) GetByStatus(status string) ([]Domain.Budget, error) {
+	res := []Domain.Budget{}
+	for _, v := range m.store {
+		if v.Status == status {
+			res = append(res, *v)
+		}
+	}
+	return res, nil
+}
+func (m *mockBudgetRepo) GetByDepartment(department string) ([]Domain.Budget, error) {
+	res := []Domain.Budget{}
+	for _, v := range m.store {
+		if v.Department == department {
+			res = append(res, *v)
+		}
+	}
+	return res, nil
+}
+func (m *mockBudgetRepo) GetByPeriod(periodID primitive.ObjectID) ([]Domain.Budget, error) {
+	res := []Domain.Budget{}
+	for _, v := range m.store {
+		if v.PeriodID == periodID {
+			res = append(res, *v)
+		}
+	}
+	return res, nil
+}
+func (m *mockBudgetRepo) GetByID(id string) (*Domain.Budget, error) {
+	if v, ok := m.store[id]; ok {
+		cp := *v
+		cp.LineItems = append([]Domain.BudgetLineItem(nil), v.LineItems...)
+		return &cp, nil
+	}
+	return nil, errors.New("not found")
+}
+func (m *mockBudgetRepo) Update(id string, t *Domain.Budget) error {
+	v, ok := m.store[id]
+	if !ok {
+		return errors.New("not found")
+	}
+	if v.Version != t.Version {
+		return &Domain.ConflictError{Entity: "budget", ID: id, Version: t.Version}
+	}
+	t.Version++
+	m.store[id] = t
+	return nil
+}
+func (m *mockBudgetRepo) Delete(id string) error {
+	if _, ok := m.store[id]; !ok {
+		return errors.New("not found")
+	}
+	delete(m.store, id)
+	return nil
+}
+func (m *mockBudgetRepo) Debit(id string, lineItemID primitive.ObjectID, amount Domain.Money) error {
+	b, ok := m.store[id]
+	if !ok || b.Status != "approved" || b.Remaining < amount {
+		return errors.New("budget not approved or insufficient funds")
+	}
+	if !lineItemID.IsZero() {
+		if li := b.LineItem(lineItemID); li == nil || li.Remaining < amount {
+			return errors.New("budget not approved or insufficient funds")
+		}
+		b.LineItem(lineItemID).Remaining -= amount
+	}
+	b.Remaining -= amount
+	b.Version++
+	return nil
+}
+func (m *mockBudgetRepo) Credit(id string, lineItemID primitive.ObjectID, amount Domain.Money) error {
+	b, ok := m.store[id]
+	if !ok {
+		return errors.New("not found")
+	}
+	if !lineItemID.IsZero() {
+		li := b.LineItem(lineItemID)
+		if li == nil {
+			return errors.New("not found")
+		}
+		li.Remaining += amount
+	}
+	b.Remaining += amount
+	b.Version++
+	return nil
+}
+
+func (m *mockBudgetRepo) MarkThresholdAlerted(id string, threshold int) (bool, error) {
+	b, ok := m.store[id]
+	if !ok {
+		return false, errors.New("not found")
+	}
+	for _, t := range b.AlertedThresholds {
+		if t == threshold {
+			return false, nil
+		}
+	}
+	b.AlertedThresholds = append(b.AlertedThresholds, threshold)
+	return true, nil
+}
+
+// failingCashRepo fails every update so the disbursement has to be rolled back
+type failingCashRepo struct{ *mockCashRepo }
+
+func (m *failingCashRepo) Update(id string, t *Domain.CashRequest) error {
+	return errors.New("write failed")
+}
+
+// flakyCashRepo lets the first ok updates through and fails the rest
+type flakyCashRepo struct {
+	*mockCashRepo
+	ok int
+}
+
+var errCashWrite = errors.New("cash request write failed")
+
+func (m *flakyCashRepo) Update(id string, t *Domain.CashRequest) error {
+	if m.ok == 0 {
+		return errCashWrite
+	}
+	m.ok--
+	return m.mockCashRepo.Update(id, t)
+}
+
+func newApprovedBudget(budgets *mockBudgetRepo, amount Domain.Money) *Domain.Budget {
+	b := &Domain.Budget{ID: primitive.NewObjectID(), Title: "Ops", Amount: amount, Remaining: amount, Status: "approved"}
+	_ = budgets.Create(b)
+	return b
+}
+
 func TestCashUsecase_CreateApproveDisburse(t *testing.T) {
 	mock := newMockCashRepo()
-	uc := NewCashRequestUsecase(mock)
+	budgets := newMockBudgetRepo()
+	uc := NewCashRequestUsecase(mock, budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo())
+	b := newApprovedBudget(budgets, 1000)
 
-	c := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Req1", Amount: 500}
-	created, err := uc.CreateCashRequest(c)
+	c := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Req1", Amount: 500, BudgetID: b.ID}
+	created, err := uc.CreateCashRequest(c, staffActor("u1"))
 	if err != nil {
 		t.Fatalf("create failed: %v", err)
 	}
@@ -65,19 +260,314 @@ func TestCashUsecase_CreateApproveDisburse(t *testing.T) {
 		t.Fatalf("expected pending")
 	}
 
-	if err := uc.ApproveCashRequest(created.ID.Hex()); err != nil {
+	if _, err := uc.ApproveCashRequest(created.ID.Hex(), financeActor, ""); err != nil {
 		t.Fatalf("approve failed: %v", err)
 	}
-	cr, _ := uc.GetCashRequestByID(created.ID.Hex())
+	cr, _ := uc.GetCashRequestByID(created.ID.Hex(), financeActor)
 	if cr.Status != "approved" {
 		t.Fatalf("expected approved")
 	}
 
-	if err := uc.DisburseCashRequest(created.ID.Hex()); err != nil {
+	if err := uc.DisburseCashRequest(created.ID.Hex(), financeActor); err != nil {
 		t.Fatalf("disburse failed: %v", err)
 	}
-	cr2, _ := uc.GetCashRequestByID(created.ID.Hex())
+	cr2, _ := uc.GetCashRequestByID(created.ID.Hex(), financeActor)
 	if cr2.Status != "disbursed" {
 		t.Fatalf("expected disbursed")
 	}
+	if budgets.store[b.ID.Hex()].Remaining != 500 {
+		t.Fatalf("expected remaining 500, got %v", budgets.store[b.ID.Hex()].Remaining)
+	}
+}
+
+func TestCashUsecase_DisburseRefusesInsufficientFunds(t *testing.T) {
+	mock := newMockCashRepo()
+	budgets := newMockBudgetRepo()
+	uc := NewCashRequestUsecase(mock, budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo())
+	b := newApprovedBudget(budgets, 100)
+
+	c := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Req", Amount: 500, BudgetID: b.ID, Status: "approved"}
+	_ = mock.Create(c)
+
+	if err := uc.DisburseCashRequest(c.ID.Hex(), financeActor); err == nil {
+		t.Fatalf("expected insufficient funds error")
+	}
+	if mock.store[c.ID.Hex()].Status != "approved" {
+		t.Fatalf("cash request should stay approved")
+	}
+	if budgets.store[b.ID.Hex()].Remaining != 100 {
+		t.Fatalf("budget should be untouched")
+	}
+}
+
+func TestCashUsecase_DisburseRefusesUnapprovedBudget(t *testing.T) {
+	mock := newMockCashRepo()
+	budgets := newMockBudgetRepo()
+	uc := NewCashRequestUsecase(mock, budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo())
+	b := newApprovedBudget(budgets, 1000)
+	b.Status = "pending"
+
+	c := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Req", Amount: 50, BudgetID: b.ID, Status: "approved"}
+	_ = mock.Create(c)
+
+	if err := uc.DisburseCashRequest(c.ID.Hex(), financeActor); err == nil {
+		t.Fatalf("expected error for unapproved budget")
+	}
+}
+
+func TestCashUsecase_DisburseRollsBackOnWriteFailure(t *testing.T) {
+	mock := &failingCashRepo{newMockCashRepo()}
+	budgets := newMockBudgetRepo()
+	uc := NewCashRequestUsecase(mock, budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo())
+	b := newApprovedBudget(budgets, 1000)
+
+	c := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Req", Amount: 300, BudgetID: b.ID, Status: "approved"}
+	_ = mock.Create(c)
+
+	if err := uc.DisburseCashRequest(c.ID.Hex(), financeActor); err == nil {
+		t.Fatalf("expected write failure")
+	}
+	if budgets.store[b.ID.Hex()].Remaining != 1000 {
+		t.Fatalf("expected budget rollback, remaining %v", budgets.store[b.ID.Hex()].Remaining)
+	}
+}
+
+func TestCashUsecase_DisburseUndoneWhenLedgerFails(t *testing.T) {
+	cash := &flakyCashRepo{mockCashRepo: newMockCashRepo(), ok: 2}
+	budgets := newMockBudgetRepo()
+	ledger := &failingLedgerRepo{newMockLedgerRepo()}
+	uc := NewCashRequestUsecase(cash, budgets, ledger, newMockApprovalPolicyRepo(), testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo())
+	b := newApprovedBudget(budgets, 1000)
+
+	// the disbursement and its rollback are both written
+	r := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Req", Amount: 300, BudgetID: b.ID, Status: "approved"}
+	_ = cash.Create(r)
+	err := uc.DisburseCashRequest(r.ID.Hex(), financeActor)
+	if !errors.Is(err, errLedgerDown) || strings.Contains(err.Error(), "rollback") {
+		t.Fatalf("expected the ledger error alone, got %v", err)
+	}
+	if got := cash.store[r.ID.Hex()]; got.Status != Domain.StatusApproved || budgets.store[b.ID.Hex()].Remaining != 1000 {
+		t.Fatalf("expected the request and budget restored, got %s with %v remaining", got.Status, budgets.store[b.ID.Hex()].Remaining)
+	}
+
+	// the disbursement is written but putting the request back is not
+	cash.ok = 1
+	err = uc.DisburseCashRequest(r.ID.Hex(), financeActor)
+	if !errors.Is(err, errLedgerDown) || !errors.Is(err, errCashWrite) || !strings.Contains(err.Error(), "rollback failed") {
+		t.Fatalf("expected the failed rollback reported with its cause, got %v", err)
+	}
+	if budgets.store[b.ID.Hex()].Remaining != 1000 {
+		t.Fatalf("expected the budget credited back even so, got %v", budgets.store[b.ID.Hex()].Remaining)
+	}
+}
+
+func TestCashUsecase_DisbursedRequestsCannotBeRejected(t *testing.T) {
+	mock := newMockCashRepo()
+	budgets := newMockBudgetRepo()
+	uc := NewCashRequestUsecase(mock, budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo())
+	b := newApprovedBudget(budgets, 1000)
+
+	c := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Req", Amount: 100, BudgetID: b.ID, Status: "disbursed"}
+	_ = mock.Create(c)
+
+	if _, err := uc.RejectCashRequest(c.ID.Hex(), financeActor, ""); !errors.Is(err, Domain.ErrInvalidTransition) {
+		t.Fatalf("expected rejecting a disbursed request to be an invalid transition, got %v", err)
+	}
+	if err := uc.DisburseCashRequest(c.ID.Hex(), financeActor); !errors.Is(err, Domain.ErrInvalidTransition) {
+		t.Fatalf("expected a second disbursement to be an invalid transition, got %v", err)
+	}
+	if mock.store[c.ID.Hex()].Status != "disbursed" || budgets.store[b.ID.Hex()].Remaining != 1000 {
+		t.Fatalf("request and budget must be untouched")
+	}
+}
+
+func TestCashUsecase_RepeatedDisbursementsDoNotDrift(t *testing.T) {
+	mock := newMockCashRepo()
+	budgets := newMockBudgetRepo()
+	uc := NewCashRequestUsecase(mock, budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo())
+	b := newApprovedBudget(budgets, Domain.NewMoney(100, 0))
+
+	// amounts arrive as JSON numbers or strings
+	for i, body := range []string{`{"amount": 0.1}`, `{"amount": "0.10"}`} {
+		for n := 0; n < 5; n++ {
+			var input Domain.CashRequest
+			if err := json.Unmarshal([]byte(body), &input); err != nil {
+				t.Fatalf("decode %d failed: %v", i, err)
+			}
+			input.ID, input.Title, input.BudgetID = primitive.NewObjectID(), "Coffee", b.ID
+			r, err := uc.CreateCashRequest(&input, staffActor("u1"))
+			if err != nil {
+				t.Fatalf("create failed: %v", err)
+			}
+			_, _ = uc.ApproveCashRequest(r.ID.Hex(), financeActor, "")
+			if err := uc.DisburseCashRequest(r.ID.Hex(), financeActor); err != nil {
+				t.Fatalf("disburse failed: %v", err)
+			}
+		}
+	}
+
+	remaining := budgets.store[b.ID.Hex()].Remaining
+	if remaining != Domain.NewMoney(99, 0) {
+		t.Fatalf("expected exactly 99.00 left, got %v", remaining)
+	}
+	if out, _ := json.Marshal(remaining); string(out) != "99.00" {
+		t.Fatalf("expected 99.00 in JSON, got %s", out)
+	}
+
+	var input Domain.CashRequest
+	if err := json.Unmarshal([]byte(`{"amount": 0.001}`), &input); !errors.Is(err, Domain.ErrInvalidMoney) {
+		t.Fatalf("expected fractions of a cent to be rejected, got %v", err)
+	}
+}
+
+func TestCashUsecase_ReconcileAndSettleAdvance(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	cash := newMockCashRepo()
+	expenses := newMockExpenseRepo()
+	ledger := newMockLedgerRepo()
+	uc := NewCashRequestUsecase(cash, budgets, ledger, newMockApprovalPolicyRepo(), testCurrencies(), expenses, newMockFiscalPeriodRepo())
+	expenseUC := NewExpenseUsecase(expenses, budgets, ledger, newMockBlobStorage(), testCurrencies(), newMockFiscalPeriodRepo(), cash)
+	b := newApprovedBudget(budgets, 1000)
+
+	advance := func(amount Domain.Money) *Domain.CashRequest {
+		r := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Trip", Amount: amount, BudgetID: b.ID, Requester: "u1", Status: "approved"}
+		_ = cash.Create(r)
+		if err := uc.DisburseCashRequest(r.ID.Hex(), financeActor); err != nil {
+			t.Fatalf("disburse failed: %v", err)
+		}
+		return r
+	}
+	spend := func(r *Domain.CashRequest, amount Domain.Money, verify bool) *Domain.Expense {
+		e, err := expenseUC.CreateExpense(&Domain.Expense{Title: "Receipt", Amount: amount, CashRequestID: r.ID}, staffActor("u1"))
+		if err != nil {
+			t.Fatalf("create expense failed: %v", err)
+		}
+		if e.BudgetID != b.ID {
+			t.Fatalf("expected expense to take the advance's budget")
+		}
+		if verify {
+			if err := expenseUC.VerifyExpense(e.ID.Hex(), financeActor); err != nil {
+				t.Fatalf("verify failed: %v", err)
+			}
+		}
+		return e
+	}
+
+	r := advance(300)
+	spend(r, 120, true)
+	pending := spend(r, 80, false)
+	if _, err := uc.SettleCashRequest(r.ID.Hex(), financeActor); err == nil {
+		t.Fatalf("expected settlement with unverified expenses to fail")
+	}
+	if err := expenseUC.VerifyExpense(pending.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	// expenses paid from the advance do not draw on the budget again
+	if budgets.store[b.ID.Hex()].Remaining != 700 {
+		t.Fatalf("expected 700 remaining before settlement, got %v", budgets.store[b.ID.Hex()].Remaining)
+	}
+
+	rec, err := uc.GetReconciliation(r.ID.Hex(), staffActor("u1"))
+	if err != nil {
+		t.Fatalf("reconciliation failed: %v", err)
+	}
+	if rec.Advance != 300 || rec.Spent != 200 || rec.Pending != 0 || rec.Unreturned != 100 || len(rec.Expenses) != 2 {
+		t.Fatalf("unexpected reconciliation %+v", rec)
+	}
+
+	settled, err := uc.SettleCashRequest(r.ID.Hex(), financeActor)
+	if err != nil {
+		t.Fatalf("settle failed: %v", err)
+	}
+	if settled.Status != Domain.StatusSettled || settled.Settlement.Returned != 100 || settled.Settlement.TopUp != 0 {
+		t.Fatalf("unexpected settlement %+v", settled.Settlement)
+	}
+	if budgets.store[b.ID.Hex()].Remaining != 800 {
+		t.Fatalf("expected returned cash back in the budget, got %v", budgets.store[b.ID.Hex()].Remaining)
+	}
+	if _, err := uc.SettleCashRequest(r.ID.Hex(), financeActor); !errors.Is(err, Domain.ErrInvalidTransition) {
+		t.Fatalf("expected second settlement to be an invalid transition, got %v", err)
+	}
+
+	over := advance(100)
+	spend(over, 150, true)
+	settled, err = uc.SettleCashRequest(over.ID.Hex(), financeActor)
+	if err != nil {
+		t.Fatalf("settle failed: %v", err)
+	}
+	if settled.Settlement.TopUp != 50 || budgets.store[b.ID.Hex()].Remaining != 650 {
+		t.Fatalf("expected a 50 top-up from the budget, got %+v with %v remaining", settled.Settlement, budgets.store[b.ID.Hex()].Remaining)
+	}
+
+	results, err := NewLedgerUsecase(ledger, budgets).CheckConsistency()
+	if err != nil {
+		t.Fatalf("consistency failed: %v", err)
+	}
+	if !results[0].Consistent {
+		t.Fatalf("expected ledger to match the budget after settlement, got %+v", results[0])
+	}
+	var advances Domain.Money
+	for _, le := range ledger.entries {
+		if le.Account == Domain.AccountCashAdvances {
+			if le.Type == Domain.LedgerCredit {
+				advances += le.Amount
+			} else {
+				advances -= le.Amount
+			}
+		}
+	}
+	if advances != 0 {
+		t.Fatalf("expected settled advances to net to zero, got %v", advances)
+	}
+}
+
+func TestCashUsecase_SettleLeavesOutRejectedExpenses(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	cash := newMockCashRepo()
+	expenses := newMockExpenseRepo()
+	ledger := newMockLedgerRepo()
+	uc := NewCashRequestUsecase(cash, budgets, ledger, newMockApprovalPolicyRepo(), testCurrencies(), expenses, newMockFiscalPeriodRepo())
+	expenseUC := NewExpenseUsecase(expenses, budgets, ledger, newMockBlobStorage(), testCurrencies(), newMockFiscalPeriodRepo(), cash)
+	b := newApprovedBudget(budgets, 1000)
+
+	r := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Trip", Amount: 300, BudgetID: b.ID, Requester: "u1", Status: "approved"}
+	_ = cash.Create(r)
+	if err := uc.DisburseCashRequest(r.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("disburse failed: %v", err)
+	}
+	kept, _ := expenseUC.CreateExpense(&Domain.Expense{Title: "Taxi", Amount: 120, CashRequestID: r.ID}, staffActor("u1"))
+	dropped, _ := expenseUC.CreateExpense(&Domain.Expense{Title: "Minibar", Amount: 90, CashRequestID: r.ID}, staffActor("u1"))
+	if err := expenseUC.VerifyExpense(kept.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+
+	// a pending expense holds up the settlement until finance decides on it
+	if _, err := uc.SettleCashRequest(r.ID.Hex(), financeActor); err == nil {
+		t.Fatalf("expected settlement with an undecided expense to fail")
+	}
+	if _, err := expenseUC.RejectExpense(dropped.ID.Hex(), financeActor, "not a business cost"); err != nil {
+		t.Fatalf("reject failed: %v", err)
+	}
+
+	rec, err := uc.GetReconciliation(r.ID.Hex(), staffActor("u1"))
+	if err != nil {
+		t.Fatalf("reconciliation failed: %v", err)
+	}
+	if rec.Spent != 120 || rec.Pending != 0 || rec.Unreturned != 180 || len(rec.Expenses) != 2 {
+		t.Fatalf("expected the rejected expense listed but not counted, got %+v", rec)
+	}
+	settled, err := uc.SettleCashRequest(r.ID.Hex(), financeActor)
+	if err != nil {
+		t.Fatalf("settle failed: %v", err)
+	}
+	if settled.Settlement.Spent != 120 || settled.Settlement.Returned != 180 {
+		t.Fatalf("expected the rejected amount returned with the rest, got %+v", settled.Settlement)
+	}
+	if remaining := budgets.store[b.ID.Hex()].Remaining; remaining != 880 {
+		t.Fatalf("expected only the verified expense drawn from the budget, got %v remaining", remaining)
+	}
+	results, _ := NewLedgerUsecase(ledger, budgets).CheckConsistency()
+	if !results[0].Consistent {
+		t.Fatalf("expected ledger to match the budget after settlement, got %+v", results[0])
+	}
 }
@@ -0,0 +1,159 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CurrencyUsecase manages the exchange-rate table and converts amounts through
+// the base currency
+type CurrencyUsecase interface {
+	BaseCurrency() string
+	GetRates(currency string) ([]Domain.ExchangeRate, error)
+	AddRate(rate *Domain.ExchangeRate, actor Domain.Actor) (*Domain.ExchangeRate, error)
+	DeleteRate(id string) error
+	// RateToBase returns how many base units one unit of currency was worth at
+	RateToBase(currency string, at time.Time) (float64, error)
+	// Convert converts amount between two currencies at the rates effective at
+	Convert(amount Domain.Money, from, to string, at time.Time) (Domain.Money, error)
+}
+
+type currencyUsecase struct {
+	rateRepo Repositories.ExchangeRateRepository
+	base     string
+}
+
+// NewCurrencyUsecase expects base to be a normalized currency code
+func NewCurrencyUsecase(rates Repositories.ExchangeRateRepository, base string) CurrencyUsecase {
+	return &currencyUsecase{rateRepo: rates, base: base}
+}
+
+func (u *currencyUsecase) BaseCurrency() string {
+	return u.base
+}
+
+func (u *currencyUsecase) GetRates(currency string) ([]Domain.ExchangeRate, error) {
+	if currency != "" {
+		code, err := Domain.NormalizeCurrency(currency)
+		if err != nil {
+			return nil, err
+		}
+		currency = code
+	}
+	return u.rateRepo.List(currency)
+}
+
+// AddRate records a rate; rates are never edited, a newer EffectiveFrom supersedes them
+func (u *currencyUsecase) AddRate(rate *Domain.ExchangeRate, actor Domain.Actor) (*Domain.ExchangeRate, error) {
+	code, err := Domain.NormalizeCurrency(rate.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if code == u.base {
+		return nil, errors.New("the base currency always has rate 1")
+	}
+	if rate.Rate <= 0 || math.IsInf(rate.Rate, 0) || math.IsNaN(rate.Rate) {
+		return nil, errors.New("rate must be greater than zero")
+	}
+	if rate.EffectiveFrom.IsZero() {
+		return nil, errors.New("effective_from is required")
+	}
+
+	rate.Currency = code
+	rate.EffectiveFrom = rate.EffectiveFrom.UTC()
+	rate.CreatedBy = actor.UserID
+	rate.CreatedAt = time.Now().UTC()
+	if err := u.rateRepo.Create(rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+func (u *currencyUsecase) DeleteRate(id string) error {
+	return u.rateRepo.Delete(id)
+}
+
+func (u *currencyUsecase) RateToBase(currency string, at time.Time) (float64, error) {
+	// records from before currencies were tracked are in the base currency
+	if currency == "" || currency == u.base {
+		return 1, nil
+	}
+	rate, err := u.rateRepo.FindEffective(currency, at)
+	if err != nil {
+		return 0, err
+	}
+	if rate == nil {
+		return 0, fmt.Errorf("%w for %s on %s", Domain.ErrNoExchangeRate, currency, at.UTC().Format("2006-01-02"))
+	}
+	return rate.Rate, nil
+}
+
+func (u *currencyUsecase) Convert(amount Domain.Money, from, to string, at time.Time) (Domain.Money, error) {
+	if from == to {
+		return amount, nil
+	}
+	fromRate, err := u.RateToBase(from, at)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := u.RateToBase(to, at)
+	if err != nil {
+		return 0, err
+	}
+	return amount.MulRate(fromRate / toRate), nil
+}
+
+// priceInBase defaults *currency to fallback (or the base currency), normalizes it,
+// and returns the rate effective at along with amount converted to base
+func priceInBase(currencies CurrencyUsecase, currency *string, fallback string, amount Domain.Money, at time.Time) (rate float64, base Domain.Money, err error) {
+	if *currency == "" {
+		*currency = fallback
+	}
+	if *currency == "" {
+		*currency = currencies.BaseCurrency()
+	}
+	if *currency, err = Domain.NormalizeCurrency(*currency); err != nil {
+		return 0, 0, err
+	}
+	if rate, err = currencies.RateToBase(*currency, at); err != nil {
+		return 0, 0, err
+	}
+	return rate, amount.MulRate(rate), nil
+}
+
+// inBase is a record's amount in the base currency; records priced before
+// currencies were tracked have no rate and are already in base
+func inBase(amount Domain.Money, rate float64) Domain.Money {
+	if rate == 0 {
+		return amount
+	}
+	return amount.MulRate(rate)
+}
+
+// advanceCurrency is the currency a cash request was paid in; requests from
+// before currencies were tracked are in the base currency
+func advanceCurrency(currencies CurrencyUsecase, r *Domain.CashRequest) string {
+	if r.Currency == "" {
+		return currencies.BaseCurrency()
+	}
+	return r.Currency
+}
+
+// budgetCurrency is the currency of the linked budget, used as the default for
+// spend drawn on it
+func budgetCurrency(budgetRepo Repositories.BudgetRepository, budgetID primitive.ObjectID) string {
+	if budgetID.IsZero() {
+		return ""
+	}
+	b, err := budgetRepo.GetByID(budgetID.Hex())
+	if err != nil {
+		return ""
+	}
+	return b.Currency
+}
@@ -0,0 +1,152 @@
+package Usecases
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"FMS/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mock exchange rate repo
+type mockExchangeRateRepo struct{ rates []Domain.ExchangeRate }
+
+func newMockExchangeRateRepo() *mockExchangeRateRepo { return &mockExchangeRateRepo{} }
+
+func (m *mockExchangeRateRepo) Create(rate *Domain.ExchangeRate) error {
+	if rate.ID.IsZero() {
+		rate.ID = primitive.NewObjectID()
+	}
+	m.rates = append(m.rates, *rate)
+	return nil
+}
+func (m *mockExchangeRateRepo) List(currency string) ([]Domain.ExchangeRate, error) {
+	res := []Domain.ExchangeRate{}
+	for _, r := range m.rates {
+		if currency == "" || r.Currency == currency {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+func (m *mockExchangeRateRepo) FindEffective(currency string, at time.Time) (*Domain.ExchangeRate, error) {
+	var found *Domain.ExchangeRate
+	for i, r := range m.rates {
+		if r.Currency != currency || r.EffectiveFrom.After(at) {
+			continue
+		}
+		if found == nil || r.EffectiveFrom.After(found.EffectiveFrom) {
+			found = &m.rates[i]
+		}
+	}
+	return found, nil
+}
+func (m *mockExchangeRateRepo) Delete(id string) error {
+	for i, r := range m.rates {
+		if r.ID.Hex() == id {
+			m.rates = append(m.rates[:i], m.rates[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// testCurrencies reports in USD with an empty rate table
+func testCurrencies() CurrencyUsecase {
+	return NewCurrencyUsecase(newMockExchangeRateRepo(), "USD")
+}
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestCurrency_ConvertsAtRateEffectiveOnDate(t *testing.T) {
+	uc := NewCurrencyUsecase(newMockExchangeRateRepo(), "USD")
+	if _, err := uc.AddRate(&Domain.ExchangeRate{Currency: "eur", Rate: 1.10, EffectiveFrom: day("2024-01-01")}, financeActor); err != nil {
+		t.Fatalf("add rate failed: %v", err)
+	}
+	_, _ = uc.AddRate(&Domain.ExchangeRate{Currency: "EUR", Rate: 1.20, EffectiveFrom: day("2024-06-01")}, financeActor)
+	_, _ = uc.AddRate(&Domain.ExchangeRate{Currency: "GBP", Rate: 1.25, EffectiveFrom: day("2024-01-01")}, financeActor)
+
+	if got, _ := uc.Convert(Domain.NewMoney(100, 0), "EUR", "USD", day("2024-03-15")); got != Domain.NewMoney(110, 0) {
+		t.Fatalf("expected the January rate in March, got %v", got)
+	}
+	if got, _ := uc.Convert(Domain.NewMoney(100, 0), "EUR", "USD", day("2024-07-01")); got != Domain.NewMoney(120, 0) {
+		t.Fatalf("expected the June rate in July, got %v", got)
+	}
+	if got, _ := uc.Convert(Domain.NewMoney(125, 0), "GBP", "EUR", day("2024-03-15")); got != Domain.NewMoney(142, 5) {
+		t.Fatalf("expected cross rate through the base currency, got %v", got)
+	}
+	if _, err := uc.Convert(Domain.NewMoney(100, 0), "EUR", "USD", day("2023-12-31")); !errors.Is(err, Domain.ErrNoExchangeRate) {
+		t.Fatalf("expected missing rate before the first one takes effect, got %v", err)
+	}
+}
+
+func TestCurrency_AddRateValidates(t *testing.T) {
+	uc := testCurrencies()
+	cases := []Domain.ExchangeRate{
+		{Currency: "USD", Rate: 1, EffectiveFrom: day("2024-01-01")},
+		{Currency: "EURO", Rate: 1.1, EffectiveFrom: day("2024-01-01")},
+		{Currency: "EUR", Rate: 0, EffectiveFrom: day("2024-01-01")},
+		{Currency: "EUR", Rate: 1.1},
+	}
+	for _, c := range cases {
+		if _, err := uc.AddRate(&c, financeActor); err == nil {
+			t.Fatalf("expected %+v to be rejected", c)
+		}
+	}
+}
+
+func TestCurrency_ForeignRequestDebitsBudgetCurrency(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	cash := newMockCashRepo()
+	ledger := newMockLedgerRepo()
+	currencies := testCurrencies()
+	_, _ = currencies.AddRate(&Domain.ExchangeRate{Currency: "EUR", Rate: 1.10, EffectiveFrom: day("2024-01-01")}, financeActor)
+	uc := NewCashRequestUsecase(cash, budgets, ledger, newMockApprovalPolicyRepo(), currencies, newMockExpenseRepo(), newMockFiscalPeriodRepo())
+
+	b := newApprovedBudget(budgets, 1000)
+	b.Currency = "USD"
+	r, err := uc.CreateCashRequest(&Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Hotel", Amount: 200, Currency: "eur", BudgetID: b.ID}, staffActor("u1"))
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if r.Currency != "EUR" || r.ExchangeRate != 1.10 || r.BaseAmount != 220 {
+		t.Fatalf("expected the request priced in base at creation, got %+v", r)
+	}
+
+	_, _ = uc.ApproveCashRequest(r.ID.Hex(), financeActor, "")
+	if err := uc.DisburseCashRequest(r.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("disburse failed: %v", err)
+	}
+	if got, _ := budgets.GetByID(b.ID.Hex()); got.Remaining != 780 {
+		t.Fatalf("expected 220 USD debited, remaining %v", got.Remaining)
+	}
+	if len(ledger.entries) != 2 || ledger.entries[0].Amount != 220 || ledger.entries[0].Currency != "USD" {
+		t.Fatalf("expected ledger posting in the budget currency, got %+v", ledger.entries)
+	}
+}
+
+func TestCurrency_MissingRateRejectsCreate(t *testing.T) {
+	uc := NewBudgetUsecase(newMockBudgetRepo(), newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo())
+	_, err := uc.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "Zurich office", Amount: 1000, Currency: "CHF"}, staffActor("u1"))
+	if !errors.Is(err, Domain.ErrNoExchangeRate) {
+		t.Fatalf("expected missing rate error, got %v", err)
+	}
+}
+
+func TestCurrency_RejectsCurrenciesWithoutCents(t *testing.T) {
+	currencies := testCurrencies()
+	for _, code := range []string{"jpy", "KWD", "BHD"} {
+		if _, err := currencies.AddRate(&Domain.ExchangeRate{Currency: code, Rate: 1, EffectiveFrom: day("2024-01-01")}, financeActor); !errors.Is(err, Domain.ErrUnsupportedCurrency) {
+			t.Fatalf("expected a rate for %s to be rejected, got %v", code, err)
+		}
+	}
+	uc := NewBudgetUsecase(newMockBudgetRepo(), newMockLedgerRepo(), newMockApprovalPolicyRepo(), currencies, newMockFiscalPeriodRepo())
+	if _, err := uc.CreateBudget(&Domain.Budget{ID: primitive.NewObjectID(), Title: "Tokyo office", Amount: 1000, Currency: "JPY"}, staffActor("u1")); !errors.Is(err, Domain.ErrUnsupportedCurrency) {
+		t.Fatalf("expected a JPY budget to be rejected, got %v", err)
+	}
+}
@@ -2,64 +2,350 @@ package Usecases
 
 import (
 	"FMS/Domain"
+	"FMS/Infrastructure"
 	"FMS/Repositories"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"io"
+	"net/http"
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// MaxReceiptSize is the largest receipt file accepted for upload
+const MaxReceiptSize = 10 << 20
+
+// receipt types accepted, checked against the sniffed content not the client header
+var allowedReceiptTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
 type ExpenseUsecase interface {
-	CreateExpense(input *Domain.Expense) (*Domain.Expense, error)
-	GetAllExpenses() ([]Domain.Expense, error)
-	GetExpenseByID(id string) (*Domain.Expense, error)
-	AttachReceipt(id, receiptURL string) error
-	VerifyExpense(id string) error
+	CreateExpense(input *Domain.Expense, actor Domain.Actor) (*Domain.Expense, error)
+	GetAllExpenses(q Domain.ListQuery, actor Domain.Actor) ([]Domain.Expense, *Domain.Pagination, error)
+	GetExpenseByID(id string, actor Domain.Actor) (*Domain.Expense, error)
+	AttachReceipt(id, receiptURL string, actor Domain.Actor) error
+	UploadReceipt(id, filename string, r io.Reader, actor Domain.Actor) (*Domain.Receipt, error)
+	OpenReceipt(id, receiptID string, actor Domain.Actor) (*Domain.Receipt, io.ReadCloser, error)
+	VerifyExpense(id string, actor Domain.Actor) error
+	// RejectExpense turns down a pending expense. No money has moved yet, so
+	// nothing is undone; a rejected expense no longer counts against its advance.
+	RejectExpense(id string, actor Domain.Actor, comment string) (*Domain.Expense, error)
 }
 
 type expenseUsecase struct {
-	repo Repositories.ExpenseRepository
+	repo       Repositories.ExpenseRepository
+	budgetRepo Repositories.BudgetRepository
+	ledgerRepo Repositories.LedgerRepository
+	storage    Infrastructure.BlobStorage
+	currencies CurrencyUsecase
+	periodRepo Repositories.FiscalPeriodRepository
+	cashRepo   Repositories.CashRequestRepository
 }
 
-func NewExpenseUsecase(repo Repositories.ExpenseRepository) ExpenseUsecase {
-	return &expenseUsecase{repo: repo}
+func NewExpenseUsecase(repo Repositories.ExpenseRepository, budgetRepo Repositories.BudgetRepository, ledger Repositories.LedgerRepository, storage Infrastructure.BlobStorage, currencies CurrencyUsecase, periods Repositories.FiscalPeriodRepository, cashRequests Repositories.CashRequestRepository) ExpenseUsecase {
+	return &expenseUsecase{repo: repo, budgetRepo: budgetRepo, ledgerRepo: ledger, storage: storage, currencies: currencies, periodRepo: periods, cashRepo: cashRequests}
 }
 
-func (u *expenseUsecase) CreateExpense(input *Domain.Expense) (*Domain.Expense, error) {
+func (u *expenseUsecase) CreateExpense(input *Domain.Expense, actor Domain.Actor) (*Domain.Expense, error) {
 	if input.Title == "" {
 		return nil, errors.New("title is required")
 	}
 	if input.Amount <= 0 {
 		return nil, errors.New("amount must be greater than zero")
 	}
-	input.Status = "pending"
+	advance, err := u.linkCashRequest(input, actor)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPeriodOpen(u.periodRepo, u.budgetRepo, input.BudgetID); err != nil {
+		return nil, err
+	}
+	if err := checkLineItem(u.budgetRepo, input.BudgetID, input.LineItemID); err != nil {
+		return nil, err
+	}
+	input.CreatedBy = actor.UserID
+	input.Status = ""
+	input.History = nil
+	if err := input.TransitionTo(Domain.StatusPending, input.CreatedBy); err != nil {
+		return nil, err
+	}
 	input.CreatedAt = time.Now().UTC()
+
+	rate, base, err := priceInBase(u.currencies, &input.Currency, budgetCurrency(u.budgetRepo, input.BudgetID), input.Amount, input.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	input.ExchangeRate, input.BaseAmount = rate, base
+	if advance != nil && input.Currency != advanceCurrency(u.currencies, advance) {
+		return nil, errors.New("expense must be in the currency of its cash request")
+	}
+
 	if err := u.repo.Create(input); err != nil {
 		return nil, err
 	}
 	return input, nil
 }
 
-func (u *expenseUsecase) GetAllExpenses() ([]Domain.Expense, error) {
-	return u.repo.GetAll()
+// GetAllExpenses pages through the expenses the caller may see; department
+// heads also see spend against their department's budgets
+func (u *expenseUsecase) GetAllExpenses(q Domain.ListQuery, actor Domain.Actor) ([]Domain.Expense, *Domain.Pagination, error) {
+	if err := normalizeListQuery(&q); err != nil {
+		return nil, nil, err
+	}
+	scope, err := spendScope(u.budgetRepo, actor)
+	if err != nil {
+		return nil, nil, err
+	}
+	q.Scope = scope
+	return u.repo.List(q)
 }
 
-func (u *expenseUsecase) GetExpenseByID(id string) (*Domain.Expense, error) {
-	return u.repo.GetByID(id)
+func (u *expenseUsecase) GetExpenseByID(id string, actor Domain.Actor) (*Domain.Expense, error) {
+	e, err := u.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.CanView(e.CreatedBy) && !headsBudget(u.budgetRepo, actor, e.BudgetID) {
+		return nil, errors.New("expense not found")
+	}
+	return e, nil
 }
 
-func (u *expenseUsecase) AttachReceipt(id, receiptURL string) error {
-	e, err := u.repo.GetByID(id)
+// getOwnExpense loads an expense the actor is allowed to change
+func (u *expenseUsecase) getOwnExpense(id string, actor Domain.Actor) (*Domain.Expense, error) {
+	e, err := u.GetExpenseByID(id, actor)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Finance && e.CreatedBy != actor.UserID {
+		return nil, Domain.ErrForbidden
+	}
+	if err := actor.CheckVersion(Domain.ExpenseStates.Entity, id, e.Version); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (u *expenseUsecase) AttachReceipt(id, receiptURL string, actor Domain.Actor) error {
+	e, err := u.getOwnExpense(id, actor)
 	if err != nil {
 		return err
 	}
-	e.ReceiptURL = receiptURL
+	e.Receipts = append(e.Receipts, Domain.Receipt{
+		ID:         primitive.NewObjectID(),
+		URL:        receiptURL,
+		UploadedBy: actor.UserID,
+		UploadedAt: time.Now().UTC(),
+	})
 	return u.repo.Update(id, e)
 }
 
-func (u *expenseUsecase) VerifyExpense(id string) error {
+// UploadReceipt stores a receipt file and attaches it to the expense. Files are
+// stored by SHA-256 so identical uploads share one blob, and uploading the same
+// file twice to an expense returns the existing receipt.
+func (u *expenseUsecase) UploadReceipt(id, filename string, r io.Reader, actor Domain.Actor) (*Domain.Receipt, error) {
+	e, err := u.getOwnExpense(id, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, MaxReceiptSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("receipt file is empty")
+	}
+	if len(data) > MaxReceiptSize {
+		return nil, errors.New("receipt file exceeds 10MB")
+	}
+	contentType := http.DetectContentType(data)
+	if !allowedReceiptTypes[contentType] {
+		return nil, errors.New("unsupported receipt type: " + contentType)
+	}
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	for i := range e.Receipts {
+		if e.Receipts[i].SHA256 == digest {
+			return &e.Receipts[i], nil
+		}
+	}
+
+	key := "receipts/" + digest
+	exists, err := u.storage.Exists(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := u.storage.Put(key, contentType, bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	receipt := Domain.Receipt{
+		ID:          primitive.NewObjectID(),
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SHA256:      digest,
+		StorageKey:  key,
+		UploadedBy:  actor.UserID,
+		UploadedAt:  time.Now().UTC(),
+	}
+	e.Receipts = append(e.Receipts, receipt)
+	if err := u.repo.Update(id, e); err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// OpenReceipt returns the receipt metadata and its file contents; the caller closes the reader
+func (u *expenseUsecase) OpenReceipt(id, receiptID string, actor Domain.Actor) (*Domain.Receipt, io.ReadCloser, error) {
+	e, err := u.GetExpenseByID(id, actor)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range e.Receipts {
+		rc := &e.Receipts[i]
+		if rc.ID.Hex() != receiptID {
+			continue
+		}
+		if rc.StorageKey == "" {
+			return nil, nil, errors.New("receipt is hosted externally")
+		}
+		body, err := u.storage.Open(rc.StorageKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		return rc, body, nil
+	}
+	return nil, nil, errors.New("receipt not found")
+}
+
+func (u *expenseUsecase) VerifyExpense(id string, actor Domain.Actor) error {
 	e, err := u.repo.GetByID(id)
 	if err != nil {
 		return err
 	}
-	e.Status = "verified"
-	return u.repo.Update(id, e)
+	if err := actor.CheckVersion(Domain.ExpenseStates.Entity, id, e.Version); err != nil {
+		return err
+	}
+	previous := *e
+	if err := e.TransitionTo(Domain.StatusVerified, actor.UserID); err != nil {
+		return err
+	}
+	if !e.CashRequestID.IsZero() {
+		return u.verifyFromAdvance(id, e, &previous)
+	}
+	// expenses not tied to a budget do not move money
+	if e.BudgetID.IsZero() {
+		return u.repo.Update(id, e)
+	}
+
+	budgetID := e.BudgetID.Hex()
+	b, err := u.budgetRepo.GetByID(budgetID)
+	if err != nil {
+		return err
+	}
+	// the budget is drawn down in its own currency at the rate on the expense date
+	amount, err := u.currencies.Convert(e.Amount, e.Currency, b.Currency, e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := checkLineBalance(b, e.LineItemID, amount); err != nil {
+		return err
+	}
+	if err := u.budgetRepo.Debit(budgetID, e.LineItemID, amount); err != nil {
+		return err
+	}
+	if err := u.repo.Update(id, e); err != nil {
+		return rollbackFailed("verification", err, u.budgetRepo.Credit(budgetID, e.LineItemID, amount))
+	}
+
+	if err := postTransfer(u.ledgerRepo, Domain.EventExpenseVerification, id, e.BudgetID, Domain.BudgetAccount(e.BudgetID), Domain.AccountExpenses, amount, b.Currency); err != nil {
+		previous.Version = e.Version
+		return rollbackFailed("ledger write", err, u.repo.Update(id, &previous), u.budgetRepo.Credit(budgetID, e.LineItemID, amount))
+	}
+	return nil
+}
+
+func (u *expenseUsecase) RejectExpense(id string, actor Domain.Actor, comment string) (*Domain.Expense, error) {
+	e, err := u.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := actor.CheckVersion(Domain.ExpenseStates.Entity, id, e.Version); err != nil {
+		return nil, err
+	}
+	if err := e.TransitionTo(Domain.StatusRejected, actor.UserID); err != nil {
+		return nil, err
+	}
+	e.RejectionComment = comment
+	if err := u.repo.Update(id, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// linkCashRequest ties an expense paid out of a cash advance to the advance's
+// budget and line item, and returns the advance
+func (u *expenseUsecase) linkCashRequest(input *Domain.Expense, actor Domain.Actor) (*Domain.CashRequest, error) {
+	if input.CashRequestID.IsZero() {
+		return nil, nil
+	}
+	r, err := u.cashRepo.GetByID(input.CashRequestID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if !actor.CanView(r.Requester) {
+		return nil, errors.New("cash request not found")
+	}
+	if r.Status != Domain.StatusDisbursed {
+		return nil, errors.New("expenses can only be recorded against a disbursed cash request")
+	}
+	if (!input.BudgetID.IsZero() && input.BudgetID != r.BudgetID) || (!input.LineItemID.IsZero() && input.LineItemID != r.LineItemID) {
+		return nil, errors.New("expense must use the budget and line item of its cash request")
+	}
+	input.BudgetID, input.LineItemID = r.BudgetID, r.LineItemID
+	if input.Currency == "" {
+		input.Currency = advanceCurrency(u.currencies, r)
+	}
+	return r, nil
+}
+
+// verifyFromAdvance verifies an expense paid out of a cash advance. The budget
+// was drawn down when the advance was disbursed, so only the ledger moves the
+// amount from cash advances to expenses.
+func (u *expenseUsecase) verifyFromAdvance(id string, e, previous *Domain.Expense) error {
+	r, err := u.cashRepo.GetByID(e.CashRequestID.Hex())
+	if err != nil {
+		return err
+	}
+	if r.Status != Domain.StatusDisbursed {
+		return errors.New("cash request is already settled")
+	}
+	b, err := u.budgetRepo.GetByID(r.BudgetID.Hex())
+	if err != nil {
+		return err
+	}
+	amount, err := u.currencies.Convert(e.Amount, e.Currency, b.Currency, r.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := u.repo.Update(id, e); err != nil {
+		return err
+	}
+	if err := postTransfer(u.ledgerRepo, Domain.EventExpenseVerification, id, r.BudgetID, Domain.AccountCashAdvances, Domain.AccountExpenses, amount, b.Currency); err != nil {
+		previous.Version = e.Version
+		return rollbackFailed("ledger write", err, u.repo.Update(id, previous))
+	}
+	return nil
 }
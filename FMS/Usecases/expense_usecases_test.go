@@ -1,7 +1,11 @@
 package Usecases
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"slices"
+	"strings"
 	"testing"
 
 	"FMS/Domain"
@@ -19,6 +23,9 @@ func (m *mockExpenseRepo) Create(t *Domain.Expense) error {
 	if t == nil {
 		return errors.New("nil")
 	}
+	if t.ID.IsZero() {
+		t.ID = primitive.NewObjectID()
+	}
 	m.store[t.ID.Hex()] = t
 	return nil
 }
@@ -29,6 +36,30 @@ func (m *mockExpenseRepo) GetAll() ([]Domain.Expense, error) {
 	}
 	return res, nil
 }
+func (m *mockExpenseRepo) List(q Domain.ListQuery) ([]Domain.Expense, *Domain.Pagination, error) {
+	all, _ := m.GetAll()
+	return mockList(all, q, func(e Domain.Expense) listFields {
+		return listFields{id: e.ID, owner: e.CreatedBy, budgetID: e.BudgetID, status: e.Status, amount: e.Amount, createdAt: e.CreatedAt}
+	})
+}
+func (m *mockExpenseRepo) GetByCashRequest(cashRequestID primitive.ObjectID) ([]Domain.Expense, error) {
+	res := []Domain.Expense{}
+	for _, v := range m.store {
+		if v.CashRequestID == cashRequestID {
+			res = append(res, *v)
+		}
+	}
+	return res, nil
+}
+func (m *mockExpenseRepo) CountByBudgets(budgetIDs []primitive.ObjectID, statuses []string) (int64, error) {
+	var n int64
+	for _, v := range m.store {
+		if slices.Contains(budgetIDs, v.BudgetID) && slices.Contains(statuses, v.Status) {
+			n++
+		}
+	}
+	return n, nil
+}
 func (m *mockExpenseRepo) GetByID(id string) (*Domain.Expense, error) {
 	if v, ok := m.store[id]; ok {
 		return v, nil
@@ -36,9 +67,14 @@ func (m *mockExpenseRepo) GetByID(id string) (*Domain.Expense, error) {
 	return nil, errors.New("not found")
 }
 func (m *mockExpenseRepo) Update(id string, t *Domain.Expense) error {
-	if _, ok := m.store[id]; !ok {
+	v, ok := m.store[id]
+	if !ok {
 		return errors.New("not found")
 	}
+	if v.Version != t.Version {
+		return &Domain.ConflictError{Entity: "expense", ID: id, Version: t.Version}
+	}
+	t.Version++
 	m.store[id] = t
 	return nil
 }
@@ -52,10 +88,10 @@ func (m *mockExpenseRepo) Delete(id string) error {
 
 func TestExpenseUsecase_CreateAttachVerify(t *testing.T) {
 	mock := newMockExpenseRepo()
-	uc := NewExpenseUsecase(mock)
+	uc := NewExpenseUsecase(mock, newMockBudgetRepo(), newMockLedgerRepo(), newMockBlobStorage(), testCurrencies(), newMockFiscalPeriodRepo(), newMockCashRepo())
 
 	e := &Domain.Expense{ID: primitive.NewObjectID(), Title: "Lunch", Amount: 20}
-	created, err := uc.CreateExpense(e)
+	created, err := uc.CreateExpense(e, staffActor("u1"))
 	if err != nil {
 		t.Fatalf("create failed: %v", err)
 	}
@@ -63,19 +99,172 @@ func TestExpenseUsecase_CreateAttachVerify(t *testing.T) {
 		t.Fatalf("expected pending")
 	}
 
-	if err := uc.AttachReceipt(created.ID.Hex(), "http://example.com/rec.jpg"); err != nil {
+	if err := uc.AttachReceipt(created.ID.Hex(), "http://example.com/rec.jpg", financeActor); err != nil {
 		t.Fatalf("attach failed: %v", err)
 	}
-	e2, _ := uc.GetExpenseByID(created.ID.Hex())
-	if e2.ReceiptURL != "http://example.com/rec.jpg" {
+	e2, _ := uc.GetExpenseByID(created.ID.Hex(), financeActor)
+	if len(e2.Receipts) != 1 || e2.Receipts[0].URL != "http://example.com/rec.jpg" {
 		t.Fatalf("receipt not attached")
 	}
 
-	if err := uc.VerifyExpense(created.ID.Hex()); err != nil {
+	if err := uc.VerifyExpense(created.ID.Hex(), financeActor); err != nil {
 		t.Fatalf("verify failed: %v", err)
 	}
-	e3, _ := uc.GetExpenseByID(created.ID.Hex())
+	e3, _ := uc.GetExpenseByID(created.ID.Hex(), financeActor)
 	if e3.Status != "verified" {
 		t.Fatalf("expected verified")
 	}
+	if err := uc.VerifyExpense(created.ID.Hex(), financeActor); !errors.Is(err, Domain.ErrInvalidTransition) {
+		t.Fatalf("expected verifying twice to be an invalid transition, got %v", err)
+	}
+	if len(e3.History) != 2 || e3.History[1].From != "pending" || e3.History[1].By != financeActor.UserID {
+		t.Fatalf("expected creation and verification in history, got %+v", e3.History)
+	}
+}
+
+func TestExpenseUsecase_Reject(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	ledger := newMockLedgerRepo()
+	uc := NewExpenseUsecase(newMockExpenseRepo(), budgets, ledger, newMockBlobStorage(), testCurrencies(), newMockFiscalPeriodRepo(), newMockCashRepo())
+	b := newApprovedBudget(budgets, 1000)
+
+	created, err := uc.CreateExpense(&Domain.Expense{Title: "Lunch", Amount: 20, BudgetID: b.ID}, staffActor("u1"))
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	rejected, err := uc.RejectExpense(created.ID.Hex(), financeActor, "personal")
+	if err != nil {
+		t.Fatalf("reject failed: %v", err)
+	}
+	if rejected.Status != Domain.StatusRejected || rejected.RejectionComment != "personal" || rejected.History[1].By != financeActor.UserID {
+		t.Fatalf("unexpected rejected expense %+v", rejected)
+	}
+	if budgets.store[b.ID.Hex()].Remaining != 1000 || len(ledger.entries) != 0 {
+		t.Fatalf("a rejected expense must not move money")
+	}
+	if err := uc.VerifyExpense(created.ID.Hex(), financeActor); !errors.Is(err, Domain.ErrInvalidTransition) {
+		t.Fatalf("expected a rejected expense to stay rejected, got %v", err)
+	}
+	if _, err := uc.RejectExpense(created.ID.Hex(), financeActor, ""); !errors.Is(err, Domain.ErrInvalidTransition) {
+		t.Fatalf("expected rejecting twice to be an invalid transition, got %v", err)
+	}
+}
+
+// mock blob storage
+type mockBlobStorage struct {
+	blobs map[string][]byte
+	puts  int
+}
+
+func newMockBlobStorage() *mockBlobStorage {
+	return &mockBlobStorage{blobs: make(map[string][]byte)}
+}
+func (m *mockBlobStorage) Put(key, contentType string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.blobs[key] = data
+	m.puts++
+	return nil
+}
+func (m *mockBlobStorage) Open(key string) (io.ReadCloser, error) {
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, errors.New("blob not found")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+func (m *mockBlobStorage) Exists(key string) (bool, error) {
+	_, ok := m.blobs[key]
+	return ok, nil
+}
+func (m *mockBlobStorage) Delete(key string) error {
+	delete(m.blobs, key)
+	return nil
+}
+
+var pdfReceipt = []byte("%PDF-1.4\n1 0 obj\n<< >>\nendobj\n")
+
+func TestExpenseUsecase_UploadAndDownloadReceipt(t *testing.T) {
+	mock := newMockExpenseRepo()
+	storage := newMockBlobStorage()
+	uc := NewExpenseUsecase(mock, newMockBudgetRepo(), newMockLedgerRepo(), storage, testCurrencies(), newMockFiscalPeriodRepo(), newMockCashRepo())
+
+	a := &Domain.Expense{ID: primitive.NewObjectID(), Title: "Taxi", Amount: 15, CreatedBy: "u1"}
+	b := &Domain.Expense{ID: primitive.NewObjectID(), Title: "Train", Amount: 30, CreatedBy: "u1"}
+	_ = mock.Create(a)
+	_ = mock.Create(b)
+
+	r1, err := uc.UploadReceipt(a.ID.Hex(), "taxi.pdf", bytes.NewReader(pdfReceipt), staffActor("u1"))
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if r1.ContentType != "application/pdf" || r1.Size != int64(len(pdfReceipt)) {
+		t.Fatalf("unexpected receipt metadata: %+v", r1)
+	}
+
+	// same file again on the same expense is deduplicated
+	again, err := uc.UploadReceipt(a.ID.Hex(), "taxi-copy.pdf", bytes.NewReader(pdfReceipt), staffActor("u1"))
+	if err != nil || again.ID != r1.ID {
+		t.Fatalf("expected existing receipt, got %+v (%v)", again, err)
+	}
+	// and on another expense it reuses the stored blob
+	if _, err := uc.UploadReceipt(b.ID.Hex(), "train.pdf", bytes.NewReader(pdfReceipt), staffActor("u1")); err != nil {
+		t.Fatalf("second upload failed: %v", err)
+	}
+	if storage.puts != 1 {
+		t.Fatalf("expected one stored blob, got %d", storage.puts)
+	}
+	if len(mock.store[a.ID.Hex()].Receipts) != 1 {
+		t.Fatalf("expected one receipt on expense a")
+	}
+
+	meta, body, err := uc.OpenReceipt(a.ID.Hex(), r1.ID.Hex(), staffActor("u1"))
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer body.Close()
+	got, _ := io.ReadAll(body)
+	if meta.Filename != "taxi.pdf" || !bytes.Equal(got, pdfReceipt) {
+		t.Fatalf("downloaded receipt does not match upload")
+	}
+}
+
+func TestExpenseUsecase_UploadReceiptValidation(t *testing.T) {
+	mock := newMockExpenseRepo()
+	uc := NewExpenseUsecase(mock, newMockBudgetRepo(), newMockLedgerRepo(), newMockBlobStorage(), testCurrencies(), newMockFiscalPeriodRepo(), newMockCashRepo())
+	e := &Domain.Expense{ID: primitive.NewObjectID(), Title: "Taxi", Amount: 15, CreatedBy: "u1"}
+	_ = mock.Create(e)
+
+	if _, err := uc.UploadReceipt(e.ID.Hex(), "notes.txt", strings.NewReader("plain text"), staffActor("u1")); err == nil {
+		t.Fatalf("expected unsupported type error")
+	}
+	big := append(append([]byte{}, pdfReceipt...), make([]byte, MaxReceiptSize)...)
+	if _, err := uc.UploadReceipt(e.ID.Hex(), "big.pdf", bytes.NewReader(big), staffActor("u1")); err == nil {
+		t.Fatalf("expected size error")
+	}
+}
+
+func TestExpenseUsecase_OwnershipScoping(t *testing.T) {
+	mock := newMockExpenseRepo()
+	uc := NewExpenseUsecase(mock, newMockBudgetRepo(), newMockLedgerRepo(), newMockBlobStorage(), testCurrencies(), newMockFiscalPeriodRepo(), newMockCashRepo())
+	mine := &Domain.Expense{ID: primitive.NewObjectID(), Title: "Mine", Amount: 10, CreatedBy: "u1"}
+	theirs := &Domain.Expense{ID: primitive.NewObjectID(), Title: "Theirs", Amount: 10, CreatedBy: "u2"}
+	_ = mock.Create(mine)
+	_ = mock.Create(theirs)
+
+	list, _, _ := uc.GetAllExpenses(Domain.ListQuery{}, staffActor("u1"))
+	if len(list) != 1 || list[0].Title != "Mine" {
+		t.Fatalf("staff should only list own expenses, got %v", list)
+	}
+	if all, _, _ := uc.GetAllExpenses(Domain.ListQuery{}, financeActor); len(all) != 2 {
+		t.Fatalf("finance should list every expense")
+	}
+	if _, err := uc.GetExpenseByID(theirs.ID.Hex(), staffActor("u1")); err == nil {
+		t.Fatalf("staff should not read other users' expenses")
+	}
+	if err := uc.AttachReceipt(theirs.ID.Hex(), "http://example.com/r.jpg", staffActor("u1")); err == nil {
+		t.Fatalf("staff should not change other users' expenses")
+	}
 }
@@ -0,0 +1,444 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FiscalUsecase manages fiscal years and quarters, the recurring budget
+// templates that fund them, and closing a period
+type FiscalUsecase interface {
+	// CreateYear creates fiscal year year starting at start (1 January when zero)
+	// and its four quarters, then creates their budgets from the active templates
+	CreateYear(year int, start time.Time, actor Domain.Actor) ([]Domain.FiscalPeriod, error)
+	GetPeriods() ([]Domain.FiscalPeriod, error)
+	GetPeriod(id string) (*Domain.FiscalPeriod, error)
+	// ClosePeriod locks the period against new spend. With carryForward the
+	// unspent remaining of each recurring budget first moves into the same
+	// template's budget for the next period.
+	ClosePeriod(id string, carryForward bool, actor Domain.Actor) (*Domain.PeriodClose, error)
+	// GenerateBudgets creates the budgets of active templates missing from an open period
+	GenerateBudgets(periodID string, actor Domain.Actor) ([]Domain.Budget, error)
+	GetTemplates() ([]Domain.BudgetTemplate, error)
+	CreateTemplate(t *Domain.BudgetTemplate, actor Domain.Actor) (*Domain.BudgetTemplate, error)
+	UpdateTemplate(id string, t *Domain.BudgetTemplate) error
+	DeleteTemplate(id string) error
+}
+
+type fiscalUsecase struct {
+	periodRepo   Repositories.FiscalPeriodRepository
+	templateRepo Repositories.BudgetTemplateRepository
+	budgetRepo   Repositories.BudgetRepository
+	ledgerRepo   Repositories.LedgerRepository
+	// budgets creates the recurring budgets so they go through the approval chain
+	budgets     BudgetUsecase
+	expenseRepo Repositories.ExpenseRepository
+	cashRepo    Repositories.CashRequestRepository
+}
+
+func NewFiscalUsecase(periods Repositories.FiscalPeriodRepository, templates Repositories.BudgetTemplateRepository, budgetRepo Repositories.BudgetRepository, ledger Repositories.LedgerRepository, budgets BudgetUsecase, expenses Repositories.ExpenseRepository, cash Repositories.CashRequestRepository) FiscalUsecase {
+	return &fiscalUsecase{periodRepo: periods, templateRepo: templates, budgetRepo: budgetRepo, ledgerRepo: ledger, budgets: budgets, expenseRepo: expenses, cashRepo: cash}
+}
+
+func (u *fiscalUsecase) CreateYear(year int, start time.Time, actor Domain.Actor) ([]Domain.FiscalPeriod, error) {
+	if year < 1900 || year > 9999 {
+		return nil, errors.New("year is out of range")
+	}
+	if start.IsZero() {
+		start = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	fy, quarters := Domain.NewFiscalYear(year, start)
+
+	overlaps, err := u.periodRepo.Overlaps(Domain.PeriodYear, fy.StartDate, fy.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	if overlaps {
+		return nil, errors.New("fiscal year overlaps an existing one")
+	}
+
+	if err := u.periodRepo.Create(&fy); err != nil {
+		return nil, err
+	}
+	periods := []Domain.FiscalPeriod{fy}
+	for i := range quarters {
+		quarters[i].YearID = fy.ID
+		if err := u.periodRepo.Create(&quarters[i]); err != nil {
+			return nil, err
+		}
+		periods = append(periods, quarters[i])
+	}
+
+	// a template that cannot be instantiated (e.g. no exchange rate yet) should not
+	// undo the year; its budgets can be generated again once fixed
+	for _, p := range periods {
+		if _, err := u.GenerateBudgets(p.ID.Hex(), actor); err != nil {
+			log.Printf("generate budgets for %s: %v", p.Name, err)
+		}
+	}
+	return periods, nil
+}
+
+func (u *fiscalUsecase) GetPeriods() ([]Domain.FiscalPeriod, error) {
+	return u.periodRepo.GetAll()
+}
+
+func (u *fiscalUsecase) GetPeriod(id string) (*Domain.FiscalPeriod, error) {
+	return u.periodRepo.GetByID(id)
+}
+
+func (u *fiscalUsecase) ClosePeriod(id string, carryForward bool, actor Domain.Actor) (*Domain.PeriodClose, error) {
+	p, err := u.periodRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == Domain.PeriodClosed {
+		return nil, Domain.ErrPeriodClosed
+	}
+	if p.Type == Domain.PeriodYear {
+		if err := u.checkQuartersClosed(p.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := u.checkNothingOpen(p.ID); err != nil {
+		return nil, err
+	}
+
+	var next *Domain.FiscalPeriod
+	var targets []Domain.Budget
+	if carryForward {
+		next, err = u.periodRepo.FindNext(p.Type, p.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			return nil, errors.New("create the next fiscal period before carrying forward")
+		}
+		if next.Status == Domain.PeriodClosed {
+			return nil, fmt.Errorf("%w: %s", Domain.ErrPeriodClosed, next.Name)
+		}
+		// make sure every recurring budget has somewhere to carry to
+		if _, err := u.GenerateBudgets(next.ID.Hex(), actor); err != nil {
+			return nil, err
+		}
+		if targets, err = u.budgetRepo.GetByPeriod(next.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Domain.PeriodClose{Carried: []Domain.CarryForward{}, Skipped: []Domain.CarrySkip{}}
+	if carryForward {
+		result.NextPeriodID = next.ID
+		if err := u.carryAll(p, targets, result); err != nil {
+			result.Period = *p
+			return result, err
+		}
+	}
+
+	// the period closes only once everything is carried; a close that fails
+	// part way leaves it open, and running it again carries the rest
+	now := time.Now().UTC()
+	if err := u.periodRepo.Close(p.ID, actor.UserID, now); err != nil {
+		result.Period = *p
+		return result, err
+	}
+	p.Status, p.ClosedBy, p.ClosedAt = Domain.PeriodClosed, actor.UserID, now
+	result.Period = *p
+	return result, nil
+}
+
+// carryAll carries the remaining of p's approved budgets into the budgets of
+// the same template in targets, adding them to result
+func (u *fiscalUsecase) carryAll(p *Domain.FiscalPeriod, targets []Domain.Budget, result *Domain.PeriodClose) error {
+	byTemplate := make(map[primitive.ObjectID]*Domain.Budget, len(targets))
+	for i := range targets {
+		if !targets[i].TemplateID.IsZero() {
+			byTemplate[targets[i].TemplateID] = &targets[i]
+		}
+	}
+
+	sources, err := u.budgetRepo.GetByPeriod(p.ID)
+	if err != nil {
+		return err
+	}
+	for i := range sources {
+		src := &sources[i]
+		if src.Status != Domain.StatusApproved || src.Remaining <= 0 {
+			continue
+		}
+		remaining := src.Remaining
+		skip := func(reason string) {
+			result.Skipped = append(result.Skipped, Domain.CarrySkip{BudgetID: src.ID, Remaining: remaining, Reason: reason})
+		}
+		dst, ok := byTemplate[src.TemplateID]
+		switch {
+		case src.TemplateID.IsZero():
+			skip("not created from a recurring template")
+		case !ok:
+			skip("template has no budget in the next period")
+		case dst.Status == Domain.StatusRejected:
+			skip("next period's budget was rejected")
+		case dst.Currency != src.Currency:
+			skip("next period's budget is in another currency")
+		default:
+			carried, err := u.carry(src, dst)
+			if err != nil {
+				skip(err.Error())
+				continue
+			}
+			result.Carried = append(result.Carried, *carried)
+		}
+	}
+	return nil
+}
+
+// checkNothingOpen refuses to close a period while spend is still on its way
+// to its budgets: a pending expense is debited when it is verified and an
+// open advance credits its return when it is settled, so neither can follow
+// the remaining once it has been carried away
+func (u *fiscalUsecase) checkNothingOpen(periodID primitive.ObjectID) error {
+	budgets, err := u.budgetRepo.GetByPeriod(periodID)
+	if err != nil {
+		return err
+	}
+	if len(budgets) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, len(budgets))
+	for i := range budgets {
+		ids[i] = budgets[i].ID
+	}
+	expenses, err := u.expenseRepo.CountByBudgets(ids, []string{Domain.StatusPending})
+	if err != nil {
+		return err
+	}
+	advances, err := u.cashRepo.CountByBudgets(ids, []string{Domain.StatusPending, Domain.StatusApproved, Domain.StatusDisbursed})
+	if err != nil {
+		return err
+	}
+	if expenses > 0 || advances > 0 {
+		return fmt.Errorf("%w: %d pending expenses, %d unsettled cash requests", Domain.ErrPeriodInUse, expenses, advances)
+	}
+	return nil
+}
+
+func (u *fiscalUsecase) checkQuartersClosed(yearID primitive.ObjectID) error {
+	periods, err := u.periodRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, q := range periods {
+		if q.YearID == yearID && q.Status != Domain.PeriodClosed {
+			return fmt.Errorf("close %s before the fiscal year", q.Name)
+		}
+	}
+	return nil
+}
+
+// carry moves src's remaining into dst and posts the transfer between the two
+// budget accounts. dst's amount grows by the same sum; a pending dst gets the
+// money as part of its balance when it is approved. Each line item's remaining
+// follows into dst's line item of the same category; with no such line it
+// stays in dst's unallocated amount.
+func (u *fiscalUsecase) carry(src, dst *Domain.Budget) (*Domain.CarryForward, error) {
+	amount := src.Remaining
+	srcID, dstID := src.ID.Hex(), dst.ID.Hex()
+
+	// the debit is conditional, so spend that raced the close cannot be carried twice
+	if err := u.budgetRepo.Debit(srcID, primitive.NilObjectID, amount); err != nil {
+		return nil, err
+	}
+	src.Version++ // the debit moved the version on
+	debited, dstBefore := *src, *dst
+	debited.Remaining -= amount
+	debited.LineItems = append([]Domain.BudgetLineItem(nil), src.LineItems...)
+	dstBefore.LineItems = append([]Domain.BudgetLineItem(nil), dst.LineItems...)
+
+	src.Remaining -= amount
+	src.CarriedOut += amount
+	for i := range src.LineItems {
+		li := &src.LineItems[i]
+		if li.Remaining <= 0 {
+			continue
+		}
+		if target := lineItemByCategory(dst, li.Category); target != nil {
+			target.Amount += li.Remaining
+			if dst.Status == Domain.StatusApproved {
+				target.Remaining += li.Remaining
+			}
+		}
+		li.CarriedOut += li.Remaining
+		li.Remaining = 0
+	}
+	if err := u.budgetRepo.Update(srcID, src); err != nil {
+		return nil, u.undoCarry(&debited, nil, amount, err)
+	}
+
+	dst.Amount += amount
+	dst.CarriedIn += amount
+	dst.BaseAmount = inBase(dst.Amount, dst.ExchangeRate)
+	if dst.Status == Domain.StatusApproved {
+		dst.Remaining += amount
+	}
+	debited.Version = src.Version
+	if err := u.budgetRepo.Update(dstID, dst); err != nil {
+		return nil, u.undoCarry(&debited, nil, amount, err)
+	}
+	dstBefore.Version = dst.Version
+
+	if err := postBudgetTransfer(u.ledgerRepo, Domain.EventCarryForward, srcID, src.ID, Domain.BudgetAccount(src.ID), dst.ID, Domain.BudgetAccount(dst.ID), amount, src.Currency); err != nil {
+		return nil, u.undoCarry(&debited, &dstBefore, amount, err)
+	}
+	return &Domain.CarryForward{FromBudgetID: src.ID, ToBudgetID: dst.ID, Amount: amount, Currency: src.Currency}, nil
+}
+
+// undoCarry restores dst (when it was changed) and src as it stood after the
+// debit, then credits the debit back, and returns cause
+func (u *fiscalUsecase) undoCarry(debited, dst *Domain.Budget, amount Domain.Money, cause error) error {
+	var rollback []error
+	if dst != nil {
+		rollback = append(rollback, u.budgetRepo.Update(dst.ID.Hex(), dst))
+	}
+	rollback = append(rollback, u.budgetRepo.Update(debited.ID.Hex(), debited))
+	rollback = append(rollback, u.budgetRepo.Credit(debited.ID.Hex(), primitive.NilObjectID, amount))
+	return rollbackFailed("carry forward", cause, rollback...)
+}
+
+func (u *fiscalUsecase) GenerateBudgets(periodID string, actor Domain.Actor) ([]Domain.Budget, error) {
+	p, err := u.periodRepo.GetByID(periodID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == Domain.PeriodClosed {
+		return nil, Domain.ErrPeriodClosed
+	}
+	templates, err := u.templateRepo.GetActive(p.Recurrence())
+	if err != nil {
+		return nil, err
+	}
+	existing, err := u.budgetRepo.GetByPeriod(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[primitive.ObjectID]bool, len(existing))
+	for _, b := range existing {
+		have[b.TemplateID] = true
+	}
+
+	created := []Domain.Budget{}
+	for _, t := range templates {
+		if have[t.ID] {
+			continue
+		}
+		b, err := u.budgets.CreateBudget(&Domain.Budget{
+			Title:       t.Title + " " + p.Name,
+			Description: t.Description,
+			Department:  t.Department,
+			Amount:      t.Amount,
+			Currency:    t.Currency,
+			DueDate:     p.EndDate,
+			PeriodID:    p.ID,
+			TemplateID:  t.ID,
+		}, actor)
+		if err != nil {
+			return created, fmt.Errorf("template %q: %w", t.Title, err)
+		}
+		created = append(created, *b)
+	}
+	return created, nil
+}
+
+func (u *fiscalUsecase) GetTemplates() ([]Domain.BudgetTemplate, error) {
+	return u.templateRepo.GetAll()
+}
+
+// CreateTemplate saves an active template and creates its budgets for the open
+// periods that have not ended yet
+func (u *fiscalUsecase) CreateTemplate(t *Domain.BudgetTemplate, actor Domain.Actor) (*Domain.BudgetTemplate, error) {
+	if err := validateTemplate(t); err != nil {
+		return nil, err
+	}
+	t.Active = true
+	t.CreatedBy = actor.UserID
+	t.CreatedAt = time.Now().UTC()
+	if err := u.templateRepo.Create(t); err != nil {
+		return nil, err
+	}
+
+	periods, err := u.periodRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range periods {
+		if p.Status != Domain.PeriodOpen || p.Recurrence() != t.Recurrence || !p.EndDate.After(t.CreatedAt) {
+			continue
+		}
+		if _, err := u.GenerateBudgets(p.ID.Hex(), actor); err != nil {
+			log.Printf("generate budgets for %s: %v", p.Name, err)
+		}
+	}
+	return t, nil
+}
+
+// UpdateTemplate changes the budgets created from now on; existing budgets keep their terms
+func (u *fiscalUsecase) UpdateTemplate(id string, t *Domain.BudgetTemplate) error {
+	if err := validateTemplate(t); err != nil {
+		return err
+	}
+	return u.templateRepo.Update(id, t)
+}
+
+func (u *fiscalUsecase) DeleteTemplate(id string) error {
+	return u.templateRepo.Delete(id)
+}
+
+func validateTemplate(t *Domain.BudgetTemplate) error {
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" {
+		return errors.New("title is required")
+	}
+	if t.Amount <= 0 {
+		return errors.New("amount must be greater than zero")
+	}
+	if t.Recurrence != Domain.RecurYearly && t.Recurrence != Domain.RecurQuarterly {
+		return errors.New("recurrence must be yearly or quarterly")
+	}
+	if t.Currency != "" {
+		code, err := Domain.NormalizeCurrency(t.Currency)
+		if err != nil {
+			return err
+		}
+		t.Currency = code
+	}
+	t.Department = normalizeDepartment(t.Department)
+	return nil
+}
+
+// checkPeriodOpen rejects new spend against a budget whose fiscal period is closed
+func checkPeriodOpen(periods Repositories.FiscalPeriodRepository, budgetRepo Repositories.BudgetRepository, budgetID primitive.ObjectID) error {
+	if budgetID.IsZero() {
+		return nil
+	}
+	b, err := budgetRepo.GetByID(budgetID.Hex())
+	if err != nil {
+		return err
+	}
+	if b.PeriodID.IsZero() {
+		return nil
+	}
+	p, err := periods.GetByID(b.PeriodID.Hex())
+	if err != nil {
+		return err
+	}
+	if p.Status == Domain.PeriodClosed {
+		return fmt.Errorf("%w: %s", Domain.ErrPeriodClosed, p.Name)
+	}
+	return nil
+}
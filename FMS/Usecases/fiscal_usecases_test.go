@@ -0,0 +1,399 @@
+package Usecases
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"FMS/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mock fiscal period repo; closeErr makes Close fail
+type mockFiscalPeriodRepo struct {
+	store    map[primitive.ObjectID]*Domain.FiscalPeriod
+	closeErr error
+}
+
+func newMockFiscalPeriodRepo() *mockFiscalPeriodRepo {
+	return &mockFiscalPeriodRepo{store: make(map[primitive.ObjectID]*Domain.FiscalPeriod)}
+}
+func (m *mockFiscalPeriodRepo) Create(p *Domain.FiscalPeriod) error {
+	if p.ID.IsZero() {
+		p.ID = primitive.NewObjectID()
+	}
+	cp := *p
+	m.store[p.ID] = &cp
+	return nil
+}
+func (m *mockFiscalPeriodRepo) GetAll() ([]Domain.FiscalPeriod, error) {
+	res := []Domain.FiscalPeriod{}
+	for _, v := range m.store {
+		res = append(res, *v)
+	}
+	return res, nil
+}
+func (m *mockFiscalPeriodRepo) GetByID(id string) (*Domain.FiscalPeriod, error) {
+	objID, _ := primitive.ObjectIDFromHex(id)
+	if v, ok := m.store[objID]; ok {
+		cp := *v
+		return &cp, nil
+	}
+	return nil, errors.New("fiscal period not found")
+}
+func (m *mockFiscalPeriodRepo) FindNext(periodType string, from time.Time) (*Domain.FiscalPeriod, error) {
+	var found *Domain.FiscalPeriod
+	for _, v := range m.store {
+		if v.Type != periodType || v.StartDate.Before(from) {
+			continue
+		}
+		if found == nil || v.StartDate.Before(found.StartDate) {
+			cp := *v
+			found = &cp
+		}
+	}
+	return found, nil
+}
+func (m *mockFiscalPeriodRepo) Overlaps(periodType string, start, end time.Time) (bool, error) {
+	for _, v := range m.store {
+		if v.Type == periodType && v.StartDate.Before(end) && v.EndDate.After(start) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockFiscalPeriodRepo) Close(id primitive.ObjectID, closedBy string, at time.Time) error {
+	if m.closeErr != nil {
+		return m.closeErr
+	}
+	v, ok := m.store[id]
+	if !ok || v.Status != Domain.PeriodOpen {
+		return Domain.ErrPeriodClosed
+	}
+	v.Status, v.ClosedBy, v.ClosedAt = Domain.PeriodClosed, closedBy, at
+	return nil
+}
+
+// mock budget template repo
+type mockBudgetTemplateRepo struct {
+	store map[string]*Domain.BudgetTemplate
+}
+
+func newMockBudgetTemplateRepo() *mockBudgetTemplateRepo {
+	return &mockBudgetTemplateRepo{store: make(map[string]*Domain.BudgetTemplate)}
+}
+func (m *mockBudgetTemplateRepo) Create(t *Domain.BudgetTemplate) error {
+	if t.ID.IsZero() {
+		t.ID = primitive.NewObjectID()
+	}
+	m.store[t.ID.Hex()] = t
+	return nil
+}
+func (m *mockBudgetTemplateRepo) GetAll() ([]Domain.BudgetTemplate, error) {
+	res := []Domain.BudgetTemplate{}
+	for _, v := range m.store {
+		res = append(res, *v)
+	}
+	return res, nil
+}
+func (m *mockBudgetTemplateRepo) GetActive(recurrence string) ([]Domain.BudgetTemplate, error) {
+	res := []Domain.BudgetTemplate{}
+	for _, v := range m.store {
+		if v.Active && v.Recurrence == recurrence {
+			res = append(res, *v)
+		}
+	}
+	return res, nil
+}
+func (m *mockBudgetTemplateRepo) GetByID(id string) (*Domain.BudgetTemplate, error) {
+	if v, ok := m.store[id]; ok {
+		return v, nil
+	}
+	return nil, errors.New("budget template not found")
+}
+func (m *mockBudgetTemplateRepo) Update(id string, t *Domain.BudgetTemplate) error {
+	if _, ok := m.store[id]; !ok {
+		return errors.New("budget template not found")
+	}
+	m.store[id] = t
+	return nil
+}
+func (m *mockBudgetTemplateRepo) Delete(id string) error {
+	if _, ok := m.store[id]; !ok {
+		return errors.New("budget template not found")
+	}
+	delete(m.store, id)
+	return nil
+}
+
+// fiscalFixture wires the fiscal, budget, cash request, expense and ledger usecases over shared mocks
+type fiscalFixture struct {
+	periods     *mockFiscalPeriodRepo
+	budgets     *mockBudgetRepo
+	ledger      *mockLedgerRepo
+	cashRepo    *mockCashRepo
+	expenseRepo *mockExpenseRepo
+	budgetUC    BudgetUsecase
+	cash        CashRequestUsecase
+	expenses    ExpenseUsecase
+	fiscal      FiscalUsecase
+}
+
+func newFiscalFixture() *fiscalFixture {
+	f := &fiscalFixture{periods: newMockFiscalPeriodRepo(), budgets: newMockBudgetRepo(), ledger: newMockLedgerRepo(), cashRepo: newMockCashRepo(), expenseRepo: newMockExpenseRepo()}
+	f.budgetUC = NewBudgetUsecase(f.budgets, f.ledger, newMockApprovalPolicyRepo(), testCurrencies(), f.periods)
+	f.cash = NewCashRequestUsecase(f.cashRepo, f.budgets, f.ledger, newMockApprovalPolicyRepo(), testCurrencies(), f.expenseRepo, f.periods)
+	f.expenses = NewExpenseUsecase(f.expenseRepo, f.budgets, f.ledger, newMockBlobStorage(), testCurrencies(), f.periods, f.cashRepo)
+	f.fiscal = NewFiscalUsecase(f.periods, newMockBudgetTemplateRepo(), f.budgets, f.ledger, f.budgetUC, f.expenseRepo, f.cashRepo)
+	return f
+}
+
+// budgetIn returns the budget created from a template for period
+func (f *fiscalFixture) budgetIn(period primitive.ObjectID) *Domain.Budget {
+	list, _ := f.budgets.GetByPeriod(period)
+	if len(list) != 1 {
+		return nil
+	}
+	return &list[0]
+}
+
+// the budgets are requested by finance, so another finance user signs them off
+var secondFinance = Domain.Actor{UserID: "finance-2", Finance: true, MFA: true}
+
+func TestFiscal_YearCreatesQuartersAndRecurringBudgets(t *testing.T) {
+	f := newFiscalFixture()
+	if _, err := f.fiscal.CreateTemplate(&Domain.BudgetTemplate{Title: "Supplies", Department: "ops", Amount: Domain.NewMoney(1000, 0), Recurrence: Domain.RecurQuarterly}, financeActor); err != nil {
+		t.Fatalf("create template failed: %v", err)
+	}
+	_, _ = f.fiscal.CreateTemplate(&Domain.BudgetTemplate{Title: "Training", Amount: Domain.NewMoney(5000, 0), Recurrence: Domain.RecurYearly}, financeActor)
+
+	periods, err := f.fiscal.CreateYear(2031, day("2031-07-01"), financeActor)
+	if err != nil {
+		t.Fatalf("create year failed: %v", err)
+	}
+	if len(periods) != 5 || periods[0].Name != "FY2031" || periods[4].Name != "FY2031-Q4" {
+		t.Fatalf("expected the year and four quarters, got %+v", periods)
+	}
+	if !periods[1].StartDate.Equal(day("2031-07-01")) || !periods[4].EndDate.Equal(day("2032-07-01")) || periods[2].YearID != periods[0].ID {
+		t.Fatalf("quarters should tile the year, got %+v", periods[1:])
+	}
+
+	yearly := f.budgetIn(periods[0].ID)
+	if yearly == nil || yearly.Amount != Domain.NewMoney(5000, 0) || yearly.Title != "Training FY2031" {
+		t.Fatalf("expected the yearly budget, got %+v", yearly)
+	}
+	q3 := f.budgetIn(periods[3].ID)
+	if q3 == nil || q3.Department != "ops" || !q3.DueDate.Equal(periods[3].EndDate) || q3.Status != Domain.StatusPending {
+		t.Fatalf("expected a pending quarterly budget due at the quarter end, got %+v", q3)
+	}
+
+	if again, _ := f.fiscal.GenerateBudgets(periods[3].ID.Hex(), financeActor); len(again) != 0 {
+		t.Fatalf("generating twice must not duplicate budgets, got %d", len(again))
+	}
+	if _, err := f.fiscal.CreateYear(2032, day("2032-01-01"), financeActor); err == nil {
+		t.Fatalf("expected overlapping fiscal year to be rejected")
+	}
+}
+
+func TestFiscal_CloseLocksExpensesAndCarriesRemaining(t *testing.T) {
+	f := newFiscalFixture()
+	_, _ = f.fiscal.CreateTemplate(&Domain.BudgetTemplate{Title: "Supplies", Amount: Domain.NewMoney(1000, 0), Recurrence: Domain.RecurQuarterly}, financeActor)
+	periods, _ := f.fiscal.CreateYear(2031, time.Time{}, financeActor)
+	q1, q2 := periods[1], periods[2]
+
+	first := f.budgetIn(q1.ID)
+	if _, err := f.budgetUC.ApproveBudget(first.ID.Hex(), secondFinance, ""); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+	e, err := f.expenses.CreateExpense(&Domain.Expense{Title: "Paper", Amount: Domain.NewMoney(300, 0), BudgetID: first.ID}, staffActor("u1"))
+	if err != nil {
+		t.Fatalf("expense failed: %v", err)
+	}
+	if err := f.expenses.VerifyExpense(e.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+
+	if _, err := f.fiscal.ClosePeriod(periods[0].ID.Hex(), false, financeActor); err == nil {
+		t.Fatalf("the year cannot close while its quarters are open")
+	}
+	result, err := f.fiscal.ClosePeriod(q1.ID.Hex(), true, financeActor)
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if len(result.Carried) != 1 || result.Carried[0].Amount != Domain.NewMoney(700, 0) || result.NextPeriodID != q2.ID {
+		t.Fatalf("expected 700 carried into Q2, got %+v", result)
+	}
+
+	closed, _ := f.budgets.GetByID(first.ID.Hex())
+	if closed.Remaining != 0 || closed.CarriedOut != Domain.NewMoney(700, 0) {
+		t.Fatalf("expected Q1 budget emptied, got %+v", closed)
+	}
+	next := f.budgetIn(q2.ID)
+	if next.Amount != Domain.NewMoney(1700, 0) || next.CarriedIn != Domain.NewMoney(700, 0) {
+		t.Fatalf("expected Q2 budget topped up, got %+v", next)
+	}
+	approved, err := f.budgetUC.ApproveBudget(next.ID.Hex(), secondFinance, "")
+	if err != nil || approved.Remaining != Domain.NewMoney(1700, 0) {
+		t.Fatalf("expected Q2 approved with the carried money, got %+v (%v)", approved, err)
+	}
+
+	for _, e := range f.ledger.entries {
+		if e.Event != Domain.EventCarryForward {
+			continue
+		}
+		if want := Domain.BudgetAccount(e.BudgetID); e.Account != want {
+			t.Fatalf("carry forward line on %s is tagged with another budget %s", e.Account, e.BudgetID.Hex())
+		}
+	}
+	if lines, _ := f.ledger.Find(Domain.LedgerFilter{BudgetID: next.ID.Hex()}); len(lines) == 0 || lines[0].Event != Domain.EventCarryForward || lines[0].Type != Domain.LedgerCredit {
+		t.Fatalf("expected the Q2 budget's ledger to show the carried credit, got %+v", lines)
+	}
+
+	results, _ := NewLedgerUsecase(f.ledger, f.budgets).CheckConsistency()
+	for _, r := range results {
+		if !r.Consistent {
+			t.Fatalf("carry forward left the ledger inconsistent: %+v", r)
+		}
+	}
+
+	if _, err := f.expenses.CreateExpense(&Domain.Expense{Title: "Late", Amount: 100, BudgetID: first.ID}, staffActor("u1")); !errors.Is(err, Domain.ErrPeriodClosed) {
+		t.Fatalf("expected closed period to reject expenses, got %v", err)
+	}
+	if _, err := f.fiscal.ClosePeriod(q1.ID.Hex(), false, financeActor); !errors.Is(err, Domain.ErrPeriodClosed) {
+		t.Fatalf("expected second close to fail, got %v", err)
+	}
+}
+
+func TestFiscal_CarryForwardNeedsNextPeriod(t *testing.T) {
+	f := newFiscalFixture()
+	periods, _ := f.fiscal.CreateYear(2031, time.Time{}, financeActor)
+	q4 := periods[4]
+
+	if _, err := f.fiscal.ClosePeriod(q4.ID.Hex(), true, financeActor); err == nil {
+		t.Fatalf("expected carry forward without a next year to fail")
+	}
+	if p, _ := f.fiscal.GetPeriod(q4.ID.Hex()); p.Status != Domain.PeriodOpen {
+		t.Fatalf("a failed close must leave the period open")
+	}
+
+	_, _ = f.fiscal.CreateYear(2032, time.Time{}, financeActor)
+	if _, err := f.fiscal.ClosePeriod(q4.ID.Hex(), true, financeActor); err != nil {
+		t.Fatalf("expected close into next year's Q1, got %v", err)
+	}
+}
+
+func TestFiscal_ClosedPeriodRejectsCashRequests(t *testing.T) {
+	f := newFiscalFixture()
+	_, _ = f.fiscal.CreateTemplate(&Domain.BudgetTemplate{Title: "Supplies", Amount: Domain.NewMoney(1000, 0), Recurrence: Domain.RecurQuarterly}, financeActor)
+	periods, _ := f.fiscal.CreateYear(2031, time.Time{}, financeActor)
+	q1 := periods[1]
+
+	b := f.budgetIn(q1.ID)
+	if _, err := f.budgetUC.ApproveBudget(b.ID.Hex(), secondFinance, ""); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+	r, err := f.cash.CreateCashRequest(&Domain.CashRequest{Title: "Float", Amount: Domain.NewMoney(200, 0), BudgetID: b.ID}, staffActor("u1"))
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := f.cash.ApproveCashRequest(r.ID.Hex(), financeActor, ""); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+
+	// the advance is open until it is settled, and its return is credited to this budget
+	if _, err := f.fiscal.ClosePeriod(q1.ID.Hex(), false, financeActor); !errors.Is(err, Domain.ErrPeriodInUse) {
+		t.Fatalf("expected the approved advance to block the close, got %v", err)
+	}
+	if err := f.cash.DisburseCashRequest(r.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("disburse failed: %v", err)
+	}
+	if _, err := f.fiscal.ClosePeriod(q1.ID.Hex(), false, financeActor); !errors.Is(err, Domain.ErrPeriodInUse) {
+		t.Fatalf("expected the disbursed advance to block the close, got %v", err)
+	}
+	if _, err := f.cash.SettleCashRequest(r.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("settle failed: %v", err)
+	}
+	if _, err := f.fiscal.ClosePeriod(q1.ID.Hex(), false, financeActor); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	if _, err := f.cash.CreateCashRequest(&Domain.CashRequest{Title: "Late", Amount: 100, BudgetID: b.ID}, staffActor("u1")); !errors.Is(err, Domain.ErrPeriodClosed) {
+		t.Fatalf("expected closed period to reject cash requests, got %v", err)
+	}
+	// an approved request that reached the store anyway must still not draw on the closed budget
+	stray := &Domain.CashRequest{Title: "Stray", Amount: 100, Currency: "USD", BudgetID: b.ID, Status: Domain.StatusApproved, Requester: "u1"}
+	_ = f.cashRepo.Create(stray)
+	if err := f.cash.DisburseCashRequest(stray.ID.Hex(), financeActor); !errors.Is(err, Domain.ErrPeriodClosed) {
+		t.Fatalf("expected closed period to block the disbursement, got %v", err)
+	}
+	if after, _ := f.budgets.GetByID(b.ID.Hex()); after.Remaining != Domain.NewMoney(1000, 0) {
+		t.Fatalf("expected the settled advance to have come back in full, got %+v", after)
+	}
+}
+
+func TestFiscal_CloseWaitsForPendingExpenses(t *testing.T) {
+	f := newFiscalFixture()
+	_, _ = f.fiscal.CreateTemplate(&Domain.BudgetTemplate{Title: "Supplies", Amount: Domain.NewMoney(1000, 0), Recurrence: Domain.RecurQuarterly}, financeActor)
+	periods, _ := f.fiscal.CreateYear(2031, time.Time{}, financeActor)
+	q1, q2 := periods[1], periods[2]
+
+	first := f.budgetIn(q1.ID)
+	if _, err := f.budgetUC.ApproveBudget(first.ID.Hex(), secondFinance, ""); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+	e, err := f.expenses.CreateExpense(&Domain.Expense{Title: "Paper", Amount: Domain.NewMoney(300, 0), BudgetID: first.ID}, staffActor("u1"))
+	if err != nil {
+		t.Fatalf("expense failed: %v", err)
+	}
+
+	if _, err := f.fiscal.ClosePeriod(q1.ID.Hex(), true, financeActor); !errors.Is(err, Domain.ErrPeriodInUse) {
+		t.Fatalf("expected the pending expense to block the close, got %v", err)
+	}
+	if p, _ := f.fiscal.GetPeriod(q1.ID.Hex()); p.Status != Domain.PeriodOpen {
+		t.Fatalf("a refused close must leave the period open")
+	}
+	if b, _ := f.budgets.GetByID(first.ID.Hex()); b.Remaining != Domain.NewMoney(1000, 0) || b.CarriedOut != 0 {
+		t.Fatalf("a refused close must not carry anything, got %+v", b)
+	}
+
+	// the expense is still debited from the budget it was charged to
+	if err := f.expenses.VerifyExpense(e.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	result, err := f.fiscal.ClosePeriod(q1.ID.Hex(), true, financeActor)
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if len(result.Carried) != 1 || result.Carried[0].Amount != Domain.NewMoney(700, 0) || result.NextPeriodID != q2.ID {
+		t.Fatalf("expected what is left after the expense carried, got %+v", result)
+	}
+}
+
+func TestFiscal_FailedCloseCanBeRunAgain(t *testing.T) {
+	f := newFiscalFixture()
+	_, _ = f.fiscal.CreateTemplate(&Domain.BudgetTemplate{Title: "Supplies", Amount: Domain.NewMoney(1000, 0), Recurrence: Domain.RecurQuarterly}, financeActor)
+	periods, _ := f.fiscal.CreateYear(2031, time.Time{}, financeActor)
+	q1, q2 := periods[1], periods[2]
+	if _, err := f.budgetUC.ApproveBudget(f.budgetIn(q1.ID).ID.Hex(), secondFinance, ""); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+
+	f.periods.closeErr = errors.New("connection reset")
+	result, err := f.fiscal.ClosePeriod(q1.ID.Hex(), true, financeActor)
+	if err == nil || len(result.Carried) != 1 {
+		t.Fatalf("expected the carry to happen and the close to fail, got %+v (%v)", result, err)
+	}
+	if p, _ := f.fiscal.GetPeriod(q1.ID.Hex()); p.Status != Domain.PeriodOpen {
+		t.Fatalf("a failed close must leave the period open")
+	}
+
+	f.periods.closeErr = nil
+	result, err = f.fiscal.ClosePeriod(q1.ID.Hex(), true, financeActor)
+	if err != nil || result.Period.Status != Domain.PeriodClosed || len(result.Carried) != 0 {
+		t.Fatalf("expected the second close to finish without carrying again, got %+v (%v)", result, err)
+	}
+	if next := f.budgetIn(q2.ID); next.CarriedIn != Domain.NewMoney(1000, 0) {
+		t.Fatalf("expected the remaining carried once, got %+v", next)
+	}
+}
@@ -0,0 +1,106 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LedgerUsecase interface {
+	GetEntries(filter Domain.LedgerFilter) ([]Domain.LedgerEntry, error)
+	CheckConsistency() ([]Domain.LedgerConsistency, error)
+}
+
+type ledgerUsecase struct {
+	ledgerRepo Repositories.LedgerRepository
+	budgetRepo Repositories.BudgetRepository
+}
+
+func NewLedgerUsecase(l Repositories.LedgerRepository, b Repositories.BudgetRepository) LedgerUsecase {
+	return &ledgerUsecase{ledgerRepo: l, budgetRepo: b}
+}
+
+func (u *ledgerUsecase) GetEntries(filter Domain.LedgerFilter) ([]Domain.LedgerEntry, error) {
+	return u.ledgerRepo.Find(filter)
+}
+
+// CheckConsistency proves every budget's remaining equals its amount minus the debits in the ledger
+func (u *ledgerUsecase) CheckConsistency() ([]Domain.LedgerConsistency, error) {
+	budgets, err := u.budgetRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	debits, err := u.ledgerRepo.DebitTotals()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Domain.LedgerConsistency, 0, len(budgets))
+	for _, b := range budgets {
+		spent := debits[b.ID.Hex()]
+		expected := b.Amount - spent
+		results = append(results, Domain.LedgerConsistency{
+			BudgetID:   b.ID.Hex(),
+			Title:      b.Title,
+			Amount:     b.Amount,
+			Remaining:  b.Remaining,
+			Debits:     spent,
+			Expected:   expected,
+			Consistent: expected == b.Remaining,
+		})
+	}
+	return results, nil
+}
+
+// postTransfer writes a balanced journal transaction moving amount from the
+// credited account to the debited one
+func postTransfer(repo Repositories.LedgerRepository, event, reference string, budgetID primitive.ObjectID, debitAccount, creditAccount string, amount Domain.Money, currency string) error {
+	return postBudgetTransfer(repo, event, reference, budgetID, debitAccount, budgetID, creditAccount, amount, currency)
+}
+
+// postBudgetTransfer is postTransfer for a transaction between two budgets,
+// tagging each line with the budget it belongs to
+func postBudgetTransfer(repo Repositories.LedgerRepository, event, reference string, debitBudget primitive.ObjectID, debitAccount string, creditBudget primitive.ObjectID, creditAccount string, amount Domain.Money, currency string) error {
+	txID := primitive.NewObjectID()
+	now := time.Now().UTC()
+	entries := []Domain.LedgerEntry{
+		{
+			TransactionID: txID,
+			Account:       debitAccount,
+			BudgetID:      debitBudget,
+			Type:          Domain.LedgerDebit,
+			Amount:        amount,
+			Currency:      currency,
+			Event:         event,
+			ReferenceID:   reference,
+			CreatedAt:     now,
+		},
+		{
+			TransactionID: txID,
+			Account:       creditAccount,
+			BudgetID:      creditBudget,
+			Type:          Domain.LedgerCredit,
+			Amount:        amount,
+			Currency:      currency,
+			Event:         event,
+			ReferenceID:   reference,
+			CreatedAt:     now,
+		},
+	}
+	return repo.Append(entries)
+}
+
+// rollbackFailed reports that step failed with cause and that undoing what
+// had already been written failed too. Each rollback error is joined; all of
+// them and cause stay matchable with errors.Is.
+func rollbackFailed(step string, cause error, rollback ...error) error {
+	err := errors.Join(rollback...)
+	if err == nil {
+		return cause
+	}
+	return fmt.Errorf("%s failed: %w; rollback failed: %w", step, cause, err)
+}
@@ -0,0 +1,125 @@
+package Usecases
+
+import (
+	"errors"
+	"testing"
+
+	"FMS/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mock ledger repo
+type mockLedgerRepo struct{ entries []Domain.LedgerEntry }
+
+func newMockLedgerRepo() *mockLedgerRepo { return &mockLedgerRepo{} }
+
+func (m *mockLedgerRepo) Append(entries []Domain.LedgerEntry) error {
+	if len(entries) == 0 {
+		return errors.New("no ledger entries")
+	}
+	m.entries = append(m.entries, entries...)
+	return nil
+}
+
+var errLedgerDown = errors.New("ledger unavailable")
+
+// failingLedgerRepo refuses every append, as if the ledger were down
+type failingLedgerRepo struct{ *mockLedgerRepo }
+
+func (m *failingLedgerRepo) Append(entries []Domain.LedgerEntry) error {
+	return errLedgerDown
+}
+
+func (m *mockLedgerRepo) Find(filter Domain.LedgerFilter) ([]Domain.LedgerEntry, error) {
+	res := make([]Domain.LedgerEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		if filter.BudgetID != "" && e.BudgetID.Hex() != filter.BudgetID {
+			continue
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+func (m *mockLedgerRepo) DebitTotals() (map[string]Domain.Money, error) {
+	totals := map[string]Domain.Money{}
+	for _, e := range m.entries {
+		if e.Account != Domain.BudgetAccount(e.BudgetID) {
+			continue
+		}
+		if e.Type == Domain.LedgerDebit {
+			totals[e.BudgetID.Hex()] += e.Amount
+		} else if e.Event == Domain.EventCashReturn {
+			totals[e.BudgetID.Hex()] -= e.Amount
+		}
+	}
+	return totals, nil
+}
+
+func TestLedger_MoneyMovementsStayConsistent(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	cash := newMockCashRepo()
+	expenses := newMockExpenseRepo()
+	ledger := newMockLedgerRepo()
+
+	budgetUC := NewBudgetUsecase(budgets, ledger, newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo())
+	cashUC := NewCashRequestUsecase(cash, budgets, ledger, newMockApprovalPolicyRepo(), testCurrencies(), expenses, newMockFiscalPeriodRepo())
+	expenseUC := NewExpenseUsecase(expenses, budgets, ledger, newMockBlobStorage(), testCurrencies(), newMockFiscalPeriodRepo(), cash)
+	ledgerUC := NewLedgerUsecase(ledger, budgets)
+
+	b := &Domain.Budget{ID: primitive.NewObjectID(), Title: "Travel", Amount: 1000, Remaining: 1000, Status: "pending"}
+	_ = budgets.Create(b)
+	if _, err := budgetUC.ApproveBudget(b.ID.Hex(), financeActor, ""); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+
+	cr := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Advance", Amount: 300, BudgetID: b.ID, Status: "approved"}
+	_ = cash.Create(cr)
+	if err := cashUC.DisburseCashRequest(cr.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("disburse failed: %v", err)
+	}
+
+	e := &Domain.Expense{ID: primitive.NewObjectID(), Title: "Hotel", Amount: 200, BudgetID: b.ID, Status: "pending"}
+	_ = expenses.Create(e)
+	if err := expenseUC.VerifyExpense(e.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+
+	if len(ledger.entries) != 6 {
+		t.Fatalf("expected 6 ledger entries, got %d", len(ledger.entries))
+	}
+	var debits, credits Domain.Money
+	for _, le := range ledger.entries {
+		if le.Type == Domain.LedgerDebit {
+			debits += le.Amount
+		} else {
+			credits += le.Amount
+		}
+	}
+	if debits != credits {
+		t.Fatalf("ledger unbalanced: debits %v credits %v", debits, credits)
+	}
+
+	results, err := ledgerUC.CheckConsistency()
+	if err != nil {
+		t.Fatalf("consistency failed: %v", err)
+	}
+	if len(results) != 1 || !results[0].Consistent || results[0].Remaining != 500 {
+		t.Fatalf("expected consistent budget with 500 remaining, got %+v", results)
+	}
+}
+
+func TestLedger_DetectsDrift(t *testing.T) {
+	budgets := newMockBudgetRepo()
+	ledger := newMockLedgerRepo()
+	b := &Domain.Budget{ID: primitive.NewObjectID(), Title: "Ops", Amount: 100, Remaining: 40, Status: "approved"}
+	_ = budgets.Create(b)
+
+	results, err := NewLedgerUsecase(ledger, budgets).CheckConsistency()
+	if err != nil {
+		t.Fatalf("consistency failed: %v", err)
+	}
+	if results[0].Consistent {
+		t.Fatalf("expected drift to be detected")
+	}
+}
@@ -0,0 +1,95 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// prepareLineItems validates a budget's line items and resets their balances
+// to their amounts. Categories are case-insensitive and unique per budget, and
+// the lines together cannot allocate more than the budget amount.
+func prepareLineItems(b *Domain.Budget) error {
+	seen := make(map[string]bool, len(b.LineItems))
+	for i := range b.LineItems {
+		li := &b.LineItems[i]
+		li.Category = strings.ToLower(strings.TrimSpace(li.Category))
+		if li.Category == "" {
+			return errors.New("line item category is required")
+		}
+		if seen[li.Category] {
+			return fmt.Errorf("duplicate line item category %s", li.Category)
+		}
+		seen[li.Category] = true
+		if li.Amount <= 0 {
+			return fmt.Errorf("line item %s amount must be greater than zero", li.Category)
+		}
+		if li.ID.IsZero() {
+			li.ID = primitive.NewObjectID()
+		}
+		li.Remaining = li.Amount
+		li.CarriedOut = 0
+	}
+	if b.Allocated() > b.Amount {
+		return errors.New("line items allocate more than the budget amount")
+	}
+	return nil
+}
+
+// lineItemByCategory returns b's line item for category, or nil
+func lineItemByCategory(b *Domain.Budget, category string) *Domain.BudgetLineItem {
+	for i := range b.LineItems {
+		if b.LineItems[i].Category == category {
+			return &b.LineItems[i]
+		}
+	}
+	return nil
+}
+
+// checkLineItem makes spend against a budget split into line items name one of
+// them, and rejects a line item on spend that cannot use one
+func checkLineItem(budgetRepo Repositories.BudgetRepository, budgetID, lineItemID primitive.ObjectID) error {
+	if budgetID.IsZero() {
+		if !lineItemID.IsZero() {
+			return errors.New("line_item_id requires a budget_id")
+		}
+		return nil
+	}
+	b, err := budgetRepo.GetByID(budgetID.Hex())
+	if err != nil {
+		return err
+	}
+	if len(b.LineItems) == 0 {
+		if !lineItemID.IsZero() {
+			return errors.New("budget has no line items")
+		}
+		return nil
+	}
+	if lineItemID.IsZero() {
+		return errors.New("line_item_id is required for a budget with line items")
+	}
+	if b.LineItem(lineItemID) == nil {
+		return errors.New("line item not found in budget")
+	}
+	return nil
+}
+
+// checkLineBalance is the early, friendlier form of the check the repository
+// debit makes atomically
+func checkLineBalance(b *Domain.Budget, lineItemID primitive.ObjectID, amount Domain.Money) error {
+	if lineItemID.IsZero() {
+		return nil
+	}
+	li := b.LineItem(lineItemID)
+	if li == nil {
+		return errors.New("line item not found in budget")
+	}
+	if li.Remaining < amount {
+		return fmt.Errorf("insufficient balance on line item %s", li.Category)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+	"fmt"
+)
+
+const (
+	defaultListPageSize = 50
+	maxListPageSize     = 200
+)
+
+// normalizeListQuery clamps paging, defaults to newest first and rejects
+// inverted ranges
+func normalizeListQuery(q *Domain.ListQuery) error {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.Limit < 1 {
+		q.Limit = defaultListPageSize
+	}
+	if q.Limit > maxListPageSize {
+		q.Limit = maxListPageSize
+	}
+	if q.Sort == "" {
+		q.Sort, q.Desc = Domain.SortCreatedAt, true
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.From.After(q.To) {
+		return fmt.Errorf("%w: from must be before to", Domain.ErrInvalidQuery)
+	}
+	if q.MinAmount < 0 || q.MaxAmount < 0 {
+		return fmt.Errorf("%w: amount bounds must not be negative", Domain.ErrInvalidQuery)
+	}
+	if q.MaxAmount > 0 && q.MinAmount > q.MaxAmount {
+		return fmt.Errorf("%w: min_amount must not exceed max_amount", Domain.ErrInvalidQuery)
+	}
+	q.Scope = nil
+	return nil
+}
+
+// budgetScope limits a budget list to the caller's own budgets, plus their
+// department's for department heads; finance sees everything
+func budgetScope(actor Domain.Actor) *Domain.ListScope {
+	if actor.Finance {
+		return nil
+	}
+	scope := &Domain.ListScope{OwnerID: actor.UserID}
+	if actor.DepartmentHead && actor.Department != "" {
+		scope.Department = normalizeDepartment(actor.Department)
+	}
+	return scope
+}
+
+// spendScope limits a cash request or expense list to the caller's own records,
+// plus those drawn on their department's budgets for department heads
+func spendScope(budgetRepo Repositories.BudgetRepository, actor Domain.Actor) (*Domain.ListScope, error) {
+	if actor.Finance {
+		return nil, nil
+	}
+	scope := &Domain.ListScope{OwnerID: actor.UserID}
+	if actor.DepartmentHead && actor.Department != "" {
+		ids, err := departmentBudgetIDs(budgetRepo, normalizeDepartment(actor.Department))
+		if err != nil {
+			return nil, err
+		}
+		scope.BudgetIDs = ids
+	}
+	return scope, nil
+}
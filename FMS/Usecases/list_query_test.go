@@ -0,0 +1,149 @@
+package Usecases
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"FMS/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// listFields is what the mock repositories' List needs to know of a record
+type listFields struct {
+	id         primitive.ObjectID
+	owner      string
+	department string
+	budgetID   primitive.ObjectID
+	status     string
+	amount     Domain.Money
+	createdAt  time.Time
+}
+
+// mockList applies q the way the Mongo repositories do, without cursors
+func mockList[T any](items []T, q Domain.ListQuery, fields func(T) listFields) ([]T, *Domain.Pagination, error) {
+	if q.Cursor != "" {
+		return nil, nil, errors.New("mock repositories do not page by cursor")
+	}
+	if q.Sort != Domain.SortCreatedAt && q.Sort != Domain.SortAmount {
+		return nil, nil, fmt.Errorf("%w: cannot sort by %s", Domain.ErrInvalidQuery, q.Sort)
+	}
+	matched := []T{}
+	for _, item := range items {
+		f := fields(item)
+		if (q.Status != "" && f.status != q.Status) ||
+			(!q.From.IsZero() && f.createdAt.Before(q.From)) || (!q.To.IsZero() && f.createdAt.After(q.To)) ||
+			(q.MinAmount > 0 && f.amount < q.MinAmount) || (q.MaxAmount > 0 && f.amount > q.MaxAmount) ||
+			(!q.BudgetID.IsZero() && f.budgetID != q.BudgetID) || (q.Requester != "" && f.owner != q.Requester) {
+			continue
+		}
+		if s := q.Scope; s != nil && f.owner != s.OwnerID && (s.Department == "" || f.department != s.Department) && !containsID(s.BudgetIDs, f.budgetID) {
+			continue
+		}
+		matched = append(matched, item)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := fields(matched[i]), fields(matched[j])
+		less := a.id.Hex() < b.id.Hex()
+		if q.Sort == Domain.SortAmount && a.amount != b.amount {
+			less = a.amount < b.amount
+		} else if q.Sort == Domain.SortCreatedAt && !a.createdAt.Equal(b.createdAt) {
+			less = a.createdAt.Before(b.createdAt)
+		}
+		return less != q.Desc
+	})
+
+	page := &Domain.Pagination{Total: int64(len(matched)), Page: q.Page, Limit: q.Limit}
+	start := (q.Page - 1) * q.Limit
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := start + q.Limit
+	if end < len(matched) {
+		page.HasMore = true
+	} else {
+		end = len(matched)
+	}
+	return matched[start:end], page, nil
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, candidate := range ids {
+		if candidate == id && !id.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestListQuery_FiltersSortsAndPages(t *testing.T) {
+	expenses := newMockExpenseRepo()
+	budgets := newMockBudgetRepo()
+	uc := NewExpenseUsecase(expenses, budgets, newMockLedgerRepo(), newMockBlobStorage(), testCurrencies(), newMockFiscalPeriodRepo(), newMockCashRepo())
+	b := newApprovedBudget(budgets, 100000)
+	other := newApprovedBudget(budgets, 100000)
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		budgetID := b.ID
+		if i%2 == 1 {
+			budgetID = other.ID
+		}
+		expenses.Create(&Domain.Expense{Title: fmt.Sprintf("e%d", i), Amount: Domain.Money(100 * (i + 1)), BudgetID: budgetID,
+			CreatedBy: "u1", Status: Domain.StatusPending, CreatedAt: start.AddDate(0, 0, i)})
+	}
+	expenses.Create(&Domain.Expense{Title: "theirs", Amount: 50, BudgetID: b.ID, CreatedBy: "u2", Status: Domain.StatusVerified, CreatedAt: start})
+
+	list, page, err := uc.GetAllExpenses(Domain.ListQuery{Limit: 3}, staffActor("u1"))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if page.Total != 7 || !page.HasMore || page.Page != 1 || len(list) != 3 || list[0].Title != "e6" {
+		t.Fatalf("expected the newest 3 of the caller's 7 expenses, got %+v %v", page, titles(list))
+	}
+	list, page, _ = uc.GetAllExpenses(Domain.ListQuery{Page: 3, Limit: 3}, staffActor("u1"))
+	if page.HasMore || len(list) != 1 || list[0].Title != "e0" {
+		t.Fatalf("expected the last page to hold e0 only, got %+v %v", page, titles(list))
+	}
+
+	list, page, _ = uc.GetAllExpenses(Domain.ListQuery{
+		BudgetID:  b.ID,
+		MinAmount: 200,
+		MaxAmount: 600,
+		From:      start.AddDate(0, 0, 1),
+		Sort:      Domain.SortAmount,
+	}, financeActor)
+	if page.Total != 2 || len(list) != 2 || list[0].Title != "e2" || list[1].Title != "e4" {
+		t.Fatalf("expected e2 and e4 by ascending amount, got %v", titles(list))
+	}
+	if _, page, _ = uc.GetAllExpenses(Domain.ListQuery{Status: Domain.StatusVerified, Requester: "u2"}, financeActor); page.Total != 1 {
+		t.Fatalf("expected one verified expense by u2, got %d", page.Total)
+	}
+
+	list, page, _ = uc.GetAllExpenses(Domain.ListQuery{Limit: 1000}, financeActor)
+	if page.Limit != maxListPageSize || len(list) != 8 {
+		t.Fatalf("expected the limit clamped to %d, got %d", maxListPageSize, page.Limit)
+	}
+
+	bad := []Domain.ListQuery{
+		{From: start, To: start.AddDate(0, 0, -1)},
+		{MinAmount: 500, MaxAmount: 100},
+		{Sort: "description"},
+	}
+	for _, q := range bad {
+		if _, _, err := uc.GetAllExpenses(q, financeActor); !errors.Is(err, Domain.ErrInvalidQuery) {
+			t.Fatalf("expected ErrInvalidQuery for %+v, got %v", q, err)
+		}
+	}
+}
+
+func titles(list []Domain.Expense) []string {
+	res := make([]string, len(list))
+	for i, e := range list {
+		res[i] = e.Title
+	}
+	return res
+}
@@ -0,0 +1,405 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Infrastructure"
+	"FMS/Repositories"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultThresholds are the budget utilisation percentages that notify by default
+var DefaultThresholds = []int{80, 100}
+
+// notificationQueueSize bounds the email and webhook messages waiting for the
+// delivery worker; once it is full further ones are logged and dropped
+const notificationQueueSize = 1000
+
+// NotificationChannel delivers a notification to one user over one medium
+type NotificationChannel interface {
+	Name() string
+	Send(n *Domain.Notification, prefs *Domain.NotificationPreferences) error
+}
+
+// NotificationUsecase serves each user's inbox and preferences, and raises
+// notifications when budgets fill up and approvals move
+type NotificationUsecase interface {
+	GetInbox(actor Domain.Actor, unreadOnly bool) ([]Domain.Notification, error)
+	MarkRead(id string, actor Domain.Actor) error
+	GetPreferences(actor Domain.Actor) (*Domain.NotificationPreferences, error)
+	UpdatePreferences(prefs *Domain.NotificationPreferences, actor Domain.Actor) (*Domain.NotificationPreferences, error)
+
+	BudgetSpent(budgetID primitive.ObjectID)
+	AwaitingApproval(entityType string, entityID primitive.ObjectID, title, department, owner string, chain []string, approvals []Domain.ApprovalRecord)
+	Rejected(entityType string, entityID primitive.ObjectID, title, owner, comment string)
+
+	// RunDelivery sends the queued email and webhook notifications until stop is closed
+	RunDelivery(stop <-chan struct{})
+}
+
+type notificationUsecase struct {
+	repo       Repositories.NotificationRepository
+	userRepo   Repositories.UserRepository
+	budgetRepo Repositories.BudgetRepository
+	thresholds []int
+	channels   map[string]NotificationChannel
+	queue      chan queuedNotification
+}
+
+// queuedNotification is a message waiting for the worker to send it on channel
+type queuedNotification struct {
+	channel NotificationChannel
+	msg     Domain.Notification
+	prefs   *Domain.NotificationPreferences
+}
+
+// NewNotificationUsecase delivers over the given channels; preferences naming a
+// channel that is not configured are skipped
+func NewNotificationUsecase(repo Repositories.NotificationRepository, users Repositories.UserRepository, budgetRepo Repositories.BudgetRepository, thresholds []int, channels ...NotificationChannel) NotificationUsecase {
+	byName := make(map[string]NotificationChannel, len(channels))
+	for _, ch := range channels {
+		byName[ch.Name()] = ch
+	}
+	sorted := append([]int(nil), thresholds...)
+	sort.Ints(sorted)
+	return &notificationUsecase{repo: repo, userRepo: users, budgetRepo: budgetRepo, thresholds: sorted, channels: byName, queue: make(chan queuedNotification, notificationQueueSize)}
+}
+
+// ParseThresholds reads a comma separated list of percentages such as "80,100"
+func ParseThresholds(s string) ([]int, error) {
+	var thresholds []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		t, err := strconv.Atoi(part)
+		if err != nil || t <= 0 {
+			return nil, fmt.Errorf("invalid threshold %q", part)
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+func (u *notificationUsecase) GetInbox(actor Domain.Actor, unreadOnly bool) ([]Domain.Notification, error) {
+	return u.repo.GetByUser(actor.UserID, unreadOnly)
+}
+
+func (u *notificationUsecase) MarkRead(id string, actor Domain.Actor) error {
+	return u.repo.MarkRead(id, actor.UserID)
+}
+
+func (u *notificationUsecase) GetPreferences(actor Domain.Actor) (*Domain.NotificationPreferences, error) {
+	return u.repo.GetPreferences(actor.UserID)
+}
+
+func (u *notificationUsecase) UpdatePreferences(prefs *Domain.NotificationPreferences, actor Domain.Actor) (*Domain.NotificationPreferences, error) {
+	prefs.UserID = actor.UserID
+	prefs.Email = strings.TrimSpace(prefs.Email)
+	prefs.WebhookURL = strings.TrimSpace(prefs.WebhookURL)
+	if err := validatePreferences(prefs); err != nil {
+		return nil, err
+	}
+	prefs.UpdatedAt = time.Now().UTC()
+	if err := u.repo.SavePreferences(prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+func validatePreferences(p *Domain.NotificationPreferences) error {
+	if p.Email != "" && (!strings.Contains(p.Email, "@") || strings.ContainsAny(p.Email, " \r\n")) {
+		return errors.New("invalid email address")
+	}
+	if p.WebhookURL != "" {
+		parsed, err := url.Parse(p.WebhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return errors.New("webhook_url must be an http or https URL")
+		}
+		// names resolving to such addresses are refused when the webhook is sent
+		host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+		ip := net.ParseIP(host)
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") || (ip != nil && !Infrastructure.IsPublicIP(ip)) {
+			return errors.New("webhook_url must not point at a loopback, private or link-local address")
+		}
+	}
+	for kind, channels := range p.Channels {
+		if !knownKind(kind) {
+			return fmt.Errorf("unknown notification kind %s", kind)
+		}
+		for _, ch := range channels {
+			switch ch {
+			case Domain.ChannelInApp:
+			case Domain.ChannelEmail:
+				if p.Email == "" {
+					return errors.New("email channel requires an email address")
+				}
+			case Domain.ChannelWebhook:
+				if p.WebhookURL == "" {
+					return errors.New("webhook channel requires a webhook_url")
+				}
+			default:
+				return fmt.Errorf("unknown notification channel %s", ch)
+			}
+		}
+	}
+	return nil
+}
+
+func knownKind(kind string) bool {
+	for _, k := range Domain.NotificationKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// BudgetSpent notifies the budget's owner and department heads the first time
+// its utilisation reaches each threshold. When spend jumps several thresholds
+// at once only the highest is sent.
+func (u *notificationUsecase) BudgetSpent(budgetID primitive.ObjectID) {
+	if budgetID.IsZero() || len(u.thresholds) == 0 {
+		return
+	}
+	b, err := u.budgetRepo.GetByID(budgetID.Hex())
+	if err != nil || b.Amount <= 0 {
+		return
+	}
+	spent := b.Amount - b.Remaining - b.CarriedOut
+	percent := int(spent * 100 / b.Amount)
+
+	crossed := 0
+	for _, t := range u.thresholds {
+		if percent < t {
+			break
+		}
+		marked, err := u.budgetRepo.MarkThresholdAlerted(b.ID.Hex(), t)
+		if err != nil {
+			log.Printf("notify budget %s threshold %d: %v", b.ID.Hex(), t, err)
+			return
+		}
+		if marked {
+			crossed = t
+		}
+	}
+	if crossed == 0 {
+		return
+	}
+
+	recipients := []string{b.CreatedBy}
+	users, err := u.userRepo.FindActive()
+	if err != nil {
+		log.Printf("notify budget %s: %v", b.ID.Hex(), err)
+	}
+	for _, user := range users {
+		if actorOf(user).HeadOf(b.Department) {
+			recipients = append(recipients, user.ID.Hex())
+		}
+	}
+	u.send(recipients, Domain.Notification{
+		Kind:       Domain.NotifyBudgetThreshold,
+		Subject:    fmt.Sprintf("Budget %s is %d%% used", b.Title, crossed),
+		Body:       fmt.Sprintf("%s has used %s of %s %s (%d%%).", b.Title, spent, b.Amount, b.Currency, percent),
+		EntityType: Domain.EntityBudget,
+		EntityID:   b.ID,
+	})
+}
+
+// AwaitingApproval notifies everyone who may sign off the next level of chain
+func (u *notificationUsecase) AwaitingApproval(entityType string, entityID primitive.ObjectID, title, department, owner string, chain []string, approvals []Domain.ApprovalRecord) {
+	if len(approvals) >= len(chain) {
+		return
+	}
+	users, err := u.userRepo.FindActive()
+	if err != nil {
+		log.Printf("notify %s %s: %v", entityType, entityID.Hex(), err)
+		return
+	}
+	var recipients []string
+	level := chain[len(approvals)]
+	for _, user := range users {
+		if _, ok := awaitingActor(actorOf(user), chain, approvals, department, owner); ok {
+			recipients = append(recipients, user.ID.Hex())
+		}
+	}
+	u.send(recipients, Domain.Notification{
+		Kind:       Domain.NotifyPendingApproval,
+		Subject:    fmt.Sprintf("%s %s awaits your approval", entityLabel(entityType), title),
+		Body:       fmt.Sprintf("%s %q is waiting on %s approval.", entityLabel(entityType), title, level),
+		EntityType: entityType,
+		EntityID:   entityID,
+	})
+}
+
+// Rejected tells the owner their submission was rejected
+func (u *notificationUsecase) Rejected(entityType string, entityID primitive.ObjectID, title, owner, comment string) {
+	body := fmt.Sprintf("%s %q was rejected.", entityLabel(entityType), title)
+	if comment != "" {
+		body += " Comment: " + comment
+	}
+	u.send([]string{owner}, Domain.Notification{
+		Kind:       Domain.NotifyRejected,
+		Subject:    fmt.Sprintf("Your %s %s was rejected", strings.ToLower(entityLabel(entityType)), title),
+		Body:       body,
+		EntityType: entityType,
+		EntityID:   entityID,
+	})
+}
+
+// send delivers n to each recipient once, on the channels they chose for its
+// kind. The inbox is written straight away; other channels are queued for
+// RunDelivery so a slow mail server or webhook does not hold up the request.
+// Failures are logged; a notification never fails the action behind it.
+func (u *notificationUsecase) send(recipients []string, n Domain.Notification) {
+	seen := make(map[string]bool, len(recipients))
+	for _, userID := range recipients {
+		if userID == "" || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		prefs, err := u.repo.GetPreferences(userID)
+		if err != nil {
+			log.Printf("notify %s: %v", userID, err)
+			continue
+		}
+		msg := n
+		msg.UserID = userID
+		msg.CreatedAt = time.Now().UTC()
+		for _, name := range prefs.ChannelsFor(n.Kind) {
+			ch, ok := u.channels[name]
+			if !ok {
+				continue
+			}
+			if name != Domain.ChannelInApp {
+				u.enqueue(queuedNotification{channel: ch, msg: msg, prefs: prefs})
+				continue
+			}
+			if err := ch.Send(&msg, prefs); err != nil {
+				log.Printf("notify %s via %s: %v", userID, name, err)
+			}
+		}
+	}
+}
+
+func (u *notificationUsecase) enqueue(q queuedNotification) {
+	select {
+	case u.queue <- q:
+	default:
+		log.Printf("notify %s via %s: delivery queue is full, dropped", q.msg.UserID, q.channel.Name())
+	}
+}
+
+// RunDelivery sends queued notifications one at a time. Anything still queued
+// when the server stops is lost; the inbox keeps its own copy.
+func (u *notificationUsecase) RunDelivery(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case q := <-u.queue:
+			u.deliver(q)
+		}
+	}
+}
+
+func (u *notificationUsecase) deliver(q queuedNotification) {
+	if err := q.channel.Send(&q.msg, q.prefs); err != nil {
+		log.Printf("notify %s via %s: %v", q.msg.UserID, q.channel.Name(), err)
+	}
+}
+
+// actorOf is the actor a stored user would be when signed in, so users who
+// cannot sign off without two-factor authentication are not asked to
+func actorOf(user Domain.User) Domain.Actor {
+	return Domain.Actor{
+		UserID:         user.ID.Hex(),
+		Username:       user.Username,
+		Role:           user.Role,
+		Department:     user.Department,
+		DepartmentHead: user.DepartmentHead,
+		Finance:        (strings.EqualFold(user.Role, Domain.RoleFinance) || strings.EqualFold(user.Department, Domain.FinanceDepartment)) && user.TOTPEnabled,
+		MFA:            user.TOTPEnabled,
+	}
+}
+
+func entityLabel(entityType string) string {
+	switch entityType {
+	case Domain.EntityBudget:
+		return "Budget"
+	case Domain.EntityCashRequest:
+		return "Cash request"
+	case Domain.EntityExpense:
+		return "Expense"
+	}
+	return entityType
+}
+
+type inAppChannel struct {
+	repo Repositories.NotificationRepository
+}
+
+// NewInAppChannel keeps notifications in the user's inbox
+func NewInAppChannel(repo Repositories.NotificationRepository) NotificationChannel {
+	return &inAppChannel{repo: repo}
+}
+
+func (c *inAppChannel) Name() string { return Domain.ChannelInApp }
+
+func (c *inAppChannel) Send(n *Domain.Notification, _ *Domain.NotificationPreferences) error {
+	stored := *n
+	stored.ID = primitive.NilObjectID
+	return c.repo.Create(&stored)
+}
+
+type emailChannel struct {
+	mailer Infrastructure.Mailer
+}
+
+func NewEmailChannel(mailer Infrastructure.Mailer) NotificationChannel {
+	return &emailChannel{mailer: mailer}
+}
+
+func (c *emailChannel) Name() string { return Domain.ChannelEmail }
+
+func (c *emailChannel) Send(n *Domain.Notification, prefs *Domain.NotificationPreferences) error {
+	if prefs.Email == "" {
+		return errors.New("no email address")
+	}
+	return c.mailer.Send(prefs.Email, n.Subject, n.Body)
+}
+
+type webhookChannel struct {
+	client Infrastructure.WebhookClient
+}
+
+// NewWebhookChannel posts the notification as JSON to the user's webhook URL.
+// The URL is the user's choice, so client should be a NewPublicWebhookClient.
+func NewWebhookChannel(client Infrastructure.WebhookClient) NotificationChannel {
+	return &webhookChannel{client: client}
+}
+
+func (c *webhookChannel) Name() string { return Domain.ChannelWebhook }
+
+func (c *webhookChannel) Send(n *Domain.Notification, prefs *Domain.NotificationPreferences) error {
+	if prefs.WebhookURL == "" {
+		return errors.New("no webhook URL")
+	}
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return c.client.Post(prefs.WebhookURL, payload, nil)
+}
@@ -0,0 +1,348 @@
+package Usecases
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"FMS/Domain"
+	"FMS/Infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type mockNotificationRepo struct {
+	notifications []Domain.Notification
+	prefs         map[string]*Domain.NotificationPreferences
+}
+
+func newMockNotificationRepo() *mockNotificationRepo {
+	return &mockNotificationRepo{prefs: map[string]*Domain.NotificationPreferences{}}
+}
+func (m *mockNotificationRepo) Create(n *Domain.Notification) error {
+	n.ID = primitive.NewObjectID()
+	m.notifications = append(m.notifications, *n)
+	return nil
+}
+func (m *mockNotificationRepo) GetByUser(userID string, unreadOnly bool) ([]Domain.Notification, error) {
+	res := []Domain.Notification{}
+	for _, n := range m.notifications {
+		if n.UserID == userID && (!unreadOnly || !n.Read) {
+			res = append(res, n)
+		}
+	}
+	return res, nil
+}
+func (m *mockNotificationRepo) MarkRead(id, userID string) error {
+	for i, n := range m.notifications {
+		if n.ID.Hex() == id && n.UserID == userID {
+			m.notifications[i].Read = true
+			return nil
+		}
+	}
+	return errors.New("notification not found")
+}
+func (m *mockNotificationRepo) GetPreferences(userID string) (*Domain.NotificationPreferences, error) {
+	if p, ok := m.prefs[userID]; ok {
+		return p, nil
+	}
+	return &Domain.NotificationPreferences{UserID: userID}, nil
+}
+func (m *mockNotificationRepo) SavePreferences(p *Domain.NotificationPreferences) error {
+	m.prefs[p.UserID] = p
+	return nil
+}
+
+// smtpStandIn is a local SMTP server that accepts every message and keeps it
+type smtpStandIn struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func startSMTPStandIn(t *testing.T) *smtpStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpStandIn{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) mailer() Infrastructure.Mailer {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	return Infrastructure.NewSMTPMailer(host, port, "", "", "fms@localhost")
+}
+
+func (s *smtpStandIn) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	address := func(line string) string {
+		return strings.Trim(strings.TrimSpace(line[strings.Index(line, ":")+1:]), "<>")
+	}
+
+	reply("220 localhost SMTP stand-in")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = smtpMessage{from: address(line)}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.to = append(msg.to, address(line))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			msg.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			// EHLO, HELO, RSET, NOOP
+			reply("250 localhost")
+		}
+	}
+}
+
+func notificationUser(users *mockUserRepo, username, role, department string, head bool) string {
+	u := &Domain.User{Username: username, Role: role, Department: department, DepartmentHead: head, TOTPEnabled: true}
+	_ = users.Create(u)
+	return u.ID.Hex()
+}
+
+func inbox(repo *mockNotificationRepo, userID, kind string) []Domain.Notification {
+	var res []Domain.Notification
+	for _, n := range repo.notifications {
+		if n.UserID == userID && n.Kind == kind {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// deliverQueued sends what the notification worker would, without starting it
+func deliverQueued(n NotificationUsecase) {
+	u := n.(*notificationUsecase)
+	for {
+		select {
+		case q := <-u.queue:
+			u.deliver(q)
+		default:
+			return
+		}
+	}
+}
+
+func TestNotification_BudgetThresholdsNotifyOnce(t *testing.T) {
+	smtp := startSMTPStandIn(t)
+	users := newMockUserRepo()
+	owner := notificationUser(users, "owner", Domain.RoleStaff, "ops", false)
+	head := notificationUser(users, "head", Domain.RoleStaff, "ops", true)
+	other := notificationUser(users, "other", Domain.RoleStaff, "sales", true)
+
+	repo := newMockNotificationRepo()
+	budgets := newMockBudgetRepo()
+	cash := newMockCashRepo()
+	expenses := newMockExpenseRepo()
+	ledger := newMockLedgerRepo()
+	notify := NewNotificationUsecase(repo, users, budgets, DefaultThresholds, NewInAppChannel(repo), NewEmailChannel(smtp.mailer()))
+	if _, err := notify.UpdatePreferences(&Domain.NotificationPreferences{
+		Email:    "owner@example.com",
+		Channels: map[string][]string{Domain.NotifyBudgetThreshold: {Domain.ChannelInApp, Domain.ChannelEmail}},
+	}, Domain.Actor{UserID: owner}); err != nil {
+		t.Fatalf("preferences failed: %v", err)
+	}
+
+	cashUC := NewNotifyingCashRequestUsecase(NewCashRequestUsecase(cash, budgets, ledger, newMockApprovalPolicyRepo(), testCurrencies(), expenses, newMockFiscalPeriodRepo()), cash, budgets, notify)
+	expenseUC := NewNotifyingExpenseUsecase(NewExpenseUsecase(expenses, budgets, ledger, newMockBlobStorage(), testCurrencies(), newMockFiscalPeriodRepo(), cash), expenses, notify)
+
+	b := newApprovedBudget(budgets, 1000)
+	b.CreatedBy, b.Department = owner, "ops"
+	disburse := func(amount Domain.Money) {
+		r := &Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Advance", Amount: amount, BudgetID: b.ID, Status: "approved"}
+		_ = cash.Create(r)
+		if err := cashUC.DisburseCashRequest(r.ID.Hex(), financeActor); err != nil {
+			t.Fatalf("disburse failed: %v", err)
+		}
+	}
+
+	disburse(700)
+	if len(repo.notifications) != 0 {
+		t.Fatalf("expected no alert below 80%%, got %+v", repo.notifications)
+	}
+	disburse(150)
+	alerts := inbox(repo, owner, Domain.NotifyBudgetThreshold)
+	if len(alerts) != 1 || !strings.Contains(alerts[0].Subject, "80%") {
+		t.Fatalf("expected one 80%% alert for the owner, got %+v", alerts)
+	}
+	if len(inbox(repo, head, Domain.NotifyBudgetThreshold)) != 1 || len(inbox(repo, other, Domain.NotifyBudgetThreshold)) != 0 {
+		t.Fatalf("expected the department head, and only them, to be told too")
+	}
+	if len(smtp.received()) != 0 {
+		t.Fatalf("expected the email to wait for the delivery worker")
+	}
+	deliverQueued(notify)
+	mail := smtp.received()
+	if len(mail) != 1 || mail[0].to[0] != "owner@example.com" || !strings.Contains(mail[0].data, "Subject: Budget Ops is 80% used") {
+		t.Fatalf("expected an 80%% email to the owner, got %+v", mail)
+	}
+
+	disburse(100)
+	if len(inbox(repo, owner, Domain.NotifyBudgetThreshold)) != 1 {
+		t.Fatalf("expected the 80%% alert not to repeat")
+	}
+
+	e, err := expenseUC.CreateExpense(&Domain.Expense{Title: "Hotel", Amount: 50, BudgetID: b.ID}, staffActor(owner))
+	if err != nil {
+		t.Fatalf("create expense failed: %v", err)
+	}
+	if err := expenseUC.VerifyExpense(e.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	alerts = inbox(repo, owner, Domain.NotifyBudgetThreshold)
+	if len(alerts) != 2 || !strings.Contains(alerts[1].Subject, "100%") {
+		t.Fatalf("expected a 100%% alert once the budget is exhausted, got %+v", alerts)
+	}
+	deliverQueued(notify)
+	if len(smtp.received()) != 2 {
+		t.Fatalf("expected a second email, got %d", len(smtp.received()))
+	}
+}
+
+func TestNotification_ApprovalsAndRejections(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		hooks []Domain.Notification
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Domain.Notification
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &n)
+		mu.Lock()
+		hooks = append(hooks, n)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	users := newMockUserRepo()
+	staff := notificationUser(users, "staff", Domain.RoleStaff, "ops", false)
+	finance := notificationUser(users, "finance", Domain.RoleFinance, "", false)
+	muted := notificationUser(users, "muted", Domain.RoleFinance, "", false)
+	notificationUser(users, "admin", Domain.RoleAdmin, "", false)
+
+	repo := newMockNotificationRepo()
+	budgets := newMockBudgetRepo()
+	notify := NewNotificationUsecase(repo, users, budgets, DefaultThresholds, NewInAppChannel(repo), NewWebhookChannel(Infrastructure.NewWebhookClient(5*time.Second)))
+	if _, err := notify.UpdatePreferences(&Domain.NotificationPreferences{Channels: map[string][]string{Domain.NotifyPendingApproval: {}}}, Domain.Actor{UserID: muted}); err != nil {
+		t.Fatalf("preferences failed: %v", err)
+	}
+	// saved directly: the API refuses a loopback URL like the test server's
+	_ = repo.SavePreferences(&Domain.NotificationPreferences{
+		UserID:     staff,
+		WebhookURL: srv.URL,
+		Channels:   map[string][]string{Domain.NotifyRejected: {Domain.ChannelWebhook}},
+	})
+	uc := NewNotifyingBudgetUsecase(NewBudgetUsecase(budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockFiscalPeriodRepo()), budgets, notify)
+
+	b, err := uc.CreateBudget(&Domain.Budget{Title: "Ops", Amount: 500, Department: "ops"}, Domain.Actor{UserID: staff, Department: "ops"})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	pending := inbox(repo, finance, Domain.NotifyPendingApproval)
+	if len(pending) != 1 || pending[0].EntityID != b.ID {
+		t.Fatalf("expected finance to be asked for approval, got %+v", repo.notifications)
+	}
+	if len(inbox(repo, muted, Domain.NotifyPendingApproval)) != 0 || len(inbox(repo, staff, Domain.NotifyPendingApproval)) != 0 {
+		t.Fatalf("expected muted users and the requester not to be asked")
+	}
+
+	if _, err := uc.RejectBudget(b.ID.Hex(), Domain.Actor{UserID: finance, Role: Domain.RoleFinance, Finance: true, MFA: true}, "over plan"); err != nil {
+		t.Fatalf("reject failed: %v", err)
+	}
+	if len(inbox(repo, staff, Domain.NotifyRejected)) != 0 {
+		t.Fatalf("expected the rejection to skip the inbox the owner opted out of")
+	}
+	deliverQueued(notify)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hooks) != 1 || hooks[0].Kind != Domain.NotifyRejected || !strings.Contains(hooks[0].Body, "over plan") {
+		t.Fatalf("expected the rejection on the owner's webhook, got %+v", hooks)
+	}
+}
+
+func TestNotification_PreferencesValidation(t *testing.T) {
+	notify := NewNotificationUsecase(newMockNotificationRepo(), newMockUserRepo(), newMockBudgetRepo(), DefaultThresholds)
+	actor := staffActor("u1")
+	bad := []*Domain.NotificationPreferences{
+		{Channels: map[string][]string{Domain.NotifyRejected: {Domain.ChannelEmail}}},
+		{Channels: map[string][]string{Domain.NotifyRejected: {"sms"}}},
+		{Channels: map[string][]string{"birthday": {Domain.ChannelInApp}}},
+		{WebhookURL: "ftp://example.com/hook"},
+		{WebhookURL: "http://127.0.0.1:8080/hook"},
+		{WebhookURL: "http://localhost/hook"},
+		{WebhookURL: "http://169.254.169.254/latest/meta-data"},
+		{WebhookURL: "https://10.1.2.3/hook"},
+		{WebhookURL: "http://[::1]/hook"},
+	}
+	for _, p := range bad {
+		if _, err := notify.UpdatePreferences(p, actor); err == nil {
+			t.Fatalf("expected %+v to be rejected", p)
+		}
+	}
+	saved, err := notify.UpdatePreferences(&Domain.NotificationPreferences{UserID: "someone-else", Email: "u1@example.com", WebhookURL: "https://hooks.example.com/fms"}, actor)
+	if err != nil || saved.UserID != "u1" {
+		t.Fatalf("expected preferences saved for the caller, got %+v, %v", saved, err)
+	}
+}
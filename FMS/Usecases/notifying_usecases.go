@@ -0,0 +1,147 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+)
+
+// The notifying usecases decorate the budget, cash request and expense
+// usecases: submissions and partial approvals notify the next approvers,
+// rejections notify the owner, and spend checks the budget's utilisation
+// thresholds. Notifications go out only after the change succeeded.
+
+type notifyingBudgetUsecase struct {
+	BudgetUsecase
+	repo   Repositories.BudgetRepository
+	notify NotificationUsecase
+}
+
+func NewNotifyingBudgetUsecase(inner BudgetUsecase, repo Repositories.BudgetRepository, notify NotificationUsecase) BudgetUsecase {
+	return &notifyingBudgetUsecase{BudgetUsecase: inner, repo: repo, notify: notify}
+}
+
+func (u *notifyingBudgetUsecase) CreateBudget(input *Domain.Budget, actor Domain.Actor) (*Domain.Budget, error) {
+	b, err := u.BudgetUsecase.CreateBudget(input, actor)
+	if err == nil {
+		u.awaiting(b)
+	}
+	return b, err
+}
+
+// UpdateBudget restarts the approval chain, so the first level is asked again
+func (u *notifyingBudgetUsecase) UpdateBudget(id string, input *Domain.Budget, actor Domain.Actor) error {
+	if err := u.BudgetUsecase.UpdateBudget(id, input, actor); err != nil {
+		return err
+	}
+	if b, err := u.repo.GetByID(id); err == nil {
+		u.awaiting(b)
+	}
+	return nil
+}
+
+func (u *notifyingBudgetUsecase) ApproveBudget(id string, actor Domain.Actor, comment string) (*Domain.Budget, error) {
+	b, err := u.BudgetUsecase.ApproveBudget(id, actor, comment)
+	if err == nil && b.Status == Domain.StatusPending {
+		u.awaiting(b)
+	}
+	return b, err
+}
+
+func (u *notifyingBudgetUsecase) RejectBudget(id string, actor Domain.Actor, comment string) (*Domain.Budget, error) {
+	b, err := u.BudgetUsecase.RejectBudget(id, actor, comment)
+	if err == nil && b.Status == Domain.StatusRejected {
+		u.notify.Rejected(Domain.EntityBudget, b.ID, b.Title, b.CreatedBy, comment)
+	}
+	return b, err
+}
+
+func (u *notifyingBudgetUsecase) awaiting(b *Domain.Budget) {
+	u.notify.AwaitingApproval(Domain.EntityBudget, b.ID, b.Title, b.Department, b.CreatedBy, b.ApprovalChain, b.Approvals)
+}
+
+type notifyingCashRequestUsecase struct {
+	CashRequestUsecase
+	repo       Repositories.CashRequestRepository
+	budgetRepo Repositories.BudgetRepository
+	notify     NotificationUsecase
+}
+
+func NewNotifyingCashRequestUsecase(inner CashRequestUsecase, repo Repositories.CashRequestRepository, budgetRepo Repositories.BudgetRepository, notify NotificationUsecase) CashRequestUsecase {
+	return &notifyingCashRequestUsecase{CashRequestUsecase: inner, repo: repo, budgetRepo: budgetRepo, notify: notify}
+}
+
+func (u *notifyingCashRequestUsecase) CreateCashRequest(input *Domain.CashRequest, actor Domain.Actor) (*Domain.CashRequest, error) {
+	r, err := u.CashRequestUsecase.CreateCashRequest(input, actor)
+	if err == nil {
+		u.awaiting(r)
+	}
+	return r, err
+}
+
+func (u *notifyingCashRequestUsecase) ApproveCashRequest(id string, actor Domain.Actor, comment string) (*Domain.CashRequest, error) {
+	r, err := u.CashRequestUsecase.ApproveCashRequest(id, actor, comment)
+	if err == nil && r.Status == Domain.StatusPending {
+		u.awaiting(r)
+	}
+	return r, err
+}
+
+func (u *notifyingCashRequestUsecase) RejectCashRequest(id string, actor Domain.Actor, comment string) (*Domain.CashRequest, error) {
+	r, err := u.CashRequestUsecase.RejectCashRequest(id, actor, comment)
+	if err == nil && r.Status == Domain.StatusRejected {
+		u.notify.Rejected(Domain.EntityCashRequest, r.ID, r.Title, r.Requester, comment)
+	}
+	return r, err
+}
+
+func (u *notifyingCashRequestUsecase) DisburseCashRequest(id string, actor Domain.Actor) error {
+	if err := u.CashRequestUsecase.DisburseCashRequest(id, actor); err != nil {
+		return err
+	}
+	if r, err := u.repo.GetByID(id); err == nil {
+		u.notify.BudgetSpent(r.BudgetID)
+	}
+	return nil
+}
+
+// SettleCashRequest may top up the advance from the budget
+func (u *notifyingCashRequestUsecase) SettleCashRequest(id string, actor Domain.Actor) (*Domain.CashRequest, error) {
+	r, err := u.CashRequestUsecase.SettleCashRequest(id, actor)
+	if err == nil && r.Settlement != nil && r.Settlement.TopUp > 0 {
+		u.notify.BudgetSpent(r.BudgetID)
+	}
+	return r, err
+}
+
+func (u *notifyingCashRequestUsecase) awaiting(r *Domain.CashRequest) {
+	u.notify.AwaitingApproval(Domain.EntityCashRequest, r.ID, r.Title, cashRequestDepartment(u.budgetRepo, r), r.Requester, r.ApprovalChain, r.Approvals)
+}
+
+type notifyingExpenseUsecase struct {
+	ExpenseUsecase
+	repo   Repositories.ExpenseRepository
+	notify NotificationUsecase
+}
+
+func NewNotifyingExpenseUsecase(inner ExpenseUsecase, repo Repositories.ExpenseRepository, notify NotificationUsecase) ExpenseUsecase {
+	return &notifyingExpenseUsecase{ExpenseUsecase: inner, repo: repo, notify: notify}
+}
+
+// VerifyExpense draws on the budget unless the expense was paid from a cash advance
+func (u *notifyingExpenseUsecase) VerifyExpense(id string, actor Domain.Actor) error {
+	if err := u.ExpenseUsecase.VerifyExpense(id, actor); err != nil {
+		return err
+	}
+	if e, err := u.repo.GetByID(id); err == nil && e.CashRequestID.IsZero() {
+		u.notify.BudgetSpent(e.BudgetID)
+	}
+	return nil
+}
+
+func (u *notifyingExpenseUsecase) RejectExpense(id string, actor Domain.Actor, comment string) (*Domain.Expense, error) {
+	e, err := u.ExpenseUsecase.RejectExpense(id, actor, comment)
+	if err == nil {
+		u.notify.Rejected(Domain.EntityExpense, e.ID, e.Title, e.CreatedBy, comment)
+	}
+	return e, err
+}
@@ -0,0 +1,149 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+)
+
+// The published usecases decorate the budget, cash request, expense and fiscal
+// usecases and queue a webhook event for every change an integration may want
+// to react to. The event data is the record as it was saved.
+
+type publishedBudgetUsecase struct {
+	BudgetUsecase
+	webhooks WebhookUsecase
+}
+
+func NewPublishedBudgetUsecase(inner BudgetUsecase, webhooks WebhookUsecase) BudgetUsecase {
+	return &publishedBudgetUsecase{BudgetUsecase: inner, webhooks: webhooks}
+}
+
+func (u *publishedBudgetUsecase) CreateBudget(input *Domain.Budget, actor Domain.Actor) (*Domain.Budget, error) {
+	b, err := u.BudgetUsecase.CreateBudget(input, actor)
+	if err == nil {
+		u.webhooks.Publish(Domain.EventBudgetCreated, b)
+	}
+	return b, err
+}
+
+// ApproveBudget publishes only once the whole approval chain has signed off
+func (u *publishedBudgetUsecase) ApproveBudget(id string, actor Domain.Actor, comment string) (*Domain.Budget, error) {
+	b, err := u.BudgetUsecase.ApproveBudget(id, actor, comment)
+	if err == nil && b.Status == Domain.StatusApproved {
+		u.webhooks.Publish(Domain.EventBudgetApproved, b)
+	}
+	return b, err
+}
+
+func (u *publishedBudgetUsecase) RejectBudget(id string, actor Domain.Actor, comment string) (*Domain.Budget, error) {
+	b, err := u.BudgetUsecase.RejectBudget(id, actor, comment)
+	if err == nil && b.Status == Domain.StatusRejected {
+		u.webhooks.Publish(Domain.EventBudgetRejected, b)
+	}
+	return b, err
+}
+
+type publishedCashRequestUsecase struct {
+	CashRequestUsecase
+	repo     Repositories.CashRequestRepository
+	webhooks WebhookUsecase
+}
+
+func NewPublishedCashRequestUsecase(inner CashRequestUsecase, repo Repositories.CashRequestRepository, webhooks WebhookUsecase) CashRequestUsecase {
+	return &publishedCashRequestUsecase{CashRequestUsecase: inner, repo: repo, webhooks: webhooks}
+}
+
+func (u *publishedCashRequestUsecase) CreateCashRequest(input *Domain.CashRequest, actor Domain.Actor) (*Domain.CashRequest, error) {
+	r, err := u.CashRequestUsecase.CreateCashRequest(input, actor)
+	if err == nil {
+		u.webhooks.Publish(Domain.EventCashRequestCreated, r)
+	}
+	return r, err
+}
+
+func (u *publishedCashRequestUsecase) ApproveCashRequest(id string, actor Domain.Actor, comment string) (*Domain.CashRequest, error) {
+	r, err := u.CashRequestUsecase.ApproveCashRequest(id, actor, comment)
+	if err == nil && r.Status == Domain.StatusApproved {
+		u.webhooks.Publish(Domain.EventCashRequestApproved, r)
+	}
+	return r, err
+}
+
+func (u *publishedCashRequestUsecase) RejectCashRequest(id string, actor Domain.Actor, comment string) (*Domain.CashRequest, error) {
+	r, err := u.CashRequestUsecase.RejectCashRequest(id, actor, comment)
+	if err == nil && r.Status == Domain.StatusRejected {
+		u.webhooks.Publish(Domain.EventCashRequestRejected, r)
+	}
+	return r, err
+}
+
+func (u *publishedCashRequestUsecase) DisburseCashRequest(id string, actor Domain.Actor) error {
+	if err := u.CashRequestUsecase.DisburseCashRequest(id, actor); err != nil {
+		return err
+	}
+	if r, err := u.repo.GetByID(id); err == nil {
+		u.webhooks.Publish(Domain.EventCashRequestDisbursed, r)
+	}
+	return nil
+}
+
+func (u *publishedCashRequestUsecase) SettleCashRequest(id string, actor Domain.Actor) (*Domain.CashRequest, error) {
+	r, err := u.CashRequestUsecase.SettleCashRequest(id, actor)
+	if err == nil {
+		u.webhooks.Publish(Domain.EventCashRequestSettled, r)
+	}
+	return r, err
+}
+
+type publishedExpenseUsecase struct {
+	ExpenseUsecase
+	repo     Repositories.ExpenseRepository
+	webhooks WebhookUsecase
+}
+
+func NewPublishedExpenseUsecase(inner ExpenseUsecase, repo Repositories.ExpenseRepository, webhooks WebhookUsecase) ExpenseUsecase {
+	return &publishedExpenseUsecase{ExpenseUsecase: inner, repo: repo, webhooks: webhooks}
+}
+
+func (u *publishedExpenseUsecase) CreateExpense(input *Domain.Expense, actor Domain.Actor) (*Domain.Expense, error) {
+	e, err := u.ExpenseUsecase.CreateExpense(input, actor)
+	if err == nil {
+		u.webhooks.Publish(Domain.EventExpenseCreated, e)
+	}
+	return e, err
+}
+
+func (u *publishedExpenseUsecase) VerifyExpense(id string, actor Domain.Actor) error {
+	if err := u.ExpenseUsecase.VerifyExpense(id, actor); err != nil {
+		return err
+	}
+	if e, err := u.repo.GetByID(id); err == nil {
+		u.webhooks.Publish(Domain.EventExpenseVerified, e)
+	}
+	return nil
+}
+
+func (u *publishedExpenseUsecase) RejectExpense(id string, actor Domain.Actor, comment string) (*Domain.Expense, error) {
+	e, err := u.ExpenseUsecase.RejectExpense(id, actor, comment)
+	if err == nil {
+		u.webhooks.Publish(Domain.EventExpenseRejected, e)
+	}
+	return e, err
+}
+
+type publishedFiscalUsecase struct {
+	FiscalUsecase
+	webhooks WebhookUsecase
+}
+
+func NewPublishedFiscalUsecase(inner FiscalUsecase, webhooks WebhookUsecase) FiscalUsecase {
+	return &publishedFiscalUsecase{FiscalUsecase: inner, webhooks: webhooks}
+}
+
+func (u *publishedFiscalUsecase) ClosePeriod(id string, carryForward bool, actor Domain.Actor) (*Domain.PeriodClose, error) {
+	result, err := u.FiscalUsecase.ClosePeriod(id, carryForward, actor)
+	if err == nil {
+		u.webhooks.Publish(Domain.EventFiscalPeriodClosed, result)
+	}
+	return result, err
+}
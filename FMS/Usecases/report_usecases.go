@@ -2,47 +2,254 @@ package Usecases
 
 import (
 	"FMS/Domain"
+	"FMS/Infrastructure"
 	"FMS/Repositories"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ReportUsecase interface {
-	GetOverview() (map[string]interface{}, error)
-	GetBudgetReport() ([]Domain.Budget, error)
-	GetCashRequestReport() ([]Domain.CashRequest, error)
-	GetExpenseReport() ([]Domain.Expense, error)
+	GetOverview(filter Domain.ReportFilter) (*Domain.ReportOverview, error)
+	GetBudgetReport(filter Domain.ReportFilter) (*Domain.BudgetReport, error)
+	GetCashRequestReport(filter Domain.ReportFilter) (*Domain.SpendReport, error)
+	GetExpenseReport(filter Domain.ReportFilter) (*Domain.SpendReport, error)
+	ExportOverview(filter Domain.ReportFilter, exp Infrastructure.Exporter) error
+	ExportBudgets(filter Domain.ReportFilter, exp Infrastructure.Exporter) error
+	ExportCashRequests(filter Domain.ReportFilter, exp Infrastructure.Exporter) error
+	ExportExpenses(filter Domain.ReportFilter, exp Infrastructure.Exporter) error
 }
 
+// export columns in order; every format uses the same order
+var (
+	overviewExportColumns = []Infrastructure.Column{
+		{Name: "Metric"}, {Name: "Count", Kind: Infrastructure.ColumnCount}, {Name: "Amount", Kind: Infrastructure.ColumnAmount},
+	}
+	budgetExportColumns = []Infrastructure.Column{
+		{Name: "ID"}, {Name: "Title"}, {Name: "Department"}, {Name: "Status"},
+		{Name: "Amount", Kind: Infrastructure.ColumnAmount}, {Name: "Remaining", Kind: Infrastructure.ColumnAmount}, {Name: "Spent", Kind: Infrastructure.ColumnAmount},
+		{Name: "Currency"}, {Name: "Base Amount", Kind: Infrastructure.ColumnAmount}, {Name: "Due Date"}, {Name: "Created By"}, {Name: "Created At"},
+	}
+	cashRequestExportColumns = []Infrastructure.Column{
+		{Name: "ID"}, {Name: "Title"}, {Name: "Budget ID"}, {Name: "Requester"}, {Name: "Status"},
+		{Name: "Amount", Kind: Infrastructure.ColumnAmount}, {Name: "Currency"}, {Name: "Base Amount", Kind: Infrastructure.ColumnAmount}, {Name: "Created At"},
+	}
+	expenseExportColumns = []Infrastructure.Column{
+		{Name: "ID"}, {Name: "Title"}, {Name: "Budget ID"}, {Name: "Created By"}, {Name: "Status"},
+		{Name: "Amount", Kind: Infrastructure.ColumnAmount}, {Name: "Currency"}, {Name: "Base Amount", Kind: Infrastructure.ColumnAmount}, {Name: "Created At"},
+	}
+)
+
 type reportUsecase struct {
-	budgetRepo      Repositories.BudgetRepository
-	cashRequestRepo Repositories.CashRequestRepository
-	expenseRepo     Repositories.ExpenseRepository
+	statsRepo  Repositories.ReportStatsRepository
+	currencies CurrencyUsecase
 }
 
-func NewReportUsecase(b Repositories.BudgetRepository, c Repositories.CashRequestRepository, e Repositories.ExpenseRepository) ReportUsecase {
-	return &reportUsecase{budgetRepo: b, cashRequestRepo: c, expenseRepo: e}
+func NewReportUsecase(stats Repositories.ReportStatsRepository, currencies CurrencyUsecase) ReportUsecase {
+	return &reportUsecase{statsRepo: stats, currencies: currencies}
 }
 
-func (u *reportUsecase) GetOverview() (map[string]interface{}, error) {
-	budgets, _ := u.budgetRepo.GetAll()
-	cashReqs, _ := u.cashRequestRepo.GetAll()
-	expenses, _ := u.expenseRepo.GetAll()
+func (u *reportUsecase) GetOverview(filter Domain.ReportFilter) (*Domain.ReportOverview, error) {
+	if err := validateReportFilter(filter); err != nil {
+		return nil, err
+	}
+	o, err := u.statsRepo.Overview(filter)
+	if err != nil {
+		return nil, err
+	}
+	o.BaseCurrency = u.currencies.BaseCurrency()
+	return o, nil
+}
 
-	overview := map[string]interface{}{
-		"budgets_count":       len(budgets),
-		"cash_requests_count": len(cashReqs),
-		"expenses_count":      len(expenses),
+func (u *reportUsecase) GetBudgetReport(filter Domain.ReportFilter) (*Domain.BudgetReport, error) {
+	if err := validateReportFilter(filter); err != nil {
+		return nil, err
 	}
-	return overview, nil
+	r, err := u.statsRepo.BudgetStats(filter)
+	if err != nil {
+		return nil, err
+	}
+	r.BaseCurrency = u.currencies.BaseCurrency()
+	r.ByCurrency = foldUntrackedCurrency(r.ByCurrency, r.BaseCurrency)
+	return r, nil
 }
 
-func (u *reportUsecase) GetBudgetReport() ([]Domain.Budget, error) {
-	return u.budgetRepo.GetAll()
+func (u *reportUsecase) GetCashRequestReport(filter Domain.ReportFilter) (*Domain.SpendReport, error) {
+	if err := validateReportFilter(filter); err != nil {
+		return nil, err
+	}
+	return u.spendReport(u.statsRepo.CashRequestStats(filter))
 }
 
-func (u *reportUsecase) GetCashRequestReport() ([]Domain.CashRequest, error) {
-	return u.cashRequestRepo.GetAll()
+func (u *reportUsecase) GetExpenseReport(filter Domain.ReportFilter) (*Domain.SpendReport, error) {
+	if err := validateReportFilter(filter); err != nil {
+		return nil, err
+	}
+	return u.spendReport(u.statsRepo.ExpenseStats(filter))
 }
 
-func (u *reportUsecase) GetExpenseReport() ([]Domain.Expense, error) {
-	return u.expenseRepo.GetAll()
+func (u *reportUsecase) spendReport(r *Domain.SpendReport, err error) (*Domain.SpendReport, error) {
+	if err != nil {
+		return nil, err
+	}
+	r.BaseCurrency = u.currencies.BaseCurrency()
+	r.ByCurrency = foldUntrackedCurrency(r.ByCurrency, r.BaseCurrency)
+	return r, nil
+}
+
+// foldUntrackedCurrency merges the group of records stored without a currency,
+// which predate currency tracking and are in the base currency, into the base group
+func foldUntrackedCurrency(groups []Domain.GroupTotal, base string) []Domain.GroupTotal {
+	var untracked *Domain.GroupTotal
+	out := make([]Domain.GroupTotal, 0, len(groups))
+	for i := range groups {
+		if groups[i].Key == "" {
+			untracked = &groups[i]
+			continue
+		}
+		out = append(out, groups[i])
+	}
+	if untracked == nil {
+		return groups
+	}
+	for i := range out {
+		if out[i].Key == base {
+			out[i].Count += untracked.Count
+			out[i].Total += untracked.Total
+			out[i].BaseTotal += untracked.BaseTotal
+			return out
+		}
+	}
+	untracked.Key = base
+	out = append(out, *untracked)
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func (u *reportUsecase) ExportOverview(filter Domain.ReportFilter, exp Infrastructure.Exporter) error {
+	o, err := u.GetOverview(filter)
+	if err != nil {
+		return err
+	}
+	if err := exp.WriteHeader(overviewExportColumns); err != nil {
+		return err
+	}
+	// counts and amounts each keep to their own column; totals are in the base currency
+	in := " (" + o.BaseCurrency + ")"
+	rows := [][]string{
+		{"Budgets", strconv.FormatInt(o.BudgetsCount, 10), ""},
+		{"Cash Requests", strconv.FormatInt(o.CashRequestsCount, 10), ""},
+		{"Expenses", strconv.FormatInt(o.ExpensesCount, 10), ""},
+		{"Total Allocated" + in, "", Infrastructure.FormatCurrency(o.TotalAllocated.Minor())},
+		{"Total Remaining" + in, "", Infrastructure.FormatCurrency(o.TotalRemaining.Minor())},
+		{"Total Disbursed" + in, "", Infrastructure.FormatCurrency(o.TotalDisbursed.Minor())},
+		{"Total Verified Spend" + in, "", Infrastructure.FormatCurrency(o.TotalVerifiedSpend.Minor())},
+	}
+	for _, row := range rows {
+		if err := exp.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *reportUsecase) ExportBudgets(filter Domain.ReportFilter, exp Infrastructure.Exporter) error {
+	if err := validateReportFilter(filter); err != nil {
+		return err
+	}
+	if err := exp.WriteHeader(budgetExportColumns); err != nil {
+		return err
+	}
+	return u.statsRepo.EachBudget(filter, func(b *Domain.Budget) error {
+		return exp.WriteRow([]string{
+			b.ID.Hex(),
+			b.Title,
+			b.Department,
+			b.Status,
+			Infrastructure.FormatCurrency(b.Amount.Minor()),
+			Infrastructure.FormatCurrency(b.Remaining.Minor()),
+			Infrastructure.FormatCurrency((b.Amount - b.Remaining - b.CarriedOut).Minor()),
+			u.exportCurrency(b.Currency),
+			Infrastructure.FormatCurrency(inBase(b.Amount, b.ExchangeRate).Minor()),
+			formatExportDate(b.DueDate),
+			b.CreatedBy,
+			formatExportDate(b.CreatedAt),
+		})
+	})
+}
+
+func (u *reportUsecase) ExportCashRequests(filter Domain.ReportFilter, exp Infrastructure.Exporter) error {
+	if err := validateReportFilter(filter); err != nil {
+		return err
+	}
+	if err := exp.WriteHeader(cashRequestExportColumns); err != nil {
+		return err
+	}
+	return u.statsRepo.EachCashRequest(filter, func(cr *Domain.CashRequest) error {
+		return exp.WriteRow([]string{
+			cr.ID.Hex(),
+			cr.Title,
+			formatExportID(cr.BudgetID),
+			cr.Requester,
+			cr.Status,
+			Infrastructure.FormatCurrency(cr.Amount.Minor()),
+			u.exportCurrency(cr.Currency),
+			Infrastructure.FormatCurrency(inBase(cr.Amount, cr.ExchangeRate).Minor()),
+			formatExportDate(cr.CreatedAt),
+		})
+	})
+}
+
+func (u *reportUsecase) ExportExpenses(filter Domain.ReportFilter, exp Infrastructure.Exporter) error {
+	if err := validateReportFilter(filter); err != nil {
+		return err
+	}
+	if err := exp.WriteHeader(expenseExportColumns); err != nil {
+		return err
+	}
+	return u.statsRepo.EachExpense(filter, func(e *Domain.Expense) error {
+		return exp.WriteRow([]string{
+			e.ID.Hex(),
+			e.Title,
+			formatExportID(e.BudgetID),
+			e.CreatedBy,
+			e.Status,
+			Infrastructure.FormatCurrency(e.Amount.Minor()),
+			u.exportCurrency(e.Currency),
+			Infrastructure.FormatCurrency(inBase(e.Amount, e.ExchangeRate).Minor()),
+			formatExportDate(e.CreatedAt),
+		})
+	})
+}
+
+// exportCurrency reports records stored without a currency in the base currency
+func (u *reportUsecase) exportCurrency(code string) string {
+	if code == "" {
+		return u.currencies.BaseCurrency()
+	}
+	return code
+}
+
+func formatExportDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+func formatExportID(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
+
+func validateReportFilter(filter Domain.ReportFilter) error {
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return errors.New("to must not be before from")
+	}
+	return nil
 }
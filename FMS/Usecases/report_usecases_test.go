@@ -1,52 +1,120 @@
 package Usecases
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"FMS/Domain"
-)
-
-type mockBudgetRepoSimple struct{ store []Domain.Budget }
+	"FMS/Infrastructure"
 
-func (m *mockBudgetRepoSimple) Create(t *Domain.Budget) error             { return nil }
-func (m *mockBudgetRepoSimple) GetAll() ([]Domain.Budget, error)          { return m.store, nil }
-func (m *mockBudgetRepoSimple) GetByID(id string) (*Domain.Budget, error) { return nil, nil }
-func (m *mockBudgetRepoSimple) Update(id string, t *Domain.Budget) error  { return nil }
-func (m *mockBudgetRepoSimple) Delete(id string) error                    { return nil }
-
-type mockCashRepoSimple struct{ store []Domain.CashRequest }
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
-func (m *mockCashRepoSimple) Create(t *Domain.CashRequest) error             { return nil }
-func (m *mockCashRepoSimple) GetAll() ([]Domain.CashRequest, error)          { return m.store, nil }
-func (m *mockCashRepoSimple) GetByID(id string) (*Domain.CashRequest, error) { return nil, nil }
-func (m *mockCashRepoSimple) Update(id string, t *Domain.CashRequest) error  { return nil }
-func (m *mockCashRepoSimple) Delete(id string) error                         { return nil }
+type mockReportStatsRepo struct {
+	overview *Domain.ReportOverview
+	budgets  []Domain.Budget
+	err      error
+}
 
-type mockExpenseRepoSimple struct{ store []Domain.Expense }
+func (m *mockReportStatsRepo) Overview(f Domain.ReportFilter) (*Domain.ReportOverview, error) {
+	return m.overview, m.err
+}
+func (m *mockReportStatsRepo) BudgetStats(f Domain.ReportFilter) (*Domain.BudgetReport, error) {
+	return &Domain.BudgetReport{}, m.err
+}
+func (m *mockReportStatsRepo) CashRequestStats(f Domain.ReportFilter) (*Domain.SpendReport, error) {
+	return &Domain.SpendReport{}, m.err
+}
+func (m *mockReportStatsRepo) ExpenseStats(f Domain.ReportFilter) (*Domain.SpendReport, error) {
+	return &Domain.SpendReport{}, m.err
+}
 
-func (m *mockExpenseRepoSimple) Create(t *Domain.Expense) error             { return nil }
-func (m *mockExpenseRepoSimple) GetAll() ([]Domain.Expense, error)          { return m.store, nil }
-func (m *mockExpenseRepoSimple) GetByID(id string) (*Domain.Expense, error) { return nil, nil }
-func (m *mockExpenseRepoSimple) Update(id string, t *Domain.Expense) error  { return nil }
-func (m *mockExpenseRepoSimple) Delete(id string) error                     { return nil }
+func (m *mockReportStatsRepo) EachBudget(f Domain.ReportFilter, fn func(*Domain.Budget) error) error {
+	for i := range m.budgets {
+		if err := fn(&m.budgets[i]); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+func (m *mockReportStatsRepo) EachCashRequest(f Domain.ReportFilter, fn func(*Domain.CashRequest) error) error {
+	return m.err
+}
+func (m *mockReportStatsRepo) EachExpense(f Domain.ReportFilter, fn func(*Domain.Expense) error) error {
+	return m.err
+}
 
 func TestReportUsecase_GetOverview(t *testing.T) {
-	b := mockBudgetRepoSimple{store: []Domain.Budget{{}}}
-	c := mockCashRepoSimple{store: []Domain.CashRequest{{}, {}}}
-	e := mockExpenseRepoSimple{store: []Domain.Expense{{}}}
+	stats := &mockReportStatsRepo{overview: &Domain.ReportOverview{BudgetsCount: 1, CashRequestsCount: 2, ExpensesCount: 1}}
 
-	ru := NewReportUsecase(&b, &c, &e)
-	o, err := ru.GetOverview()
+	ru := NewReportUsecase(stats, testCurrencies())
+	o, err := ru.GetOverview(Domain.ReportFilter{})
 	if err != nil {
 		t.Fatalf("overview failed: %v", err)
 	}
-	if o["budgets_count"].(int) != 1 {
+	if o.BudgetsCount != 1 {
 		t.Fatalf("budgets_count expected 1")
 	}
-	if o["cash_requests_count"].(int) != 2 {
+	if o.CashRequestsCount != 2 {
 		t.Fatalf("cash_requests_count expected 2")
 	}
-	if o["expenses_count"].(int) != 1 {
+	if o.ExpensesCount != 1 {
 		t.Fatalf("expenses_count expected 1")
 	}
 }
+
+func TestReportUsecase_PropagatesErrors(t *testing.T) {
+	ru := NewReportUsecase(&mockReportStatsRepo{err: errors.New("db down")}, testCurrencies())
+	if _, err := ru.GetOverview(Domain.ReportFilter{}); err == nil {
+		t.Fatalf("expected repository error to surface")
+	}
+}
+
+func TestReportUsecase_RejectsInvertedRange(t *testing.T) {
+	ru := NewReportUsecase(&mockReportStatsRepo{}, testCurrencies())
+	now := time.Now()
+	if _, err := ru.GetBudgetReport(Domain.ReportFilter{From: now, To: now.Add(-time.Hour)}); err == nil {
+		t.Fatalf("expected error for inverted date range")
+	}
+}
+
+// recordingExporter keeps written rows so column order can be asserted
+type recordingExporter struct{ rows [][]string }
+
+func (r *recordingExporter) ContentType() string { return "text/plain" }
+func (r *recordingExporter) Extension() string   { return "txt" }
+func (r *recordingExporter) WriteHeader(columns []Infrastructure.Column) error {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	r.rows = append(r.rows, names)
+	return nil
+}
+func (r *recordingExporter) WriteRow(values []string) error {
+	r.rows = append(r.rows, values)
+	return nil
+}
+func (r *recordingExporter) Close() error { return nil }
+
+func TestReportUsecase_ExportBudgetsStreamsRows(t *testing.T) {
+	stats := &mockReportStatsRepo{budgets: []Domain.Budget{
+		{ID: primitive.NewObjectID(), Title: "Travel", Department: "ops", Status: "approved", Amount: Domain.NewMoney(12500, 0), Remaining: Domain.NewMoney(2500, 50)},
+	}}
+	exp := &recordingExporter{}
+
+	if err := NewReportUsecase(stats, testCurrencies()).ExportBudgets(Domain.ReportFilter{}, exp); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if len(exp.rows) != 2 {
+		t.Fatalf("expected header and one row, got %d", len(exp.rows))
+	}
+	if exp.rows[0][0] != "ID" || exp.rows[0][4] != "Amount" || exp.rows[0][5] != "Remaining" {
+		t.Fatalf("unexpected column order: %v", exp.rows[0])
+	}
+	row := exp.rows[1]
+	if row[4] != "12,500.00" || row[5] != "2,500.50" || row[6] != "9,999.50" {
+		t.Fatalf("unexpected currency formatting: %v", row)
+	}
+}
@@ -0,0 +1,85 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchLength    = 200
+)
+
+// searchableTypes are the entity types GET /search covers, in result tie order
+var searchableTypes = []string{Domain.EntityBudget, Domain.EntityCashRequest, Domain.EntityExpense}
+
+// SearchUsecase finds budgets, cash requests and expenses by the words in
+// their title and description
+type SearchUsecase interface {
+	Search(q Domain.SearchQuery, actor Domain.Actor) ([]Domain.SearchResult, error)
+}
+
+type searchUsecase struct {
+	repo       Repositories.SearchRepository
+	budgetRepo Repositories.BudgetRepository
+}
+
+func NewSearchUsecase(repo Repositories.SearchRepository, budgetRepo Repositories.BudgetRepository) SearchUsecase {
+	return &searchUsecase{repo: repo, budgetRepo: budgetRepo}
+}
+
+// Search ranks matches across the requested types. Callers only find what they
+// could list: finance everything, department heads their department's records
+// and everyone their own.
+func (u *searchUsecase) Search(q Domain.SearchQuery, actor Domain.Actor) ([]Domain.SearchResult, error) {
+	q.Text = strings.TrimSpace(q.Text)
+	if q.Text == "" {
+		return nil, fmt.Errorf("%w: q is required", Domain.ErrInvalidQuery)
+	}
+	if len(q.Text) > maxSearchLength {
+		return nil, fmt.Errorf("%w: q is longer than %d characters", Domain.ErrInvalidQuery, maxSearchLength)
+	}
+	if q.Limit < 1 {
+		q.Limit = defaultSearchLimit
+	}
+	if q.Limit > maxSearchLimit {
+		q.Limit = maxSearchLimit
+	}
+
+	types := searchableTypes
+	if len(q.Types) > 0 {
+		types = nil
+		for _, t := range searchableTypes {
+			if containsString(q.Types, t) {
+				types = append(types, t)
+			}
+		}
+		for _, t := range q.Types {
+			if !containsString(searchableTypes, t) {
+				return nil, fmt.Errorf("%w: cannot search %s", Domain.ErrInvalidQuery, t)
+			}
+		}
+	}
+	q.Types = types
+
+	q.Scopes = map[string]*Domain.ListScope{Domain.EntityBudget: budgetScope(actor)}
+	spend, err := spendScope(u.budgetRepo, actor)
+	if err != nil {
+		return nil, err
+	}
+	q.Scopes[Domain.EntityCashRequest] = spend
+	q.Scopes[Domain.EntityExpense] = spend
+	return u.repo.Search(q)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
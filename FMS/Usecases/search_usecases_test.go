@@ -0,0 +1,111 @@
+package Usecases
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"FMS/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mockSearchRepo scores a record by how many query words its title (three
+// times) and description contain, standing in for Mongo's text score
+type mockSearchRepo struct {
+	budgets  *mockBudgetRepo
+	cash     *mockCashRepo
+	expenses *mockExpenseRepo
+}
+
+func (m *mockSearchRepo) Search(q Domain.SearchQuery) ([]Domain.SearchResult, error) {
+	score := func(title, description string) float64 {
+		s := 0.0
+		for _, w := range strings.Fields(strings.ToLower(q.Text)) {
+			s += 3*float64(strings.Count(strings.ToLower(title), w)) + float64(strings.Count(strings.ToLower(description), w))
+		}
+		return s
+	}
+	visible := func(scope *Domain.ListScope, owner, department string, budgetID primitive.ObjectID) bool {
+		return scope == nil || owner == scope.OwnerID || (scope.Department != "" && department == scope.Department) || containsID(scope.BudgetIDs, budgetID)
+	}
+
+	results := []Domain.SearchResult{}
+	add := func(entity string, id primitive.ObjectID, title, description string) {
+		if s := score(title, description); s > 0 {
+			results = append(results, Domain.SearchResult{Type: entity, ID: id, Title: title, Score: s})
+		}
+	}
+	for _, t := range q.Types {
+		switch t {
+		case Domain.EntityBudget:
+			for _, b := range m.budgets.store {
+				if visible(q.Scopes[t], b.CreatedBy, b.Department, primitive.NilObjectID) {
+					add(t, b.ID, b.Title, b.Description)
+				}
+			}
+		case Domain.EntityCashRequest:
+			for _, r := range m.cash.store {
+				if visible(q.Scopes[t], r.Requester, "", r.BudgetID) {
+					add(t, r.ID, r.Title, r.Description)
+				}
+			}
+		case Domain.EntityExpense:
+			for _, e := range m.expenses.store {
+				if visible(q.Scopes[t], e.CreatedBy, "", e.BudgetID) {
+					add(t, e.ID, e.Title, e.Description)
+				}
+			}
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+func TestSearch_RanksAndRespectsVisibility(t *testing.T) {
+	budgets, cash, expenses := newMockBudgetRepo(), newMockCashRepo(), newMockExpenseRepo()
+	uc := NewSearchUsecase(&mockSearchRepo{budgets: budgets, cash: cash, expenses: expenses}, budgets)
+
+	ops := &Domain.Budget{Title: "Ops travel", Department: "ops", CreatedBy: "u2", Status: Domain.StatusApproved}
+	_ = budgets.Create(ops)
+	_ = expenses.Create(&Domain.Expense{Title: "Conference travel", Description: "March conference, travel and hotel", CreatedBy: "u1", BudgetID: ops.ID})
+	_ = expenses.Create(&Domain.Expense{Title: "Taxi", Description: "travel to the airport", CreatedBy: "u3", BudgetID: ops.ID})
+	_ = cash.Create(&Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Conference per diem", Requester: "u3", BudgetID: primitive.NewObjectID()})
+
+	all, err := uc.Search(Domain.SearchQuery{Text: "conference travel"}, financeActor)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(all) != 4 || all[0].Title != "Conference travel" || all[0].Type != Domain.EntityExpense {
+		t.Fatalf("expected 4 ranked results led by the conference expense, got %+v", all)
+	}
+
+	own, _ := uc.Search(Domain.SearchQuery{Text: "travel"}, staffActor("u1"))
+	if len(own) != 1 || own[0].Title != "Conference travel" {
+		t.Fatalf("staff should only find their own records, got %+v", own)
+	}
+
+	head := Domain.Actor{UserID: "u9", Department: "Ops", DepartmentHead: true}
+	dept, _ := uc.Search(Domain.SearchQuery{Text: "travel"}, head)
+	if len(dept) != 3 {
+		t.Fatalf("a department head should find the ops budget and its expenses, got %+v", dept)
+	}
+
+	typed, _ := uc.Search(Domain.SearchQuery{Text: "conference", Types: []string{Domain.EntityCashRequest}}, financeActor)
+	if len(typed) != 1 || typed[0].Type != Domain.EntityCashRequest {
+		t.Fatalf("expected only the cash request, got %+v", typed)
+	}
+	if limited, _ := uc.Search(Domain.SearchQuery{Text: "travel", Limit: 2}, financeActor); len(limited) != 2 {
+		t.Fatalf("expected the limit to apply, got %d", len(limited))
+	}
+
+	for _, q := range []Domain.SearchQuery{{Text: "   "}, {Text: "travel", Types: []string{"user"}}, {Text: strings.Repeat("x", 201)}} {
+		if _, err := uc.Search(q, financeActor); !errors.Is(err, Domain.ErrInvalidQuery) {
+			t.Fatalf("expected ErrInvalidQuery for %+v, got %v", q, err)
+		}
+	}
+}
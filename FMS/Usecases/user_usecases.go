@@ -5,33 +5,122 @@ import (
 	"FMS/Infrastructure"
 	"FMS/Repositories"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// default and maximum page sizes for user listings
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+// Access tokens are checked without a lookup of the session, so they are kept
+// short; refresh tokens rotate on every use.
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// two-factor authentication settings
+const (
+	totpIssuer = "FMS"
+	// a login challenge is answered within mfaChallengeTTL and mfaMaxAttempts
+	// tries, or the password has to be entered again
+	mfaChallengeTTL   = 5 * time.Minute
+	mfaMaxAttempts    = 5
+	recoveryCodeCount = 10
+)
+
+// Failed logins are counted per username and per client address for
+// loginFailureWindow. After freeLoginAttempts each failure doubles the wait
+// before the next attempt, up to maxLoginDelay, and at the lockout thresholds
+// the username or address is locked for loginLockout or until an admin
+// unlocks it. An address gets more failures than a username since users
+// behind one NAT share it.
+const (
+	loginFailureWindow   = 15 * time.Minute
+	freeLoginAttempts    = 3
+	baseLoginDelay       = time.Second
+	maxLoginDelay        = 30 * time.Second
+	usernameLockoutAfter = 10
+	addressLockoutAfter  = 50
+	loginLockout         = 15 * time.Minute
+)
+
+// password policy
+const (
+	MinPasswordLength = 10
+	// bcrypt only reads the first 72 bytes
+	maxPasswordBytes = 72
 )
 
 type UserUsecase interface {
-	Register(username, password string) (*Domain.User, error)
-	Login(username, password string) (string, error) // returns jwt token
-	Promote(username string) error
+	Register(username, password, department string) (*Domain.User, error)
+	// Login returns a token pair or, when the user has two-factor
+	// authentication on, a challenge for CompleteLogin. clientIP may be empty
+	// when the caller's address is unknown.
+	Login(username, password, clientIP string) (*Domain.LoginResult, error)
+	// CompleteLogin answers a login challenge with a TOTP or recovery code. A
+	// wrong code counts as a failed login of the user and clientIP.
+	CompleteLogin(mfaToken, code, clientIP string) (*Domain.TokenPair, error)
+	// Refresh exchanges a refresh token for a new pair
+	Refresh(refreshToken string) (*Domain.TokenPair, error)
+	// Logout revokes the session of refreshToken, or every session of its user
+	Logout(refreshToken string, everywhere bool) error
+	ListUsers(q Domain.UserQuery) (*Domain.UserPage, error)
+	GetUser(id string) (*Domain.User, error)
+	SetRole(id, role string, actor Domain.Actor) error
+	SetDepartment(id, department string, head bool, actor Domain.Actor) error
+	Deactivate(id string, actor Domain.Actor) error
+	Reactivate(id string, actor Domain.Actor) error
+	ResetPassword(id, password string, actor Domain.Actor) error
+	// Unlock lifts a lockout after too many failed logins
+	Unlock(id string, actor Domain.Actor) error
+	// SetupTOTP creates a new TOTP secret; it takes effect once EnableTOTP confirms a code
+	SetupTOTP(userID string) (*Domain.TOTPSetup, error)
+	// EnableTOTP turns two-factor authentication on for the actor and returns the recovery codes
+	EnableTOTP(code string, actor Domain.Actor) ([]string, error)
+	DisableTOTP(code string, actor Domain.Actor) error
+	// ResetTOTP turns two-factor authentication off for a user who lost their device
+	ResetTOTP(userID string, actor Domain.Actor) error
+	// TokenStatus lets AuthMiddleware reject tokens of deactivated users and revoked tokens
+	TokenStatus(userID string) (bool, int64, error)
 }
 
 type userUsecase struct {
 	userRepo Repositories.UserRepository
 	pw       Infrastructure.PasswordService
 	jwt      Infrastructure.JWTService
+	tokens   Repositories.RefreshTokenRepository
+	attempts Repositories.LoginAttemptRepository
+	breached Infrastructure.BreachedPasswords // nil skips the breached-password check
 }
 
-func NewUserUsecase(r Repositories.UserRepository, pw Infrastructure.PasswordService, jwt Infrastructure.JWTService) UserUsecase {
-	return &userUsecase{userRepo: r, pw: pw, jwt: jwt}
+func NewUserUsecase(r Repositories.UserRepository, pw Infrastructure.PasswordService, jwt Infrastructure.JWTService, tokens Repositories.RefreshTokenRepository, attempts Repositories.LoginAttemptRepository, breached Infrastructure.BreachedPasswords) UserUsecase {
+	return &userUsecase{userRepo: r, pw: pw, jwt: jwt, tokens: tokens, attempts: attempts, breached: breached}
 }
 
-func (u *userUsecase) Register(username, password string) (*Domain.User, error) {
+func (u *userUsecase) Register(username, password, department string) (*Domain.User, error) {
 	if username == "" || password == "" {
 		return nil, errors.New("username and password required")
 	}
+	department = normalizeDepartment(department)
+	// the finance department passes FinanceOnly, so only an admin may grant it
+	if department == Domain.FinanceDepartment {
+		return nil, errors.New("finance department must be assigned by an admin")
+	}
 
 	if _, err := u.userRepo.FindByUsername(username); err == nil {
 		return nil, errors.New("username already exists")
 	}
+	if err := u.checkPasswordPolicy(username, password); err != nil {
+		return nil, err
+	}
 	hash, err := u.pw.Hash(password)
 	if err != nil {
 		return nil, err
@@ -40,12 +129,13 @@ func (u *userUsecase) Register(username, password string) (*Domain.User, error)
 	user := &Domain.User{
 		Username:     username,
 		PasswordHash: hash,
-		Role:         "user",
+		Role:         Domain.RoleStaff,
+		Department:   department,
 		CreatedAt:    time.Now().UTC(),
 	}
 	// if no users => admin
 	if c, _ := u.userRepo.Count(); c == 0 {
-		user.Role = "admin"
+		user.Role = Domain.RoleAdmin
 	}
 	if err := u.userRepo.Create(user); err != nil {
 		return nil, err
@@ -54,22 +144,505 @@ func (u *userUsecase) Register(username, password string) (*Domain.User, error)
 	return user, nil
 }
 
-func (u *userUsecase) Login(username, password string) (string, error) {
+// Login starts a new session: a fresh refresh-token family and its first pair.
+// A user with two-factor authentication gets a challenge instead, whose token
+// names the user so CompleteLogin can find the stored hash.
+func (u *userUsecase) Login(username, password, clientIP string) (*Domain.LoginResult, error) {
+	keys := loginThrottleKeys(username, clientIP)
+	now := time.Now().UTC()
+	if err := u.checkThrottle(keys, now); err != nil {
+		return nil, err
+	}
 	user, err := u.userRepo.FindByUsername(username)
+	if err == nil {
+		err = u.pw.Compare(user.PasswordHash, password)
+	}
+	if err != nil {
+		if err := u.recordLoginFailure(keys, now); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid credentials")
+	}
+	if user.Deactivated {
+		return nil, Domain.ErrAccountDeactivated
+	}
+	if !user.TOTPEnabled {
+		// the address keeps its count: one account of the attacker's own must
+		// not reset the count of their guesses at others
+		if err := u.attempts.Clear(keys[0].key); err != nil {
+			return nil, err
+		}
+		pair, err := u.issue(user, primitive.NewObjectID(), false)
+		if err != nil {
+			return nil, err
+		}
+		return &Domain.LoginResult{TokenPair: pair}, nil
+	}
+
+	raw, hash, err := Infrastructure.NewOpaqueToken()
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return nil, err
 	}
-	if err := u.pw.Compare(user.PasswordHash, password); err != nil {
-		return "", errors.New("invalid credentials")
+	// the password alone clears nothing: the failures and the wrong codes of a
+	// live challenge carry over until the second factor is passed, so logging
+	// in again does not buy more guesses
+	challenge := &Domain.MFAChallenge{Hash: hash, ExpiresAt: now.Add(mfaChallengeTTL)}
+	if c := user.MFAChallenge; c != nil && now.Before(c.ExpiresAt) {
+		challenge.Attempts = c.Attempts
 	}
-	// generate token
-	token, err := u.jwt.Generate(user.ID.Hex(), user.Username, user.Role, 24*time.Hour)
+	if err := u.userRepo.SetMFAChallenge(user.ID.Hex(), challenge); err != nil {
+		return nil, err
+	}
+	return &Domain.LoginResult{MFARequired: true, MFAToken: user.ID.Hex() + "." + raw}, nil
+}
+
+// loginThrottleKey is a username or address failures are counted under and
+// the failure count that locks it
+type loginThrottleKey struct {
+	key       string
+	lockAfter int
+}
+
+// loginThrottleKeys puts the username first. Unknown usernames are counted
+// too, so failures do not tell which accounts exist.
+func loginThrottleKeys(username, clientIP string) []loginThrottleKey {
+	keys := []loginThrottleKey{{key: "user:" + strings.ToLower(strings.TrimSpace(username)), lockAfter: usernameLockoutAfter}}
+	if clientIP != "" {
+		keys = append(keys, loginThrottleKey{key: "ip:" + clientIP, lockAfter: addressLockoutAfter})
+	}
+	return keys
+}
+
+// checkThrottle refuses an attempt while a key is locked or still waiting out
+// the delay earned by its last failure
+func (u *userUsecase) checkThrottle(keys []loginThrottleKey, now time.Time) error {
+	for _, k := range keys {
+		a, err := u.attempts.Get(k.key)
+		if err != nil {
+			return err
+		}
+		if now.Before(a.LockedUntil) {
+			return &Domain.ThrottleError{RetryAfter: a.LockedUntil.Sub(now), Locked: true}
+		}
+		if wait := a.LastFailure.Add(loginDelay(a, now)).Sub(now); wait > 0 {
+			return &Domain.ThrottleError{RetryAfter: wait}
+		}
+	}
+	return nil
+}
+
+// loginDelay is how long after its last failure a key must wait: nothing for
+// the first freeLoginAttempts failures of the window, then baseLoginDelay
+// doubling with every further failure
+func loginDelay(a *Domain.LoginAttempts, now time.Time) time.Duration {
+	if a.Failures < freeLoginAttempts || now.Sub(a.LastFailure) >= loginFailureWindow {
+		return 0
+	}
+	delay := baseLoginDelay
+	for i := freeLoginAttempts; i < a.Failures && delay < maxLoginDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxLoginDelay)
+}
+
+func (u *userUsecase) recordLoginFailure(keys []loginThrottleKey, now time.Time) error {
+	for _, k := range keys {
+		a, err := u.attempts.RecordFailure(k.key, now, loginFailureWindow)
+		if err != nil {
+			return err
+		}
+		if a.Failures >= k.lockAfter {
+			if err := u.attempts.Lock(k.key, now.Add(loginLockout)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkPasswordPolicy rejects short passwords, passwords bcrypt would cut
+// short, the username and passwords on the breached list
+func (u *userUsecase) checkPasswordPolicy(username, password string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return &Domain.PasswordPolicyError{Reason: fmt.Sprintf("must be at least %d characters", MinPasswordLength)}
+	}
+	if len(password) > maxPasswordBytes {
+		return &Domain.PasswordPolicyError{Reason: fmt.Sprintf("must be at most %d bytes", maxPasswordBytes)}
+	}
+	if strings.EqualFold(password, username) {
+		return &Domain.PasswordPolicyError{Reason: "must not be the username"}
+	}
+	if u.breached != nil && u.breached.Contains(password) {
+		return &Domain.PasswordPolicyError{Reason: "appears in a list of breached passwords"}
+	}
+	return nil
+}
+
+func (u *userUsecase) CompleteLogin(mfaToken, code, clientIP string) (*Domain.TokenPair, error) {
+	userID, raw, ok := strings.Cut(mfaToken, ".")
+	if !ok {
+		return nil, Domain.ErrInvalidMFACode
+	}
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, Domain.ErrInvalidMFACode
+	}
+	keys := loginThrottleKeys(user.Username, clientIP)
+	now := time.Now().UTC()
+	if err := u.checkThrottle(keys, now); err != nil {
+		return nil, err
+	}
+	c := user.MFAChallenge
+	if c == nil || c.Hash != Infrastructure.HashOpaqueToken(raw) || !now.Before(c.ExpiresAt) || c.Attempts >= mfaMaxAttempts {
+		return nil, Domain.ErrInvalidMFACode
+	}
+	if user.Deactivated {
+		return nil, Domain.ErrAccountDeactivated
+	}
+
+	passed, err := u.checkSecondFactor(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !passed {
+		if err := u.userRepo.CountMFAAttempt(userID); err != nil {
+			return nil, err
+		}
+		if err := u.recordLoginFailure(keys, now); err != nil {
+			return nil, err
+		}
+		return nil, Domain.ErrInvalidMFACode
+	}
+	if err := u.userRepo.SetMFAChallenge(userID, nil); err != nil {
+		return nil, err
+	}
+	if err := u.attempts.Clear(keys[0].key); err != nil {
+		return nil, err
+	}
+	return u.issue(user, primitive.NewObjectID(), true)
+}
+
+// checkSecondFactor accepts a TOTP code not used before, or one of the user's
+// recovery codes, which it uses up
+func (u *userUsecase) checkSecondFactor(user *Domain.User, code string) (bool, error) {
+	if ok, err := u.checkTOTP(user, code); ok || err != nil {
+		return ok, err
+	}
+	code = Infrastructure.NormalizeRecoveryCode(code)
+	for _, hash := range user.RecoveryCodes {
+		if u.pw.Compare(hash, code) == nil {
+			return u.userRepo.UseRecoveryCode(user.ID.Hex(), hash)
+		}
+	}
+	return false, nil
+}
+
+// checkTOTP accepts a code for the user's secret once; a code seen by someone
+// looking over the user's shoulder is worthless after the user has logged in
+func (u *userUsecase) checkTOTP(user *Domain.User, code string) (bool, error) {
+	if user.TOTPSecret == "" {
+		return false, nil
+	}
+	step, ok := Infrastructure.VerifyTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return u.userRepo.UseTOTPStep(user.ID.Hex(), step)
+}
+
+// Refresh uses up refreshToken and issues the next pair of its family, with
+// claims read from the user as they are now
+func (u *userUsecase) Refresh(refreshToken string) (*Domain.TokenPair, error) {
+	t, err := u.tokens.FindByHash(Infrastructure.HashOpaqueToken(refreshToken))
+	if err != nil || t.RevokedAt != nil || !time.Now().Before(t.ExpiresAt) {
+		return nil, Domain.ErrInvalidRefreshToken
+	}
+	if t.UsedAt != nil {
+		return nil, u.revokeReused(t)
+	}
+	user, err := u.userRepo.FindByID(t.UserID)
+	if err != nil {
+		return nil, Domain.ErrInvalidRefreshToken
+	}
+	if user.Deactivated {
+		return nil, Domain.ErrAccountDeactivated
+	}
+	used, err := u.tokens.Use(t.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	// another refresh with the same token got there first
+	if !used {
+		return nil, u.revokeReused(t)
+	}
+	// the mfa claim comes from the login, not the user: a session started
+	// before the user enrolled has not passed a second factor
+	return u.issue(user, t.FamilyID, t.MFA && user.TOTPEnabled)
+}
+
+// revokeReused answers a refresh token presented after it was used. Either the
+// user or a thief holds a copy, so the family is revoked and so are the user's
+// access tokens, leaving both to log in again.
+func (u *userUsecase) revokeReused(t *Domain.RefreshToken) error {
+	if err := u.tokens.RevokeFamily(t.FamilyID, time.Now().UTC()); err != nil {
+		return err
+	}
+	if err := u.userRepo.BumpTokenVersion(t.UserID); err != nil {
+		return err
+	}
+	return Domain.ErrRefreshTokenReused
+}
+
+func (u *userUsecase) Logout(refreshToken string, everywhere bool) error {
+	t, err := u.tokens.FindByHash(Infrastructure.HashOpaqueToken(refreshToken))
+	if err != nil {
+		return Domain.ErrInvalidRefreshToken
+	}
+	if everywhere {
+		return u.revokeSessions(t.UserID)
+	}
+	return u.tokens.RevokeFamily(t.FamilyID, time.Now().UTC())
+}
+
+// revokeSessions ends every session of the user, refresh and access tokens alike
+func (u *userUsecase) revokeSessions(userID string) error {
+	if err := u.tokens.RevokeUser(userID, time.Now().UTC()); err != nil {
+		return err
+	}
+	return u.userRepo.BumpTokenVersion(userID)
+}
+
+// issue signs an access token for user and adds a refresh token to family;
+// mfa tells whether the family's login passed a second factor
+func (u *userUsecase) issue(user *Domain.User, family primitive.ObjectID, mfa bool) (*Domain.TokenPair, error) {
+	access, err := u.jwt.Generate(Infrastructure.TokenClaims{
+		UserID:         user.ID.Hex(),
+		Username:       user.Username,
+		Role:           user.Role,
+		Department:     user.Department,
+		DepartmentHead: user.DepartmentHead,
+		TokenVersion:   user.TokenVersion,
+		MFA:            mfa,
+	}, AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, hash, err := Infrastructure.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if err := u.tokens.Create(&Domain.RefreshToken{
+		FamilyID:  family,
+		UserID:    user.ID.Hex(),
+		Hash:      hash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(RefreshTokenTTL),
+		MFA:       mfa,
+	}); err != nil {
+		return nil, err
+	}
+	return &Domain.TokenPair{
+		AccessToken:      access,
+		ExpiresIn:        int(AccessTokenTTL / time.Second),
+		RefreshToken:     refresh,
+		RefreshExpiresIn: int(RefreshTokenTTL / time.Second),
+	}, nil
+}
+
+func (u *userUsecase) ListUsers(q Domain.UserQuery) (*Domain.UserPage, error) {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.Limit < 1 {
+		q.Limit = defaultUserPageSize
+	}
+	if q.Limit > maxUserPageSize {
+		q.Limit = maxUserPageSize
+	}
+	q.Search = strings.TrimSpace(q.Search)
+
+	users, total, err := u.userRepo.List(q)
+	if err != nil {
+		return nil, err
+	}
+	return &Domain.UserPage{Users: users, Total: total, Page: q.Page, Limit: q.Limit}, nil
+}
+
+func (u *userUsecase) GetUser(id string) (*Domain.User, error) {
+	return u.userRepo.FindByID(id)
+}
+
+func (u *userUsecase) SetRole(id, role string, actor Domain.Actor) error {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if !Domain.ValidRole(role) {
+		return errors.New("role must be one of staff, finance, admin")
+	}
+	if err := u.userRepo.UpdateRole(id, role); err != nil {
+		return err
+	}
+	// access tokens carry the old role; a refresh picks up the new one
+	return u.userRepo.BumpTokenVersion(id)
+}
+
+// SetDepartment assigns a department and whether the user heads it
+func (u *userUsecase) SetDepartment(id, department string, head bool, actor Domain.Actor) error {
+	department = normalizeDepartment(department)
+	if head && department == "" {
+		return errors.New("department required for a department head")
+	}
+	if err := u.userRepo.UpdateDepartment(id, department, head); err != nil {
+		return err
+	}
+	return u.userRepo.BumpTokenVersion(id)
+}
+
+func (u *userUsecase) Deactivate(id string, actor Domain.Actor) error {
+	// an admin locking themselves out would leave nobody to undo it
+	if id == actor.UserID {
+		return errors.New("cannot deactivate your own account")
+	}
+	if err := u.userRepo.SetDeactivated(id, true); err != nil {
+		return err
+	}
+	return u.revokeSessions(id)
+}
+
+func (u *userUsecase) Reactivate(id string, actor Domain.Actor) error {
+	return u.userRepo.SetDeactivated(id, false)
+}
+
+func (u *userUsecase) ResetPassword(id, password string, actor Domain.Actor) error {
+	if password == "" {
+		return errors.New("password required")
+	}
+	user, err := u.userRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := u.checkPasswordPolicy(user.Username, password); err != nil {
+		return err
+	}
+	hash, err := u.pw.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err := u.userRepo.UpdatePassword(id, hash); err != nil {
+		return err
+	}
+	// whoever knew the old password may still hold a session
+	return u.revokeSessions(id)
+}
+
+func (u *userUsecase) SetupTOTP(userID string) (*Domain.TOTPSetup, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	secret, err := Infrastructure.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.userRepo.SetTOTP(userID, secret, false, nil); err != nil {
+		return nil, err
+	}
+	return &Domain.TOTPSetup{Secret: secret, URI: Infrastructure.TOTPProvisioningURI(totpIssuer, user.Username, secret)}, nil
+}
+
+// EnableTOTP needs a code from the authenticator, proving it was set up with
+// the secret. Only hashes of the recovery codes are kept, so they are shown
+// this once.
+func (u *userUsecase) EnableTOTP(code string, actor Domain.Actor) ([]string, error) {
+	userID := actor.UserID
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("two-factor authentication has not been set up")
+	}
+	ok, err := u.checkTOTP(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, Domain.ErrInvalidMFACode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = Infrastructure.NewRecoveryCode(); err != nil {
+			return nil, err
+		}
+		if hashes[i], err = u.pw.Hash(Infrastructure.NormalizeRecoveryCode(codes[i])); err != nil {
+			return nil, err
+		}
+	}
+	if err := u.userRepo.SetTOTP(userID, user.TOTPSecret, true, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP needs a current code, so a stolen session alone cannot turn
+// two-factor authentication off
+func (u *userUsecase) DisableTOTP(code string, actor Domain.Actor) error {
+	userID := actor.UserID
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	ok, err := u.checkSecondFactor(user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return Domain.ErrInvalidMFACode
+	}
+	if err := u.userRepo.SetTOTP(userID, "", false, nil); err != nil {
+		return err
+	}
+	// tokens issued while it was on would still pass FinanceOnly
+	return u.userRepo.BumpTokenVersion(userID)
+}
+
+func (u *userUsecase) ResetTOTP(userID string, actor Domain.Actor) error {
+	if _, err := u.userRepo.FindByID(userID); err != nil {
+		return err
+	}
+	if err := u.userRepo.SetTOTP(userID, "", false, nil); err != nil {
+		return err
+	}
+	return u.revokeSessions(userID)
+}
+
+func (u *userUsecase) Unlock(id string, actor Domain.Actor) error {
+	user, err := u.userRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	return u.attempts.Clear(loginThrottleKeys(user.Username, "")[0].key)
+}
+
+func (u *userUsecase) TokenStatus(userID string) (bool, int64, error) {
+	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
-		return "", err
+		return false, 0, err
 	}
-	return token, nil
+	return !user.Deactivated, user.TokenVersion, nil
 }
 
-func (u *userUsecase) Promote(username string) error {
-	return u.userRepo.Promote(username)
+// departments are compared lower-case everywhere
+func normalizeDepartment(department string) string {
+	return strings.ToLower(strings.TrimSpace(department))
 }
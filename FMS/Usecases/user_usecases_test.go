@@ -0,0 +1,615 @@
+package Usecases
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"FMS/Domain"
+	"FMS/Infrastructure"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mock user repo
+type mockUserRepo struct{ store map[string]*Domain.User }
+
+func newMockUserRepo() *mockUserRepo {
+	return &mockUserRepo{store: make(map[string]*Domain.User)}
+}
+func (m *mockUserRepo) Create(u *Domain.User) error {
+	u.ID = primitive.NewObjectID()
+	stored := *u
+	m.store[u.ID.Hex()] = &stored
+	return nil
+}
+func (m *mockUserRepo) FindByUsername(username string) (*Domain.User, error) {
+	for _, u := range m.store {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+func (m *mockUserRepo) FindByID(id string) (*Domain.User, error) {
+	if u, ok := m.store[id]; ok {
+		return u, nil
+	}
+	return nil, errors.New("user not found")
+}
+func (m *mockUserRepo) List(q Domain.UserQuery) ([]Domain.User, int64, error) {
+	res := []Domain.User{}
+	for _, u := range m.store {
+		if strings.Contains(strings.ToLower(u.Username), strings.ToLower(q.Search)) {
+			res = append(res, *u)
+		}
+	}
+	return res, int64(len(res)), nil
+}
+func (m *mockUserRepo) FindActive() ([]Domain.User, error) {
+	res := []Domain.User{}
+	for _, u := range m.store {
+		if !u.Deactivated {
+			res = append(res, *u)
+		}
+	}
+	return res, nil
+}
+func (m *mockUserRepo) Count() (int64, error) { return int64(len(m.store)), nil }
+func (m *mockUserRepo) UpdateRole(id, role string) error {
+	return m.update(id, func(u *Domain.User) { u.Role = role })
+}
+func (m *mockUserRepo) UpdateDepartment(id, department string, head bool) error {
+	return m.update(id, func(u *Domain.User) { u.Department = department; u.DepartmentHead = head })
+}
+func (m *mockUserRepo) SetDeactivated(id string, deactivated bool) error {
+	return m.update(id, func(u *Domain.User) { u.Deactivated = deactivated })
+}
+func (m *mockUserRepo) UpdatePassword(id, passwordHash string) error {
+	return m.update(id, func(u *Domain.User) { u.PasswordHash = passwordHash })
+}
+func (m *mockUserRepo) BumpTokenVersion(id string) error {
+	return m.update(id, func(u *Domain.User) { u.TokenVersion++ })
+}
+func (m *mockUserRepo) SetTOTP(id, secret string, enabled bool, recoveryHashes []string) error {
+	return m.update(id, func(u *Domain.User) {
+		u.TOTPSecret, u.TOTPEnabled, u.RecoveryCodes = secret, enabled, recoveryHashes
+		if secret == "" {
+			u.MFAChallenge = nil
+		}
+	})
+}
+func (m *mockUserRepo) UseTOTPStep(id string, step int64) (bool, error) {
+	u, ok := m.store[id]
+	if !ok || u.TOTPLastStep >= step {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	return true, nil
+}
+func (m *mockUserRepo) UseRecoveryCode(id, hash string) (bool, error) {
+	u, ok := m.store[id]
+	if !ok {
+		return false, nil
+	}
+	for i, h := range u.RecoveryCodes {
+		if h == hash {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i:i], u.RecoveryCodes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockUserRepo) SetMFAChallenge(id string, c *Domain.MFAChallenge) error {
+	return m.update(id, func(u *Domain.User) { u.MFAChallenge = c })
+}
+func (m *mockUserRepo) CountMFAAttempt(id string) error {
+	return m.update(id, func(u *Domain.User) {
+		if u.MFAChallenge != nil {
+			u.MFAChallenge.Attempts++
+		}
+	})
+}
+func (m *mockUserRepo) update(id string, fn func(*Domain.User)) error {
+	u, ok := m.store[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	fn(u)
+	return nil
+}
+
+// plain-text password and static token stand-ins
+type mockPasswordService struct{}
+
+func (mockPasswordService) Hash(password string) (string, error) { return "hash:" + password, nil }
+func (mockPasswordService) Compare(hash, password string) error {
+	if hash != "hash:"+password {
+		return errors.New("mismatch")
+	}
+	return nil
+}
+
+type mockJWTService struct{}
+
+func (mockJWTService) Generate(claims Infrastructure.TokenClaims, ttl time.Duration) (string, error) {
+	token := "token-" + claims.UserID + "-" + claims.Department
+	if claims.MFA {
+		token += "+mfa"
+	}
+	return token, nil
+}
+func (mockJWTService) Validate(tokenStr string) (*jwt.RegisteredClaims, map[string]interface{}, error) {
+	return nil, map[string]interface{}{}, nil
+}
+func (mockJWTService) JWKS() Infrastructure.JWKSet { return Infrastructure.JWKSet{} }
+
+// mock refresh token store
+type mockRefreshTokenRepo struct {
+	store map[primitive.ObjectID]*Domain.RefreshToken
+}
+
+func newMockRefreshTokenRepo() *mockRefreshTokenRepo {
+	return &mockRefreshTokenRepo{store: make(map[primitive.ObjectID]*Domain.RefreshToken)}
+}
+func (m *mockRefreshTokenRepo) Create(t *Domain.RefreshToken) error {
+	t.ID = primitive.NewObjectID()
+	stored := *t
+	m.store[t.ID] = &stored
+	return nil
+}
+func (m *mockRefreshTokenRepo) FindByHash(hash string) (*Domain.RefreshToken, error) {
+	for _, t := range m.store {
+		if t.Hash == hash {
+			cp := *t
+			return &cp, nil
+		}
+	}
+	return nil, errors.New("refresh token not found")
+}
+func (m *mockRefreshTokenRepo) Use(id primitive.ObjectID, at time.Time) (bool, error) {
+	t, ok := m.store[id]
+	if !ok || t.UsedAt != nil || t.RevokedAt != nil {
+		return false, nil
+	}
+	t.UsedAt = &at
+	return true, nil
+}
+func (m *mockRefreshTokenRepo) RevokeFamily(familyID primitive.ObjectID, at time.Time) error {
+	return m.revoke(func(t *Domain.RefreshToken) bool { return t.FamilyID == familyID }, at)
+}
+func (m *mockRefreshTokenRepo) RevokeUser(userID string, at time.Time) error {
+	return m.revoke(func(t *Domain.RefreshToken) bool { return t.UserID == userID }, at)
+}
+func (m *mockRefreshTokenRepo) revoke(match func(*Domain.RefreshToken) bool, at time.Time) error {
+	for _, t := range m.store {
+		if match(t) && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+// in-memory login attempt store
+type mockLoginAttemptRepo struct {
+	store map[string]*Domain.LoginAttempts
+}
+
+func newMockLoginAttemptRepo() *mockLoginAttemptRepo {
+	return &mockLoginAttemptRepo{store: make(map[string]*Domain.LoginAttempts)}
+}
+func (m *mockLoginAttemptRepo) Get(key string) (*Domain.LoginAttempts, error) {
+	if a, ok := m.store[key]; ok {
+		copied := *a
+		return &copied, nil
+	}
+	return &Domain.LoginAttempts{Key: key}, nil
+}
+func (m *mockLoginAttemptRepo) RecordFailure(key string, at time.Time, window time.Duration) (*Domain.LoginAttempts, error) {
+	a, ok := m.store[key]
+	if !ok {
+		a = &Domain.LoginAttempts{Key: key}
+		m.store[key] = a
+	}
+	if a.LastFailure.After(at.Add(-window)) {
+		a.Failures++
+	} else {
+		a.Failures = 1
+	}
+	a.LastFailure = at
+	copied := *a
+	return &copied, nil
+}
+func (m *mockLoginAttemptRepo) Lock(key string, until time.Time) error {
+	if a, ok := m.store[key]; ok {
+		a.LockedUntil = until
+	}
+	return nil
+}
+func (m *mockLoginAttemptRepo) Clear(key string) error {
+	delete(m.store, key)
+	return nil
+}
+
+// mock breached password list
+type mockBreachedPasswords map[string]bool
+
+func (m mockBreachedPasswords) Contains(password string) bool { return m[password] }
+
+// the password and client address used throughout the user tests
+const (
+	testPassword = "long enough"
+	testIP       = "192.0.2.1"
+)
+
+func newTestUserUsecase() (UserUsecase, *mockUserRepo) {
+	repo := newMockUserRepo()
+	return NewUserUsecase(repo, mockPasswordService{}, mockJWTService{}, newMockRefreshTokenRepo(), newMockLoginAttemptRepo(), mockBreachedPasswords{"password123": true}), repo
+}
+
+func TestUserUsecase_FirstUserIsAdminThenStaff(t *testing.T) {
+	uc, _ := newTestUserUsecase()
+	first, _ := uc.Register("alice", testPassword, "")
+	second, _ := uc.Register("bob", testPassword, "")
+	if first.Role != Domain.RoleAdmin || second.Role != Domain.RoleStaff {
+		t.Fatalf("expected admin then staff, got %s and %s", first.Role, second.Role)
+	}
+}
+
+func TestUserUsecase_SetRoleValidatesRoleSet(t *testing.T) {
+	uc, repo := newTestUserUsecase()
+	u, _ := uc.Register("alice", testPassword, "")
+
+	if err := uc.SetRole(u.ID.Hex(), "superuser", adminActor); err == nil {
+		t.Fatalf("expected invalid role to be rejected")
+	}
+	if err := uc.SetRole(u.ID.Hex(), "Finance", adminActor); err != nil {
+		t.Fatalf("set role failed: %v", err)
+	}
+	if repo.store[u.ID.Hex()].Role != Domain.RoleFinance {
+		t.Fatalf("expected finance role")
+	}
+}
+
+func TestUserUsecase_DeactivatedUserCannotLogin(t *testing.T) {
+	uc, _ := newTestUserUsecase()
+	admin, _ := uc.Register("admin", testPassword, "")
+	u, _ := uc.Register("bob", testPassword, "")
+
+	if err := uc.Deactivate(u.ID.Hex(), Domain.Actor{UserID: admin.ID.Hex()}); err != nil {
+		t.Fatalf("deactivate failed: %v", err)
+	}
+	if _, err := uc.Login("bob", testPassword, testIP); !errors.Is(err, Domain.ErrAccountDeactivated) {
+		t.Fatalf("expected deactivated error, got %v", err)
+	}
+	if active, _, _ := uc.TokenStatus(u.ID.Hex()); active {
+		t.Fatalf("expected inactive user")
+	}
+
+	if err := uc.Reactivate(u.ID.Hex(), adminActor); err != nil {
+		t.Fatalf("reactivate failed: %v", err)
+	}
+	if _, err := uc.Login("bob", testPassword, testIP); err != nil {
+		t.Fatalf("login after reactivation failed: %v", err)
+	}
+	if err := uc.Deactivate(admin.ID.Hex(), Domain.Actor{UserID: admin.ID.Hex()}); err == nil {
+		t.Fatalf("admin should not deactivate themselves")
+	}
+}
+
+func TestUserUsecase_ResetPassword(t *testing.T) {
+	uc, _ := newTestUserUsecase()
+	u, _ := uc.Register("bob", "old password 1", "")
+	if err := uc.ResetPassword(u.ID.Hex(), "new password 2", adminActor); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if _, err := uc.Login("bob", "old password 1", testIP); err == nil {
+		t.Fatalf("old password should no longer work")
+	}
+	if _, err := uc.Login("bob", "new password 2", testIP); err != nil {
+		t.Fatalf("new password should work: %v", err)
+	}
+}
+
+func TestUserUsecase_ListUsersClampsPaging(t *testing.T) {
+	uc, _ := newTestUserUsecase()
+	_, _ = uc.Register("alice", testPassword, "")
+	_, _ = uc.Register("bob", testPassword, "")
+	page, err := uc.ListUsers(Domain.UserQuery{Search: "ali", Limit: 1000})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if page.Page != 1 || page.Limit != maxUserPageSize || page.Total != 1 {
+		t.Fatalf("unexpected page %+v", page)
+	}
+}
+
+func TestUserUsecase_DepartmentInRegistrationAndToken(t *testing.T) {
+	uc, _ := newTestUserUsecase()
+	_, _ = uc.Register("admin", testPassword, "")
+
+	if _, err := uc.Register("mallory", testPassword, "Finance"); err == nil {
+		t.Fatalf("self-registration into finance should be rejected")
+	}
+	u, err := uc.Register("bob", testPassword, " Operations ")
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if u.Department != "operations" {
+		t.Fatalf("expected normalized department, got %q", u.Department)
+	}
+	pair, _ := uc.Login("bob", testPassword, testIP)
+	if !strings.HasSuffix(pair.AccessToken, "-operations") {
+		t.Fatalf("expected department in token claims, got %s", pair.AccessToken)
+	}
+	if err := uc.SetDepartment(u.ID.Hex(), "", true, adminActor); err == nil {
+		t.Fatalf("department head without department should be rejected")
+	}
+}
+
+func TestUserUsecase_RefreshRotatesAndRevokesReusedFamily(t *testing.T) {
+	uc, repo := newTestUserUsecase()
+	u, _ := uc.Register("bob", testPassword, "")
+
+	first, err := uc.Login("bob", testPassword, testIP)
+	if err != nil || first.RefreshToken == "" || first.ExpiresIn != int(AccessTokenTTL/time.Second) {
+		t.Fatalf("expected a short-lived pair with a refresh token, got %+v (%v)", first, err)
+	}
+	second, err := uc.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh must rotate the refresh token")
+	}
+	if _, version, _ := uc.TokenStatus(u.ID.Hex()); version != 0 {
+		t.Fatalf("a normal refresh must not revoke access tokens")
+	}
+
+	// the first token comes back: someone holds a copy
+	if _, err := uc.Refresh(first.RefreshToken); !errors.Is(err, Domain.ErrRefreshTokenReused) {
+		t.Fatalf("expected reuse to be detected, got %v", err)
+	}
+	if _, err := uc.Refresh(second.RefreshToken); !errors.Is(err, Domain.ErrInvalidRefreshToken) {
+		t.Fatalf("reuse must revoke the rest of the family, got %v", err)
+	}
+	if repo.store[u.ID.Hex()].TokenVersion != 1 {
+		t.Fatalf("reuse must revoke the user's access tokens")
+	}
+
+	// a separate login is a separate family and is not affected
+	other, _ := uc.Login("bob", testPassword, testIP)
+	if _, err := uc.Refresh(other.RefreshToken); err != nil {
+		t.Fatalf("a new login should refresh normally: %v", err)
+	}
+}
+
+func TestUserUsecase_LogoutAndDeactivationEndSessions(t *testing.T) {
+	uc, repo := newTestUserUsecase()
+	admin, _ := uc.Register("admin", testPassword, "")
+	u, _ := uc.Register("bob", testPassword, "")
+
+	phone, _ := uc.Login("bob", testPassword, testIP)
+	laptop, _ := uc.Login("bob", testPassword, testIP)
+	if err := uc.Logout(phone.RefreshToken, false); err != nil {
+		t.Fatalf("logout failed: %v", err)
+	}
+	if _, err := uc.Refresh(phone.RefreshToken); !errors.Is(err, Domain.ErrInvalidRefreshToken) {
+		t.Fatalf("logged out session should not refresh, got %v", err)
+	}
+	if _, err := uc.Refresh(laptop.RefreshToken); err != nil {
+		t.Fatalf("logout must only end its own session: %v", err)
+	}
+
+	session, _ := uc.Login("bob", testPassword, testIP)
+	if err := uc.Deactivate(u.ID.Hex(), Domain.Actor{UserID: admin.ID.Hex()}); err != nil {
+		t.Fatalf("deactivate failed: %v", err)
+	}
+	if _, err := uc.Refresh(session.RefreshToken); err == nil {
+		t.Fatalf("a deactivated user's sessions should be revoked")
+	}
+	if repo.store[u.ID.Hex()].TokenVersion == 0 {
+		t.Fatalf("deactivation must revoke access tokens")
+	}
+}
+
+func TestUserUsecase_TOTPEnrollmentAndTwoStepLogin(t *testing.T) {
+	uc, repo := newTestUserUsecase()
+	u, _ := uc.Register("alice", testPassword, "")
+	id := u.ID.Hex()
+	codeAt := func(offset int64) string {
+		code, err := Infrastructure.TOTPCode(repo.store[id].TOTPSecret, Infrastructure.TOTPStep(time.Now())+offset)
+		if err != nil {
+			t.Fatalf("totp code: %v", err)
+		}
+		return code
+	}
+
+	setup, err := uc.SetupTOTP(id)
+	if err != nil || !strings.HasPrefix(setup.URI, "otpauth://totp/FMS:alice?") {
+		t.Fatalf("setup failed: %+v %v", setup, err)
+	}
+	if _, err := uc.EnableTOTP(codeAt(10), Domain.Actor{UserID: id}); !errors.Is(err, Domain.ErrInvalidMFACode) {
+		t.Fatalf("a wrong code must not enable 2FA, got %v", err)
+	}
+	recovery, err := uc.EnableTOTP(codeAt(0), Domain.Actor{UserID: id})
+	if err != nil || len(recovery) != 10 {
+		t.Fatalf("enable failed: %v %v", recovery, err)
+	}
+	if repo.store[id].RecoveryCodes[0] == recovery[0] {
+		t.Fatalf("recovery codes must be stored hashed")
+	}
+
+	login, _ := uc.Login("alice", testPassword, testIP)
+	if !login.MFARequired || login.TokenPair != nil {
+		t.Fatalf("password alone must not issue tokens: %+v", login)
+	}
+	if _, err := uc.CompleteLogin(login.MFAToken, codeAt(0), testIP); !errors.Is(err, Domain.ErrInvalidMFACode) {
+		t.Fatalf("the enrollment code must not be replayed, got %v", err)
+	}
+	if _, err := uc.CompleteLogin(login.MFAToken, codeAt(1), testIP); err != nil {
+		t.Fatalf("second step failed: %v", err)
+	}
+	if _, err := uc.CompleteLogin(login.MFAToken, codeAt(1), testIP); err == nil {
+		t.Fatalf("a challenge must only be answered once")
+	}
+
+	login, _ = uc.Login("alice", testPassword, testIP)
+	if _, err := uc.CompleteLogin(login.MFAToken, strings.ToUpper(recovery[0]), testIP); err != nil {
+		t.Fatalf("recovery code rejected: %v", err)
+	}
+	login, _ = uc.Login("alice", testPassword, testIP)
+	if _, err := uc.CompleteLogin(login.MFAToken, recovery[0], testIP); !errors.Is(err, Domain.ErrInvalidMFACode) {
+		t.Fatalf("a recovery code must only work once, got %v", err)
+	}
+}
+
+func TestUserUsecase_MFAClaimFollowsTheLogin(t *testing.T) {
+	uc, repo := newTestUserUsecase()
+	u, _ := uc.Register("alice", testPassword, "")
+	id := u.ID.Hex()
+
+	before, _ := uc.Login("alice", testPassword, testIP)
+	_, _ = uc.SetupTOTP(id)
+	code, _ := Infrastructure.TOTPCode(repo.store[id].TOTPSecret, Infrastructure.TOTPStep(time.Now()))
+	recovery, err := uc.EnableTOTP(code, Domain.Actor{UserID: id})
+	if err != nil {
+		t.Fatalf("enable failed: %v", err)
+	}
+	refreshed, err := uc.Refresh(before.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if strings.HasSuffix(refreshed.AccessToken, "+mfa") {
+		t.Fatalf("a session started before enrollment must not gain the mfa claim")
+	}
+
+	login, _ := uc.Login("alice", testPassword, testIP)
+	pair, err := uc.CompleteLogin(login.MFAToken, recovery[0], testIP)
+	if err != nil || !strings.HasSuffix(pair.AccessToken, "+mfa") {
+		t.Fatalf("expected the mfa claim after the second step, got %+v (%v)", pair, err)
+	}
+	if next, err := uc.Refresh(pair.RefreshToken); err != nil || !strings.HasSuffix(next.AccessToken, "+mfa") {
+		t.Fatalf("expected refreshes of a two-step login to keep the mfa claim, got %+v (%v)", next, err)
+	}
+}
+
+func TestUserUsecase_WrongSecondFactorsLockOut(t *testing.T) {
+	repo, attempts := newMockUserRepo(), newMockLoginAttemptRepo()
+	uc := NewUserUsecase(repo, mockPasswordService{}, mockJWTService{}, newMockRefreshTokenRepo(), attempts, nil)
+	u, _ := uc.Register("alice", testPassword, "")
+	id := u.ID.Hex()
+	_, _ = uc.SetupTOTP(id)
+	code, _ := Infrastructure.TOTPCode(repo.store[id].TOTPSecret, Infrastructure.TOTPStep(time.Now()))
+	recovery, err := uc.EnableTOTP(code, Domain.Actor{UserID: id})
+	if err != nil {
+		t.Fatalf("enable failed: %v", err)
+	}
+	// waits out the delay earned by the last failure
+	rewind := func() {
+		for _, a := range attempts.store {
+			a.LastFailure = a.LastFailure.Add(-maxLoginDelay)
+		}
+	}
+	login := func() string {
+		rewind()
+		result, err := uc.Login("alice", testPassword, testIP)
+		if err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+		return result.MFAToken
+	}
+
+	first := login()
+	for i := 0; i < mfaMaxAttempts; i++ {
+		rewind()
+		if _, err := uc.CompleteLogin(first, "000000", testIP); !errors.Is(err, Domain.ErrInvalidMFACode) {
+			t.Fatalf("wrong code %d: expected ErrInvalidMFACode, got %v", i+1, err)
+		}
+	}
+	rewind()
+	if _, err := uc.CompleteLogin(login(), recovery[0], testIP); !errors.Is(err, Domain.ErrInvalidMFACode) {
+		t.Fatalf("logging in again must not reset a spent challenge, got %v", err)
+	}
+
+	// an expired challenge is replaced with fresh tries, but the failures keep counting
+	repo.store[id].MFAChallenge.ExpiresAt = time.Now().Add(-time.Second)
+	next := login()
+	for i := mfaMaxAttempts; i < usernameLockoutAfter; i++ {
+		rewind()
+		uc.CompleteLogin(next, "000000", testIP)
+	}
+	rewind()
+	var throttled *Domain.ThrottleError
+	if _, err := uc.CompleteLogin(next, recovery[0], testIP); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("expected the user to be locked out after %d wrong codes, got %v", usernameLockoutAfter, err)
+	}
+	if _, err := uc.Login("alice", testPassword, "198.51.100.7"); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("expected the password to be refused while locked, got %v", err)
+	}
+}
+
+func TestUserUsecase_LoginThrottlingAndLockout(t *testing.T) {
+	repo, attempts := newMockUserRepo(), newMockLoginAttemptRepo()
+	uc := NewUserUsecase(repo, mockPasswordService{}, mockJWTService{}, newMockRefreshTokenRepo(), attempts, nil)
+	u, _ := uc.Register("bob", testPassword, "")
+	// waits out the delay earned by the last failure
+	rewind := func() {
+		for _, a := range attempts.store {
+			a.LastFailure = a.LastFailure.Add(-maxLoginDelay)
+		}
+	}
+
+	for i := 0; i < freeLoginAttempts; i++ {
+		if _, err := uc.Login("bob", "wrong password", testIP); err == nil || errors.Is(err, Domain.ErrTooManyAttempts) {
+			t.Fatalf("attempt %d should fail as invalid credentials, got %v", i+1, err)
+		}
+	}
+	var throttled *Domain.ThrottleError
+	if _, err := uc.Login("bob", testPassword, testIP); !errors.As(err, &throttled) || throttled.Locked || throttled.RetryAfter <= 0 {
+		t.Fatalf("an attempt right after %d failures should be delayed, got %v", freeLoginAttempts, err)
+	}
+	if _, err := uc.Login("carol", "wrong password", testIP); !errors.Is(err, Domain.ErrTooManyAttempts) {
+		t.Fatalf("failures should also slow down other usernames from the same address, got %v", err)
+	}
+	if _, err := uc.Login("bob", testPassword, "198.51.100.7"); !errors.Is(err, Domain.ErrTooManyAttempts) {
+		t.Fatalf("failures should slow down the username from any address, got %v", err)
+	}
+
+	for i := freeLoginAttempts; i < usernameLockoutAfter; i++ {
+		rewind()
+		uc.Login("bob", "wrong password", testIP)
+	}
+	rewind()
+	if _, err := uc.Login("bob", testPassword, "198.51.100.7"); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("bob should be locked out after %d failures, got %v", usernameLockoutAfter, err)
+	}
+
+	if err := uc.Unlock(u.ID.Hex(), adminActor); err != nil {
+		t.Fatalf("unlock failed: %v", err)
+	}
+	if _, err := uc.Login("bob", testPassword, "198.51.100.7"); err != nil {
+		t.Fatalf("login after unlock failed: %v", err)
+	}
+	if attempts.store["ip:"+testIP] == nil {
+		t.Fatalf("a successful login must not clear the address's failures")
+	}
+}
+
+func TestUserUsecase_PasswordPolicy(t *testing.T) {
+	uc, _ := newTestUserUsecase()
+	for _, pw := range []string{"short", "alice-the-user", "password123", strings.Repeat("x", 73)} {
+		if _, err := uc.Register("alice-the-user", pw, ""); !errors.Is(err, Domain.ErrWeakPassword) {
+			t.Fatalf("password %q should be rejected, got %v", pw, err)
+		}
+	}
+	u, err := uc.Register("alice-the-user", testPassword, "")
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := uc.ResetPassword(u.ID.Hex(), "password123", adminActor); !errors.Is(err, Domain.ErrWeakPassword) {
+		t.Fatalf("a reset should apply the policy too, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// departmentBudgetIDs returns the IDs of every budget belonging to department
+func departmentBudgetIDs(repo Repositories.BudgetRepository, department string) ([]primitive.ObjectID, error) {
+	budgets, err := repo.GetByDepartment(department)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(budgets))
+	for _, b := range budgets {
+		ids = append(ids, b.ID)
+	}
+	return ids, nil
+}
+
+// headsBudget reports whether the actor heads the department of the given budget
+func headsBudget(repo Repositories.BudgetRepository, actor Domain.Actor, budgetID primitive.ObjectID) bool {
+	if !actor.DepartmentHead || budgetID.IsZero() {
+		return false
+	}
+	b, err := repo.GetByID(budgetID.Hex())
+	if err != nil {
+		return false
+	}
+	return actor.HeadOf(b.Department)
+}
@@ -0,0 +1,287 @@
+package Usecases
+
+import (
+	"FMS/Domain"
+	"FMS/Infrastructure"
+	"FMS/Repositories"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// WebhookMaxAttempts is how many times a delivery is tried before it is marked failed
+	WebhookMaxAttempts = 8
+	webhookBaseDelay   = 30 * time.Second
+	webhookMaxDelay    = 6 * time.Hour
+	// a claimed delivery is left alone by other workers for this long
+	webhookLease = 2 * time.Minute
+)
+
+// WebhookUsecase manages webhook subscriptions and the outbox that delivers
+// domain events to them
+type WebhookUsecase interface {
+	// CreateSubscription generates the signing secret; the returned subscription
+	// is the only place it is ever shown
+	CreateSubscription(s *Domain.WebhookSubscription, actor Domain.Actor) (*Domain.WebhookSubscription, error)
+	GetSubscriptions() ([]Domain.WebhookSubscription, error)
+	GetSubscription(id string) (*Domain.WebhookSubscription, error)
+	UpdateSubscription(id string, s *Domain.WebhookSubscription) (*Domain.WebhookSubscription, error)
+	DeleteSubscription(id string) error
+
+	// Publish queues event for every active subscription to it
+	Publish(event string, data interface{})
+	// ProcessDue sends every delivery due at now and reports how many were attempted
+	ProcessDue(now time.Time) int
+
+	GetDeliveries(filter Domain.WebhookDeliveryFilter) ([]Domain.WebhookDelivery, error)
+	GetDelivery(id string) (*Domain.WebhookDelivery, error)
+	RetryDelivery(id string) error
+}
+
+type webhookUsecase struct {
+	subs       Repositories.WebhookSubscriptionRepository
+	deliveries Repositories.WebhookDeliveryRepository
+	client     Infrastructure.WebhookClient
+}
+
+func NewWebhookUsecase(subs Repositories.WebhookSubscriptionRepository, deliveries Repositories.WebhookDeliveryRepository, client Infrastructure.WebhookClient) WebhookUsecase {
+	return &webhookUsecase{subs: subs, deliveries: deliveries, client: client}
+}
+
+// webhookEnvelope is the JSON body posted to subscribers
+type webhookEnvelope struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+func (u *webhookUsecase) CreateSubscription(s *Domain.WebhookSubscription, actor Domain.Actor) (*Domain.WebhookSubscription, error) {
+	if err := validateSubscription(s); err != nil {
+		return nil, err
+	}
+	secret, err := Infrastructure.NewWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	s.ID = primitive.NilObjectID
+	s.Secret = secret
+	s.Active = true
+	s.CreatedBy = actor.UserID
+	s.CreatedAt = time.Now().UTC()
+	if err := u.subs.Create(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (u *webhookUsecase) GetSubscriptions() ([]Domain.WebhookSubscription, error) {
+	return u.subs.GetAll()
+}
+
+func (u *webhookUsecase) GetSubscription(id string) (*Domain.WebhookSubscription, error) {
+	return u.subs.GetByID(id)
+}
+
+func (u *webhookUsecase) UpdateSubscription(id string, s *Domain.WebhookSubscription) (*Domain.WebhookSubscription, error) {
+	existing, err := u.subs.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSubscription(s); err != nil {
+		return nil, err
+	}
+	existing.URL = s.URL
+	existing.Events = s.Events
+	existing.Active = s.Active
+	if err := u.subs.Update(id, existing); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (u *webhookUsecase) DeleteSubscription(id string) error {
+	return u.subs.Delete(id)
+}
+
+func validateSubscription(s *Domain.WebhookSubscription) error {
+	s.URL = strings.TrimSpace(s.URL)
+	parsed, err := url.Parse(s.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	if len(s.Events) == 0 {
+		return errors.New("at least one event is required")
+	}
+	seen := make(map[string]bool, len(s.Events))
+	events := s.Events[:0]
+	for _, e := range s.Events {
+		if !knownEvent(e) {
+			return fmt.Errorf("unknown webhook event %s", e)
+		}
+		if !seen[e] {
+			seen[e] = true
+			events = append(events, e)
+		}
+	}
+	s.Events = events
+	return nil
+}
+
+func knownEvent(event string) bool {
+	for _, e := range Domain.WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish writes one outbox entry per subscriber. Failures are logged, never
+// returned: the change behind the event has already been made.
+func (u *webhookUsecase) Publish(event string, data interface{}) {
+	subs, err := u.subs.GetByEvent(event)
+	if err != nil {
+		log.Printf("webhook %s: %v", event, err)
+		return
+	}
+	if len(subs) == 0 {
+		return
+	}
+	now := time.Now().UTC()
+	for _, s := range subs {
+		id := primitive.NewObjectID()
+		payload, err := json.Marshal(webhookEnvelope{ID: id.Hex(), Event: event, CreatedAt: now, Data: data})
+		if err != nil {
+			log.Printf("webhook %s: %v", event, err)
+			return
+		}
+		d := &Domain.WebhookDelivery{
+			ID:             id,
+			SubscriptionID: s.ID,
+			Event:          event,
+			Payload:        string(payload),
+			Status:         Domain.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		if err := u.deliveries.Create(d); err != nil {
+			log.Printf("webhook %s for subscription %s: %v", event, s.ID.Hex(), err)
+		}
+	}
+}
+
+func (u *webhookUsecase) ProcessDue(now time.Time) int {
+	processed := 0
+	for {
+		d, err := u.deliveries.ClaimDue(now, now.Add(webhookLease))
+		if err != nil {
+			log.Printf("webhook outbox: %v", err)
+			return processed
+		}
+		if d == nil {
+			return processed
+		}
+		processed++
+		u.deliver(d, now)
+	}
+}
+
+// deliver makes one attempt at d and schedules the next one with exponential
+// backoff when it fails
+func (u *webhookUsecase) deliver(d *Domain.WebhookDelivery, now time.Time) {
+	attempt := Domain.WebhookAttempt{At: now}
+	d.Attempts++
+
+	sub, err := u.subs.GetByID(d.SubscriptionID.Hex())
+	switch {
+	case err != nil:
+		attempt.Error = err.Error()
+		d.Attempts = WebhookMaxAttempts
+	case !sub.Active:
+		attempt.Error = "subscription is inactive"
+		d.Attempts = WebhookMaxAttempts
+	default:
+		ts := now.Unix()
+		body := []byte(d.Payload)
+		started := time.Now()
+		err = u.client.Post(sub.URL, body, map[string]string{
+			"X-FMS-Event":     d.Event,
+			"X-FMS-Delivery":  d.ID.Hex(),
+			"X-FMS-Timestamp": strconv.FormatInt(ts, 10),
+			"X-FMS-Signature": Infrastructure.SignWebhook(sub.Secret, ts, body),
+		})
+		attempt.DurationMS = time.Since(started).Milliseconds()
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+	}
+
+	d.LastError = attempt.Error
+	switch {
+	case attempt.Error == "":
+		d.Status = Domain.DeliveryDelivered
+		d.DeliveredAt = now
+	case d.Attempts >= WebhookMaxAttempts:
+		d.Status = Domain.DeliveryFailed
+	default:
+		d.NextAttemptAt = now.Add(webhookBackoff(d.Attempts))
+	}
+	if err := u.deliveries.RecordAttempt(d, attempt); err != nil {
+		log.Printf("webhook delivery %s: %v", d.ID.Hex(), err)
+	}
+}
+
+// webhookBackoff is the wait after the given number of failed attempts:
+// 30s, 1m, 2m, 4m, ... capped at webhookMaxDelay
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookMaxDelay {
+			return webhookMaxDelay
+		}
+	}
+	return delay
+}
+
+func (u *webhookUsecase) GetDeliveries(filter Domain.WebhookDeliveryFilter) ([]Domain.WebhookDelivery, error) {
+	if filter.Status != "" && filter.Status != Domain.DeliveryPending && filter.Status != Domain.DeliveryDelivered && filter.Status != Domain.DeliveryFailed {
+		return nil, fmt.Errorf("unknown delivery status %s", filter.Status)
+	}
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+	return u.deliveries.Find(filter)
+}
+
+func (u *webhookUsecase) GetDelivery(id string) (*Domain.WebhookDelivery, error) {
+	return u.deliveries.GetByID(id)
+}
+
+// RetryDelivery requeues a failed delivery to be sent on the next run
+func (u *webhookUsecase) RetryDelivery(id string) error {
+	return u.deliveries.Retry(id, time.Now().UTC())
+}
+
+// RunWebhookWorker calls ProcessDue every interval until stop is closed
+func RunWebhookWorker(u WebhookUsecase, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			u.ProcessDue(time.Now().UTC())
+		}
+	}
+}
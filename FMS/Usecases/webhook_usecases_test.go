@@ -0,0 +1,323 @@
+package Usecases
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"FMS/Domain"
+	"FMS/Infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type mockWebhookSubRepo struct {
+	store map[string]*Domain.WebhookSubscription
+}
+
+func newMockWebhookSubRepo() *mockWebhookSubRepo {
+	return &mockWebhookSubRepo{store: map[string]*Domain.WebhookSubscription{}}
+}
+func (m *mockWebhookSubRepo) Create(s *Domain.WebhookSubscription) error {
+	s.ID = primitive.NewObjectID()
+	cp := *s
+	m.store[s.ID.Hex()] = &cp
+	return nil
+}
+func (m *mockWebhookSubRepo) GetAll() ([]Domain.WebhookSubscription, error) {
+	res := []Domain.WebhookSubscription{}
+	for _, s := range m.store {
+		res = append(res, *s)
+	}
+	return res, nil
+}
+func (m *mockWebhookSubRepo) GetByID(id string) (*Domain.WebhookSubscription, error) {
+	if s, ok := m.store[id]; ok {
+		cp := *s
+		return &cp, nil
+	}
+	return nil, errors.New("webhook subscription not found")
+}
+func (m *mockWebhookSubRepo) GetByEvent(event string) ([]Domain.WebhookSubscription, error) {
+	res := []Domain.WebhookSubscription{}
+	for _, s := range m.store {
+		if s.Active && s.Subscribes(event) {
+			res = append(res, *s)
+		}
+	}
+	return res, nil
+}
+func (m *mockWebhookSubRepo) Update(id string, s *Domain.WebhookSubscription) error {
+	existing, ok := m.store[id]
+	if !ok {
+		return errors.New("webhook subscription not found")
+	}
+	existing.URL, existing.Events, existing.Active = s.URL, s.Events, s.Active
+	return nil
+}
+func (m *mockWebhookSubRepo) Delete(id string) error {
+	if _, ok := m.store[id]; !ok {
+		return errors.New("webhook subscription not found")
+	}
+	delete(m.store, id)
+	return nil
+}
+
+type mockWebhookDeliveryRepo struct {
+	store map[string]*Domain.WebhookDelivery
+}
+
+func newMockWebhookDeliveryRepo() *mockWebhookDeliveryRepo {
+	return &mockWebhookDeliveryRepo{store: map[string]*Domain.WebhookDelivery{}}
+}
+func (m *mockWebhookDeliveryRepo) Create(d *Domain.WebhookDelivery) error {
+	if d.ID.IsZero() {
+		d.ID = primitive.NewObjectID()
+	}
+	cp := *d
+	m.store[d.ID.Hex()] = &cp
+	return nil
+}
+func (m *mockWebhookDeliveryRepo) ClaimDue(now, leaseUntil time.Time) (*Domain.WebhookDelivery, error) {
+	var due *Domain.WebhookDelivery
+	for _, d := range m.store {
+		if d.Status == Domain.DeliveryPending && !d.NextAttemptAt.After(now) && (due == nil || d.NextAttemptAt.Before(due.NextAttemptAt)) {
+			due = d
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+	due.NextAttemptAt = leaseUntil
+	cp := *due
+	return &cp, nil
+}
+func (m *mockWebhookDeliveryRepo) RecordAttempt(d *Domain.WebhookDelivery, attempt Domain.WebhookAttempt) error {
+	stored := m.store[d.ID.Hex()]
+	stored.Status, stored.Attempts, stored.NextAttemptAt = d.Status, d.Attempts, d.NextAttemptAt
+	stored.LastError, stored.DeliveredAt = d.LastError, d.DeliveredAt
+	stored.Log = append(stored.Log, attempt)
+	return nil
+}
+func (m *mockWebhookDeliveryRepo) GetByID(id string) (*Domain.WebhookDelivery, error) {
+	if d, ok := m.store[id]; ok {
+		cp := *d
+		return &cp, nil
+	}
+	return nil, errors.New("webhook delivery not found")
+}
+func (m *mockWebhookDeliveryRepo) Find(filter Domain.WebhookDeliveryFilter) ([]Domain.WebhookDelivery, error) {
+	res := []Domain.WebhookDelivery{}
+	for _, d := range m.store {
+		if (filter.Status == "" || d.Status == filter.Status) && (filter.Event == "" || d.Event == filter.Event) &&
+			(filter.SubscriptionID == "" || d.SubscriptionID.Hex() == filter.SubscriptionID) {
+			res = append(res, *d)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	return res, nil
+}
+func (m *mockWebhookDeliveryRepo) Retry(id string, at time.Time) error {
+	d, ok := m.store[id]
+	if !ok || d.Status != Domain.DeliveryFailed {
+		return errors.New("only failed deliveries can be retried")
+	}
+	d.Status, d.Attempts, d.NextAttemptAt = Domain.DeliveryPending, 0, at
+	return nil
+}
+
+var adminActor = Domain.Actor{UserID: "admin-user", Role: Domain.RoleAdmin, MFA: true}
+
+// webhookReceiver records the requests an httptest server got, answering with status
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (w *webhookReceiver) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.requests = append(w.requests, r)
+	w.bodies = append(w.bodies, body)
+	rw.WriteHeader(w.status)
+}
+
+func (w *webhookReceiver) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.requests)
+}
+
+func TestWebhook_DisbursementIsSignedAndDelivered(t *testing.T) {
+	receiver := &webhookReceiver{status: http.StatusOK}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	deliveries := newMockWebhookDeliveryRepo()
+	webhooks := NewWebhookUsecase(newMockWebhookSubRepo(), deliveries, Infrastructure.NewWebhookClient(5*time.Second))
+	sub, err := webhooks.CreateSubscription(&Domain.WebhookSubscription{URL: srv.URL, Events: []string{Domain.EventCashRequestDisbursed}}, adminActor)
+	if err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	if len(sub.Secret) != 64 {
+		t.Fatalf("expected a 32-byte hex secret, got %q", sub.Secret)
+	}
+
+	cash := newMockCashRepo()
+	budgets := newMockBudgetRepo()
+	uc := NewPublishedCashRequestUsecase(NewCashRequestUsecase(cash, budgets, newMockLedgerRepo(), newMockApprovalPolicyRepo(), testCurrencies(), newMockExpenseRepo(), newMockFiscalPeriodRepo()), cash, webhooks)
+	b := newApprovedBudget(budgets, 1000)
+	r, _ := uc.CreateCashRequest(&Domain.CashRequest{ID: primitive.NewObjectID(), Title: "Travel", Amount: 400, BudgetID: b.ID}, staffActor("u1"))
+	if _, err := uc.ApproveCashRequest(r.ID.Hex(), financeActor, ""); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if len(deliveries.store) != 0 {
+		t.Fatalf("only subscribed events should be queued, got %d", len(deliveries.store))
+	}
+	if err := uc.DisburseCashRequest(r.ID.Hex(), financeActor); err != nil {
+		t.Fatalf("disburse: %v", err)
+	}
+	if len(deliveries.store) != 1 {
+		t.Fatalf("expected one queued delivery, got %d", len(deliveries.store))
+	}
+
+	if n := webhooks.ProcessDue(time.Now()); n != 1 {
+		t.Fatalf("expected 1 delivery attempted, got %d", n)
+	}
+	if receiver.count() != 1 {
+		t.Fatalf("expected the receiver to be called once, got %d", receiver.count())
+	}
+	req, body := receiver.requests[0], receiver.bodies[0]
+	ts, _ := strconv.ParseInt(req.Header.Get("X-FMS-Timestamp"), 10, 64)
+	if !Infrastructure.VerifyWebhook(sub.Secret, ts, body, req.Header.Get("X-FMS-Signature")) {
+		t.Fatalf("signature %q does not verify", req.Header.Get("X-FMS-Signature"))
+	}
+	if Infrastructure.VerifyWebhook(sub.Secret, ts+1, body, req.Header.Get("X-FMS-Signature")) {
+		t.Fatalf("signature must cover the timestamp")
+	}
+	if req.Header.Get("X-FMS-Event") != Domain.EventCashRequestDisbursed {
+		t.Fatalf("unexpected event header %q", req.Header.Get("X-FMS-Event"))
+	}
+	var envelope struct {
+		ID    string             `json:"id"`
+		Event string             `json:"event"`
+		Data  Domain.CashRequest `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if envelope.ID != req.Header.Get("X-FMS-Delivery") || envelope.Data.ID != r.ID || envelope.Data.Status != Domain.StatusDisbursed {
+		t.Fatalf("unexpected payload %s", body)
+	}
+
+	log, _ := webhooks.GetDeliveries(Domain.WebhookDeliveryFilter{Status: Domain.DeliveryDelivered})
+	if len(log) != 1 || log[0].Attempts != 1 || len(log[0].Log) != 1 || log[0].DeliveredAt.IsZero() {
+		t.Fatalf("unexpected delivery log %+v", log)
+	}
+	if n := webhooks.ProcessDue(time.Now()); n != 0 {
+		t.Fatalf("delivered events must not be sent again, got %d", n)
+	}
+}
+
+func TestWebhook_FailedDeliveriesBackOffThenFail(t *testing.T) {
+	receiver := &webhookReceiver{status: http.StatusInternalServerError}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	deliveries := newMockWebhookDeliveryRepo()
+	webhooks := NewWebhookUsecase(newMockWebhookSubRepo(), deliveries, Infrastructure.NewWebhookClient(5*time.Second))
+	if _, err := webhooks.CreateSubscription(&Domain.WebhookSubscription{URL: srv.URL, Events: []string{Domain.EventExpenseVerified}}, adminActor); err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	webhooks.Publish(Domain.EventExpenseVerified, map[string]string{"id": "e1"})
+
+	now := time.Now()
+	webhooks.ProcessDue(now)
+	var d *Domain.WebhookDelivery
+	for _, stored := range deliveries.store {
+		d = stored
+	}
+	if d.Status != Domain.DeliveryPending || d.Attempts != 1 || d.LastError == "" {
+		t.Fatalf("expected a pending retry after the first failure, got %+v", d)
+	}
+	if got := d.NextAttemptAt.Sub(now); got != 30*time.Second {
+		t.Fatalf("expected the first retry after 30s, got %v", got)
+	}
+	if n := webhooks.ProcessDue(now.Add(29 * time.Second)); n != 0 {
+		t.Fatalf("retry must wait for its backoff, got %d attempts", n)
+	}
+
+	prev := 30 * time.Second
+	for d.Status == Domain.DeliveryPending {
+		now = d.NextAttemptAt
+		webhooks.ProcessDue(now)
+		if d.Status == Domain.DeliveryPending {
+			if got := d.NextAttemptAt.Sub(now); got != 2*prev {
+				t.Fatalf("attempt %d: expected backoff %v, got %v", d.Attempts, 2*prev, got)
+			}
+			prev *= 2
+		}
+	}
+	if d.Status != Domain.DeliveryFailed || d.Attempts != WebhookMaxAttempts || len(d.Log) != WebhookMaxAttempts {
+		t.Fatalf("expected failure after %d attempts, got %+v", WebhookMaxAttempts, d)
+	}
+	if receiver.count() != WebhookMaxAttempts {
+		t.Fatalf("expected %d requests, got %d", WebhookMaxAttempts, receiver.count())
+	}
+
+	receiver.mu.Lock()
+	receiver.status = http.StatusNoContent
+	receiver.mu.Unlock()
+	if err := webhooks.RetryDelivery(d.ID.Hex()); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	webhooks.ProcessDue(time.Now())
+	if d.Status != Domain.DeliveryDelivered {
+		t.Fatalf("expected the manual retry to deliver, got %+v", d)
+	}
+	if err := webhooks.RetryDelivery(d.ID.Hex()); err == nil {
+		t.Fatalf("delivered deliveries cannot be retried")
+	}
+}
+
+func TestWebhook_SubscriptionValidation(t *testing.T) {
+	webhooks := NewWebhookUsecase(newMockWebhookSubRepo(), newMockWebhookDeliveryRepo(), nil)
+
+	cases := []Domain.WebhookSubscription{
+		{URL: "ftp://erp.example.com", Events: []string{Domain.EventBudgetApproved}},
+		{URL: "https://erp.example.com/hooks"},
+		{URL: "https://erp.example.com/hooks", Events: []string{"budget.deleted"}},
+	}
+	for _, c := range cases {
+		if _, err := webhooks.CreateSubscription(&c, adminActor); err == nil {
+			t.Fatalf("expected %+v to be rejected", c)
+		}
+	}
+
+	sub, err := webhooks.CreateSubscription(&Domain.WebhookSubscription{URL: "https://erp.example.com/hooks", Events: []string{Domain.EventBudgetApproved, Domain.EventBudgetApproved}}, adminActor)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if len(sub.Events) != 1 || !sub.Active {
+		t.Fatalf("expected one active event, got %+v", sub)
+	}
+
+	sub.Active = false
+	if _, err := webhooks.UpdateSubscription(sub.ID.Hex(), sub); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	webhooks.Publish(Domain.EventBudgetApproved, nil)
+	if log, _ := webhooks.GetDeliveries(Domain.WebhookDeliveryFilter{}); len(log) != 0 {
+		t.Fatalf("inactive subscriptions must not be queued, got %d", len(log))
+	}
+}